@@ -0,0 +1,34 @@
+package teaacme
+
+import (
+	"strings"
+	"sync"
+)
+
+// HTTP-01验证的路径前缀
+const ChallengePathPrefix = "/.well-known/acme-challenge/"
+
+// 正在进行的验证：路径 => 响应内容
+var challenges = sync.Map{}
+
+// 查找验证路径对应的响应内容，由监听服务直接输出
+func FindChallengeResponse(path string) (response string, found bool) {
+	if !strings.HasPrefix(path, ChallengePathPrefix) {
+		return "", false
+	}
+	value, found := challenges.Load(path)
+	if !found {
+		return "", false
+	}
+	return value.(string), true
+}
+
+// 添加验证
+func addChallenge(path string, response string) {
+	challenges.Store(path, response)
+}
+
+// 删除验证
+func removeChallenge(path string) {
+	challenges.Delete(path)
+}
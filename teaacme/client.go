@@ -0,0 +1,238 @@
+package teaacme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/utils/string"
+	"golang.org/x/crypto/acme"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 申请证书，并将证书和私钥写入到文件中
+func issue(ctx context.Context, config *teaconfigs.ACMEConfig, domains []string, certFile string, keyFile string) (*x509.Certificate, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("no domains to issue certificate")
+	}
+
+	client, err := newClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		err = authorize(ctx, client, authzURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成私钥和CSR
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ders, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(ders) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+	leaf, err := x509.ParseCertificate(ders[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// 先写入私钥再写入证书，读取证书时如果私钥不匹配会失败，下次检查时再加载
+	keyData, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = writeFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}), 0600)
+	if err != nil {
+		return nil, err
+	}
+	certData := []byte{}
+	for _, der := range ders {
+		certData = append(certData, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	err = writeFile(certFile, certData, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return leaf, nil
+}
+
+// 完成一个域名的HTTP-01验证
+func authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return errors.New("no http-01 challenge for '" + authz.Identifier.Value + "'")
+	}
+
+	response, err := client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	path := client.HTTP01ChallengePath(challenge.Token)
+	addChallenge(path, response)
+	defer removeChallenge(path)
+
+	_, err = client.Accept(ctx, challenge)
+	if err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// 创建客户端，并注册账号
+func newClient(ctx context.Context, config *teaconfigs.ACMEConfig) (*acme.Client, error) {
+	key, err := accountKey(config)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{
+		Timeout: 60 * time.Second,
+	}
+	if len(config.CACertificate) > 0 {
+		data, err := ioutil.ReadFile(configPath(config.CACertificate))
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("invalid ca certificate '" + config.CACertificate + "'")
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{
+				RootCAs: pool,
+			},
+		}
+	}
+
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: config.Directory(),
+		HTTPClient:   httpClient,
+		UserAgent:    "TeaWeb",
+	}
+
+	account := &acme.Account{}
+	if len(config.Email) > 0 {
+		account.Contact = []string{"mailto:" + config.Email}
+	}
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+	return client, nil
+}
+
+// 读取账号私钥，不存在时自动生成，每个ACME服务和邮箱使用一个账号
+func accountKey(config *teaconfigs.ACMEConfig) (crypto.Signer, error) {
+	file := configPath("ssl.acme.account." + stringutil.Md5(config.Directory()+"@"+config.Email) + ".key")
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid account key '" + file + "'")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = writeFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}), 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// 读取证书文件中的第一个证书
+func readCertificate(file string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid certificate '" + file + "'")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// 先写入临时文件再改名，避免监听服务读取到写了一半的文件
+func writeFile(file string, data []byte, perm os.FileMode) error {
+	tmpFile := file + ".tmp"
+	err := ioutil.WriteFile(tmpFile, data, perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, file)
+}
+
+// 文件路径，相对路径的文件在配置目录下
+func configPath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return Tea.ConfigFile(file)
+}
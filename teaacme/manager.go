@@ -0,0 +1,264 @@
+package teaacme
+
+import (
+	"context"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/timers"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 证书状态
+type CertificateState = string
+
+const (
+	CertificateStatePending CertificateState = "pending" // 等待申请
+	CertificateStateIssuing CertificateState = "issuing" // 正在申请
+	CertificateStateIssued  CertificateState = "issued"  // 已申请
+	CertificateStateFailed  CertificateState = "failed"  // 申请失败
+)
+
+// 每次申请的超时时间
+const issueTimeout = 5 * time.Minute
+
+// 证书申请状态
+type Status struct {
+	State     CertificateState
+	Domains   []string
+	NotBefore time.Time
+	NotAfter  time.Time
+	Error     string
+	UpdatedAt time.Time
+}
+
+// 转换为Map，用于在界面上显示
+func (this *Status) AsMap() maps.Map {
+	m := maps.Map{
+		"state":     this.State,
+		"domains":   this.Domains,
+		"error":     this.Error,
+		"notBefore": "",
+		"notAfter":  "",
+		"updatedAt": "",
+	}
+	if !this.NotBefore.IsZero() {
+		m["notBefore"] = this.NotBefore.Format("2006-01-02 15:04:05")
+		m["notAfter"] = this.NotAfter.Format("2006-01-02 15:04:05")
+	}
+	if !this.UpdatedAt.IsZero() {
+		m["updatedAt"] = this.UpdatedAt.Format("2006-01-02 15:04:05")
+	}
+	return m
+}
+
+// 证书管理器，定时检查证书是否需要申请或更新
+type Manager struct {
+	locker   sync.Mutex
+	servers  map[string]*teaconfigs.ServerConfig // server id => server
+	statuses map[string]*Status                  // server id => status
+	issuing  map[string]bool                     // server id => true
+	looper   *timers.Looper
+	onIssued func() // 有新证书时的回调
+}
+
+// 共享的管理器
+var SharedManager = NewManager()
+
+// 获取新对象
+func NewManager() *Manager {
+	return &Manager{
+		servers:  map[string]*teaconfigs.ServerConfig{},
+		statuses: map[string]*Status{},
+		issuing:  map[string]bool{},
+	}
+}
+
+// 启动，onIssued在证书申请成功后调用，用来重新加载证书
+func (this *Manager) Start(servers []*teaconfigs.ServerConfig, onIssued func()) {
+	this.locker.Lock()
+	this.servers = map[string]*teaconfigs.ServerConfig{}
+	for _, server := range servers {
+		if server.SSL != nil && server.SSL.On && server.SSL.IsACME() {
+			this.servers[server.Id] = server
+		}
+	}
+	this.onIssued = onIssued
+	if this.looper == nil && len(this.servers) > 0 {
+		this.looper = timers.Loop(1*time.Hour, func(looper *timers.Looper) {
+			this.check()
+		})
+	}
+	this.locker.Unlock()
+
+	go this.check()
+}
+
+// 停止
+func (this *Manager) Stop() {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.looper != nil {
+		this.looper.Stop()
+		this.looper = nil
+	}
+	this.servers = map[string]*teaconfigs.ServerConfig{}
+}
+
+// 立即申请某个服务的证书
+func (this *Manager) Renew(serverId string) error {
+	this.locker.Lock()
+	server, found := this.servers[serverId]
+	this.locker.Unlock()
+	if !found {
+		return errors.New("acme is not enabled for server '" + serverId + "'")
+	}
+	go this.issue(server)
+	return nil
+}
+
+// 查找某个服务的证书状态
+func (this *Manager) FindStatus(serverId string) *Status {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	status, found := this.statuses[serverId]
+	if !found {
+		return nil
+	}
+	s := *status
+	return &s
+}
+
+// 检查所有服务的证书
+func (this *Manager) check() {
+	this.locker.Lock()
+	servers := []*teaconfigs.ServerConfig{}
+	for _, server := range this.servers {
+		servers = append(servers, server)
+	}
+	this.locker.Unlock()
+
+	for _, server := range servers {
+		if this.shouldIssue(server) {
+			this.issue(server)
+		}
+	}
+}
+
+// 判断是否需要申请证书：证书不存在、即将过期或者域名有变化
+func (this *Manager) shouldIssue(server *teaconfigs.ServerConfig) bool {
+	domains := server.CertificateNames()
+	cert, err := readCertificate(configPath(server.SSL.Certificate))
+	if err != nil {
+		this.updateStatus(server.Id, func(status *Status) {
+			status.State = CertificateStatePending
+			status.Domains = domains
+		})
+		return true
+	}
+
+	this.updateStatus(server.Id, func(status *Status) {
+		if status.State != CertificateStateFailed {
+			status.State = CertificateStateIssued
+		}
+		status.Domains = cert.DNSNames
+		status.NotBefore = cert.NotBefore
+		status.NotAfter = cert.NotAfter
+	})
+
+	if time.Now().Add(server.SSL.ACME.RenewBeforeDuration()).After(cert.NotAfter) {
+		return true
+	}
+	return !sameDomains(domains, cert.DNSNames)
+}
+
+// 申请证书
+func (this *Manager) issue(server *teaconfigs.ServerConfig) {
+	this.locker.Lock()
+	if this.issuing[server.Id] {
+		this.locker.Unlock()
+		return
+	}
+	this.issuing[server.Id] = true
+	this.locker.Unlock()
+
+	defer func() {
+		this.locker.Lock()
+		delete(this.issuing, server.Id)
+		this.locker.Unlock()
+	}()
+
+	domains := server.CertificateNames()
+	this.updateStatus(server.Id, func(status *Status) {
+		status.State = CertificateStateIssuing
+		status.Error = ""
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), issueTimeout)
+	defer cancel()
+
+	logs.Println("[acme]issue certificate for", strings.Join(domains, ", "))
+	cert, err := issue(ctx, server.SSL.ACME, domains, configPath(server.SSL.Certificate), configPath(server.SSL.CertificateKey))
+	if err != nil {
+		logs.Error(errors.New("[acme]issue certificate for server '" + server.Id + "' failed: " + err.Error()))
+		this.updateStatus(server.Id, func(status *Status) {
+			status.State = CertificateStateFailed
+			status.Error = err.Error()
+		})
+		return
+	}
+
+	this.updateStatus(server.Id, func(status *Status) {
+		status.State = CertificateStateIssued
+		status.Domains = cert.DNSNames
+		status.NotBefore = cert.NotBefore
+		status.NotAfter = cert.NotAfter
+		status.Error = ""
+	})
+
+	this.locker.Lock()
+	onIssued := this.onIssued
+	this.locker.Unlock()
+	if onIssued != nil {
+		onIssued()
+	}
+}
+
+// 修改状态
+func (this *Manager) updateStatus(serverId string, f func(status *Status)) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	status, found := this.statuses[serverId]
+	if !found {
+		status = &Status{
+			State: CertificateStatePending,
+		}
+		this.statuses[serverId] = status
+	}
+	f(status)
+	status.UpdatedAt = time.Now()
+}
+
+// 判断两组域名是否相同
+func sameDomains(domains1 []string, domains2 []string) bool {
+	if len(domains1) != len(domains2) {
+		return false
+	}
+	s1 := append([]string{}, domains1...)
+	s2 := append([]string{}, domains2...)
+	sort.Strings(s1)
+	sort.Strings(s2)
+	for index, domain := range s1 {
+		if !strings.EqualFold(domain, s2[index]) {
+			return false
+		}
+	}
+	return true
+}
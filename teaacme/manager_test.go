@@ -0,0 +1,104 @@
+package teaacme
+
+import (
+	"context"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindChallengeResponse(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	addChallenge(ChallengePathPrefix+"abc", "abc.def")
+	response, found := FindChallengeResponse(ChallengePathPrefix + "abc")
+	a.IsTrue(found)
+	a.IsTrue(response == "abc.def")
+
+	_, found = FindChallengeResponse("/abc")
+	a.IsFalse(found)
+
+	removeChallenge(ChallengePathPrefix + "abc")
+	_, found = FindChallengeResponse(ChallengePathPrefix + "abc")
+	a.IsFalse(found)
+}
+
+func TestSameDomains(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsTrue(sameDomains([]string{"a.com", "b.com"}, []string{"b.com", "A.com"}))
+	a.IsFalse(sameDomains([]string{"a.com", "b.com"}, []string{"a.com"}))
+	a.IsFalse(sameDomains([]string{"a.com", "b.com"}, []string{"a.com", "c.com"}))
+}
+
+func TestManager_Status(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	manager := NewManager()
+	a.IsTrue(manager.FindStatus("abc") == nil)
+	a.IsNotNil(manager.Renew("abc"))
+
+	manager.updateStatus("abc", func(status *Status) {
+		status.State = CertificateStateFailed
+		status.Error = "test"
+	})
+	status := manager.FindStatus("abc")
+	a.IsTrue(status != nil)
+	a.IsTrue(status.State == CertificateStateFailed)
+	a.IsTrue(status.AsMap()["error"] == "test")
+}
+
+// 使用pebble测试：
+// pebble -config test/config/pebble-config.json（httpPort设置为5002）
+// TEA_ACME_DIRECTORY=https://localhost:14000/dir TEA_ACME_CA=/path/to/pebble.minica.pem go test -run TestIssue
+func TestIssue(t *testing.T) {
+	directory := os.Getenv("TEA_ACME_DIRECTORY")
+	if len(directory) == 0 {
+		t.Skip("TEA_ACME_DIRECTORY is not set")
+	}
+
+	// 模拟监听服务输出验证内容
+	server := &http.Server{
+		Addr: ":5002",
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			response, found := FindChallengeResponse(req.URL.Path)
+			if !found {
+				http.NotFound(writer, req)
+				return
+			}
+			writer.Write([]byte(response))
+		}),
+	}
+	go server.ListenAndServe()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "teaacme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := teaconfigs.NewACMEConfig()
+	config.DirectoryURL = directory
+	config.CACertificate = os.Getenv("TEA_ACME_CA")
+	config.Email = "test@example.com"
+
+	certFile := filepath.Join(dir, "test.pem")
+	keyFile := filepath.Join(dir, "test.key")
+	cert, err := issue(context.Background(), config, []string{"localhost"}, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(cert.DNSNames, cert.NotBefore, cert.NotAfter)
+
+	cert2, err := readCertificate(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert2.Equal(cert) {
+		t.Fatal("certificate not equal")
+	}
+}
@@ -0,0 +1,140 @@
+package teacache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache-Control指令
+type cacheControl struct {
+	noStore              bool
+	noCache              bool
+	private              bool
+	maxAge               int64 // -1 表示没有设置
+	sMaxAge              int64 // -1 表示没有设置
+	staleWhileRevalidate int64 // -1 表示没有设置
+	staleIfError         int64 // -1 表示没有设置
+}
+
+// 分析Cache-Control，支持多个Header
+func parseCacheControl(values []string) *cacheControl {
+	cc := &cacheControl{
+		maxAge:               -1,
+		sMaxAge:              -1,
+		staleWhileRevalidate: -1,
+		staleIfError:         -1,
+	}
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if len(directive) == 0 {
+				continue
+			}
+			name := directive
+			arg := ""
+			index := strings.Index(directive, "=")
+			if index >= 0 {
+				name = strings.TrimSpace(directive[:index])
+				arg = strings.Trim(strings.TrimSpace(directive[index+1:]), "\"")
+			}
+			switch strings.ToLower(name) {
+			case "no-store":
+				cc.noStore = true
+			case "no-cache":
+				cc.noCache = true
+			case "private":
+				cc.private = true
+			case "max-age":
+				cc.maxAge = parseDeltaSeconds(arg)
+			case "s-maxage":
+				cc.sMaxAge = parseDeltaSeconds(arg)
+			case "stale-while-revalidate":
+				cc.staleWhileRevalidate = parseDeltaSeconds(arg)
+			case "stale-if-error":
+				cc.staleIfError = parseDeltaSeconds(arg)
+			}
+		}
+	}
+	return cc
+}
+
+// 分析秒数，无法分析时返回-1
+func parseDeltaSeconds(s string) int64 {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || i < 0 {
+		return -1
+	}
+	return i
+}
+
+// 计算新鲜期：s-maxage > max-age > Expires - Date，都没有时使用策略中的有效期
+// 已经在上游缓存的时间（Age）会被扣除
+func freshLifetime(header http.Header, cc *cacheControl, defaultLife time.Duration, now time.Time) int64 {
+	var life int64
+	switch {
+	case cc.noCache:
+		return 0
+	case cc.sMaxAge >= 0:
+		life = cc.sMaxAge
+	case cc.maxAge >= 0:
+		life = cc.maxAge
+	case len(header.Get("Expires")) > 0:
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			return 0 // 无效的Expires表示已经过期
+		}
+		date := now
+		if len(header.Get("Date")) > 0 {
+			t, err := http.ParseTime(header.Get("Date"))
+			if err == nil {
+				date = t
+			}
+		}
+		life = int64(expires.Sub(date).Seconds())
+	default:
+		life = int64(defaultLife.Seconds())
+	}
+	return life - responseAge(header)
+}
+
+// 源站或上游缓存返回的Age
+func responseAge(header http.Header) int64 {
+	age := parseDeltaSeconds(header.Get("Age"))
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+// 分析Vary中的Header名称，Accept-Encoding已经体现在缓存Key中，所以会被忽略
+// 如果包含 * 则返回false，表示不能缓存
+func parseVary(header http.Header) (names []string, ok bool) {
+	for _, value := range header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 0 {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			name = http.CanonicalHeaderKey(name)
+			if name == "Accept-Encoding" {
+				continue
+			}
+			found := false
+			for _, n := range names {
+				if n == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, true
+}
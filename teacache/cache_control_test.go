@@ -0,0 +1,122 @@
+package teacache
+
+import (
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/iwind/TeaGo/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		cc := parseCacheControl([]string{"public, max-age=60, s-maxage=\"120\"", "stale-while-revalidate=30, stale-if-error=600"})
+		a.IsFalse(cc.noStore)
+		a.IsTrue(cc.maxAge == 60)
+		a.IsTrue(cc.sMaxAge == 120)
+		a.IsTrue(cc.staleWhileRevalidate == 30)
+		a.IsTrue(cc.staleIfError == 600)
+	}
+
+	{
+		cc := parseCacheControl([]string{"No-Store, private, max-age=abc"})
+		a.IsTrue(cc.noStore)
+		a.IsTrue(cc.private)
+		a.IsTrue(cc.maxAge == -1)
+		a.IsTrue(cc.staleIfError == -1)
+	}
+}
+
+func TestFreshLifetime(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	now := time.Now()
+	life := func(header http.Header) int64 {
+		return freshLifetime(header, parseCacheControl(header["Cache-Control"]), 1*time.Hour, now)
+	}
+
+	a.IsTrue(life(http.Header{}) == 3600)
+	a.IsTrue(life(http.Header{"Cache-Control": {"max-age=60"}}) == 60)
+	a.IsTrue(life(http.Header{"Cache-Control": {"max-age=60, s-maxage=30"}}) == 30)
+	a.IsTrue(life(http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}}) == 40)
+	a.IsTrue(life(http.Header{"Cache-Control": {"no-cache"}}) == 0)
+	a.IsTrue(life(http.Header{"Expires": {"0"}}) == 0)
+	a.IsTrue(life(http.Header{
+		"Date":    {now.UTC().Format(http.TimeFormat)},
+		"Expires": {now.Add(10 * time.Minute).UTC().Format(http.TimeFormat)},
+	}) == 600)
+}
+
+func TestParseVary(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		names, ok := parseVary(http.Header{"Vary": {"Accept-Encoding, accept-language", "Accept-Language, Cookie"}})
+		a.IsTrue(ok)
+		a.IsTrue(len(names) == 2)
+		a.IsTrue(names[0] == "Accept-Language")
+		a.IsTrue(names[1] == "Cookie")
+	}
+
+	{
+		_, ok := parseVary(http.Header{"Vary": {"*"}})
+		a.IsFalse(ok)
+	}
+}
+
+func TestPrepareItem(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	policy := shared.NewCachePolicy()
+	policy.Life = "1h"
+	policy.StaleIfError = "1m"
+	a.IsNil(policy.Validate())
+
+	now := time.Now()
+
+	{
+		item := &Item{}
+		life, ok := prepareItem(item, http.Header{"Cache-Control": {"max-age=60, stale-while-revalidate=30"}}, policy, now)
+		a.IsTrue(ok)
+		a.IsTrue(item.ExpiredAt == now.Unix()+60)
+		a.IsTrue(item.StaleWhileRevalidate == 30)
+		a.IsTrue(item.StaleIfError == 60)
+		a.IsTrue(life == 120*time.Second)
+	}
+
+	{
+		// 有验证器时过期后仍然保留
+		item := &Item{}
+		life, ok := prepareItem(item, http.Header{"Cache-Control": {"max-age=0"}, "Etag": {"\"abc\""}}, policy, now)
+		a.IsTrue(ok)
+		a.IsFalse(item.IsFresh(now.Unix()))
+		a.IsTrue(life == 3600*time.Second)
+	}
+
+	for _, header := range []http.Header{
+		{"Cache-Control": {"no-store"}},
+		{"Cache-Control": {"private, max-age=60"}},
+		{"Set-Cookie": {"a=b"}},
+		{"Vary": {"*"}},
+		{"Cache-Control": {"max-age=0"}},
+	} {
+		_, ok := prepareItem(&Item{}, header, policy, now)
+		a.IsFalse(ok)
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	respHeader := http.Header{
+		"Etag":          {"\"abc\""},
+		"Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+	}
+	a.IsTrue(isNotModified(http.Header{"If-None-Match": {"\"xyz\", W/\"abc\""}}, respHeader))
+	a.IsFalse(isNotModified(http.Header{"If-None-Match": {"\"xyz\""}}, respHeader))
+	a.IsTrue(isNotModified(http.Header{"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, respHeader))
+	a.IsFalse(isNotModified(http.Header{"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:04 GMT"}}, respHeader))
+	a.IsFalse(isNotModified(http.Header{}, respHeader))
+}
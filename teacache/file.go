@@ -9,60 +9,56 @@ import (
 	"github.com/iwind/TeaGo/timers"
 	"github.com/iwind/TeaGo/types"
 	"github.com/iwind/TeaGo/utils/string"
+	"io"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// 缓存文件格式：12位过期时间戳 + 8位Key长度 + Key + 数据
+const (
+	fileTimestampLength = 12
+	fileKeyLengthLength = 8
+	fileHeaderLength    = fileTimestampLength + fileKeyLengthLength
+)
+
 // 文件缓存管理器
+// 在后台加载所有缓存文件的索引，加载完成后读取、统计和清理都只需要查找索引
 type FileManager struct {
-	Capacity float64       // 容量
-	Life     time.Duration // 有效期
+	Capacity float64        // 容量
+	Life     time.Duration  // 有效期
+	Eviction EvictionPolicy // 容量不足时的淘汰策略，默认为LRU
 
 	dir          string
 	writingFiles map[string]bool // file path => true
 	writeLocker  sync.Mutex
+
+	background  bool // 是否启动后台任务
+	index       *cacheIndex
+	indexOnce   sync.Once
+	indexLoaded int32 // 索引是否已经加载完成
+
+	onEvict func(count int) // 淘汰条目时的回调
 }
 
 func NewFileManager() *FileManager {
+	return newFileManager(true)
+}
+
+// 获取新对象，background表示是否启动后台任务：加载索引、定时删除过期的缓存
+func newFileManager(background bool) *FileManager {
 	manager := &FileManager{}
 	manager.writingFiles = map[string]bool{}
+	manager.background = background
+	if !background {
+		return manager
+	}
 
 	// 删除过期
 	timers.Loop(10*time.Minute, func(looper *timers.Looper) {
-		if len(manager.dir) == 0 {
-			return
-		}
-		dirFile := files.NewFile(manager.dir)
-		if !dirFile.IsDir() {
-			return
-		}
-		for _, dirFile1 := range dirFile.List() {
-			if !dirFile1.IsDir() {
-				continue
-			}
-			for _, dirFile2 := range dirFile1.List() {
-				for _, file := range dirFile2.List() {
-					if file.Ext() != ".cache" {
-						continue
-					}
-					reader, err := file.Reader()
-					if err != nil {
-						logs.Error(err)
-						continue
-					}
-					timestamp := types.Int64(string(reader.Read(12)))
-					reader.Close()
-					if timestamp < time.Now().Unix() {
-						err := file.Delete()
-						if err != nil {
-							logs.Error(err)
-						}
-					}
-				}
-
-				time.Sleep(500 * time.Millisecond)
-			}
-		}
+		manager.sweep()
 	})
 
 	return manager
@@ -73,9 +69,21 @@ func (this *FileManager) SetOptions(options map[string]interface{}) {
 	if found {
 		this.dir = types.String(dir)
 	}
+
+	if this.background && len(this.dir) > 0 {
+		go this.loadIndex()
+	}
 }
 
 func (this *FileManager) Write(key string, data []byte) error {
+	return this.WriteWithLife(key, data, this.Life)
+}
+
+// 使用指定的有效期写入
+func (this *FileManager) WriteWithLife(key string, data []byte, duration time.Duration) error {
+	if duration <= 0 {
+		duration = this.Life
+	}
 	if len(this.dir) == 0 {
 		return errors.New("cache dir should not be empty")
 	}
@@ -99,45 +107,318 @@ func (this *FileManager) Write(key string, data []byte) error {
 		return errors.New("file is locking")
 	}
 
-	this.writeLocker.Lock()
-	this.writingFiles[newFile.Path()] = true
-	this.writeLocker.Unlock()
-
 	// 头部加入有效期
-	var life = int64(this.Life.Seconds())
+	var life = int64(duration.Seconds())
 	if life <= 0 {
 		life = 30 * 86400
 	} else if life >= 365*86400 { // 最大值限制
 		life = 365 * 86400
 	}
-	data = append([]byte(fmt.Sprintf("%012d", time.Now().Unix()+life)), data ...)
-	err := newFile.Write(data)
+	expiredAt := time.Now().Unix() + life
+	header := fmt.Sprintf("%012d%08d", expiredAt, len(key)) + key
 
-	// 解除锁定
 	this.writeLocker.Lock()
-	defer this.writeLocker.Unlock()
-	delete(this.writingFiles, newFile.Path())
-	return err
+	this.writingFiles[newFile.Path()] = true
+	this.writeLocker.Unlock()
+
+	// 解除锁定
+	defer func() {
+		this.writeLocker.Lock()
+		delete(this.writingFiles, newFile.Path())
+		this.writeLocker.Unlock()
+	}()
+
+	data = append([]byte(header), data...)
+	err := newFile.Write(data)
+	if err != nil {
+		return err
+	}
+
+	// 写入完成后才加入索引，容量不足时淘汰旧的缓存
+	_, evicted, err := this.getIndex().put(&indexEntry{
+		key:       key,
+		size:      int64(len(data)),
+		expiredAt: expiredAt,
+	}, true)
+	if err != nil {
+		os.Remove(newFile.Path())
+		return err
+	}
+	this.removeFiles(evicted)
+	this.evict(len(evicted))
+	return nil
 }
 
 func (this *FileManager) Read(key string) (data []byte, err error) {
-	md5 := stringutil.Md5(key)
-	file := files.NewFile(this.dir + Tea.DS + md5[:2] + Tea.DS + md5[2:4] + Tea.DS + md5 + ".cache")
+	// 索引加载完成后，不在索引中的缓存不需要再查找文件
+	if this.isIndexLoaded() {
+		entry, expired := this.getIndex().get(key, time.Now().Unix())
+		if expired != nil {
+			this.removeFiles([]*indexEntry{expired})
+		}
+		if entry == nil {
+			return nil, ErrNotFound
+		}
+	}
+
+	file := files.NewFile(this.filePath(key))
 	if this.isLocking(file) {
 		return nil, errors.New("file is locking")
 	}
 	if !file.Exists() {
+		this.getIndex().remove(key)
 		return nil, ErrNotFound
 	}
 	data, err = file.ReadAll()
-	if err != nil || len(data) < 12 { // 12是时间戳长度
+	if err != nil {
 		return nil, err
 	}
-	timestamp := types.Int64(string(data[:12]))
+	if len(data) < fileHeaderLength {
+		return nil, ErrNotFound
+	}
+	timestamp := types.Int64(string(data[:fileTimestampLength]))
 	if timestamp < time.Now().Unix() {
 		return nil, ErrNotFound
 	}
-	return data[12:], nil
+	keyLength := types.Int(string(data[fileTimestampLength:fileHeaderLength]))
+	if keyLength <= 0 || fileHeaderLength+keyLength > len(data) || string(data[fileHeaderLength:fileHeaderLength+keyLength]) != key {
+		return nil, ErrNotFound
+	}
+	return data[fileHeaderLength+keyLength:], nil
+}
+
+// 删除
+func (this *FileManager) Delete(key string) error {
+	this.getIndex().remove(key)
+	err := os.Remove(this.filePath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// 按规则删除
+func (this *FileManager) Purge(pattern string) (count int, err error) {
+	// 没有通配符时直接删除
+	if !hasWildcard(pattern) {
+		this.getIndex().remove(pattern)
+		path := this.filePath(pattern)
+		if _, err := os.Stat(path); err != nil {
+			return 0, nil
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	if this.isIndexLoaded() {
+		removed := this.getIndex().removeIf(func(entry *indexEntry) bool {
+			return MatchKey(pattern, entry.key)
+		})
+		this.removeFiles(removed)
+		return len(removed), nil
+	}
+
+	err = this.walk(func(path string, info os.FileInfo) error {
+		key, _, err := this.readFileHeader(path)
+		if err != nil {
+			return nil
+		}
+		if !MatchKey(pattern, key) {
+			return nil
+		}
+		this.getIndex().remove(key)
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		count++
+		return nil
+	})
+	return
+}
+
+// 清除所有缓存
+func (this *FileManager) Clean() error {
+	this.getIndex().clear()
+	return this.walk(func(path string, info os.FileInfo) error {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// 统计
+func (this *FileManager) Stat() (count int, size int64, err error) {
+	if this.isIndexLoaded() {
+		count, size = this.getIndex().stat()
+		return
+	}
+
+	err = this.walk(func(path string, info os.FileInfo) error {
+		count++
+		size += info.Size()
+		return nil
+	})
+	return
+}
+
+// 获取索引，第一次使用时才创建，以便于使用设置后的容量和淘汰策略
+func (this *FileManager) getIndex() *cacheIndex {
+	this.indexOnce.Do(func() {
+		this.index = newCacheIndex(int64(this.Capacity), this.Eviction)
+	})
+	return this.index
+}
+
+// 索引是否已经加载完成
+func (this *FileManager) isIndexLoaded() bool {
+	return atomic.LoadInt32(&this.indexLoaded) == 1
+}
+
+// 从缓存目录中加载索引，加载过程中写入的缓存优先
+func (this *FileManager) loadIndex() {
+	if !atomic.CompareAndSwapInt32(&this.indexLoaded, 0, 2) {
+		return
+	}
+
+	now := time.Now().Unix()
+	err := this.walk(func(path string, info os.FileInfo) error {
+		key, expiredAt, err := this.readFileHeader(path)
+		if err != nil || expiredAt < now {
+			os.Remove(path)
+			return nil
+		}
+		_, evicted, err := this.getIndex().put(&indexEntry{
+			key:       key,
+			size:      info.Size(),
+			expiredAt: expiredAt,
+		}, false)
+		if err != nil {
+			os.Remove(path)
+			return nil
+		}
+		this.removeFiles(evicted)
+		this.evict(len(evicted))
+		return nil
+	})
+	if err != nil {
+		logs.Error(err)
+		atomic.StoreInt32(&this.indexLoaded, 0)
+		return
+	}
+	atomic.StoreInt32(&this.indexLoaded, 1)
+}
+
+// 删除过期的缓存
+func (this *FileManager) sweep() {
+	if len(this.dir) == 0 {
+		return
+	}
+
+	if this.isIndexLoaded() {
+		removed := this.getIndex().removeExpired(time.Now().Unix())
+		this.removeFiles(removed)
+		return
+	}
+
+	// 索引还没有加载完成时，需要读取每个文件的头部
+	this.walk(func(path string, info os.FileInfo) error {
+		_, expiredAt, err := this.readFileHeader(path)
+		if err != nil || expiredAt >= time.Now().Unix() {
+			return nil
+		}
+		err = os.Remove(path)
+		if err != nil {
+			logs.Error(err)
+		}
+		return nil
+	})
+}
+
+// 删除一组条目对应的文件
+func (this *FileManager) removeFiles(entries []*indexEntry) {
+	for _, entry := range entries {
+		path := this.filePath(entry.key)
+		if this.isLocking(files.NewFile(path)) {
+			continue
+		}
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			logs.Error(err)
+		}
+	}
+}
+
+// 记录因为容量不足而淘汰的条目数，过期删除的不计算在内
+func (this *FileManager) evict(count int) {
+	if count > 0 && this.onEvict != nil {
+		this.onEvict(count)
+	}
+}
+
+// 缓存文件路径
+func (this *FileManager) filePath(key string) string {
+	md5 := stringutil.Md5(key)
+	return this.dir + Tea.DS + md5[:2] + Tea.DS + md5[2:4] + Tea.DS + md5 + ".cache"
+}
+
+// 遍历所有的缓存文件
+func (this *FileManager) walk(f func(path string, info os.FileInfo) error) error {
+	if len(this.dir) == 0 {
+		return errors.New("cache dir should not be empty")
+	}
+	if _, err := os.Stat(this.dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return filepath.Walk(this.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".cache" {
+			return nil
+		}
+		if this.isLocking(files.NewFile(path)) {
+			return nil
+		}
+		return f(path, info)
+	})
+}
+
+// 读取缓存文件头部的Key和过期时间
+func (this *FileManager) readFileHeader(path string) (key string, expiredAt int64, err error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer fp.Close()
+
+	header := make([]byte, fileHeaderLength)
+	_, err = io.ReadFull(fp, header)
+	if err != nil {
+		return "", 0, err
+	}
+	expiredAt = types.Int64(string(header[:fileTimestampLength]))
+	keyLength := types.Int(string(header[fileTimestampLength:]))
+	if keyLength <= 0 {
+		return "", 0, errors.New("invalid cache file")
+	}
+	keyData := make([]byte, keyLength)
+	_, err = io.ReadFull(fp, keyData)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(keyData), expiredAt, nil
 }
 
 func (this *FileManager) isLocking(file *files.File) bool {
@@ -1,9 +1,13 @@
 package teacache
 
 import (
+	"github.com/TeaWeb/code/teaconfigs/shared"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/assert"
 	"github.com/iwind/TeaGo/logs"
+	"math/rand"
+	"os"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -63,3 +67,132 @@ func TestFileManagerConcurrent(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestFileManager_Purge(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	m := NewFileManager()
+	m.dir = Tea.TmpDir() + "/cache"
+	a.IsNil(m.Clean())
+
+	a.IsNil(m.Write("https://example.com/static/a.css", []byte("a")))
+	a.IsNil(m.Write("https://example.com/static/b.css", []byte("bb")))
+	a.IsNil(m.Write("https://example.com/index.html", []byte("ccc")))
+
+	count, _, err := m.Stat()
+	a.IsNil(err)
+	a.IsTrue(count == 3)
+
+	a.IsNil(m.Delete("https://example.com/index.html"))
+	_, err = m.Read("https://example.com/index.html")
+	a.IsTrue(err == ErrNotFound)
+
+	purged, err := m.Purge("https://example.com/static/?.css")
+	a.IsNil(err)
+	a.IsTrue(purged == 2)
+
+	count, _, err = m.Stat()
+	a.IsNil(err)
+	a.IsTrue(count == 0)
+}
+
+func TestFileManager_Index(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	m := newFileManager(false)
+	m.dir = Tea.TmpDir() + "/cache-index"
+	a.IsNil(os.MkdirAll(m.dir, 0777))
+	a.IsNil(m.Clean())
+
+	a.IsNil(m.Write("https://example.com/a", []byte("a")))
+
+	// 加载已有的缓存
+	m2 := newFileManager(false)
+	m2.dir = m.dir
+	m2.Capacity = 100 * indexShardCount
+	m2.loadIndex()
+	a.IsTrue(m2.isIndexLoaded())
+
+	count, _, err := m2.Stat()
+	a.IsNil(err)
+	a.IsTrue(count == 1)
+
+	_, err = m2.Read("https://example.com/a")
+	a.IsNil(err)
+
+	// 不在索引中的缓存不会读取文件
+	_, err = m2.Read("https://example.com/b")
+	a.IsTrue(err == ErrNotFound)
+
+	// 容量不足时淘汰
+	evictions := 0
+	m2.onEvict = func(count int) {
+		evictions += count
+	}
+	for i := 0; i < 200; i++ {
+		a.IsNil(m2.Write("https://example.com/"+strconv.Itoa(i), make([]byte, 20)))
+	}
+	a.IsTrue(evictions > 0)
+
+	_, size, err := m2.Stat()
+	a.IsNil(err)
+	a.IsTrue(size <= int64(m2.Capacity))
+
+	// 过期删除的不计算为淘汰
+	evictions = 0
+	_, _, err = m2.getIndex().put(&indexEntry{key: "https://example.com/expired", size: 1, expiredAt: time.Now().Unix() - 1}, true)
+	a.IsNil(err)
+	m2.sweep()
+	a.IsTrue(evictions == 0)
+
+	// 超出容量时不能留下缓存文件
+	a.IsNotNil(m2.Write("https://example.com/huge", make([]byte, 100*indexShardCount+1)))
+	_, err = os.Stat(m2.filePath("https://example.com/huge"))
+	a.IsTrue(os.IsNotExist(err))
+
+	a.IsNil(m2.Clean())
+}
+
+func TestFileManager_FromConfig(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	dir := Tea.TmpDir() + "/cache-config"
+	a.IsNil(os.MkdirAll(dir, 0777))
+
+	policy := shared.NewCachePolicy()
+	policy.Type = "file"
+	policy.Capacity = "1m"
+	policy.Eviction = EvictionPolicyLFU
+	policy.Options = map[string]interface{}{
+		"dir": dir,
+	}
+
+	// 后台加载索引时需要使用设置后的淘汰策略
+	m, ok := NewManagerFromConfig(policy).(*FileManager)
+	a.IsTrue(ok)
+	a.IsNotNil(m.onEvict)
+	a.IsTrue(m.getIndex().policy == EvictionPolicyLFU)
+}
+
+func BenchmarkFileManager(b *testing.B) {
+	m := newFileManager(false)
+	m.dir = Tea.TmpDir() + "/cache-bench"
+	m.Capacity = 16 << 20
+	os.MkdirAll(m.dir, 0777)
+	m.loadIndex()
+	defer m.Clean()
+
+	data := make([]byte, 1024)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for pb.Next() {
+			key := "https://example.com/" + strconv.Itoa(r.Intn(10000))
+			_, err := m.Read(key)
+			if err != nil {
+				m.Write(key, data)
+			}
+		}
+	})
+}
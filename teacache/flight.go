@@ -0,0 +1,111 @@
+package teacache
+
+import (
+	"github.com/TeaWeb/code/teaproxy"
+	"sync"
+	"time"
+)
+
+// 合并回源：同一个Key同时只有一个请求回源，其他请求等待其完成后再读取缓存
+type flightGroup struct {
+	locker sync.Mutex
+	calls  map[string]*flightCall
+}
+
+// 正在回源的请求
+type flightCall struct {
+	done      chan struct{}
+	doneOnce  sync.Once
+	expiredAt time.Time
+}
+
+// 正在回源的请求和Key
+type flightLeader struct {
+	key  string
+	call *flightCall
+}
+
+var sharedFlightGroup = newFlightGroup()
+var flightLeaders = sync.Map{} // *teaproxy.Request => *flightLeader
+
+// 获取新对象
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		calls: map[string]*flightCall{},
+	}
+}
+
+// 开始回源，leader为true表示当前请求需要回源，否则需要等待返回的call完成
+// 超过timeout仍然没有完成的回源请求会被忽略，以免某个请求出错后其他请求一直等待
+func (this *flightGroup) begin(key string, timeout time.Duration) (call *flightCall, leader bool) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	now := time.Now()
+	call, found := this.calls[key]
+	if found && now.Before(call.expiredAt) {
+		return call, false
+	}
+
+	call = &flightCall{
+		done:      make(chan struct{}),
+		expiredAt: now.Add(timeout),
+	}
+	this.calls[key] = call
+	return call, true
+}
+
+// 结束回源，唤醒所有等待的请求
+func (this *flightGroup) end(key string, call *flightCall) {
+	this.locker.Lock()
+	if this.calls[key] == call {
+		delete(this.calls, key)
+	}
+	this.locker.Unlock()
+
+	call.doneOnce.Do(func() {
+		close(call.done)
+	})
+}
+
+// 等待回源完成，返回false表示超时
+func (this *flightCall) wait() bool {
+	timeout := time.Until(this.expiredAt)
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-this.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// 记录当前请求为回源请求，请求结束时调用endFlight
+func beginFlight(req *teaproxy.Request, key string, call *flightCall) {
+	flightLeaders.Store(req, &flightLeader{
+		key:  key,
+		call: call,
+	})
+}
+
+// 当前请求是否为正在回源的请求
+func isFlightLeader(req *teaproxy.Request) bool {
+	_, found := flightLeaders.Load(req)
+	return found
+}
+
+// 如果当前请求为回源请求，则结束回源
+func endFlight(req *teaproxy.Request) {
+	v, found := flightLeaders.Load(req)
+	if !found {
+		return
+	}
+	flightLeaders.Delete(req)
+	leader := v.(*flightLeader)
+	sharedFlightGroup.end(leader.key, leader.call)
+}
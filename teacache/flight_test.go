@@ -0,0 +1,136 @@
+package teacache
+
+import (
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/TeaWeb/code/teaproxy"
+	"github.com/iwind/TeaGo/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	group := newFlightGroup()
+	call, leader := group.begin("a", 1*time.Second)
+	a.IsTrue(leader)
+
+	call2, leader := group.begin("a", 1*time.Second)
+	a.IsFalse(leader)
+	a.IsTrue(call == call2)
+
+	// 不同的Key
+	_, leader = group.begin("b", 1*time.Second)
+	a.IsTrue(leader)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		group.end("a", call)
+	}()
+	a.IsTrue(call2.wait())
+
+	// 结束后重新开始
+	_, leader = group.begin("a", 1*time.Second)
+	a.IsTrue(leader)
+
+	// 重复结束
+	group.end("a", call)
+}
+
+func TestFlightGroup_Timeout(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	group := newFlightGroup()
+	_, leader := group.begin("a", 100*time.Millisecond)
+	a.IsTrue(leader)
+
+	call, leader := group.begin("a", 100*time.Millisecond)
+	a.IsFalse(leader)
+
+	before := time.Now()
+	a.IsFalse(call.wait())
+	a.IsTrue(time.Since(before) < 1*time.Second)
+
+	// 超时后的请求可以重新回源
+	_, leader = group.begin("a", 100*time.Millisecond)
+	a.IsTrue(leader)
+}
+
+func TestFlightGroup_Concurrent(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	group := newFlightGroup()
+	cache := NewMemoryManager()
+	fetches := int32(0)
+	hits := int32(0)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := cache.Read("/hello")
+			if err == nil {
+				atomic.AddInt32(&hits, 1)
+				return
+			}
+
+			call, leader := group.begin("/hello", 5*time.Second)
+			if leader {
+				// 模拟回源
+				atomic.AddInt32(&fetches, 1)
+				time.Sleep(200 * time.Millisecond)
+				cache.Write("/hello", []byte("Hello"))
+				group.end("/hello", call)
+				return
+			}
+
+			if !call.wait() {
+				t.Error("wait timeout")
+				return
+			}
+			data, err := cache.Read("/hello")
+			if err != nil || string(data) != "Hello" {
+				t.Error("should read from cache")
+				return
+			}
+			atomic.AddInt32(&hits, 1)
+		}()
+	}
+	wg.Wait()
+
+	a.IsTrue(fetches == 1)
+	a.IsTrue(hits == 99)
+}
+
+func TestProcessBeforeRequest_FlightLeader(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	policy := shared.NewCachePolicy()
+	policy.Filename = "cache.policy.flight.conf"
+	policy.On = true
+	policy.Type = "memory"
+	policy.Key = "${requestURI}"
+	policy.Coalescing = true
+	policy.CoalescingTimeout = "3s"
+	a.IsNil(policy.Validate())
+
+	req := teaproxy.NewRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	req.SetCachePolicy(policy)
+	writer := teaproxy.NewResponseWriter(httptest.NewRecorder())
+
+	// 跳转到默认文件时同一个请求会再次经过缓存处理，不能等待自己回源
+	before := time.Now()
+	a.IsTrue(ProcessBeforeRequest(req, writer))
+	a.IsTrue(isFlightLeader(req))
+	a.IsTrue(ProcessBeforeRequest(req, writer))
+	a.IsTrue(time.Since(before) < 1*time.Second)
+
+	endFlight(req)
+	a.IsFalse(isFlightLeader(req))
+}
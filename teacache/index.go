@@ -0,0 +1,391 @@
+package teacache
+
+import (
+	"container/heap"
+	"container/list"
+	"errors"
+	"github.com/iwind/TeaGo/maps"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// 淘汰策略
+type EvictionPolicy = string
+
+const (
+	EvictionPolicyLRU EvictionPolicy = "lru" // 最近最少使用
+	EvictionPolicyLFU EvictionPolicy = "lfu" // 最不经常使用
+)
+
+// 所有的淘汰策略
+func AllEvictionPolicies() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "LRU",
+			"code":        EvictionPolicyLRU,
+			"description": "容量不足时优先淘汰最久没有被访问的缓存",
+		},
+		{
+			"name":        "LFU",
+			"code":        EvictionPolicyLFU,
+			"description": "容量不足时优先淘汰访问次数最少的缓存",
+		},
+	}
+}
+
+// 索引分片数量，每个分片有自己的锁，所有分片共用同一个容量
+const indexShardCount = 16
+
+var errItemTooLarge = errors.New("cache item is too large")
+
+// 索引条目，除了淘汰相关的字段外，创建后不再修改
+type indexEntry struct {
+	key       string
+	size      int64
+	expiredAt int64  // 0 表示不过期
+	data      []byte // 只有内存缓存才会保存数据
+
+	element   *list.Element // LRU中的位置
+	heapIndex int           // LFU中的位置
+	hits      int64         // 访问次数
+	accessed  int64         // 最后访问的序号，所有分片共用一个序列
+}
+
+// 判断是否过期
+func (this *indexEntry) isExpired(now int64) bool {
+	return this.expiredAt > 0 && this.expiredAt < now
+}
+
+// 淘汰队列
+type evictionQueue interface {
+	push(entry *indexEntry)
+	touch(entry *indexEntry)
+	remove(entry *indexEntry)
+	victim() *indexEntry
+}
+
+// 缓存索引，按Key分片
+type cacheIndex struct {
+	shards   []*indexShard
+	policy   EvictionPolicy
+	capacity int64 // 所有分片的总容量，0 表示不限制
+	size     int64 // 所有分片的总尺寸，包括正在加入的条目，使用atomic读写
+	sequence int64 // 访问序号，使用atomic读写
+}
+
+// 索引分片
+type indexShard struct {
+	locker  sync.Mutex
+	entries map[string]*indexEntry
+	queue   evictionQueue
+	size    int64
+	total   *int64 // 所有分片的总尺寸
+}
+
+// 获取新对象，capacity为所有分片的总容量
+func newCacheIndex(capacity int64, policy EvictionPolicy) *cacheIndex {
+	index := &cacheIndex{
+		policy:   policy,
+		capacity: capacity,
+	}
+	for i := 0; i < indexShardCount; i++ {
+		shard := &indexShard{
+			entries: map[string]*indexEntry{},
+			total:   &index.size,
+		}
+		if policy == EvictionPolicyLFU {
+			shard.queue = &lfuQueue{}
+		} else {
+			shard.queue = newLRUQueue()
+		}
+		index.shards = append(index.shards, shard)
+	}
+	return index
+}
+
+// 查找Key所在的分片
+func (this *cacheIndex) shard(key string) *indexShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return this.shards[h.Sum32()%indexShardCount]
+}
+
+// 读取条目，并记录访问；过期的条目会被删除
+func (this *cacheIndex) get(key string, now int64) (entry *indexEntry, expired *indexEntry) {
+	shard := this.shard(key)
+	shard.locker.Lock()
+	defer shard.locker.Unlock()
+
+	entry, found := shard.entries[key]
+	if !found {
+		return nil, nil
+	}
+	if entry.isExpired(now) {
+		shard.remove(entry)
+		return nil, entry
+	}
+	entry.hits++
+	entry.accessed = atomic.AddInt64(&this.sequence, 1)
+	shard.queue.touch(entry)
+	return entry, nil
+}
+
+// 加入条目，如果容量不足则从所有分片中淘汰旧的条目
+// replace为false时，如果已经存在同样Key的条目则不加入
+// 返回被替换和被淘汰的条目
+func (this *cacheIndex) put(entry *indexEntry, replace bool) (replaced *indexEntry, evicted []*indexEntry, err error) {
+	if this.capacity > 0 && entry.size > this.capacity {
+		return nil, nil, errItemTooLarge
+	}
+
+	// 先删除旧的条目，以便于腾出空间
+	shard := this.shard(entry.key)
+	shard.locker.Lock()
+	oldEntry, found := shard.entries[entry.key]
+	if found {
+		if !replace {
+			shard.locker.Unlock()
+			return nil, nil, nil
+		}
+		shard.remove(oldEntry)
+		replaced = oldEntry
+	}
+	shard.locker.Unlock()
+
+	// 预留空间，超出容量时淘汰旧的条目
+	size := atomic.AddInt64(&this.size, entry.size)
+	if this.capacity > 0 {
+		for size > this.capacity {
+			victim := this.evict()
+			if victim == nil {
+				break
+			}
+			evicted = append(evicted, victim)
+			size = atomic.LoadInt64(&this.size)
+		}
+	}
+
+	shard.locker.Lock()
+	defer shard.locker.Unlock()
+
+	// 期间可能有同样Key的条目加入
+	oldEntry, found = shard.entries[entry.key]
+	if found {
+		if !replace {
+			atomic.AddInt64(&this.size, -entry.size)
+			return replaced, evicted, nil
+		}
+		shard.remove(oldEntry)
+		replaced = oldEntry
+	}
+
+	entry.hits = 1
+	entry.accessed = atomic.AddInt64(&this.sequence, 1)
+	shard.entries[entry.key] = entry
+	shard.queue.push(entry)
+	shard.size += entry.size
+	return
+}
+
+// 从所有分片中找出最应该淘汰的条目并删除，没有条目时返回nil
+func (this *cacheIndex) evict() *indexEntry {
+	for {
+		var victimShard *indexShard
+		victimHits := int64(0)
+		victimAccessed := int64(0)
+		for _, shard := range this.shards {
+			shard.locker.Lock()
+			victim := shard.queue.victim()
+			if victim != nil && (victimShard == nil || this.evictsBefore(victim.hits, victim.accessed, victimHits, victimAccessed)) {
+				victimShard = shard
+				victimHits = victim.hits
+				victimAccessed = victim.accessed
+			}
+			shard.locker.Unlock()
+		}
+		if victimShard == nil {
+			return nil
+		}
+
+		// 查找期间分片可能有变化，淘汰分片当前的条目
+		victimShard.locker.Lock()
+		victim := victimShard.queue.victim()
+		if victim != nil {
+			victimShard.remove(victim)
+		}
+		victimShard.locker.Unlock()
+		if victim != nil {
+			return victim
+		}
+	}
+}
+
+// 判断一个条目是否比另外一个条目更应该被淘汰
+func (this *cacheIndex) evictsBefore(hits1 int64, accessed1 int64, hits2 int64, accessed2 int64) bool {
+	if this.policy == EvictionPolicyLFU && hits1 != hits2 {
+		return hits1 < hits2
+	}
+	return accessed1 < accessed2
+}
+
+// 删除条目
+func (this *cacheIndex) remove(key string) *indexEntry {
+	shard := this.shard(key)
+	shard.locker.Lock()
+	defer shard.locker.Unlock()
+
+	entry, found := shard.entries[key]
+	if !found {
+		return nil
+	}
+	shard.remove(entry)
+	return entry
+}
+
+// 删除符合条件的条目，返回被删除的条目
+func (this *cacheIndex) removeIf(f func(entry *indexEntry) bool) (removed []*indexEntry) {
+	for _, shard := range this.shards {
+		shard.locker.Lock()
+		for _, entry := range shard.entries {
+			if f(entry) {
+				shard.remove(entry)
+				removed = append(removed, entry)
+			}
+		}
+		shard.locker.Unlock()
+	}
+	return
+}
+
+// 删除过期的条目
+func (this *cacheIndex) removeExpired(now int64) []*indexEntry {
+	return this.removeIf(func(entry *indexEntry) bool {
+		return entry.isExpired(now)
+	})
+}
+
+// 清除所有条目
+func (this *cacheIndex) clear() {
+	for _, shard := range this.shards {
+		shard.locker.Lock()
+		for _, entry := range shard.entries {
+			shard.remove(entry)
+		}
+		shard.locker.Unlock()
+	}
+}
+
+// 统计条目数量和尺寸
+func (this *cacheIndex) stat() (count int, size int64) {
+	for _, shard := range this.shards {
+		shard.locker.Lock()
+		count += len(shard.entries)
+		size += shard.size
+		shard.locker.Unlock()
+	}
+	return
+}
+
+// 从分片中删除条目，调用前需要加锁
+func (this *indexShard) remove(entry *indexEntry) {
+	delete(this.entries, entry.key)
+	this.queue.remove(entry)
+	this.size -= entry.size
+	atomic.AddInt64(this.total, -entry.size)
+}
+
+// LRU队列，最近访问的在最前面
+type lruQueue struct {
+	list *list.List
+}
+
+func newLRUQueue() *lruQueue {
+	return &lruQueue{
+		list: list.New(),
+	}
+}
+
+func (this *lruQueue) push(entry *indexEntry) {
+	entry.element = this.list.PushFront(entry)
+}
+
+func (this *lruQueue) touch(entry *indexEntry) {
+	if entry.element != nil {
+		this.list.MoveToFront(entry.element)
+	}
+}
+
+func (this *lruQueue) remove(entry *indexEntry) {
+	if entry.element != nil {
+		this.list.Remove(entry.element)
+		entry.element = nil
+	}
+}
+
+func (this *lruQueue) victim() *indexEntry {
+	element := this.list.Back()
+	if element == nil {
+		return nil
+	}
+	return element.Value.(*indexEntry)
+}
+
+// LFU队列，访问次数最少的在堆顶，次数相同时最久没有访问的优先
+type lfuQueue []*indexEntry
+
+func (this lfuQueue) Len() int {
+	return len(this)
+}
+
+func (this lfuQueue) Less(i, j int) bool {
+	if this[i].hits == this[j].hits {
+		return this[i].accessed < this[j].accessed
+	}
+	return this[i].hits < this[j].hits
+}
+
+func (this lfuQueue) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+	this[i].heapIndex = i
+	this[j].heapIndex = j
+}
+
+func (this *lfuQueue) Push(x interface{}) {
+	entry := x.(*indexEntry)
+	entry.heapIndex = len(*this)
+	*this = append(*this, entry)
+}
+
+func (this *lfuQueue) Pop() interface{} {
+	old := *this
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.heapIndex = -1
+	*this = old[:n-1]
+	return entry
+}
+
+func (this *lfuQueue) push(entry *indexEntry) {
+	heap.Push(this, entry)
+}
+
+func (this *lfuQueue) touch(entry *indexEntry) {
+	if entry.heapIndex >= 0 && entry.heapIndex < len(*this) {
+		heap.Fix(this, entry.heapIndex)
+	}
+}
+
+func (this *lfuQueue) remove(entry *indexEntry) {
+	if entry.heapIndex >= 0 && entry.heapIndex < len(*this) && (*this)[entry.heapIndex] == entry {
+		heap.Remove(this, entry.heapIndex)
+	}
+}
+
+func (this *lfuQueue) victim() *indexEntry {
+	if len(*this) == 0 {
+		return nil
+	}
+	return (*this)[0]
+}
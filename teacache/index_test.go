@@ -0,0 +1,201 @@
+package teacache
+
+import (
+	"fmt"
+	"github.com/iwind/TeaGo/assert"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheIndex_LRU(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	index := newCacheIndex(3, EvictionPolicyLRU)
+	keys := []string{"key0", "key1", "key2", "key3"}
+
+	for _, key := range keys[:3] {
+		_, evicted, err := index.put(&indexEntry{key: key, size: 1}, true)
+		a.IsNil(err)
+		a.IsTrue(len(evicted) == 0)
+	}
+
+	// 访问第一个，第二个就成为最久没有访问的
+	entry, _ := index.get(keys[0], time.Now().Unix())
+	a.IsNotNil(entry)
+
+	_, evicted, err := index.put(&indexEntry{key: keys[3], size: 1}, true)
+	a.IsNil(err)
+	a.IsTrue(len(evicted) == 1 && evicted[0].key == keys[1])
+
+	entry, _ = index.get(keys[1], time.Now().Unix())
+	a.IsTrue(entry == nil)
+
+	count, size := index.stat()
+	a.IsTrue(count == 3)
+	a.IsTrue(size == 3)
+}
+
+func TestCacheIndex_LFU(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	index := newCacheIndex(3, EvictionPolicyLFU)
+	keys := []string{"key0", "key1", "key2", "key3"}
+
+	for _, key := range keys[:3] {
+		index.put(&indexEntry{key: key, size: 1}, true)
+	}
+
+	now := time.Now().Unix()
+	for i := 0; i < 3; i++ {
+		index.get(keys[0], now)
+		index.get(keys[2], now)
+	}
+	index.get(keys[1], now)
+
+	_, evicted, err := index.put(&indexEntry{key: keys[3], size: 1}, true)
+	a.IsNil(err)
+	a.IsTrue(len(evicted) == 1 && evicted[0].key == keys[1])
+
+	// 新加入的访问次数最少
+	_, evicted, err = index.put(&indexEntry{key: keys[1], size: 1}, true)
+	a.IsNil(err)
+	a.IsTrue(len(evicted) == 1 && evicted[0].key == keys[3])
+}
+
+func TestCacheIndex_Put(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	index := newCacheIndex(10, EvictionPolicyLRU)
+
+	_, _, err := index.put(&indexEntry{key: "a", size: 11}, true)
+	a.IsTrue(err == errItemTooLarge)
+
+	_, _, err = index.put(&indexEntry{key: "a", size: 5}, true)
+	a.IsNil(err)
+
+	// 替换
+	replaced, _, err := index.put(&indexEntry{key: "a", size: 6}, true)
+	a.IsNil(err)
+	a.IsTrue(replaced != nil && replaced.size == 5)
+
+	// 不替换
+	replaced, _, err = index.put(&indexEntry{key: "a", size: 1}, false)
+	a.IsNil(err)
+	a.IsTrue(replaced == nil)
+
+	_, size := index.stat()
+	a.IsTrue(size == 6)
+
+	// 过期
+	index.put(&indexEntry{key: "b", size: 1, expiredAt: time.Now().Unix() - 1}, true)
+	entry, expired := index.get("b", time.Now().Unix())
+	a.IsTrue(entry == nil)
+	a.IsTrue(expired != nil)
+
+	index.put(&indexEntry{key: "c", size: 1, expiredAt: time.Now().Unix() - 1}, true)
+	removed := index.removeExpired(time.Now().Unix())
+	a.IsTrue(len(removed) == 1 && removed[0].key == "c")
+
+	index.clear()
+	count, size := index.stat()
+	a.IsTrue(count == 0 && size == 0)
+}
+
+func TestCacheIndex_Capacity(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	// 容量由所有分片共用，单个条目可以超过平均每个分片的容量
+	index := newCacheIndex(100, EvictionPolicyLRU)
+	_, evicted, err := index.put(&indexEntry{key: "a", size: 60}, true)
+	a.IsNil(err)
+	a.IsTrue(len(evicted) == 0)
+
+	_, evicted, err = index.put(&indexEntry{key: "b", size: 30}, true)
+	a.IsNil(err)
+	a.IsTrue(len(evicted) == 0)
+
+	// 从其他分片中淘汰
+	_, evicted, err = index.put(&indexEntry{key: "c", size: 30}, true)
+	a.IsNil(err)
+	a.IsTrue(len(evicted) == 1 && evicted[0].key == "a")
+
+	_, _, err = index.put(&indexEntry{key: "d", size: 101}, true)
+	a.IsTrue(err == errItemTooLarge)
+
+	count, size := index.stat()
+	a.IsTrue(count == 2)
+	a.IsTrue(size == 60)
+	a.IsTrue(index.size == 60)
+}
+
+func TestCacheIndex_Concurrent(t *testing.T) {
+	index := newCacheIndex(1024*indexShardCount, EvictionPolicyLFU)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			now := time.Now().Unix()
+			for j := 0; j < 10000; j++ {
+				key := "key" + strconv.Itoa(rand.Intn(5000))
+				if j%3 == 0 {
+					index.put(&indexEntry{key: key, size: int64(rand.Intn(100) + 1)}, true)
+				} else {
+					index.get(key, now)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := int64(0)
+	for _, shard := range index.shards {
+		size := int64(0)
+		for _, entry := range shard.entries {
+			size += entry.size
+		}
+		if size != shard.size {
+			t.Fatal("shard size mismatch")
+		}
+		total += size
+	}
+	if total != index.size {
+		t.Fatal("index size mismatch")
+	}
+	if total > index.capacity {
+		t.Fatal("index size exceeds capacity")
+	}
+}
+
+func BenchmarkCacheIndex_LRU(b *testing.B) {
+	benchmarkCacheIndex(b, EvictionPolicyLRU)
+}
+
+func BenchmarkCacheIndex_LFU(b *testing.B) {
+	benchmarkCacheIndex(b, EvictionPolicyLFU)
+}
+
+func benchmarkCacheIndex(b *testing.B, policy EvictionPolicy) {
+	index := newCacheIndex(1<<20, policy)
+	keys := []string{}
+	for i := 0; i < 100000; i++ {
+		keys = append(keys, fmt.Sprintf("https://example.com/%d", i))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		now := time.Now().Unix()
+		for pb.Next() {
+			key := keys[r.Intn(len(keys))]
+			entry, _ := index.get(key, now)
+			if entry == nil {
+				index.put(&indexEntry{key: key, size: 100}, true)
+			}
+		}
+	})
+}
@@ -1,23 +1,127 @@
 package teacache
 
-import "encoding/binary"
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
 
+// 缓存条目数据的格式标识
+var itemMagic = []byte("TCI1")
+
+// 缓存条目头部长度：标识(4) + Header长度(4) + Vary长度(4) + 创建时间(8) + 过期时间(8) + stale-while-revalidate(8) + stale-if-error(8)
+const itemPrefixLength = 44
+
+var errInvalidItem = errors.New("invalid cache item")
+
+// 缓存条目
 type Item struct {
 	Header []byte
 	Body   []byte
+
+	CreatedAt            int64    // 创建时间，已经减去了源站的Age
+	ExpiredAt            int64    // 新鲜期截止时间
+	StaleWhileRevalidate int64    // 过期后可以一边使用一边在后台更新的秒数
+	StaleIfError         int64    // 过期后源站出错时仍然可以使用的秒数
+	Vary                 []string // Vary中的Header名称，只有Vary而没有Header时表示为Vary索引
 }
 
+// 编码
 func (this *Item) Encode() (data []byte) {
-	l := make([]byte, 8)
-	binary.BigEndian.PutUint32(l, uint32(len(this.Header)))
-	l = append(l, this.Header ...)
-	l = append(l, this.Body ...)
-	return l
-}
-
-func (this *Item) Decode(data []byte) {
-	l := data[:8]
-	headerLength := binary.BigEndian.Uint32(l)
-	this.Header = data[8 : 8+headerLength]
-	this.Body = data[8+headerLength:]
+	vary := []byte(strings.Join(this.Vary, ","))
+
+	prefix := make([]byte, itemPrefixLength)
+	copy(prefix, itemMagic)
+	binary.BigEndian.PutUint32(prefix[4:], uint32(len(this.Header)))
+	binary.BigEndian.PutUint32(prefix[8:], uint32(len(vary)))
+	binary.BigEndian.PutUint64(prefix[12:], uint64(this.CreatedAt))
+	binary.BigEndian.PutUint64(prefix[20:], uint64(this.ExpiredAt))
+	binary.BigEndian.PutUint64(prefix[28:], uint64(this.StaleWhileRevalidate))
+	binary.BigEndian.PutUint64(prefix[36:], uint64(this.StaleIfError))
+
+	data = make([]byte, 0, itemPrefixLength+len(vary)+len(this.Header)+len(this.Body))
+	data = append(data, prefix...)
+	data = append(data, vary...)
+	data = append(data, this.Header...)
+	data = append(data, this.Body...)
+	return data
+}
+
+// 解码，旧格式或者损坏的数据返回错误
+func (this *Item) Decode(data []byte) error {
+	if len(data) < itemPrefixLength || !bytes.Equal(data[:4], itemMagic) {
+		return errInvalidItem
+	}
+	headerLength := int(binary.BigEndian.Uint32(data[4:]))
+	varyLength := int(binary.BigEndian.Uint32(data[8:]))
+	if headerLength < 0 || varyLength < 0 || itemPrefixLength+varyLength+headerLength > len(data) {
+		return errInvalidItem
+	}
+	this.CreatedAt = int64(binary.BigEndian.Uint64(data[12:]))
+	this.ExpiredAt = int64(binary.BigEndian.Uint64(data[20:]))
+	this.StaleWhileRevalidate = int64(binary.BigEndian.Uint64(data[28:]))
+	this.StaleIfError = int64(binary.BigEndian.Uint64(data[36:]))
+
+	offset := itemPrefixLength
+	this.Vary = nil
+	if varyLength > 0 {
+		this.Vary = strings.Split(string(data[offset:offset+varyLength]), ",")
+	}
+	offset += varyLength
+	this.Header = data[offset : offset+headerLength]
+	this.Body = data[offset+headerLength:]
+	return nil
+}
+
+// 是否为Vary索引
+func (this *Item) IsVaryIndex() bool {
+	return len(this.Vary) > 0 && len(this.Header) == 0
+}
+
+// 是否在新鲜期内
+func (this *Item) IsFresh(now int64) bool {
+	return now < this.ExpiredAt
+}
+
+// 是否可以一边使用旧内容一边在后台更新
+func (this *Item) CanServeWhileRevalidate(now int64) bool {
+	return now < this.ExpiredAt+this.StaleWhileRevalidate
+}
+
+// 源站出错时是否可以使用旧内容
+func (this *Item) CanServeIfError(now int64) bool {
+	return now < this.ExpiredAt+this.StaleIfError
+}
+
+// 年龄
+func (this *Item) Age(now int64) int64 {
+	age := now - this.CreatedAt
+	if age < 0 {
+		age = 0
+	}
+	return age
+}
+
+// 转换为响应对象
+func (this *Item) Response() (*http.Response, error) {
+	reader := io.MultiReader(bytes.NewReader(this.Header), bytes.NewReader(this.Body))
+	return http.ReadResponse(bufio.NewReader(reader), nil)
+}
+
+// 将状态码和Header编码为缓存中的Header数据
+func encodeItemHeader(statusCode int, header http.Header) []byte {
+	resp := &http.Response{}
+	resp.Header = header
+	resp.StatusCode = statusCode
+	resp.ProtoMajor = 1
+	resp.ProtoMinor = 1
+	resp.ContentLength = 1 // Trick：这样可以屏蔽Content-Length
+
+	writer := bytes.NewBuffer([]byte{})
+	resp.Write(writer)
+	return writer.Bytes()
 }
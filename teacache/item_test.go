@@ -7,15 +7,43 @@ import (
 
 func TestItem_Encode(t *testing.T) {
 	item := &Item{
-		Header: []byte("Hello"),
-		Body:   []byte("World"),
+		Header:               []byte("Hello"),
+		Body:                 []byte("World"),
+		CreatedAt:            100,
+		ExpiredAt:            200,
+		StaleWhileRevalidate: 10,
+		StaleIfError:         20,
+		Vary:                 []string{"Accept-Language", "Cookie"},
 	}
 
 	a := assert.NewAssertion(t).Quiet()
 	a.Log(string(item.Encode()))
 
 	newItem := &Item{}
-	newItem.Decode(item.Encode())
+	a.IsNil(newItem.Decode(item.Encode()))
 	a.Equals(string(newItem.Header), "Hello")
 	a.Equals(string(newItem.Body), "World")
+	a.IsTrue(newItem.CreatedAt == 100)
+	a.IsTrue(newItem.ExpiredAt == 200)
+	a.IsTrue(newItem.StaleWhileRevalidate == 10)
+	a.IsTrue(newItem.StaleIfError == 20)
+	a.IsTrue(len(newItem.Vary) == 2 && newItem.Vary[1] == "Cookie")
+	a.IsFalse(newItem.IsVaryIndex())
+
+	a.IsTrue(newItem.IsFresh(199))
+	a.IsFalse(newItem.IsFresh(200))
+	a.IsTrue(newItem.CanServeWhileRevalidate(209))
+	a.IsFalse(newItem.CanServeWhileRevalidate(210))
+	a.IsTrue(newItem.CanServeIfError(219))
+	a.IsTrue(newItem.Age(150) == 50)
+}
+
+func TestItem_DecodeInvalid(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	item := &Item{}
+	a.IsNotNil(item.Decode([]byte("HTTP/1.1 200 OK\r\n\r\n")))
+
+	data := (&Item{Header: []byte("Hello")}).Encode()
+	a.IsNotNil(item.Decode(data[:len(data)-1]))
 }
@@ -14,11 +14,26 @@ type ManagerInterface interface {
 	// 写入
 	Write(key string, data []byte) error
 
+	// 使用指定的有效期写入，life小于等于0时使用默认有效期
+	WriteWithLife(key string, data []byte, life time.Duration) error
+
 	// 读取
 	Read(key string) (data []byte, err error)
 
 	// 设置选项
 	SetOptions(options map[string]interface{})
+
+	// 删除
+	Delete(key string) error
+
+	// 按规则删除，支持前缀（比如 https://example.com/static/* ）和通配符 * ?
+	Purge(pattern string) (count int, err error)
+
+	// 清除所有缓存
+	Clean() error
+
+	// 统计：缓存数量、占用的字节数
+	Stat() (count int, size int64, err error)
 }
 
 // 获取新的管理对象
@@ -28,19 +43,25 @@ func NewManagerFromConfig(config *shared.CachePolicy) ManagerInterface {
 		m := NewMemoryManager()
 		m.Life, _ = time.ParseDuration(config.Life)
 		m.Capacity, _ = stringutil.ParseFileSize(config.Capacity)
+		m.Eviction = config.Eviction
+		m.onEvict = FindPolicyStat(config.Filename).evict
 		m.SetOptions(config.Options)
 		return m
 	case "file":
 		m := NewFileManager()
 		m.Life, _ = time.ParseDuration(config.Life)
 		m.Capacity, _ = stringutil.ParseFileSize(config.Capacity)
-		m.SetOptions(config.Options)
+		m.Eviction = config.Eviction
+		m.onEvict = FindPolicyStat(config.Filename).evict
+		m.SetOptions(config.Options) // 会在后台加载索引，需要在设置淘汰策略之后
 		return m
 	case "redis":
 		m := NewRedisManager()
 		m.Life, _ = time.ParseDuration(config.Life)
 		m.Capacity, _ = stringutil.ParseFileSize(config.Capacity)
+		m.Prefix = redisPolicyKeyPrefix(config.Filename)
 		m.SetOptions(config.Options)
+		return m
 	}
 	return nil
 }
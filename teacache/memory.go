@@ -1,36 +1,30 @@
 package teacache
 
 import (
-	"errors"
-	"github.com/iwind/TeaGo/caches"
+	"github.com/iwind/TeaGo/timers"
 	"sync"
 	"time"
 )
 
 // 内存缓存管理器
 type MemoryManager struct {
-	Capacity float64       // 容量
-	Life     time.Duration // 有效期
+	Capacity float64        // 容量
+	Life     time.Duration  // 有效期
+	Eviction EvictionPolicy // 容量不足时的淘汰策略，默认为LRU
 
-	cache        *caches.Factory
-	memory       float64
-	memoryLocker sync.Mutex
+	index     *cacheIndex
+	indexOnce sync.Once
+
+	onEvict func(count int) // 淘汰条目时的回调
 }
 
 func NewMemoryManager() *MemoryManager {
 	m := &MemoryManager{}
 
-	factory := caches.NewFactory()
-	factory.OnOperation(func(op caches.CacheOperation, value interface{}) {
-		m.memoryLocker.Lock()
-		defer m.memoryLocker.Unlock()
-		if op == caches.CacheOperationSet {
-			m.memory += float64(len(value.([]byte)))
-		} else if op == caches.CacheOperationDelete {
-			m.memory -= float64(len(value.([]byte)))
-		}
+	// 删除过期
+	timers.Loop(30*time.Second, func(looper *timers.Looper) {
+		m.clean(false)
 	})
-	m.cache = factory
 	return m
 }
 
@@ -39,19 +33,87 @@ func (this *MemoryManager) SetOptions(options map[string]interface{}) {
 }
 
 func (this *MemoryManager) Write(key string, data []byte) error {
-	// 检查容量
-	if this.Capacity > 0 && this.memory+float64(len(data)) >= this.Capacity {
-		return errors.New("out of memory")
+	return this.WriteWithLife(key, data, this.Life)
+}
+
+// 使用指定的有效期写入
+func (this *MemoryManager) WriteWithLife(key string, data []byte, life time.Duration) error {
+	if life <= 0 {
+		life = this.Life
 	}
 
-	this.cache.Set(key, data).Expire(this.Life)
+	expiredAt := int64(0)
+	if life > 0 {
+		expiredAt = time.Now().Add(life).Unix()
+	}
+	_, evicted, err := this.getIndex().put(&indexEntry{
+		key:       key,
+		size:      int64(len(data)),
+		expiredAt: expiredAt,
+		data:      data,
+	}, true)
+	if err != nil {
+		return err
+	}
+	this.evict(len(evicted))
 	return nil
 }
 
 func (this *MemoryManager) Read(key string) (data []byte, err error) {
-	value, found := this.cache.Get(key)
-	if !found {
+	entry, _ := this.getIndex().get(key, time.Now().Unix())
+	if entry == nil {
 		return nil, ErrNotFound
 	}
-	return value.([]byte), nil
+	return entry.data, nil
+}
+
+// 删除
+func (this *MemoryManager) Delete(key string) error {
+	this.getIndex().remove(key)
+	return nil
+}
+
+// 按规则删除
+func (this *MemoryManager) Purge(pattern string) (count int, err error) {
+	removed := this.getIndex().removeIf(func(entry *indexEntry) bool {
+		return MatchKey(pattern, entry.key)
+	})
+	return len(removed), nil
+}
+
+// 清除所有缓存
+func (this *MemoryManager) Clean() error {
+	this.clean(true)
+	return nil
+}
+
+// 统计
+func (this *MemoryManager) Stat() (count int, size int64, err error) {
+	count, size = this.getIndex().stat()
+	return
+}
+
+// 获取索引，第一次使用时才创建，以便于使用设置后的容量和淘汰策略
+func (this *MemoryManager) getIndex() *cacheIndex {
+	this.indexOnce.Do(func() {
+		this.index = newCacheIndex(int64(this.Capacity), this.Eviction)
+	})
+	return this.index
+}
+
+// 清除条目，all为false时只清除过期的条目
+func (this *MemoryManager) clean(all bool) {
+	if all {
+		this.getIndex().clear()
+		return
+	}
+
+	this.getIndex().removeExpired(time.Now().Unix())
+}
+
+// 记录因为容量不足而淘汰的条目数，过期删除的不计算在内
+func (this *MemoryManager) evict(count int) {
+	if count > 0 && this.onEvict != nil {
+		this.onEvict(count)
+	}
 }
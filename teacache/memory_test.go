@@ -1,8 +1,12 @@
 package teacache
 
 import (
+	"github.com/TeaWeb/code/teaconfigs/shared"
 	"github.com/iwind/TeaGo/assert"
+	"math/rand"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestCacheMemoryConfig(t *testing.T) {
@@ -12,7 +16,8 @@ func TestCacheMemoryConfig(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Log("memory:", m.memory, "bytes")
+	_, size, _ := m.Stat()
+	t.Log("memory:", size, "bytes")
 
 	a := assert.NewAssertion(t).Quiet()
 
@@ -25,3 +30,123 @@ func TestCacheMemoryConfig(t *testing.T) {
 
 	t.Log(string(data))
 }
+
+func TestMemoryManager_Purge(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	m := NewMemoryManager()
+	m.Write("https://example.com/static/a.css", []byte("a"))
+	m.Write("https://example.com/static/b.css", []byte("bb"))
+	m.Write("https://example.com/index.html", []byte("ccc"))
+
+	count, size, err := m.Stat()
+	a.IsNil(err)
+	a.IsTrue(count == 3)
+	a.IsTrue(size == 6)
+
+	err = m.Delete("https://example.com/index.html")
+	a.IsNil(err)
+	_, err = m.Read("https://example.com/index.html")
+	a.IsTrue(err == ErrNotFound)
+
+	purged, err := m.Purge("https://example.com/static/*")
+	a.IsNil(err)
+	a.IsTrue(purged == 2)
+
+	count, size, err = m.Stat()
+	a.IsNil(err)
+	a.IsTrue(count == 0)
+	a.IsTrue(size == 0)
+
+	m.Write("/hello", []byte("Hello"))
+	a.IsNil(m.Clean())
+	count, _, _ = m.Stat()
+	a.IsTrue(count == 0)
+}
+
+func TestDeletePolicyKey(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	policy := shared.NewCachePolicy()
+	policy.Type = "memory"
+	m := NewMemoryManager()
+	cachePolicyMapLocker.Lock()
+	cachePolicyMap[policy] = m
+	cachePolicyMapLocker.Unlock()
+	defer func() {
+		cachePolicyMapLocker.Lock()
+		delete(cachePolicyMap, policy)
+		cachePolicyMapLocker.Unlock()
+	}()
+
+	m.Write("/hello", []byte("Hello"))
+	m.Write("/hello@gzip", []byte("Hello"))
+
+	count, err := DeletePolicyKey(policy, "/hello")
+	a.IsNil(err)
+	a.IsTrue(count == 2)
+
+	count, err = DeletePolicyKey(policy, "/hello")
+	a.IsNil(err)
+	a.IsTrue(count == 0)
+}
+
+func TestMemoryManager_Eviction(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	m := NewMemoryManager()
+	m.Capacity = 100 * indexShardCount
+	evictions := 0
+	m.onEvict = func(count int) {
+		evictions += count
+	}
+
+	data := make([]byte, 10)
+	for i := 0; i < 1000; i++ {
+		a.IsNil(m.Write("/hello"+strconv.Itoa(i), data))
+	}
+
+	_, size, err := m.Stat()
+	a.IsNil(err)
+	a.IsTrue(size <= int64(m.Capacity))
+	a.IsTrue(evictions > 0)
+
+	// 最后写入的仍然存在
+	_, err = m.Read("/hello999")
+	a.IsNil(err)
+
+	// 容量由所有分片共用
+	a.IsNil(m.Write("/big", make([]byte, 1000)))
+	_, err = m.Read("/big")
+	a.IsNil(err)
+
+	// 超出总容量
+	a.IsNotNil(m.Write("/huge", make([]byte, 100*indexShardCount+1)))
+
+	// 过期删除的不计算为淘汰
+	evictions = 0
+	_, _, err = m.getIndex().put(&indexEntry{key: "/expired", size: 1, expiredAt: time.Now().Unix() - 1}, true)
+	a.IsNil(err)
+	_, err = m.Read("/expired")
+	a.IsTrue(err == ErrNotFound)
+	m.clean(false)
+	a.IsTrue(evictions == 0)
+}
+
+func BenchmarkMemoryManager(b *testing.B) {
+	m := NewMemoryManager()
+	m.Capacity = 64 << 20
+	data := make([]byte, 1024)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for pb.Next() {
+			key := "https://example.com/" + strconv.Itoa(r.Intn(100000))
+			_, err := m.Read(key)
+			if err != nil {
+				m.Write(key, data)
+			}
+		}
+	})
+}
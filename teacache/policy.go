@@ -0,0 +1,102 @@
+package teacache
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/iwind/TeaGo/utils/string"
+	"time"
+)
+
+// 对缓存策略正在使用的缓存管理器执行操作
+// 内存缓存只能操作正在使用的管理器；文件和Redis缓存的数据是共享的，只需要操作一个管理器即可
+func walkPolicyManagers(policy *shared.CachePolicy, f func(manager ManagerInterface) error) error {
+	managers := []ManagerInterface{}
+	cachePolicyMapLocker.RLock()
+	for p, manager := range cachePolicyMap {
+		if p.Filename == policy.Filename {
+			managers = append(managers, manager)
+		}
+	}
+	cachePolicyMapLocker.RUnlock()
+
+	if policy.Type != "memory" {
+		if len(managers) > 0 {
+			return f(managers[0])
+		}
+
+		var manager ManagerInterface
+		switch policy.Type {
+		case "file":
+			m := newFileManager(false)
+			m.Life, _ = time.ParseDuration(policy.Life)
+			m.Capacity, _ = stringutil.ParseFileSize(policy.Capacity)
+			m.SetOptions(policy.Options)
+			manager = m
+		case "redis":
+			m := NewRedisManager()
+			m.Life, _ = time.ParseDuration(policy.Life)
+			m.Capacity, _ = stringutil.ParseFileSize(policy.Capacity)
+			m.Prefix = redisPolicyKeyPrefix(policy.Filename)
+			m.SetOptions(policy.Options)
+			defer m.Close()
+			manager = m
+		default:
+			return nil
+		}
+		return f(manager)
+	}
+
+	for _, manager := range managers {
+		err := f(manager)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除某个缓存策略中的Key，同时会删除压缩过的版本，返回删除的缓存数量
+func DeletePolicyKey(policy *shared.CachePolicy, key string) (count int, err error) {
+	err = walkPolicyManagers(policy, func(manager ManagerInterface) error {
+		for _, k := range []string{key, key + "@" + teaconfigs.CompressionEncodingGzip, key + "@" + teaconfigs.CompressionEncodingBrotli} {
+			_, err := manager.Read(k)
+			if err == nil {
+				count++
+			}
+			err = manager.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// 按规则删除某个缓存策略中的缓存
+func PurgePolicy(policy *shared.CachePolicy, pattern string) (count int, err error) {
+	err = walkPolicyManagers(policy, func(manager ManagerInterface) error {
+		c, err := manager.Purge(pattern)
+		count += c
+		return err
+	})
+	return
+}
+
+// 清除某个缓存策略中的所有缓存
+func CleanPolicy(policy *shared.CachePolicy) error {
+	return walkPolicyManagers(policy, func(manager ManagerInterface) error {
+		return manager.Clean()
+	})
+}
+
+// 统计某个缓存策略中的缓存
+func StatPolicy(policy *shared.CachePolicy) (count int, size int64, err error) {
+	err = walkPolicyManagers(policy, func(manager ManagerInterface) error {
+		c, s, err := manager.Stat()
+		count += c
+		size += s
+		return err
+	})
+	return
+}
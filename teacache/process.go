@@ -1,20 +1,30 @@
 package teacache
 
 import (
-	"bufio"
 	"bytes"
 	"github.com/TeaWeb/code/teaconfigs/shared"
 	"github.com/TeaWeb/code/teaproxy"
 	"github.com/iwind/TeaGo/lists"
 	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/utils/string"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 var cachePolicyMap = map[*shared.CachePolicy]ManagerInterface{}
 var cachePolicyMapLocker = sync.RWMutex{}
 
+// 正在后台更新的Key
+var revalidatingKeys = map[string]bool{}
+var revalidatingKeysLocker = sync.Mutex{}
+
+// 更新缓存时从源站更新的Header
+var revalidateHeaders = []string{"Cache-Control", "Date", "Expires", "ETag", "Last-Modified", "Vary"}
+
 func ProcessBeforeRequest(req *teaproxy.Request, writer *teaproxy.ResponseWriter) bool {
 	cacheConfig := req.CachePolicy()
 	if cacheConfig == nil || !cacheConfig.On {
@@ -38,37 +48,103 @@ func ProcessBeforeRequest(req *teaproxy.Request, writer *teaproxy.ResponseWriter
 	if len(cacheConfig.Key) == 0 {
 		return true
 	}
-	key := req.Format(cacheConfig.Key)
-	data, err := cache.Read(key)
+
+	// 错误页面的子请求不读写缓存，避免错误页面写入原URI的缓存
+	if req.IsErrorPage() {
+		return true
+	}
+
+	// 更新缓存的子请求直接访问源站
+	if req.IsForked() {
+		req.SetCacheEnabled()
+		writer.SetBodyCopying(true)
+		return true
+	}
+
+	method := req.Method()
+	if method != http.MethodGet && method != http.MethodHead {
+		setCacheStatus(req, writer, CacheStatusBypass, 0)
+		return true
+	}
+
+	reqHeader := req.Raw().Header
+	reqCacheControl := parseCacheControl(reqHeader["Cache-Control"])
+	if reqCacheControl.noStore {
+		setCacheStatus(req, writer, CacheStatusBypass, 0)
+		return true
+	}
+	if reqCacheControl.noCache || strings.Contains(strings.ToLower(reqHeader.Get("Pragma")), "no-cache") {
+		setCacheStatus(req, writer, CacheStatusBypass, 0)
+		enableCacheWriting(req, writer)
+		return true
+	}
+
+	key := cacheKey(req, cacheConfig)
+	item, err := readItem(cache, key, reqHeader)
+
+	// 合并回源：等待正在回源的请求完成后重新读取
+	// 跳转到默认文件时同一个请求会再次经过这里，不能等待自己
+	if err == ErrNotFound && cacheConfig.Coalescing && method == http.MethodGet && !isFlightLeader(req) {
+		call, leader := sharedFlightGroup.begin(key, cacheConfig.CoalescingTimeoutDuration())
+		if leader {
+			beginFlight(req, key, call)
+		} else if call.wait() {
+			item, err = readItem(cache, key, reqHeader)
+		}
+	}
+
 	if err != nil {
 		if err != ErrNotFound {
 			logs.Error(err)
+			setCacheStatus(req, writer, CacheStatusBypass, 0)
 		} else {
-			req.SetCacheEnabled()
-			writer.SetBodyCopying(true)
+			setCacheStatus(req, writer, CacheStatusMiss, 0)
+			enableCacheWriting(req, writer)
 		}
 		return true
 	}
 
-	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data[8:])), nil)
-	if err != nil {
-		logs.Error(err)
+	now := time.Now().Unix()
+	if item.IsFresh(now) {
+		return !serveItem(req, writer, item, CacheStatusHit, now)
+	}
+
+	// HEAD请求没有内容，无法用来更新缓存
+	if method == http.MethodHead {
+		setCacheStatus(req, writer, CacheStatusExpired, 0)
 		return true
 	}
-	defer resp.Body.Close()
 
-	writer.WriteHeader(resp.StatusCode)
-	for k, vs := range resp.Header {
-		for _, v := range vs {
-			writer.Header().Add(k, v)
+	// stale-while-revalidate
+	if item.CanServeWhileRevalidate(now) {
+		if serveItem(req, writer, item, CacheStatusStale, now) {
+			revalidateInBackground(req, key, item)
+			return false
 		}
+		return true
 	}
-	io.Copy(writer, resp.Body)
 
-	return false
+	// 合并回源：只有一个请求更新缓存，其他请求等待后使用更新后的缓存
+	if cacheConfig.Coalescing {
+		call, leader := sharedFlightGroup.begin(key, cacheConfig.CoalescingTimeoutDuration())
+		if leader {
+			defer sharedFlightGroup.end(key, call)
+		} else if call.wait() {
+			newItem, err := readItem(cache, key, reqHeader)
+			now = time.Now().Unix()
+			if err == nil && newItem.IsFresh(now) {
+				return !serveItem(req, writer, newItem, CacheStatusHit, now)
+			}
+		}
+	}
+
+	return !revalidate(req, writer, cache, key, item, now)
 }
 
 func ProcessAfterRequest(req *teaproxy.Request, writer *teaproxy.ResponseWriter) bool {
+	// 写入缓存后再唤醒等待的请求
+	defer endFlight(req)
+
 	if !req.IsCacheEnabled() {
 		return true
 	}
@@ -78,8 +154,18 @@ func ProcessAfterRequest(req *teaproxy.Request, writer *teaproxy.ResponseWriter)
 		return true
 	}
 
+	cachePolicyMapLocker.RLock()
+	cache, found := cachePolicyMap[cacheConfig]
+	cachePolicyMapLocker.RUnlock()
+	if !found {
+		return true
+	}
+
 	//check status
-	if writer.StatusCode() == http.StatusNotModified { // 如果没有修改就不会有body，会有陷阱，所以这里不加入缓存
+	if writer.StatusCode() == http.StatusNotModified { // 如果没有修改就不会有body，只有更新缓存的子请求才会更新已有的缓存
+		if req.IsForked() {
+			refreshItem(req, writer, cache, cacheConfig)
+		}
 		return true
 	}
 	if len(cacheConfig.Status) == 0 {
@@ -89,29 +175,372 @@ func ProcessAfterRequest(req *teaproxy.Request, writer *teaproxy.ResponseWriter)
 		return true
 	}
 
-	cachePolicyMapLocker.RLock()
-	cache, found := cachePolicyMap[cacheConfig]
-	cachePolicyMapLocker.RUnlock()
-	if !found {
-		return true
+	// 缓存状态Header不需要保存
+	if cacheConfig.StatusHeader {
+		writer.Header().Del(CacheStatusHeader)
 	}
 
-	key := req.Format(cacheConfig.Key)
 	headerData := writer.HeaderData()
+	if len(headerData) == 0 {
+		return true
+	}
 	item := &Item{
 		Header: headerData,
 		Body:   writer.Body(),
 	}
-	if len(headerData) == 0 {
+	life, ok := prepareItem(item, writer.Header(), cacheConfig, time.Now())
+	if !ok {
 		return true
 	}
+
 	data := item.Encode()
 	if cacheConfig.MaxDataSize() > 0 && float64(len(data)) > cacheConfig.MaxDataSize() {
 		return true
 	}
-	err := cache.Write(key, data)
+	err := writeItem(cache, cacheKey(req, cacheConfig), req.Raw().Header, item, life)
+	if err != nil {
+		logs.Error(err)
+	}
+	return true
+}
+
+// 计算缓存Key，压缩和未压缩的内容分开存储
+func cacheKey(req *teaproxy.Request, cacheConfig *shared.CachePolicy) string {
+	key := req.Format(cacheConfig.Key)
+	encoding := req.CompressionEncoding()
+	if len(encoding) > 0 {
+		key += "@" + encoding
+	}
+	return key
+}
+
+// 根据Vary计算某个版本的Key
+func varyKey(key string, names []string, reqHeader http.Header) string {
+	buf := bytes.NewBuffer([]byte{})
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteString(":")
+		buf.WriteString(strings.TrimSpace(strings.Join(reqHeader[http.CanonicalHeaderKey(name)], ",")))
+		buf.WriteString("\n")
+	}
+	return key + "#" + stringutil.Md5(buf.String())
+}
+
+// 设置缓存状态，并记录到统计中
+func setCacheStatus(req *teaproxy.Request, writer *teaproxy.ResponseWriter, status CacheStatus, bytesSaved int64) {
+	req.SetCacheStatus(status)
+
+	cacheConfig := req.CachePolicy()
+	if cacheConfig == nil {
+		return
+	}
+	if cacheConfig.StatusHeader {
+		writer.Header().Set(CacheStatusHeader, status)
+	}
+	FindPolicyStat(cacheConfig.Filename).record(status, bytesSaved)
+}
+
+// 开启缓存写入
+func enableCacheWriting(req *teaproxy.Request, writer *teaproxy.ResponseWriter) {
+	if req.Method() != http.MethodGet {
+		return
+	}
+	req.SetCacheEnabled()
+	writer.SetBodyCopying(true)
+}
+
+// 读取缓存条目，如果有Vary，则读取和当前请求对应的版本
+func readItem(cache ManagerInterface, key string, reqHeader http.Header) (*Item, error) {
+	data, err := cache.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	item := &Item{}
+	if item.Decode(data) != nil {
+		return nil, ErrNotFound
+	}
+	if !item.IsVaryIndex() {
+		return item, nil
+	}
+
+	data, err = cache.Read(varyKey(key, item.Vary, reqHeader))
+	if err != nil {
+		return nil, err
+	}
+	item = &Item{}
+	if item.Decode(data) != nil || item.IsVaryIndex() {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+// 写入缓存条目，如果有Vary，则同时写入索引和对应的版本
+func writeItem(cache ManagerInterface, key string, reqHeader http.Header, item *Item, life time.Duration) error {
+	if len(item.Vary) == 0 {
+		return cache.WriteWithLife(key, item.Encode(), life)
+	}
+
+	index := &Item{
+		CreatedAt:            item.CreatedAt,
+		ExpiredAt:            item.ExpiredAt,
+		StaleWhileRevalidate: item.StaleWhileRevalidate,
+		StaleIfError:         item.StaleIfError,
+		Vary:                 item.Vary,
+	}
+	err := cache.WriteWithLife(key, index.Encode(), life)
+	if err != nil {
+		return err
+	}
+	return cache.WriteWithLife(varyKey(key, item.Vary, reqHeader), item.Encode(), life)
+}
+
+// 根据响应Header设置缓存条目的时间信息，并计算在缓存中保存的时间
+// 返回false表示不能缓存
+func prepareItem(item *Item, header http.Header, cacheConfig *shared.CachePolicy, now time.Time) (life time.Duration, ok bool) {
+	cc := parseCacheControl(header["Cache-Control"])
+	if cc.noStore || cc.private || len(header["Set-Cookie"]) > 0 {
+		return 0, false
+	}
+
+	varyNames, ok := parseVary(header)
+	if !ok {
+		return 0, false
+	}
+
+	ttl := freshLifetime(header, cc, cacheConfig.LifeDuration(), now)
+	hasValidators := len(header.Get("ETag")) > 0 || len(header.Get("Last-Modified")) > 0
+	if ttl <= 0 && !hasValidators {
+		return 0, false
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	swr := cc.staleWhileRevalidate
+	if swr < 0 {
+		swr = int64(cacheConfig.StaleWhileRevalidateDuration().Seconds())
+	}
+	sie := cc.staleIfError
+	if sie < 0 {
+		sie = int64(cacheConfig.StaleIfErrorDuration().Seconds())
+	}
+
+	item.CreatedAt = now.Unix() - responseAge(header)
+	item.ExpiredAt = now.Unix() + ttl
+	item.StaleWhileRevalidate = swr
+	item.StaleIfError = sie
+	item.Vary = varyNames
+
+	// 过期后仍然需要保留一段时间，用来使用旧内容或者用ETag、Last-Modified验证
+	extra := swr
+	if sie > extra {
+		extra = sie
+	}
+	if hasValidators {
+		policyLife := int64(cacheConfig.LifeDuration().Seconds())
+		if policyLife > extra {
+			extra = policyLife
+		}
+	}
+	seconds := ttl + extra
+	if seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// 输出缓存条目，返回是否已经输出
+func serveItem(req *teaproxy.Request, writer *teaproxy.ResponseWriter, item *Item, status CacheStatus, now int64) bool {
+	resp, err := item.Response()
+	if err != nil {
+		logs.Error(err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	bytesSaved := int64(len(item.Body))
+	if status == CacheStatusExpired {
+		bytesSaved = 0
+	}
+	setCacheStatus(req, writer, status, bytesSaved)
+
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			writer.Header().Add(k, v)
+		}
+	}
+	writer.Header().Set("Age", strconv.FormatInt(item.Age(now), 10))
+
+	if resp.StatusCode == http.StatusOK && isNotModified(req.Raw().Header, resp.Header) {
+		writer.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	writer.WriteHeader(resp.StatusCode)
+	if req.Method() != http.MethodHead {
+		io.Copy(writer, resp.Body)
+	}
+	return true
+}
+
+// 判断客户端的条件请求是否满足
+func isNotModified(reqHeader http.Header, respHeader http.Header) bool {
+	ifNoneMatch := reqHeader.Get("If-None-Match")
+	if len(ifNoneMatch) > 0 {
+		etag := strings.TrimPrefix(respHeader.Get("ETag"), "W/")
+		if len(etag) == 0 {
+			return false
+		}
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := reqHeader.Get("If-Modified-Since")
+	lastModified := respHeader.Get("Last-Modified")
+	if len(ifModifiedSince) > 0 && len(lastModified) > 0 {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(lastModified)
+		if err != nil {
+			return false
+		}
+		return !modified.After(since)
+	}
+	return false
+}
+
+// 为更新缓存构造条件请求的Header
+func revalidateHeader(item *Item) http.Header {
+	// 去除客户端自己的条件请求和缓存控制
+	header := http.Header{
+		"If-None-Match":     nil,
+		"If-Modified-Since": nil,
+		"If-Range":          nil,
+		"Range":             nil,
+		"Cache-Control":     nil,
+		"Pragma":            nil,
+	}
+	resp, err := item.Response()
+	if err != nil {
+		return header
+	}
+	resp.Body.Close()
+
+	if etag := resp.Header.Get("ETag"); len(etag) > 0 {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); len(lastModified) > 0 {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	return header
+}
+
+// 同步更新过期的缓存，返回是否已经输出
+func revalidate(req *teaproxy.Request, writer *teaproxy.ResponseWriter, cache ManagerInterface, key string, item *Item, now int64) bool {
+	subReq := req.Fork(revalidateHeader(item))
+	recorder := newResponseRecorder()
+	subWriter := teaproxy.NewResponseWriter(recorder)
+	err := subReq.Execute(subWriter)
+	statusCode := subWriter.StatusCode()
+
+	// 没有修改，使用更新后的缓存
+	if err == nil && statusCode == http.StatusNotModified {
+		newItem, err := readItem(cache, key, req.Raw().Header)
+		if err == nil {
+			item = newItem
+		}
+		return serveItem(req, writer, item, CacheStatusExpired, time.Now().Unix())
+	}
+
+	// 源站出错时使用旧内容
+	if (err != nil || statusCode >= http.StatusInternalServerError) && item.CanServeIfError(now) {
+		if err != nil {
+			logs.Error(err)
+		}
+		return serveItem(req, writer, item, CacheStatusStale, now)
+	}
+
 	if err != nil {
 		logs.Error(err)
+		return false
+	}
+
+	setCacheStatus(req, writer, CacheStatusExpired, 0)
+	for k, vs := range recorder.header {
+		writer.Header()[k] = vs
 	}
+	writer.WriteHeader(statusCode)
+	writer.Write(recorder.body.Bytes())
 	return true
 }
+
+// 在后台更新过期的缓存，同一个Key同时只会有一个更新请求
+func revalidateInBackground(req *teaproxy.Request, key string, item *Item) {
+	revalidatingKeysLocker.Lock()
+	if revalidatingKeys[key] {
+		revalidatingKeysLocker.Unlock()
+		return
+	}
+	revalidatingKeys[key] = true
+	revalidatingKeysLocker.Unlock()
+
+	subReq := req.Fork(revalidateHeader(item))
+	go func() {
+		defer func() {
+			revalidatingKeysLocker.Lock()
+			delete(revalidatingKeys, key)
+			revalidatingKeysLocker.Unlock()
+		}()
+
+		subWriter := teaproxy.NewResponseWriter(newResponseRecorder())
+		err := subReq.Execute(subWriter)
+		if err != nil {
+			logs.Error(err)
+		}
+	}()
+}
+
+// 源站返回304时更新缓存条目的有效期和Header
+func refreshItem(req *teaproxy.Request, writer *teaproxy.ResponseWriter, cache ManagerInterface, cacheConfig *shared.CachePolicy) {
+	key := cacheKey(req, cacheConfig)
+	reqHeader := req.Raw().Header
+	item, err := readItem(cache, key, reqHeader)
+	if err != nil {
+		return
+	}
+	resp, err := item.Response()
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	header := resp.Header
+	for _, name := range revalidateHeaders {
+		values, found := writer.Header()[name]
+		if found {
+			header[name] = values
+		}
+	}
+	header.Del("Age")
+
+	newItem := &Item{
+		Header: encodeItemHeader(resp.StatusCode, header),
+		Body:   item.Body,
+	}
+	life, ok := prepareItem(newItem, header, cacheConfig, time.Now())
+	if !ok {
+		cache.Delete(key)
+		return
+	}
+	err = writeItem(cache, key, reqHeader, newItem, life)
+	if err != nil {
+		logs.Error(err)
+	}
+}
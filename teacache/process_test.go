@@ -0,0 +1,217 @@
+package teacache
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/TeaWeb/code/teaproxy"
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcess_RevalidateInBackground(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	locker := sync.Mutex{}
+	hits := 0
+	conditions := []string{}
+	backend := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		locker.Lock()
+		hits++
+		hit := hits
+		conditions = append(conditions, req.Header.Get("If-None-Match"))
+		locker.Unlock()
+
+		if hit == 1 {
+			// 立即过期，但可以在后台更新
+			writer.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+			writer.Header().Set("ETag", `"v1"`)
+			writer.Write([]byte("v1"))
+			return
+		}
+		writer.Header().Set("Cache-Control", "max-age=60")
+		writer.Header().Set("ETag", `"v2"`)
+		writer.Write([]byte("v2"))
+	}))
+	defer backend.Close()
+
+	server := teaconfigs.NewServerConfig()
+	backendConfig := teaconfigs.NewBackendConfig()
+	backendConfig.Address = strings.TrimPrefix(backend.URL, "http://")
+	server.AddBackend(backendConfig)
+	listener, address := testCacheProxy(t, server)
+	defer listener.Shutdown()
+
+	{
+		status, body := testCacheGet(t, address, "/hello")
+		a.IsTrue(status == CacheStatusMiss)
+		a.IsTrue(body == "v1")
+	}
+
+	// 使用旧内容，同时在后台用条件请求更新缓存
+	{
+		status, body := testCacheGet(t, address, "/hello")
+		a.IsTrue(status == CacheStatusStale)
+		a.IsTrue(body == "v1")
+	}
+
+	// 等待后台更新完成
+	status, body := "", ""
+	for i := 0; i < 50; i++ {
+		status, body = testCacheGet(t, address, "/hello")
+		if status == CacheStatusHit {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	a.IsTrue(status == CacheStatusHit)
+	a.IsTrue(body == "v2")
+
+	locker.Lock()
+	defer locker.Unlock()
+	a.IsTrue(hits == 2)
+	a.IsTrue(conditions[0] == "")
+	a.IsTrue(conditions[1] == `"v1"`)
+}
+
+func TestProcess_ErrorPage(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	locker := sync.Mutex{}
+	hits := map[string]int{}
+	backend := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		locker.Lock()
+		hits[req.URL.Path]++
+		locker.Unlock()
+
+		writer.Header().Set("Cache-Control", "max-age=60")
+		writer.Write([]byte("error page"))
+	}))
+	defer backend.Close()
+
+	// 无法连接的地址
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddress := ln.Addr().String()
+	ln.Close()
+
+	server := teaconfigs.NewServerConfig()
+	backendConfig := teaconfigs.NewBackendConfig()
+	backendConfig.Address = strings.TrimPrefix(backend.URL, "http://")
+	server.AddBackend(backendConfig)
+
+	location := teaconfigs.NewLocation()
+	location.On = true
+	location.Pattern = "/fail"
+	deadBackend := teaconfigs.NewBackendConfig()
+	deadBackend.Address = deadAddress
+	location.AddBackend(deadBackend)
+	a.IsNil(location.ValidateBackends())
+	server.AddLocation(location)
+
+	page := teaconfigs.NewErrorPageConfig()
+	page.Status = []string{"502"}
+	page.Type = teaconfigs.ErrorPageTypeURL
+	page.URL = "/50x.html"
+	server.AddErrorPage(page)
+
+	listener, address := testCacheProxy(t, server)
+	defer listener.Shutdown()
+
+	// 错误页面不能写入出错的URI的缓存
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get("http://" + address + "/fail")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		a.IsNil(err)
+		a.IsTrue(resp.StatusCode == http.StatusBadGateway)
+		a.IsTrue(string(data) == "error page")
+		a.IsTrue(resp.Header.Get(CacheStatusHeader) == CacheStatusMiss)
+	}
+
+	cachePolicyMapLocker.RLock()
+	cache := cachePolicyMap[server.CachePolicyObject()]
+	cachePolicyMapLocker.RUnlock()
+	a.IsNotNil(cache)
+	_, err = cache.Read("http://" + address + "/fail")
+	a.IsTrue(err == ErrNotFound)
+
+	locker.Lock()
+	defer locker.Unlock()
+	a.IsTrue(hits["/fail"] == 0)
+	a.IsTrue(hits["/50x.html"] == 2)
+}
+
+// 为代理服务开启内存缓存并启动，返回监听服务和地址
+func testCacheProxy(t *testing.T, server *teaconfigs.ServerConfig) (*teaproxy.Listener, string) {
+	policy := shared.NewCachePolicy()
+	policy.On = true
+	policy.Type = "memory"
+	policy.Key = "${scheme}://${host}${requestURI}"
+	policy.Life = "1h"
+	policy.Capacity = "1m"
+	policy.StatusHeader = true
+	err := policy.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer policy.Delete()
+
+	server.CacheOn = true
+	server.CachePolicy = policy.Filename
+	err = server.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	listener := teaproxy.NewListener(&teaconfigs.ListenerConfig{
+		Key:     "http://" + address,
+		Address: address,
+		Http:    true,
+		Servers: []*teaconfigs.ServerConfig{server},
+	})
+	go listener.Start()
+
+	// 等待启动
+	for i := 0; i < 20; i++ {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return listener, address
+}
+
+// 发送请求，返回缓存状态和内容
+func testCacheGet(t *testing.T, address string, path string) (status string, body string) {
+	resp, err := http.Get("http://" + address + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get(CacheStatusHeader), string(data)
+}
@@ -4,10 +4,22 @@ import (
 	"fmt"
 	"github.com/go-redis/redis"
 	"github.com/iwind/TeaGo/maps"
+	"strings"
 	"time"
 )
 
-// 内存缓存管理器
+// Redis中Key的前缀
+const redisKeyPrefix = "TEA_CACHE_"
+
+// 缓存策略在Redis中Key的前缀，不同的缓存策略可能使用同一个Redis
+func redisPolicyKeyPrefix(filename string) string {
+	if len(filename) == 0 {
+		return redisKeyPrefix
+	}
+	return redisKeyPrefix + filename + "_"
+}
+
+// Redis缓存管理器
 type RedisManager struct {
 	Capacity float64       // 容量
 	Life     time.Duration // 有效期
@@ -18,6 +30,8 @@ type RedisManager struct {
 	Password string
 	Sock     string
 
+	Prefix string // Key的前缀，为空时使用redisKeyPrefix
+
 	client *redis.Client
 }
 
@@ -57,14 +71,117 @@ func (this *RedisManager) SetOptions(options map[string]interface{}) {
 }
 
 func (this *RedisManager) Write(key string, data []byte) error {
-	cmd := this.client.Set("TEA_CACHE_"+key, string(data), this.Life)
+	return this.WriteWithLife(key, data, this.Life)
+}
+
+// 使用指定的有效期写入
+func (this *RedisManager) WriteWithLife(key string, data []byte, life time.Duration) error {
+	if life <= 0 {
+		life = this.Life
+	}
+	cmd := this.client.Set(this.keyPrefix()+key, string(data), life)
 	return cmd.Err()
 }
 
 func (this *RedisManager) Read(key string) (data []byte, err error) {
-	cmd := this.client.Get("TEA_CACHE_" + key)
+	cmd := this.client.Get(this.keyPrefix() + key)
 	if cmd.Err() != nil {
+		if cmd.Err() == redis.Nil {
+			return nil, ErrNotFound
+		}
 		return nil, cmd.Err()
 	}
 	return []byte(cmd.Val()), nil
 }
+
+// 删除
+func (this *RedisManager) Delete(key string) error {
+	return this.client.Del(this.keyPrefix() + key).Err()
+}
+
+// 按规则删除
+func (this *RedisManager) Purge(pattern string) (count int, err error) {
+	if !hasWildcard(pattern) {
+		n, err := this.client.Del(this.keyPrefix() + pattern).Result()
+		return int(n), err
+	}
+
+	err = this.scan(redisEscapePattern(this.keyPrefix()+pattern), func(keys []string) error {
+		n, err := this.client.Del(keys...).Result()
+		count += int(n)
+		return err
+	})
+	return
+}
+
+// 清除所有缓存
+func (this *RedisManager) Clean() error {
+	return this.scan(redisEscapePattern(this.keyPrefix())+"*", func(keys []string) error {
+		return this.client.Del(keys...).Err()
+	})
+}
+
+// 统计
+func (this *RedisManager) Stat() (count int, size int64, err error) {
+	err = this.scan(redisEscapePattern(this.keyPrefix())+"*", func(keys []string) error {
+		pipe := this.client.Pipeline()
+		cmds := []*redis.IntCmd{}
+		for _, key := range keys {
+			cmds = append(cmds, pipe.StrLen(key))
+		}
+		_, err := pipe.Exec()
+		if err != nil {
+			return err
+		}
+		count += len(keys)
+		for _, cmd := range cmds {
+			size += cmd.Val()
+		}
+		return nil
+	})
+	return
+}
+
+// 关闭连接
+func (this *RedisManager) Close() error {
+	if this.client == nil {
+		return nil
+	}
+	return this.client.Close()
+}
+
+// Key的前缀
+func (this *RedisManager) keyPrefix() string {
+	if len(this.Prefix) == 0 {
+		return redisKeyPrefix
+	}
+	return this.Prefix
+}
+
+// 遍历匹配的Key
+func (this *RedisManager) scan(match string, f func(keys []string) error) error {
+	cursor := uint64(0)
+	for {
+		keys, nextCursor, err := this.client.Scan(cursor, match, 1000).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			err = f(keys)
+			if err != nil {
+				return err
+			}
+		}
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// 转换为Redis的匹配规则，只保留 * 和 ? 通配符
+func redisEscapePattern(pattern string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "[", "\\[", "]", "\\]", "^", "\\^")
+	return replacer.Replace(pattern)
+}
@@ -1,6 +1,7 @@
 package teacache
 
 import (
+	"github.com/iwind/TeaGo/assert"
 	"testing"
 	"time"
 )
@@ -20,3 +21,14 @@ func TestRedisManager(t *testing.T) {
 		t.Log("read:", string(r))
 	}
 }
+
+func TestRedisPolicyKeyPrefix(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsTrue(redisPolicyKeyPrefix("") == "TEA_CACHE_")
+	a.IsTrue(redisPolicyKeyPrefix("cache.policy.abc.conf") == "TEA_CACHE_cache.policy.abc.conf_")
+
+	manager := NewRedisManager()
+	a.IsTrue(manager.keyPrefix() == "TEA_CACHE_")
+	manager.Prefix = redisPolicyKeyPrefix("cache.policy.abc.conf")
+	a.IsTrue(manager.keyPrefix() == "TEA_CACHE_cache.policy.abc.conf_")
+}
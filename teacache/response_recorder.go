@@ -0,0 +1,38 @@
+package teacache
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// 在内存中记录响应内容，用于更新缓存的子请求
+type responseRecorder struct {
+	header     http.Header
+	statusCode int
+	body       *bytes.Buffer
+}
+
+// 获取新对象
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{
+		header: http.Header{},
+		body:   bytes.NewBuffer([]byte{}),
+	}
+}
+
+func (this *responseRecorder) Header() http.Header {
+	return this.header
+}
+
+func (this *responseRecorder) Write(data []byte) (int, error) {
+	if this.statusCode == 0 {
+		this.statusCode = http.StatusOK
+	}
+	return this.body.Write(data)
+}
+
+func (this *responseRecorder) WriteHeader(statusCode int) {
+	if this.statusCode == 0 {
+		this.statusCode = statusCode
+	}
+}
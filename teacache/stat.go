@@ -0,0 +1,100 @@
+package teacache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// 缓存状态
+type CacheStatus = string
+
+const (
+	CacheStatusHit     CacheStatus = "HIT"     // 命中
+	CacheStatusMiss    CacheStatus = "MISS"    // 未命中
+	CacheStatusBypass  CacheStatus = "BYPASS"  // 跳过缓存
+	CacheStatusExpired CacheStatus = "EXPIRED" // 已过期，从源站更新
+	CacheStatusStale   CacheStatus = "STALE"   // 已过期，使用旧内容
+)
+
+// 缓存状态Header
+const CacheStatusHeader = "X-Cache"
+
+var policyStatMap = map[string]*PolicyStat{} // filename => stat
+var policyStatMapLocker = sync.Mutex{}
+
+// 缓存策略统计，从启动时开始计算
+type PolicyStat struct {
+	hits       int64
+	misses     int64
+	bytesSaved int64
+	evictions  int64
+}
+
+// 查找某个缓存策略的统计，如果不存在则创建
+func FindPolicyStat(filename string) *PolicyStat {
+	policyStatMapLocker.Lock()
+	defer policyStatMapLocker.Unlock()
+
+	stat, found := policyStatMap[filename]
+	if !found {
+		stat = &PolicyStat{}
+		policyStatMap[filename] = stat
+	}
+	return stat
+}
+
+// 命中次数
+func (this *PolicyStat) Hits() int64 {
+	return atomic.LoadInt64(&this.hits)
+}
+
+// 未命中次数
+func (this *PolicyStat) Misses() int64 {
+	return atomic.LoadInt64(&this.misses)
+}
+
+// 节省的字节数，即从缓存中直接输出的内容长度
+func (this *PolicyStat) BytesSaved() int64 {
+	return atomic.LoadInt64(&this.bytesSaved)
+}
+
+// 因为容量不足而被淘汰的条目数，不包括过期删除的条目
+func (this *PolicyStat) Evictions() int64 {
+	return atomic.LoadInt64(&this.evictions)
+}
+
+// 命中率，范围为0-1
+func (this *PolicyStat) HitRatio() float64 {
+	hits := this.Hits()
+	total := hits + this.Misses()
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// 重置
+func (this *PolicyStat) Reset() {
+	atomic.StoreInt64(&this.hits, 0)
+	atomic.StoreInt64(&this.misses, 0)
+	atomic.StoreInt64(&this.bytesSaved, 0)
+	atomic.StoreInt64(&this.evictions, 0)
+}
+
+// 记录缓存状态
+func (this *PolicyStat) record(status CacheStatus, bytesSaved int64) {
+	switch status {
+	case CacheStatusHit, CacheStatusStale:
+		atomic.AddInt64(&this.hits, 1)
+		atomic.AddInt64(&this.bytesSaved, bytesSaved)
+	case CacheStatusMiss, CacheStatusExpired:
+		atomic.AddInt64(&this.misses, 1)
+	}
+}
+
+// 记录淘汰的条目数
+func (this *PolicyStat) evict(count int) {
+	if count > 0 {
+		atomic.AddInt64(&this.evictions, int64(count))
+	}
+}
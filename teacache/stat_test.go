@@ -0,0 +1,30 @@
+package teacache
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestPolicyStat(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	stat := FindPolicyStat("test.policy.conf")
+	a.IsTrue(stat == FindPolicyStat("test.policy.conf"))
+	a.IsTrue(stat.HitRatio() == 0)
+
+	stat.record(CacheStatusHit, 100)
+	stat.record(CacheStatusStale, 50)
+	stat.record(CacheStatusMiss, 0)
+	stat.record(CacheStatusExpired, 0)
+	stat.record(CacheStatusBypass, 0)
+	stat.evict(3)
+
+	a.IsTrue(stat.Hits() == 2)
+	a.IsTrue(stat.Misses() == 2)
+	a.IsTrue(stat.BytesSaved() == 150)
+	a.IsTrue(stat.Evictions() == 3)
+	a.IsTrue(stat.HitRatio() == 0.5)
+
+	stat.Reset()
+	a.IsTrue(stat.Hits() == 0)
+}
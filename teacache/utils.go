@@ -1,6 +1,9 @@
 package teacache
 
-import "github.com/iwind/TeaGo/maps"
+import (
+	"github.com/iwind/TeaGo/maps"
+	"strings"
+)
 
 // 所有的缓存配置
 func AllCacheTypes() []maps.Map {
@@ -29,3 +32,40 @@ func TypeName(typeCode string) string {
 	}
 	return ""
 }
+
+// 判断Key是否匹配规则
+// 规则中的 * 匹配任意个字符，? 匹配单个字符，没有通配符时只匹配相同的Key
+func MatchKey(pattern string, key string) bool {
+	if !hasWildcard(pattern) {
+		return pattern == key
+	}
+
+	// 使用回溯的方式匹配 *
+	p, k := 0, 0
+	starP, starK := -1, 0
+	for k < len(key) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]) {
+			p++
+			k++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			starP = p
+			starK = k
+			p++
+		} else if starP >= 0 {
+			p = starP + 1
+			starK++
+			k = starK
+		} else {
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// 判断规则中是否有通配符
+func hasWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
@@ -0,0 +1,20 @@
+package teacache
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestMatchKey(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	a.IsTrue(MatchKey("https://example.com/", "https://example.com/"))
+	a.IsFalse(MatchKey("https://example.com/", "https://example.com/a"))
+	a.IsTrue(MatchKey("https://example.com/*", "https://example.com/a/b/c"))
+	a.IsTrue(MatchKey("*", "https://example.com/a/b/c"))
+	a.IsTrue(MatchKey("https://*.example.com/*.css", "https://www.example.com/static/a.css"))
+	a.IsFalse(MatchKey("https://*.example.com/*.css", "https://www.example.com/static/a.js"))
+	a.IsTrue(MatchKey("/a?c", "/abc"))
+	a.IsFalse(MatchKey("/a?c", "/abbc"))
+	a.IsTrue(MatchKey("/a**c", "/ac"))
+}
@@ -5,6 +5,19 @@ import (
 	"errors"
 )
 
+// 日志存储目标
+type AccessLogTarget = string
+
+const (
+	AccessLogTargetFile   AccessLogTarget = "file"
+	AccessLogTargetStdout AccessLogTarget = "stdout"
+	AccessLogTargetPipe   AccessLogTarget = "pipe"
+	AccessLogTargetSyslog AccessLogTarget = "syslog"
+)
+
+// 默认的日志格式
+const AccessLogDefaultFormat = "${remoteAddr} - [${timeLocal}] \"${request}\" ${status} ${bodyBytesSent} \"${http.Referer}\" \"${http.UserAgent}\""
+
 // 日志配置
 // 参考 http://nginx.org/en/docs/http/ngx_http_log_module.html#access_log
 type AccessLogConfig struct {
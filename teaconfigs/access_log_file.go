@@ -4,6 +4,6 @@ package teaconfigs
 type AccessLogFileConfig struct {
 	Path   string `yaml:"path"`
 	Format string `yaml:"format"`
-	Buffer string `yaml:"buffer"` // @TODO
-	Flush  string `yaml:"flush"`  // @TODO
+	Buffer string `yaml:"buffer"` // 缓冲区尺寸，比如 64k
+	Flush  string `yaml:"flush"`  // 刷新间隔，比如 5s
 }
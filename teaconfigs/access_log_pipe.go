@@ -2,8 +2,8 @@ package teaconfigs
 
 // 日志pipe配置
 type AccessLogPipeConfig struct {
-	Path   string `yaml:"path"`
-	Format string `yaml:"format"` // @TODO
-	Buffer string `yaml:"buffer"` // @TODO
-	Flush  string `yaml:"flush"`  // @TODO
+	Path   string `yaml:"path"`   // 接收日志的命令，可以带参数，日志会写入命令的标准输入
+	Format string `yaml:"format"` // 日志格式
+	Buffer string `yaml:"buffer"` // 缓冲区尺寸，比如 64k
+	Flush  string `yaml:"flush"`  // 刷新间隔，比如 5s
 }
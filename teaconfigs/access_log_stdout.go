@@ -3,6 +3,6 @@ package teaconfigs
 // 日志stdout配置
 type AccessLogStdoutConfig struct {
 	Format string `yaml:"format"`
-	Buffer string `yaml:"buffer"` // @TODO
-	Flush  string `yaml:"flush"`  // @TODO
+	Buffer string `yaml:"buffer"` // 缓冲区尺寸，比如 64k
+	Flush  string `yaml:"flush"`  // 刷新间隔，比如 5s
 }
@@ -0,0 +1,12 @@
+package teaconfigs
+
+// 日志syslog配置
+// 参考：https://tools.ietf.org/html/rfc5424
+type AccessLogSyslogConfig struct {
+	Network  string `yaml:"network"`  // 网络协议：udp, tcp, unix
+	Addr     string `yaml:"addr"`     // 地址，比如 127.0.0.1:514 或 /dev/log
+	Facility string `yaml:"facility"` // 设施：kern, user, daemon, local0 ... local7 等，默认为local7
+	Severity string `yaml:"severity"` // 级别：emerg, alert, crit, err, warning, notice, info, debug，默认为info
+	AppName  string `yaml:"appName"`  // 应用名称，默认为teaweb
+	Format   string `yaml:"format"`   // 日志格式
+}
@@ -0,0 +1,64 @@
+package teaconfigs
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Let's Encrypt的ACME服务地址
+const ACMEDefaultDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+// ACME自动申请证书配置
+type ACMEConfig struct {
+	On            bool   `yaml:"on" json:"on"`                       // 是否开启
+	DirectoryURL  string `yaml:"directoryURL" json:"directoryURL"`   // ACME服务地址，为空表示使用Let's Encrypt
+	Email         string `yaml:"email" json:"email"`                 // 联系邮箱
+	RenewBefore   int    `yaml:"renewBefore" json:"renewBefore"`     // 过期前多少天更新证书，默认为30天
+	CACertificate string `yaml:"caCertificate" json:"caCertificate"` // 用来校验ACME服务的CA证书文件，用于pebble之类的测试服务
+}
+
+// 获取新对象
+func NewACMEConfig() *ACMEConfig {
+	return &ACMEConfig{
+		On:          true,
+		RenewBefore: 30,
+	}
+}
+
+// 校验
+func (this *ACMEConfig) Validate() error {
+	if len(this.DirectoryURL) > 0 {
+		u, err := url.Parse(this.DirectoryURL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return errors.New("invalid acme directory url '" + this.DirectoryURL + "'")
+		}
+	}
+	if len(this.Email) > 0 && !strings.Contains(this.Email, "@") {
+		return errors.New("invalid acme email '" + this.Email + "'")
+	}
+	if this.RenewBefore <= 0 {
+		this.RenewBefore = 30
+	}
+	return nil
+}
+
+// ACME服务地址
+func (this *ACMEConfig) Directory() string {
+	if len(this.DirectoryURL) == 0 {
+		return ACMEDefaultDirectoryURL
+	}
+	return this.DirectoryURL
+}
+
+// 过期前多久更新证书
+func (this *ACMEConfig) RenewBeforeDuration() time.Duration {
+	if this.RenewBefore <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(this.RenewBefore) * 24 * time.Hour
+}
@@ -1,7 +1,7 @@
 package api
 
 import (
-	"github.com/iwind/TeaGo/lists"
+	"github.com/TeaWeb/code/teautils"
 	"sync"
 	"time"
 )
@@ -70,17 +70,17 @@ type APIAccessPolicy struct {
 	} `yaml:"access" json:"access"` // 访问控制
 }
 
-// 检查权限
+// 检查权限，IP支持单个IP、CIDR和all
 func (this *APIAccessPolicy) AllowAccess(ip string) bool {
 	// Access
 	if this.Access.On {
 		// deny
-		if this.Access.DenyOn && lists.Contains(this.Access.Deny, ip) {
+		if this.Access.DenyOn && teautils.MatchIP(this.Access.Deny, ip) {
 			return false
 		}
 
 		// allow
-		if this.Access.AllowOn && !lists.Contains(this.Access.Allow, ip) {
+		if this.Access.AllowOn && !teautils.MatchIP(this.Access.Allow, ip) {
 			return false
 		}
 	}
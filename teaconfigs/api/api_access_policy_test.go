@@ -141,3 +141,23 @@ func TestAPIAccessPolicyPerformance(t *testing.T) {
 
 	t.Log(int(float64(times) / time.Since(before).Seconds()))
 }
+
+func TestAPIAccessPolicy_AllowAccess(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	p := APIAccessPolicy{}
+	a.IsTrue(p.AllowAccess("192.168.1.100"))
+
+	p.Access.On = true
+	p.Access.DenyOn = true
+	p.Access.Deny = []string{"192.168.1.0/24"}
+	a.IsFalse(p.AllowAccess("192.168.1.100"))
+	a.IsTrue(p.AllowAccess("192.168.2.100"))
+
+	p.Access.DenyOn = false
+	p.Access.AllowOn = true
+	p.Access.Allow = []string{"10.0.0.0/8", "127.0.0.1"}
+	a.IsTrue(p.AllowAccess("10.1.1.1"))
+	a.IsTrue(p.AllowAccess("127.0.0.1"))
+	a.IsFalse(p.AllowAccess("192.168.2.100"))
+}
@@ -0,0 +1,119 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/utils/string"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 直接返回给客户端的响应，用于异步处理和只记录日志
+type DirectResponseConfig struct {
+	Status      int    `yaml:"status" json:"status"`           // 状态码
+	ContentType string `yaml:"contentType" json:"contentType"` // 内容类型，默认为text/plain
+	Body        string `yaml:"body" json:"body"`               // 内容，支持请求变量，比如${requestId}
+}
+
+// 校验
+func (this *DirectResponseConfig) Validate() error {
+	if this.Status != 0 && (this.Status < 100 || this.Status > 999) {
+		return errors.New("invalid response status '" + strconv.Itoa(this.Status) + "'")
+	}
+	return nil
+}
+
+// 状态码，没有设置时使用默认状态码
+func (this *DirectResponseConfig) StatusCode(defaultStatus int) int {
+	if this == nil || this.Status == 0 {
+		return defaultStatus
+	}
+	return this.Status
+}
+
+// 异步处理设置
+// 请求会立即得到响应，然后在后台通过队列转发到后端服务，失败时按设置重试
+type AsyncConfig struct {
+	Response      *DirectResponseConfig `yaml:"response" json:"response"`           // 立即返回的响应，默认返回202
+	QueueSize     int                   `yaml:"queueSize" json:"queueSize"`         // 队列长度，默认为1024，队列满时返回503
+	Concurrency   int                   `yaml:"concurrency" json:"concurrency"`     // 同时转发的请求数，默认为8
+	MaxTries      int                   `yaml:"maxTries" json:"maxTries"`           // 最多尝试次数，默认为3
+	RetryInterval string                `yaml:"retryInterval" json:"retryInterval"` // 重试间隔，默认为1s
+	MaxBodySize   string                `yaml:"maxBodySize" json:"maxBodySize"`     // 请求体最大尺寸，默认为1m，超出时返回413
+
+	retryInterval time.Duration
+	maxBodySize   int64
+}
+
+// 获取新对象
+func NewAsyncConfig() *AsyncConfig {
+	return &AsyncConfig{
+		Response: &DirectResponseConfig{
+			Status: http.StatusAccepted,
+		},
+		QueueSize:     1024,
+		Concurrency:   8,
+		MaxTries:      3,
+		RetryInterval: "1s",
+		MaxBodySize:   "1m",
+	}
+}
+
+// 校验
+func (this *AsyncConfig) Validate() error {
+	if this.Response != nil {
+		err := this.Response.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.QueueSize < 0 {
+		return errors.New("queueSize should not be less than 0")
+	}
+	if this.QueueSize == 0 {
+		this.QueueSize = 1024
+	}
+	if this.Concurrency < 0 {
+		return errors.New("concurrency should not be less than 0")
+	}
+	if this.Concurrency == 0 {
+		this.Concurrency = 8
+	}
+	if this.MaxTries < 0 {
+		return errors.New("maxTries should not be less than 0")
+	}
+	if this.MaxTries == 0 {
+		this.MaxTries = 3
+	}
+
+	this.retryInterval = 1 * time.Second
+	if len(this.RetryInterval) > 0 {
+		interval, err := time.ParseDuration(this.RetryInterval)
+		if err != nil || interval < 0 {
+			return errors.New("invalid retry interval '" + this.RetryInterval + "'")
+		}
+		this.retryInterval = interval
+	}
+
+	this.maxBodySize = 1024 * 1024
+	if len(this.MaxBodySize) > 0 {
+		size, err := stringutil.ParseFileSize(this.MaxBodySize)
+		if err != nil || size < 0 {
+			return errors.New("invalid max body size '" + this.MaxBodySize + "'")
+		}
+		this.maxBodySize = int64(size)
+	}
+
+	return nil
+}
+
+// 重试间隔
+func (this *AsyncConfig) RetryIntervalDuration() time.Duration {
+	return this.retryInterval
+}
+
+// 请求体最大尺寸
+func (this *AsyncConfig) MaxBodyBytes() int64 {
+	return this.maxBodySize
+}
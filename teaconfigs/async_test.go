@@ -0,0 +1,41 @@
+package teaconfigs
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAsyncConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		config := NewAsyncConfig()
+		a.IsNil(config.Validate())
+		a.IsTrue(config.Response.StatusCode(http.StatusOK) == http.StatusAccepted)
+		a.IsTrue(config.RetryIntervalDuration() == 1*time.Second)
+		a.IsTrue(config.MaxBodyBytes() == 1024*1024)
+	}
+
+	{
+		config := &AsyncConfig{}
+		a.IsNil(config.Validate())
+		a.IsTrue(config.QueueSize == 1024)
+		a.IsTrue(config.Concurrency == 8)
+		a.IsTrue(config.MaxTries == 3)
+		a.IsTrue(config.Response.StatusCode(http.StatusAccepted) == http.StatusAccepted)
+	}
+
+	{
+		config := NewAsyncConfig()
+		config.RetryInterval = "abc"
+		a.IsNotNil(config.Validate())
+	}
+
+	{
+		config := NewAsyncConfig()
+		config.Response.Status = 1000
+		a.IsNotNil(config.Validate())
+	}
+}
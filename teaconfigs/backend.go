@@ -1,6 +1,9 @@
 package teaconfigs
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"github.com/TeaWeb/code/teaconfigs/shared"
 	"github.com/iwind/TeaGo/utils/string"
 	"strings"
@@ -8,6 +11,9 @@ import (
 	"time"
 )
 
+// 计算平均响应时间时最新一次响应时间的权重
+const backendResponseTimeWeight = 0.3
+
 // 服务后端配置
 type BackendConfig struct {
 	shared.HeaderList `yaml:",inline"`
@@ -17,6 +23,7 @@ type BackendConfig struct {
 	Code         string    `yaml:"code" json:"code"`                             // 代号
 	Name         []string  `yaml:"name" json:"name"`                             // 域名 TODO
 	Address      string    `yaml:"address" json:"address"`                       // 地址
+	Scheme       string    `yaml:"scheme" json:"scheme"`                         // 协议：http, https，默认为http
 	Weight       uint      `yaml:"weight" json:"weight"`                         // 是否为备份
 	IsBackup     bool      `yaml:"backup" json:"isBackup"`                       // 超时时间
 	FailTimeout  string    `yaml:"failTimeout" json:"failTimeout"`               // 失败超时
@@ -27,9 +34,30 @@ type BackendConfig struct {
 	IsDown       bool      `yaml:"down" json:"isDown"`                           // 是否下线
 	DownTime     time.Time `yaml:"downTime,omitempty" json:"downTime,omitempty"` // 下线时间
 
-	failTimeoutDuration time.Duration
-	failsLocker         sync.Mutex
-	connsLocker         sync.Mutex
+	TLS   *BackendTLSConfig `yaml:"tls" json:"tls"`     // 使用https时的TLS配置
+	HTTP2 bool              `yaml:"http2" json:"http2"` // 是否使用HTTP/2连接后端服务，http后端使用h2c
+
+	ConnectTimeout    string `yaml:"connectTimeout" json:"connectTimeout"`       // 连接超时时间，为空表示使用failTimeout
+	ReadHeaderTimeout string `yaml:"readHeaderTimeout" json:"readHeaderTimeout"` // 读取响应Header的超时时间，为空表示不限
+	IdleTimeout       string `yaml:"idleTimeout" json:"idleTimeout"`             // 空闲连接超时时间，为空表示不限
+	Timeout           string `yaml:"timeout" json:"timeout"`                     // 整个请求的超时时间，包括所有的重试，为空表示15秒，0s表示不限，gRPC请求使用客户端的grpc-timeout
+
+	// 健康检查结果
+	CheckOk        bool      `yaml:"-" json:"checkOk"`        // 最近一次检查是否成功
+	CheckTime      time.Time `yaml:"-" json:"checkTime"`      // 最近一次检查时间
+	CheckError     string    `yaml:"-" json:"checkError"`     // 最近一次检查的错误信息
+	CheckSuccesses uint      `yaml:"-" json:"checkSuccesses"` // 连续成功次数
+	CheckFails     uint      `yaml:"-" json:"checkFails"`     // 连续失败次数
+
+	failTimeoutDuration       time.Duration
+	connectTimeoutDuration    time.Duration
+	readHeaderTimeoutDuration time.Duration
+	idleTimeoutDuration       time.Duration
+	timeoutDuration           time.Duration
+	connsLocker               sync.Mutex
+	responseTime              float64 // 平均响应时间（EWMA），单位为秒
+	responseTimeLocker        sync.Mutex
+	checkLocker               sync.Mutex // 用于失败次数、上下线状态和健康检查结果
 }
 
 // 获取新对象
@@ -47,10 +75,72 @@ func (this *BackendConfig) Validate() error {
 		this.failTimeoutDuration, _ = time.ParseDuration(this.FailTimeout)
 	}
 
+	// 超时时间
+	this.connectTimeoutDuration = this.failTimeoutDuration
+	if len(this.ConnectTimeout) > 0 {
+		duration, err := time.ParseDuration(this.ConnectTimeout)
+		if err != nil {
+			return errors.New("invalid connect timeout '" + this.ConnectTimeout + "'")
+		}
+		this.connectTimeoutDuration = duration
+	}
+	if this.connectTimeoutDuration <= 0 {
+		this.connectTimeoutDuration = 15 * time.Second
+	}
+
+	this.readHeaderTimeoutDuration = 0
+	if len(this.ReadHeaderTimeout) > 0 {
+		duration, err := time.ParseDuration(this.ReadHeaderTimeout)
+		if err != nil {
+			return errors.New("invalid read header timeout '" + this.ReadHeaderTimeout + "'")
+		}
+		this.readHeaderTimeoutDuration = duration
+	}
+
+	this.idleTimeoutDuration = 0
+	if len(this.IdleTimeout) > 0 {
+		duration, err := time.ParseDuration(this.IdleTimeout)
+		if err != nil {
+			return errors.New("invalid idle timeout '" + this.IdleTimeout + "'")
+		}
+		this.idleTimeoutDuration = duration
+	}
+
+	this.timeoutDuration = 15 * time.Second
+	if len(this.Timeout) > 0 {
+		duration, err := time.ParseDuration(this.Timeout)
+		if err != nil {
+			return errors.New("invalid timeout '" + this.Timeout + "'")
+		}
+		this.timeoutDuration = duration
+	}
+
+	// 协议
+	if len(this.Scheme) == 0 {
+		this.Scheme = BackendSchemeHTTP
+	}
+	if this.Scheme != BackendSchemeHTTP && this.Scheme != BackendSchemeHTTPS {
+		return errors.New("invalid backend scheme '" + this.Scheme + "'")
+	}
+
 	// 是否有端口
 	if strings.Index(this.Address, ":") == -1 {
-		// @TODO 如果是tls，则为443
-		this.Address += ":80"
+		if this.Scheme == BackendSchemeHTTPS {
+			this.Address += ":443"
+		} else {
+			this.Address += ":80"
+		}
+	}
+
+	// TLS
+	if this.Scheme == BackendSchemeHTTPS {
+		if this.TLS == nil {
+			this.TLS = NewBackendTLSConfig()
+		}
+		err := this.TLS.Validate()
+		if err != nil {
+			return err
+		}
 	}
 
 	// Headers
@@ -62,11 +152,53 @@ func (this *BackendConfig) Validate() error {
 	return nil
 }
 
+// 是否使用https
+func (this *BackendConfig) IsHTTPS() bool {
+	return this.Scheme == BackendSchemeHTTPS
+}
+
+// 连接后端服务的TLS配置，不使用https时返回nil
+func (this *BackendConfig) TLSConfig() *tls.Config {
+	if !this.IsHTTPS() || this.TLS == nil {
+		return nil
+	}
+	return this.TLS.TLSConfig()
+}
+
 // 超时时间
 func (this *BackendConfig) FailTimeoutDuration() time.Duration {
 	return this.failTimeoutDuration
 }
 
+// 连接超时时间
+func (this *BackendConfig) ConnectTimeoutDuration() time.Duration {
+	return this.connectTimeoutDuration
+}
+
+// 读取响应Header的超时时间，0表示不限
+func (this *BackendConfig) ReadHeaderTimeoutDuration() time.Duration {
+	return this.readHeaderTimeoutDuration
+}
+
+// 空闲连接超时时间，0表示不限
+func (this *BackendConfig) IdleTimeoutDuration() time.Duration {
+	return this.idleTimeoutDuration
+}
+
+// 整个请求的超时时间，0表示不限
+func (this *BackendConfig) TimeoutDuration() time.Duration {
+	return this.timeoutDuration
+}
+
+// 用来区分不同客户端配置的Key
+func (this *BackendConfig) ClientKey() string {
+	key := fmt.Sprintf("%d@%d@%d@%d@%t", this.connectTimeoutDuration, this.readHeaderTimeoutDuration, this.idleTimeoutDuration, this.MaxConns, this.HTTP2)
+	if this.IsHTTPS() && this.TLS != nil {
+		key += "@" + this.TLS.Key()
+	}
+	return key
+}
+
 // 候选对象代号
 func (this *BackendConfig) CandidateCodes() []string {
 	codes := []string{this.Id}
@@ -83,14 +215,50 @@ func (this *BackendConfig) CandidateWeight() uint {
 
 // 增加错误次数
 func (this *BackendConfig) IncreaseFails() uint {
-	this.failsLocker.Lock()
-	defer this.failsLocker.Unlock()
+	this.checkLocker.Lock()
+	defer this.checkLocker.Unlock()
 
 	this.CurrentFails ++
 
 	return this.CurrentFails
 }
 
+// 记录一次请求失败，超过最大失败次数时下线，返回上下线状态是否有变化
+func (this *BackendConfig) RecordFail() (changed bool) {
+	this.checkLocker.Lock()
+	defer this.checkLocker.Unlock()
+
+	this.CurrentFails ++
+	if this.MaxFails > 0 && this.CurrentFails >= this.MaxFails {
+		return this.setDown(true)
+	}
+	return false
+}
+
+// 清除失败次数，不改变上下线状态
+func (this *BackendConfig) ResetFails() {
+	this.checkLocker.Lock()
+	defer this.checkLocker.Unlock()
+
+	this.CurrentFails = 0
+}
+
+// 手动上线或者下线，返回上下线状态是否有变化
+func (this *BackendConfig) SetDown(isDown bool) (changed bool) {
+	this.checkLocker.Lock()
+	defer this.checkLocker.Unlock()
+
+	return this.setDown(isDown)
+}
+
+// 是否已下线
+func (this *BackendConfig) IsOffline() bool {
+	this.checkLocker.Lock()
+	defer this.checkLocker.Unlock()
+
+	return this.IsDown
+}
+
 // 增加连接数
 func (this *BackendConfig) IncreaseConn() {
 	this.connsLocker.Lock()
@@ -108,3 +276,92 @@ func (this *BackendConfig) DecreaseConn() {
 		this.CurrentConns --
 	}
 }
+
+// 候选对象当前连接数
+func (this *BackendConfig) CandidateConns() uint {
+	this.connsLocker.Lock()
+	defer this.connsLocker.Unlock()
+	return this.CurrentConns
+}
+
+// 记录一次响应时间，使用指数加权移动平均（EWMA）计算平均响应时间
+func (this *BackendConfig) RecordResponseTime(duration time.Duration) {
+	this.responseTimeLocker.Lock()
+	defer this.responseTimeLocker.Unlock()
+
+	seconds := duration.Seconds()
+	if this.responseTime == 0 {
+		this.responseTime = seconds
+	} else {
+		this.responseTime = this.responseTime*(1-backendResponseTimeWeight) + seconds*backendResponseTimeWeight
+	}
+}
+
+// 候选对象平均响应时间，单位为秒
+func (this *BackendConfig) CandidateResponseTime() float64 {
+	this.responseTimeLocker.Lock()
+	defer this.responseTimeLocker.Unlock()
+	return this.responseTime
+}
+
+// 从另外一个后端服务中复制运行时状态，用于重新加载配置时保留下线状态、失败次数和健康检查结果
+func (this *BackendConfig) CopyState(backend *BackendConfig) {
+	backend.checkLocker.Lock()
+	this.IsDown = backend.IsDown
+	this.DownTime = backend.DownTime
+	this.CurrentFails = backend.CurrentFails
+	this.CheckOk = backend.CheckOk
+	this.CheckTime = backend.CheckTime
+	this.CheckError = backend.CheckError
+	this.CheckSuccesses = backend.CheckSuccesses
+	this.CheckFails = backend.CheckFails
+	backend.checkLocker.Unlock()
+
+	responseTime := backend.CandidateResponseTime()
+	this.responseTimeLocker.Lock()
+	this.responseTime = responseTime
+	this.responseTimeLocker.Unlock()
+}
+
+// 记录健康检查结果，返回上下线状态是否有变化
+func (this *BackendConfig) RecordCheck(err error, healthyThreshold uint, unhealthyThreshold uint) (changed bool) {
+	this.checkLocker.Lock()
+	defer this.checkLocker.Unlock()
+
+	this.CheckTime = time.Now()
+	if err == nil {
+		this.CheckOk = true
+		this.CheckError = ""
+		this.CheckSuccesses ++
+		this.CheckFails = 0
+
+		if this.CheckSuccesses >= healthyThreshold {
+			return this.setDown(false)
+		}
+		return false
+	}
+
+	this.CheckOk = false
+	this.CheckError = err.Error()
+	this.CheckFails ++
+	this.CheckSuccesses = 0
+
+	if this.CheckFails >= unhealthyThreshold {
+		return this.setDown(true)
+	}
+	return false
+}
+
+// 改变上下线状态，调用者需要持有checkLocker
+func (this *BackendConfig) setDown(isDown bool) (changed bool) {
+	if this.IsDown == isDown {
+		return false
+	}
+	this.IsDown = isDown
+	if isDown {
+		this.DownTime = time.Now()
+	} else {
+		this.CurrentFails = 0
+	}
+	return true
+}
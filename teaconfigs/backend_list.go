@@ -28,12 +28,33 @@ type BackendListInterface interface {
 
 	// 设置调度算法
 	SetSchedulingConfig(scheduling *SchedulingConfig)
+
+	// 健康检查设置
+	HealthCheckConfig() *HealthCheckConfig
+
+	// 设置健康检查
+	SetHealthCheckConfig(healthCheck *HealthCheckConfig)
+
+	// 重试设置
+	RetryConfig() *RetryConfig
+
+	// 设置重试
+	SetRetryConfig(retry *RetryConfig)
+
+	// 是否开启gRPC模式
+	IsGRPC() bool
+
+	// 设置gRPC模式
+	SetGRPC(grpc bool)
 }
 
 // BackendList定义
 type BackendList struct {
-	Backends   []*BackendConfig  `yaml:"backends" json:"backends"`
-	Scheduling *SchedulingConfig `yaml:"scheduling" json:"scheduling"` // 调度算法选项
+	Backends    []*BackendConfig   `yaml:"backends" json:"backends"`
+	Scheduling  *SchedulingConfig  `yaml:"scheduling" json:"scheduling"`   // 调度算法选项
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck" json:"healthCheck"` // 健康检查设置
+	Retry       *RetryConfig       `yaml:"retry" json:"retry"`             // 重试和故障转移设置
+	GRPC        bool               `yaml:"grpc" json:"grpc"`               // gRPC模式，使用HTTP/2连接后端服务，并将错误转换为grpc-status
 
 	schedulingIsBackup bool
 	schedulingObject   scheduling.SchedulingInterface
@@ -49,6 +70,22 @@ func (this *BackendList) ValidateBackends() error {
 		}
 	}
 
+	// health check
+	if this.HealthCheck != nil {
+		err := this.HealthCheck.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// retry
+	if this.Retry != nil {
+		err := this.Retry.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
 	// scheduling
 	this.SetupScheduling(false)
 
@@ -122,6 +159,59 @@ func (this *BackendList) NextBackend(options maps.Map) *BackendConfig {
 	return candidate.(*BackendConfig)
 }
 
+// 取得下一个没有尝试过的后端服务，用于故障转移
+func (this *BackendList) NextRetryBackend(options maps.Map, triedBackends []*BackendConfig) *BackendConfig {
+	isTried := func(backend *BackendConfig) bool {
+		for _, tried := range triedBackends {
+			if tried == backend {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 先从调度算法中选择
+	for i := 0; i < len(this.Backends); i++ {
+		backend := this.NextBackend(options)
+		if backend == nil {
+			break
+		}
+		if !isTried(backend) {
+			return backend
+		}
+	}
+
+	// 调度算法没有选出新的后端服务时，按顺序查找可用的
+	for _, isBackup := range []bool{false, true} {
+		for _, backend := range this.Backends {
+			if backend.On && !backend.IsOffline() && backend.IsBackup == isBackup && !isTried(backend) {
+				return backend
+			}
+		}
+	}
+
+	return nil
+}
+
+// 从正在运行的列表中复制ID和地址都相同的后端服务的运行时状态，并重新设置调度算法
+func (this *BackendList) CopyBackendStates(backendList *BackendList) {
+	if backendList == nil {
+		return
+	}
+	copied := false
+	for _, backend := range this.Backends {
+		oldBackend := backendList.FindBackend(backend.Id)
+		if oldBackend == nil || oldBackend.Address != backend.Address {
+			continue
+		}
+		backend.CopyState(oldBackend)
+		copied = true
+	}
+	if copied {
+		this.SetupScheduling(false)
+	}
+}
+
 // 设置调度算法
 func (this *BackendList) SetupScheduling(isBackup bool) {
 	if !isBackup {
@@ -144,7 +234,7 @@ func (this *BackendList) SetupScheduling(isBackup bool) {
 	}
 
 	for _, backend := range this.Backends {
-		if backend.On && !backend.IsDown {
+		if backend.On && !backend.IsOffline() {
 			if isBackup && backend.IsBackup {
 				this.schedulingObject.Add(backend)
 			} else if !isBackup && !backend.IsBackup {
@@ -165,3 +255,33 @@ func (this *BackendList) SchedulingConfig() *SchedulingConfig {
 func (this *BackendList) SetSchedulingConfig(scheduling *SchedulingConfig) {
 	this.Scheduling = scheduling
 }
+
+// 健康检查设置
+func (this *BackendList) HealthCheckConfig() *HealthCheckConfig {
+	return this.HealthCheck
+}
+
+// 设置健康检查
+func (this *BackendList) SetHealthCheckConfig(healthCheck *HealthCheckConfig) {
+	this.HealthCheck = healthCheck
+}
+
+// 重试设置
+func (this *BackendList) RetryConfig() *RetryConfig {
+	return this.Retry
+}
+
+// 设置重试
+func (this *BackendList) SetRetryConfig(retry *RetryConfig) {
+	this.Retry = retry
+}
+
+// 是否开启gRPC模式
+func (this *BackendList) IsGRPC() bool {
+	return this.GRPC
+}
+
+// 设置gRPC模式
+func (this *BackendList) SetGRPC(grpc bool) {
+	this.GRPC = grpc
+}
@@ -0,0 +1,110 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/maps"
+	"net/http"
+	"time"
+)
+
+// 重试的错误类型
+type RetryErrorType = string
+
+const (
+	RetryErrorConnect RetryErrorType = "connect" // 连接失败
+	RetryErrorTimeout RetryErrorType = "timeout" // 超时
+	RetryErrorOther   RetryErrorType = "error"   // 其他错误，比如连接被重置
+)
+
+// 所有的重试错误类型
+func AllRetryErrorTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name": "连接失败",
+			"code": RetryErrorConnect,
+		},
+		{
+			"name": "超时",
+			"code": RetryErrorTimeout,
+		},
+		{
+			"name": "其他错误",
+			"code": RetryErrorOther,
+		},
+	}
+}
+
+// 重试和故障转移配置
+type RetryConfig struct {
+	On            bool     `yaml:"on" json:"on"`                       // 是否开启
+	MaxTries      int      `yaml:"maxTries" json:"maxTries"`           // 最多尝试次数，包括第一次请求，默认为2
+	TryTimeout    string   `yaml:"tryTimeout" json:"tryTimeout"`       // 每次尝试等待响应Header的超时时间，为空表示不限
+	Errors        []string `yaml:"errors" json:"errors"`               // 需要重试的错误类型，为空表示所有错误
+	StatusCodes   []int    `yaml:"statusCodes" json:"statusCodes"`     // 需要重试的状态码，比如502、503、504
+	NonIdempotent bool     `yaml:"nonIdempotent" json:"nonIdempotent"` // 是否重试POST、PATCH等非幂等的请求
+
+	tryTimeout time.Duration
+}
+
+// 获取新对象
+func NewRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		On:       true,
+		MaxTries: 2,
+	}
+}
+
+// 校验
+func (this *RetryConfig) Validate() error {
+	if this.MaxTries <= 0 {
+		this.MaxTries = 2
+	}
+
+	this.tryTimeout = 0
+	if len(this.TryTimeout) > 0 {
+		duration, err := time.ParseDuration(this.TryTimeout)
+		if err != nil {
+			return errors.New("invalid try timeout '" + this.TryTimeout + "'")
+		}
+		this.tryTimeout = duration
+	}
+
+	for _, errorType := range this.Errors {
+		if errorType != RetryErrorConnect && errorType != RetryErrorTimeout && errorType != RetryErrorOther {
+			return errors.New("invalid retry error type '" + errorType + "'")
+		}
+	}
+
+	return nil
+}
+
+// 每次尝试的超时时间
+func (this *RetryConfig) TryTimeoutDuration() time.Duration {
+	return this.tryTimeout
+}
+
+// 判断某个请求方法是否可以重试
+func (this *RetryConfig) MatchMethod(method string) bool {
+	if this.NonIdempotent {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// 判断某个错误类型是否需要重试
+func (this *RetryConfig) MatchError(errorType RetryErrorType) bool {
+	if len(this.Errors) == 0 {
+		return true
+	}
+	return lists.Contains(this.Errors, errorType)
+}
+
+// 判断某个状态码是否需要重试
+func (this *RetryConfig) MatchStatus(statusCode int) bool {
+	return lists.Contains(this.StatusCodes, statusCode)
+}
@@ -0,0 +1,70 @@
+package teaconfigs
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"github.com/iwind/TeaGo/maps"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := &RetryConfig{On: true}
+	a.IsNil(config.Validate())
+	a.IsTrue(config.MaxTries == 2)
+	a.IsTrue(config.TryTimeoutDuration() == 0)
+	a.IsTrue(config.MatchError(RetryErrorConnect))
+	a.IsTrue(config.MatchError(RetryErrorOther))
+	a.IsFalse(config.MatchStatus(http.StatusBadGateway))
+
+	config.TryTimeout = "3s"
+	config.Errors = []string{RetryErrorConnect, RetryErrorTimeout}
+	config.StatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+	a.IsNil(config.Validate())
+	a.IsTrue(config.TryTimeoutDuration() == 3*time.Second)
+	a.IsTrue(config.MatchError(RetryErrorTimeout))
+	a.IsFalse(config.MatchError(RetryErrorOther))
+	a.IsTrue(config.MatchStatus(http.StatusServiceUnavailable))
+	a.IsFalse(config.MatchStatus(http.StatusInternalServerError))
+
+	config.TryTimeout = "3"
+	a.IsNotNil(config.Validate())
+
+	config.TryTimeout = ""
+	config.Errors = []string{"reset"}
+	a.IsNotNil(config.Validate())
+}
+
+func TestRetryConfig_MatchMethod(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := NewRetryConfig()
+	a.IsTrue(config.MatchMethod(http.MethodGet))
+	a.IsTrue(config.MatchMethod(http.MethodPut))
+	a.IsFalse(config.MatchMethod(http.MethodPost))
+	a.IsFalse(config.MatchMethod(http.MethodPatch))
+
+	config.NonIdempotent = true
+	a.IsTrue(config.MatchMethod(http.MethodPost))
+}
+
+func TestBackendList_NextRetryBackend(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backend1 := &BackendConfig{On: true, Address: "127.0.0.1:8001"}
+	backend2 := &BackendConfig{On: true, Address: "127.0.0.1:8002"}
+	backend3 := &BackendConfig{On: true, Address: "127.0.0.1:8003", IsBackup: true}
+
+	list := &BackendList{}
+	list.AddBackend(backend1)
+	list.AddBackend(backend2)
+	list.AddBackend(backend3)
+	a.IsNil(list.ValidateBackends())
+
+	a.IsTrue(list.NextRetryBackend(maps.Map{}, []*BackendConfig{backend1}) == backend2)
+	a.IsTrue(list.NextRetryBackend(maps.Map{}, []*BackendConfig{backend2}) == backend1)
+	a.IsTrue(list.NextRetryBackend(maps.Map{}, []*BackendConfig{backend1, backend2}) == backend3)
+	a.IsTrue(list.NextRetryBackend(maps.Map{}, []*BackendConfig{backend1, backend2, backend3}) == nil)
+}
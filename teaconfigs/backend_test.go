@@ -2,7 +2,11 @@ package teaconfigs
 
 import (
 	"github.com/go-yaml/yaml"
+	"github.com/iwind/TeaGo/assert"
+	"math"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestBackendConfig(t *testing.T) {
@@ -12,3 +16,143 @@ func TestBackendConfig(t *testing.T) {
 	}
 	t.Log(string(yamlData))
 }
+
+func TestBackendConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		a.IsNil(backend.Validate())
+		a.IsTrue(backend.Address == "127.0.0.1:80")
+		a.IsTrue(backend.Scheme == BackendSchemeHTTP)
+		a.IsFalse(backend.IsHTTPS())
+		a.IsTrue(backend.TLSConfig() == nil)
+	}
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		backend.Scheme = BackendSchemeHTTPS
+		backend.TLS = &BackendTLSConfig{
+			ServerName:         "example.com",
+			InsecureSkipVerify: true,
+		}
+		a.IsNil(backend.Validate())
+		a.IsTrue(backend.Address == "127.0.0.1:443")
+		a.IsTrue(backend.IsHTTPS())
+		a.IsTrue(backend.TLSConfig().ServerName == "example.com")
+		a.IsTrue(backend.TLSConfig().InsecureSkipVerify)
+	}
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		backend.Scheme = "ftp"
+		a.IsNotNil(backend.Validate())
+	}
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		backend.Scheme = BackendSchemeHTTPS
+		backend.TLS = &BackendTLSConfig{
+			Certificate: "client.pem",
+		}
+		a.IsNotNil(backend.Validate())
+	}
+}
+
+func TestBackendConfig_Timeouts(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		a.IsNil(backend.Validate())
+		a.IsTrue(backend.ConnectTimeoutDuration() == 15*time.Second)
+		a.IsTrue(backend.ReadHeaderTimeoutDuration() == 0)
+		a.IsTrue(backend.IdleTimeoutDuration() == 0)
+		a.IsTrue(backend.TimeoutDuration() == 15*time.Second)
+	}
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		backend.FailTimeout = "5s"
+		backend.ReadHeaderTimeout = "10s"
+		backend.IdleTimeout = "1m"
+		backend.Timeout = "0s"
+		a.IsNil(backend.Validate())
+		a.IsTrue(backend.ConnectTimeoutDuration() == 5*time.Second)
+		a.IsTrue(backend.ReadHeaderTimeoutDuration() == 10*time.Second)
+		a.IsTrue(backend.IdleTimeoutDuration() == time.Minute)
+		a.IsTrue(backend.TimeoutDuration() == 0)
+
+		key := backend.ClientKey()
+		backend.ConnectTimeout = "3s"
+		a.IsNil(backend.Validate())
+		a.IsTrue(backend.ConnectTimeoutDuration() == 3*time.Second)
+		a.IsTrue(backend.ClientKey() != key)
+	}
+
+	{
+		backend := NewBackendConfig()
+		backend.Address = "127.0.0.1"
+		backend.Timeout = "abc"
+		a.IsNotNil(backend.Validate())
+	}
+}
+
+func TestBackendConfig_RecordResponseTime(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backend := NewBackendConfig()
+	a.IsTrue(backend.CandidateResponseTime() == 0)
+
+	backend.RecordResponseTime(1 * time.Second)
+	a.IsTrue(backend.CandidateResponseTime() == 1)
+
+	backend.RecordResponseTime(2 * time.Second)
+	a.IsTrue(math.Abs(backend.CandidateResponseTime()-1.3) < 0.000001)
+
+	backend.IncreaseConn()
+	a.IsTrue(backend.CandidateConns() == 1)
+}
+
+func TestBackendConfig_RecordFail(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backend := NewBackendConfig()
+	backend.MaxFails = 2
+
+	a.IsFalse(backend.RecordFail())
+	a.IsTrue(backend.RecordFail())
+	a.IsTrue(backend.IsOffline())
+	a.IsFalse(backend.RecordFail())
+
+	// 健康检查成功后上线，并清除失败次数
+	a.IsTrue(backend.RecordCheck(nil, 1, 1))
+	a.IsFalse(backend.IsOffline())
+	a.IsTrue(backend.CurrentFails == 0)
+
+	// 请求失败和健康检查同时进行
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			backend.RecordFail()
+		}()
+		go func() {
+			defer wg.Done()
+			backend.RecordCheck(nil, 1, 1)
+		}()
+	}
+	wg.Wait()
+
+	backend.SetDown(false)
+	a.IsFalse(backend.IsOffline())
+	a.IsFalse(backend.SetDown(false))
+	a.IsTrue(backend.SetDown(true))
+}
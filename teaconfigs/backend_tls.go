@@ -0,0 +1,93 @@
+package teaconfigs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/utils/string"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+)
+
+// 后端服务协议
+type BackendScheme = string
+
+const (
+	BackendSchemeHTTP  BackendScheme = "http"
+	BackendSchemeHTTPS BackendScheme = "https"
+)
+
+// 连接后端服务的TLS配置
+type BackendTLSConfig struct {
+	ServerName         string `yaml:"serverName" json:"serverName"`                 // SNI域名，为空表示使用请求的域名
+	CACertificates     string `yaml:"caCertificates" json:"caCertificates"`         // 用来校验后端证书的CA证书文件，为空表示使用系统的CA证书
+	Certificate        string `yaml:"certificate" json:"certificate"`               // 客户端证书文件
+	CertificateKey     string `yaml:"certificateKey" json:"certificateKey"`         // 客户端证书密钥
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" json:"insecureSkipVerify"` // 是否跳过证书校验，只用于测试环境
+
+	tlsConfig *tls.Config
+}
+
+// 获取新对象
+func NewBackendTLSConfig() *BackendTLSConfig {
+	return &BackendTLSConfig{}
+}
+
+// 校验
+func (this *BackendTLSConfig) Validate() error {
+	config := &tls.Config{
+		ServerName:         this.ServerName,
+		InsecureSkipVerify: this.InsecureSkipVerify,
+	}
+
+	// CA证书
+	if len(this.CACertificates) > 0 {
+		data, err := ioutil.ReadFile(this.configPath(this.CACertificates))
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("invalid ca certificates '" + this.CACertificates + "'")
+		}
+		config.RootCAs = pool
+	}
+
+	// 客户端证书
+	if len(this.Certificate) > 0 || len(this.CertificateKey) > 0 {
+		if len(this.Certificate) == 0 {
+			return errors.New("'certificate' should not be empty")
+		}
+		if len(this.CertificateKey) == 0 {
+			return errors.New("'certificateKey' should not be empty")
+		}
+		cert, err := tls.LoadX509KeyPair(this.configPath(this.Certificate), this.configPath(this.CertificateKey))
+		if err != nil {
+			return err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	this.tlsConfig = config
+	return nil
+}
+
+// 获取TLS配置，调用前需要先校验
+func (this *BackendTLSConfig) TLSConfig() *tls.Config {
+	return this.tlsConfig
+}
+
+// 用来区分不同TLS配置的Key
+func (this *BackendTLSConfig) Key() string {
+	return stringutil.Md5(this.ServerName + "@" + this.CACertificates + "@" + this.Certificate + "@" + this.CertificateKey + "@" + strconv.FormatBool(this.InsecureSkipVerify))
+}
+
+// 文件路径，相对路径的文件在配置目录下
+func (this *BackendTLSConfig) configPath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return Tea.ConfigFile(file)
+}
@@ -11,6 +11,7 @@ import (
 type CacheConfig struct {
 	Filename    string   `yaml:"filename" json:"filename"`       // 文件名
 	PolicyFiles []string `yaml:"policyFiles" json:"policyFiles"` // 策略文件
+	PurgeToken  string   `yaml:"purgeToken" json:"purgeToken"`   // 通过HTTP接口清除缓存时使用的令牌，为空表示不允许
 }
 
 // 获取新对象
@@ -57,6 +58,19 @@ func (this *CacheConfig) FindAllPolicies() []*shared.CachePolicy {
 	return result
 }
 
+// 查找单个缓存策略
+func (this *CacheConfig) FindPolicy(file string) *shared.CachePolicy {
+	if !lists.Contains(this.PolicyFiles, file) {
+		return nil
+	}
+	policy := shared.NewCachePolicyFromFile(file)
+	if policy == nil {
+		return nil
+	}
+	policy.Validate()
+	return policy
+}
+
 // 保存
 func (this *CacheConfig) Save() error {
 	if len(this.Filename) == 0 {
@@ -0,0 +1,190 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/utils/string"
+	"strconv"
+	"strings"
+)
+
+// 压缩编码
+type CompressionEncoding = string
+
+const (
+	CompressionEncodingGzip   CompressionEncoding = "gzip"
+	CompressionEncodingBrotli CompressionEncoding = "br"
+)
+
+// 默认可以压缩的内容类型
+var DefaultCompressionMimeTypes = []string{
+	"application/atom+xml",
+	"application/javascript",
+	"application/x-javascript",
+	"application/json",
+	"application/rss+xml",
+	"application/x-web-app-manifest+json",
+	"application/xhtml+xml",
+	"application/xml",
+	"image/svg+xml",
+	"text/*",
+}
+
+// 压缩配置
+type CompressionConfig struct {
+	On        bool     `yaml:"on" json:"on"`               // 是否开启
+	Encodings []string `yaml:"encodings" json:"encodings"` // 支持的编码：gzip, br，排在前面的优先
+	MinLength string   `yaml:"minLength" json:"minLength"` // 最小压缩长度，比如 1k
+	Level     int      `yaml:"level" json:"level"`         // 压缩级别，gzip为1-9，br为0-11，超出范围时自动调整
+	MimeTypes []string `yaml:"mimeTypes" json:"mimeTypes"` // 可以压缩的内容类型，支持 text/* 这样的通配符，为空表示使用默认的类型
+
+	minLength int64
+}
+
+// 获取新对象
+func NewCompressionConfig() *CompressionConfig {
+	return &CompressionConfig{
+		On:        true,
+		Encodings: []string{CompressionEncodingBrotli, CompressionEncodingGzip},
+		MinLength: "1k",
+		Level:     5,
+	}
+}
+
+// 校验
+func (this *CompressionConfig) Validate() error {
+	for _, encoding := range this.Encodings {
+		if encoding != CompressionEncodingGzip && encoding != CompressionEncodingBrotli {
+			return errors.New("invalid compression encoding '" + encoding + "'")
+		}
+	}
+	if len(this.Encodings) == 0 {
+		this.Encodings = []string{CompressionEncodingGzip}
+	}
+
+	this.minLength = 0
+	if len(this.MinLength) > 0 {
+		size, err := stringutil.ParseFileSize(this.MinLength)
+		if err != nil {
+			return errors.New("invalid compression min length '" + this.MinLength + "'")
+		}
+		this.minLength = int64(size)
+	}
+
+	return nil
+}
+
+// 最小压缩长度
+func (this *CompressionConfig) MinBytes() int64 {
+	return this.minLength
+}
+
+// 某个编码对应的压缩级别
+func (this *CompressionConfig) LevelForEncoding(encoding CompressionEncoding) int {
+	level := this.Level
+	switch encoding {
+	case CompressionEncodingGzip:
+		if level < 1 {
+			level = 1
+		} else if level > 9 {
+			level = 9
+		}
+	case CompressionEncodingBrotli:
+		if level < 0 {
+			level = 0
+		} else if level > 11 {
+			level = 11
+		}
+	}
+	return level
+}
+
+// 判断内容类型是否可以压缩
+func (this *CompressionConfig) MatchMimeType(contentType string) bool {
+	if len(contentType) == 0 {
+		return false
+	}
+	index := strings.Index(contentType, ";")
+	if index >= 0 {
+		contentType = contentType[:index]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+
+	mimeTypes := this.MimeTypes
+	if len(mimeTypes) == 0 {
+		mimeTypes = DefaultCompressionMimeTypes
+	}
+	for _, mimeType := range mimeTypes {
+		mimeType = strings.ToLower(mimeType)
+		if mimeType == "*" || mimeType == "*/*" || mimeType == contentType {
+			return true
+		}
+		if strings.HasSuffix(mimeType, "/*") && strings.HasPrefix(contentType, mimeType[:len(mimeType)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据Accept-Encoding选择编码
+// 选择q值最大的编码，q值相同时按照配置中的顺序
+func (this *CompressionConfig) Negotiate(acceptEncoding string) CompressionEncoding {
+	if len(acceptEncoding) == 0 {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+	for _, piece := range strings.Split(acceptEncoding, ",") {
+		piece = strings.TrimSpace(piece)
+		if len(piece) == 0 {
+			continue
+		}
+		name := piece
+		q := float64(1)
+		index := strings.Index(piece, ";")
+		if index >= 0 {
+			name = strings.TrimSpace(piece[:index])
+			param := strings.TrimSpace(piece[index+1:])
+			if strings.HasPrefix(param, "q=") {
+				f, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					q = f
+				}
+			}
+		}
+		qualities[strings.ToLower(name)] = q
+	}
+
+	result := ""
+	resultQ := float64(0)
+	for _, encoding := range this.Encodings {
+		q, found := qualities[encoding]
+		if !found {
+			q, found = qualities["*"]
+		}
+		if !found || q <= 0 {
+			continue
+		}
+		if q > resultQ {
+			result = encoding
+			resultQ = q
+		}
+	}
+	return result
+}
+
+// 所有的压缩编码
+func AllCompressionEncodings() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "Brotli",
+			"encoding":    CompressionEncodingBrotli,
+			"description": "压缩率较高，需要客户端支持br编码",
+		},
+		{
+			"name":        "Gzip",
+			"encoding":    CompressionEncodingGzip,
+			"description": "兼容性最好的压缩方式",
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package teaconfigs
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestCompressionConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		config := NewCompressionConfig()
+		a.IsNil(config.Validate())
+		a.IsTrue(config.MinBytes() == 1024)
+	}
+
+	{
+		config := &CompressionConfig{
+			Encodings: []string{"deflate"},
+		}
+		a.IsNotNil(config.Validate())
+	}
+
+	{
+		config := &CompressionConfig{}
+		a.IsNil(config.Validate())
+		a.IsTrue(config.Encodings[0] == CompressionEncodingGzip)
+		a.IsTrue(config.LevelForEncoding(CompressionEncodingGzip) == 1)
+		a.IsTrue(config.LevelForEncoding(CompressionEncodingBrotli) == 0)
+	}
+}
+
+func TestCompressionConfig_MatchMimeType(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := NewCompressionConfig()
+	a.IsTrue(config.MatchMimeType("text/html; charset=utf-8"))
+	a.IsTrue(config.MatchMimeType("application/json"))
+	a.IsFalse(config.MatchMimeType("image/png"))
+	a.IsFalse(config.MatchMimeType(""))
+
+	config.MimeTypes = []string{"image/*"}
+	a.IsTrue(config.MatchMimeType("image/png"))
+	a.IsFalse(config.MatchMimeType("text/html"))
+}
+
+func TestCompressionConfig_Negotiate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := NewCompressionConfig()
+	a.IsNil(config.Validate())
+
+	a.IsTrue(config.Negotiate("") == "")
+	a.IsTrue(config.Negotiate("identity") == "")
+	a.IsTrue(config.Negotiate("gzip, deflate") == "gzip")
+	a.IsTrue(config.Negotiate("gzip, deflate, br") == "br")
+	a.IsTrue(config.Negotiate("gzip;q=1.0, br;q=0.5") == "gzip")
+	a.IsTrue(config.Negotiate("gzip;q=0, br;q=0") == "")
+	a.IsTrue(config.Negotiate("*") == "br")
+	a.IsTrue(config.Negotiate("br;q=0, *") == "gzip")
+}
@@ -0,0 +1,157 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/utils/string"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 错误页面类型
+type ErrorPageType = string
+
+const (
+	ErrorPageTypeFile     ErrorPageType = "file"     // 文件
+	ErrorPageTypeURL      ErrorPageType = "url"      // 内部URI
+	ErrorPageTypeTemplate ErrorPageType = "template" // 内联模板
+)
+
+// 所有的错误页面类型
+func AllErrorPageTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "文件",
+			"code":        ErrorPageTypeFile,
+			"description": "读取本地文件内容",
+		},
+		{
+			"name":        "内部URI",
+			"code":        ErrorPageTypeURL,
+			"description": "请求当前服务中的一个URI，比如/errors/404.html",
+		},
+		{
+			"name":        "模板",
+			"code":        ErrorPageTypeTemplate,
+			"description": "直接输出模板内容，支持请求变量，比如${status}、${requestURI}",
+		},
+	}
+}
+
+// 状态码范围，比如50x、500-599
+var errorPageStatusReg = regexp.MustCompile(`^(\d)(\d|x)(\d|x)$`)
+
+// 自定义错误页面
+type ErrorPageConfig struct {
+	On          bool          `yaml:"on" json:"on"`                   // 是否开启
+	Id          string        `yaml:"id" json:"id"`                   // ID
+	Status      []string      `yaml:"status" json:"status"`           // 状态码，支持404、50x、500-599等形式
+	Type        ErrorPageType `yaml:"type" json:"type"`               // 类型
+	File        string        `yaml:"file" json:"file"`               // 文件路径，相对路径的文件在Tea.Root下
+	URL         string        `yaml:"url" json:"url"`                 // 内部URI
+	Template    string        `yaml:"template" json:"template"`       // 模板内容
+	ContentType string        `yaml:"contentType" json:"contentType"` // 模板内容类型，默认为text/html
+	NewStatus   int           `yaml:"newStatus" json:"newStatus"`     // 使用新的状态码，0表示不改变
+
+	statusRanges [][2]int
+}
+
+// 获取新对象
+func NewErrorPageConfig() *ErrorPageConfig {
+	return &ErrorPageConfig{
+		On:   true,
+		Id:   stringutil.Rand(16),
+		Type: ErrorPageTypeTemplate,
+	}
+}
+
+// 校验
+func (this *ErrorPageConfig) Validate() error {
+	this.statusRanges = [][2]int{}
+	for _, status := range this.Status {
+		status = strings.TrimSpace(status)
+		if len(status) == 0 {
+			continue
+		}
+
+		// 500-599
+		if index := strings.Index(status, "-"); index > 0 {
+			from, err1 := strconv.Atoi(strings.TrimSpace(status[:index]))
+			to, err2 := strconv.Atoi(strings.TrimSpace(status[index+1:]))
+			if err1 != nil || err2 != nil || from > to {
+				return errors.New("invalid status '" + status + "'")
+			}
+			this.statusRanges = append(this.statusRanges, [2]int{from, to})
+			continue
+		}
+
+		// 404, 50x
+		status = strings.ToLower(status)
+		if !errorPageStatusReg.MatchString(status) {
+			return errors.New("invalid status '" + status + "'")
+		}
+		from, _ := strconv.Atoi(strings.Replace(status, "x", "0", -1))
+		to, _ := strconv.Atoi(strings.Replace(status, "x", "9", -1))
+		this.statusRanges = append(this.statusRanges, [2]int{from, to})
+	}
+
+	switch this.Type {
+	case ErrorPageTypeFile:
+		if len(this.File) == 0 {
+			return errors.New("'file' should not be empty")
+		}
+	case ErrorPageTypeURL:
+		if !strings.HasPrefix(this.URL, "/") {
+			return errors.New("'url' should start with '/'")
+		}
+	case ErrorPageTypeTemplate:
+	default:
+		return errors.New("invalid error page type '" + this.Type + "'")
+	}
+
+	return nil
+}
+
+// 判断是否匹配状态码
+func (this *ErrorPageConfig) Match(statusCode int) bool {
+	if !this.On {
+		return false
+	}
+	for _, r := range this.statusRanges {
+		if statusCode >= r[0] && statusCode <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// 文件的完整路径
+func (this *ErrorPageConfig) FilePath() string {
+	if filepath.IsAbs(this.File) {
+		return this.File
+	}
+	return Tea.Root + Tea.DS + this.File
+}
+
+// 输出的内容类型
+func (this *ErrorPageConfig) MimeType() string {
+	if len(this.ContentType) > 0 {
+		return this.ContentType
+	}
+	if this.Type == ErrorPageTypeFile {
+		mimeType := mime.TypeByExtension(filepath.Ext(this.File))
+		if len(mimeType) > 0 {
+			return mimeType
+		}
+	}
+	return "text/html; charset=utf-8"
+}
+
+// 是否输出JSON内容
+func (this *ErrorPageConfig) IsJSON() bool {
+	return this.Type != ErrorPageTypeURL && strings.Contains(this.MimeType(), "json")
+}
@@ -0,0 +1,81 @@
+package teaconfigs
+
+// ErrorPageList接口
+type ErrorPageListInterface interface {
+	// 校验
+	ValidateErrorPages() error
+
+	// 取得所有的错误页面
+	AllErrorPages() []*ErrorPageConfig
+
+	// 根据ID查找错误页面
+	FindErrorPage(pageId string) *ErrorPageConfig
+
+	// 添加错误页面
+	AddErrorPage(page *ErrorPageConfig)
+
+	// 删除错误页面
+	RemoveErrorPage(pageId string)
+}
+
+// ErrorPageList定义
+type ErrorPageList struct {
+	Pages []*ErrorPageConfig `yaml:"pages" json:"pages"`
+}
+
+// 校验
+func (this *ErrorPageList) ValidateErrorPages() error {
+	for _, page := range this.Pages {
+		err := page.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 取得所有的错误页面
+func (this *ErrorPageList) AllErrorPages() []*ErrorPageConfig {
+	if this.Pages == nil {
+		return []*ErrorPageConfig{}
+	}
+	return this.Pages
+}
+
+// 根据ID查找错误页面
+func (this *ErrorPageList) FindErrorPage(pageId string) *ErrorPageConfig {
+	for _, page := range this.Pages {
+		if page.Id == pageId {
+			page.Validate()
+			return page
+		}
+	}
+	return nil
+}
+
+// 添加错误页面
+func (this *ErrorPageList) AddErrorPage(page *ErrorPageConfig) {
+	this.Pages = append(this.Pages, page)
+}
+
+// 删除错误页面
+func (this *ErrorPageList) RemoveErrorPage(pageId string) {
+	result := []*ErrorPageConfig{}
+	for _, page := range this.Pages {
+		if page.Id == pageId {
+			continue
+		}
+		result = append(result, page)
+	}
+	this.Pages = result
+}
+
+// 查找匹配某个状态码的错误页面
+func (this *ErrorPageList) MatchErrorPage(statusCode int) *ErrorPageConfig {
+	for _, page := range this.Pages {
+		if page.Match(statusCode) {
+			return page
+		}
+	}
+	return nil
+}
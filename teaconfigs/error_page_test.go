@@ -0,0 +1,83 @@
+package teaconfigs
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestErrorPageConfig_Match(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	page := NewErrorPageConfig()
+	page.Status = []string{"404", "50x", "520-529"}
+	a.IsNil(page.Validate())
+
+	a.IsTrue(page.Match(404))
+	a.IsFalse(page.Match(403))
+	a.IsTrue(page.Match(500))
+	a.IsTrue(page.Match(504))
+	a.IsFalse(page.Match(510))
+	a.IsTrue(page.Match(525))
+	a.IsFalse(page.Match(530))
+
+	page.On = false
+	a.IsFalse(page.Match(404))
+}
+
+func TestErrorPageConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		page := NewErrorPageConfig()
+		page.Status = []string{"abc"}
+		a.IsNotNil(page.Validate())
+	}
+
+	{
+		page := NewErrorPageConfig()
+		page.Status = []string{"599-500"}
+		a.IsNotNil(page.Validate())
+	}
+
+	{
+		page := NewErrorPageConfig()
+		page.Type = ErrorPageTypeURL
+		page.URL = "errors/404.html"
+		a.IsNotNil(page.Validate())
+
+		page.URL = "/errors/404.html"
+		a.IsNil(page.Validate())
+		a.IsFalse(page.IsJSON())
+	}
+
+	{
+		page := NewErrorPageConfig()
+		page.Type = ErrorPageTypeFile
+		a.IsNotNil(page.Validate())
+
+		page.File = "errors/404.json"
+		a.IsNil(page.Validate())
+		a.IsTrue(page.IsJSON())
+	}
+}
+
+func TestErrorPageList_MatchErrorPage(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	page1 := NewErrorPageConfig()
+	page1.Status = []string{"404"}
+	page2 := NewErrorPageConfig()
+	page2.Status = []string{"4xx"}
+
+	list := &ErrorPageList{}
+	list.AddErrorPage(page1)
+	list.AddErrorPage(page2)
+	a.IsNil(list.ValidateErrorPages())
+
+	a.IsTrue(list.MatchErrorPage(404) == page1)
+	a.IsTrue(list.MatchErrorPage(403) == page2)
+	a.IsTrue(list.MatchErrorPage(500) == nil)
+
+	list.RemoveErrorPage(page1.Id)
+	a.IsTrue(list.MatchErrorPage(404) == page2)
+}
@@ -0,0 +1,126 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/maps"
+	"strings"
+	"time"
+)
+
+// 健康检查类型
+type HealthCheckType = string
+
+const (
+	HealthCheckTypeHTTP HealthCheckType = "http"
+	HealthCheckTypeTCP  HealthCheckType = "tcp"
+)
+
+// 健康检查配置
+type HealthCheckConfig struct {
+	On   bool            `yaml:"on" json:"on"`     // 是否开启
+	Type HealthCheckType `yaml:"type" json:"type"` // 类型：http, tcp
+
+	// 检查的URL，可以是一个路径（比如 /health ），也可以是完整的URL，只对HTTP有效
+	URL string `yaml:"url" json:"url"`
+
+	Interval           string `yaml:"interval" json:"interval"`                     // 检查间隔
+	Timeout            string `yaml:"timeout" json:"timeout"`                       // 超时时间
+	HealthyThreshold   uint   `yaml:"healthyThreshold" json:"healthyThreshold"`     // 连续成功多少次后上线
+	UnhealthyThreshold uint   `yaml:"unhealthyThreshold" json:"unhealthyThreshold"` // 连续失败多少次后下线
+	StatusCodes        []int  `yaml:"statusCodes" json:"statusCodes"`               // 期望的状态码，为空表示2xx和3xx
+
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// 获取新对象
+func NewHealthCheckConfig() *HealthCheckConfig {
+	return &HealthCheckConfig{
+		On:                 true,
+		Type:               HealthCheckTypeHTTP,
+		URL:                "/",
+		Interval:           "10s",
+		Timeout:            "5s",
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}
+}
+
+// 校验
+func (this *HealthCheckConfig) Validate() error {
+	if len(this.Type) == 0 {
+		this.Type = HealthCheckTypeHTTP
+	}
+	if this.Type != HealthCheckTypeHTTP && this.Type != HealthCheckTypeTCP {
+		return errors.New("invalid health check type '" + this.Type + "'")
+	}
+
+	this.interval, _ = time.ParseDuration(this.Interval)
+	if this.interval <= 0 {
+		this.interval = 10 * time.Second
+	}
+
+	this.timeout, _ = time.ParseDuration(this.Timeout)
+	if this.timeout <= 0 {
+		this.timeout = 5 * time.Second
+	}
+
+	if this.HealthyThreshold == 0 {
+		this.HealthyThreshold = 1
+	}
+	if this.UnhealthyThreshold == 0 {
+		this.UnhealthyThreshold = 1
+	}
+
+	return nil
+}
+
+// 检查间隔
+func (this *HealthCheckConfig) IntervalDuration() time.Duration {
+	return this.interval
+}
+
+// 超时时间
+func (this *HealthCheckConfig) TimeoutDuration() time.Duration {
+	return this.timeout
+}
+
+// 判断状态码是否符合期望
+func (this *HealthCheckConfig) MatchStatus(statusCode int) bool {
+	if len(this.StatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 400
+	}
+	return lists.Contains(this.StatusCodes, statusCode)
+}
+
+// 组合某个后端服务器的检查URL
+func (this *HealthCheckConfig) BackendURL(backend *BackendConfig) string {
+	if strings.HasPrefix(this.URL, "http://") || strings.HasPrefix(this.URL, "https://") {
+		return this.URL
+	}
+	path := this.URL
+	if len(path) == 0 || path[0] != '/' {
+		path = "/" + path
+	}
+	if backend.IsHTTPS() {
+		return "https://" + backend.Address + path
+	}
+	return "http://" + backend.Address + path
+}
+
+// 所有的检查类型
+func AllHealthCheckTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "HTTP",
+			"type":        HealthCheckTypeHTTP,
+			"description": "请求某个URL，根据响应的状态码判断后端服务器是否健康",
+		},
+		{
+			"name":        "TCP",
+			"type":        HealthCheckTypeTCP,
+			"description": "尝试连接后端服务器地址，能连接成功即为健康",
+		},
+	}
+}
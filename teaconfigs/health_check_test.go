@@ -0,0 +1,55 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestHealthCheckConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := &HealthCheckConfig{}
+	a.IsNil(config.Validate())
+	a.IsTrue(config.Type == HealthCheckTypeHTTP)
+	a.IsTrue(config.IntervalDuration().Seconds() == 10)
+	a.IsTrue(config.TimeoutDuration().Seconds() == 5)
+	a.IsTrue(config.MatchStatus(200))
+	a.IsTrue(config.MatchStatus(302))
+	a.IsFalse(config.MatchStatus(500))
+
+	config.StatusCodes = []int{200}
+	a.IsFalse(config.MatchStatus(302))
+
+	config.Type = "udp"
+	a.IsNotNil(config.Validate())
+}
+
+func TestHealthCheckConfig_BackendURL(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backend := &BackendConfig{
+		Address: "127.0.0.1:8080",
+	}
+
+	config := &HealthCheckConfig{URL: "health"}
+	a.IsTrue(config.BackendURL(backend) == "http://127.0.0.1:8080/health")
+
+	config.URL = "http://example.com/ping"
+	a.IsTrue(config.BackendURL(backend) == "http://example.com/ping")
+}
+
+func TestBackendConfig_RecordCheck(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backend := NewBackendConfig()
+	a.IsFalse(backend.RecordCheck(errors.New("refused"), 2, 2))
+	a.IsTrue(backend.RecordCheck(errors.New("refused"), 2, 2))
+	a.IsTrue(backend.IsDown)
+
+	a.IsFalse(backend.RecordCheck(nil, 2, 2))
+	a.IsTrue(backend.IsDown)
+	a.IsTrue(backend.RecordCheck(nil, 2, 2))
+	a.IsFalse(backend.IsDown)
+	a.IsTrue(backend.CheckOk)
+}
@@ -0,0 +1,242 @@
+package teaconfigs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/utils/string"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 超出限速后的处理方式
+type RateLimitMode = string
+
+const (
+	RateLimitModeReject RateLimitMode = "reject" // 直接拒绝
+	RateLimitModeDelay  RateLimitMode = "delay"  // 延迟处理，等待时间超出最长等待时间时拒绝
+)
+
+// 所有的限速处理方式
+func AllRateLimitModes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "拒绝",
+			"code":        RateLimitModeReject,
+			"description": "超出速率和突发数量的请求直接返回429",
+		},
+		{
+			"name":        "延迟",
+			"code":        RateLimitModeDelay,
+			"description": "超出速率的请求等待一段时间后再处理，等待时间超出最长等待时间时返回429",
+		},
+	}
+}
+
+// 限制计数存储类型
+type LimitStoreType = string
+
+const (
+	LimitStoreTypeMemory LimitStoreType = "memory" // 内存，只在当前节点有效
+	LimitStoreTypeRedis  LimitStoreType = "redis"  // Redis，可以在多个节点之间共享
+)
+
+// 所有的存储类型
+func AllLimitStoreTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "内存",
+			"code":        LimitStoreTypeMemory,
+			"description": "计数保存在内存中，只在当前节点有效",
+		},
+		{
+			"name":        "Redis",
+			"code":        LimitStoreTypeRedis,
+			"description": "计数保存在Redis中，可以在多个节点之间共享",
+		},
+	}
+}
+
+// 限制计数存储配置
+type LimitStoreConfig struct {
+	Type    LimitStoreType         `yaml:"type" json:"type"`       // 类型
+	Options map[string]interface{} `yaml:"options" json:"options"` // 选项，Redis支持network、host、port、password、sock
+}
+
+// 校验
+func (this *LimitStoreConfig) Validate() error {
+	switch this.Type {
+	case "", LimitStoreTypeMemory, LimitStoreTypeRedis:
+	default:
+		return errors.New("invalid limit store type '" + this.Type + "'")
+	}
+	return nil
+}
+
+// 区分不同存储的Key，选项相同的存储可以共用
+func (this *LimitStoreConfig) Key() string {
+	if this == nil || len(this.Type) == 0 || this.Type == LimitStoreTypeMemory {
+		return LimitStoreTypeMemory
+	}
+	keys := []string{}
+	for key := range this.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pieces := []string{this.Type}
+	for _, key := range keys {
+		pieces = append(pieces, key+"="+fmt.Sprintf("%v", this.Options[key]))
+	}
+	return strings.Join(pieces, "@")
+}
+
+// 请求速率限制
+// 参考：http://nginx.org/en/docs/http/ngx_http_limit_req_module.html
+type RateLimitConfig struct {
+	On       bool              `yaml:"on" json:"on"`             // 是否开启
+	Id       string            `yaml:"id" json:"id"`             // ID
+	Key      string            `yaml:"key" json:"key"`           // 区分终端的变量表达式，比如${remoteAddr}、${header.X-Api-Key}，为空时使用${remoteAddr}
+	Rate     int               `yaml:"rate" json:"rate"`         // 每个周期允许的请求数
+	Period   string            `yaml:"period" json:"period"`     // 周期，比如1s、1m，默认为1s
+	Burst    int               `yaml:"burst" json:"burst"`       // 允许突发的请求数
+	Mode     RateLimitMode     `yaml:"mode" json:"mode"`         // 超出后的处理方式
+	MaxDelay string            `yaml:"maxDelay" json:"maxDelay"` // 延迟处理时最长的等待时间，比如5s
+	Store    *LimitStoreConfig `yaml:"store" json:"store"`       // 存储，为空时存储在内存中
+
+	period   time.Duration
+	maxDelay time.Duration
+}
+
+// 获取新对象
+func NewRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		On:     true,
+		Id:     stringutil.Rand(16),
+		Key:    "${remoteAddr}",
+		Period: "1s",
+		Mode:   RateLimitModeReject,
+	}
+}
+
+// 校验
+func (this *RateLimitConfig) Validate() error {
+	if this.Rate <= 0 {
+		return errors.New("rate should be greater than 0")
+	}
+	if this.Burst < 0 {
+		return errors.New("burst should not be less than 0")
+	}
+
+	this.period = 1 * time.Second
+	if len(this.Period) > 0 {
+		period, err := time.ParseDuration(this.Period)
+		if err != nil || period <= 0 {
+			return errors.New("invalid period '" + this.Period + "'")
+		}
+		this.period = period
+	}
+
+	switch this.Mode {
+	case "", RateLimitModeReject, RateLimitModeDelay:
+	default:
+		return errors.New("invalid rate limit mode '" + this.Mode + "'")
+	}
+
+	this.maxDelay = 0
+	if len(this.MaxDelay) > 0 {
+		maxDelay, err := time.ParseDuration(this.MaxDelay)
+		if err != nil || maxDelay < 0 {
+			return errors.New("invalid max delay '" + this.MaxDelay + "'")
+		}
+		this.maxDelay = maxDelay
+	}
+
+	if this.Store != nil {
+		err := this.Store.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 区分终端的变量表达式
+func (this *RateLimitConfig) KeyFormat() string {
+	if len(this.Key) == 0 {
+		return "${remoteAddr}"
+	}
+	return this.Key
+}
+
+// 两个请求之间的间隔
+func (this *RateLimitConfig) Interval() time.Duration {
+	if this.Rate <= 0 {
+		return this.period
+	}
+	return this.period / time.Duration(this.Rate)
+}
+
+// 最长的等待时间，拒绝模式下为0
+func (this *RateLimitConfig) MaxDelayDuration() time.Duration {
+	if this.Mode != RateLimitModeDelay {
+		return 0
+	}
+	if this.maxDelay <= 0 {
+		// 默认可以等待所有的突发请求
+		return this.Interval() * time.Duration(this.Burst)
+	}
+	return this.maxDelay
+}
+
+// 描述，用于日志和界面显示
+func (this *RateLimitConfig) Summary() string {
+	period := this.Period
+	if len(period) == 0 {
+		period = "1s"
+	}
+	return strconv.Itoa(this.Rate) + "/" + period + " burst=" + strconv.Itoa(this.Burst)
+}
+
+// 并发连接数限制
+// 参考：http://nginx.org/en/docs/http/ngx_http_limit_conn_module.html
+type ConnLimitConfig struct {
+	On       bool              `yaml:"on" json:"on"`             // 是否开启
+	Id       string            `yaml:"id" json:"id"`             // ID
+	Key      string            `yaml:"key" json:"key"`           // 区分终端的变量表达式，为空时使用${remoteAddr}
+	MaxConns int               `yaml:"maxConns" json:"maxConns"` // 每个Key最多同时处理的请求数
+	Store    *LimitStoreConfig `yaml:"store" json:"store"`       // 存储，为空时存储在内存中
+}
+
+// 获取新对象
+func NewConnLimitConfig() *ConnLimitConfig {
+	return &ConnLimitConfig{
+		On:  true,
+		Id:  stringutil.Rand(16),
+		Key: "${remoteAddr}",
+	}
+}
+
+// 校验
+func (this *ConnLimitConfig) Validate() error {
+	if this.MaxConns <= 0 {
+		return errors.New("maxConns should be greater than 0")
+	}
+	if this.Store != nil {
+		err := this.Store.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 区分终端的变量表达式
+func (this *ConnLimitConfig) KeyFormat() string {
+	if len(this.Key) == 0 {
+		return "${remoteAddr}"
+	}
+	return this.Key
+}
@@ -0,0 +1,141 @@
+package teaconfigs
+
+// LimitList接口
+type LimitListInterface interface {
+	// 校验
+	ValidateLimits() error
+
+	// 取得所有的速率限制
+	AllRateLimits() []*RateLimitConfig
+
+	// 根据ID查找速率限制
+	FindRateLimit(limitId string) *RateLimitConfig
+
+	// 添加速率限制
+	AddRateLimit(limit *RateLimitConfig)
+
+	// 删除速率限制
+	RemoveRateLimit(limitId string)
+
+	// 取得所有的连接数限制
+	AllConnLimits() []*ConnLimitConfig
+
+	// 根据ID查找连接数限制
+	FindConnLimit(limitId string) *ConnLimitConfig
+
+	// 添加连接数限制
+	AddConnLimit(limit *ConnLimitConfig)
+
+	// 删除连接数限制
+	RemoveConnLimit(limitId string)
+}
+
+// LimitList定义
+type LimitList struct {
+	RateLimits []*RateLimitConfig `yaml:"rateLimits" json:"rateLimits"` // 请求速率限制
+	ConnLimits []*ConnLimitConfig `yaml:"connLimits" json:"connLimits"` // 并发连接数限制
+}
+
+// 校验
+func (this *LimitList) ValidateLimits() error {
+	for _, limit := range this.RateLimits {
+		err := limit.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	for _, limit := range this.ConnLimits {
+		err := limit.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 是否有开启的限制
+func (this *LimitList) HasLimits() bool {
+	for _, limit := range this.RateLimits {
+		if limit.On {
+			return true
+		}
+	}
+	for _, limit := range this.ConnLimits {
+		if limit.On {
+			return true
+		}
+	}
+	return false
+}
+
+// 取得所有的速率限制
+func (this *LimitList) AllRateLimits() []*RateLimitConfig {
+	if this.RateLimits == nil {
+		return []*RateLimitConfig{}
+	}
+	return this.RateLimits
+}
+
+// 根据ID查找速率限制
+func (this *LimitList) FindRateLimit(limitId string) *RateLimitConfig {
+	for _, limit := range this.RateLimits {
+		if limit.Id == limitId {
+			limit.Validate()
+			return limit
+		}
+	}
+	return nil
+}
+
+// 添加速率限制
+func (this *LimitList) AddRateLimit(limit *RateLimitConfig) {
+	this.RateLimits = append(this.RateLimits, limit)
+}
+
+// 删除速率限制
+func (this *LimitList) RemoveRateLimit(limitId string) {
+	result := []*RateLimitConfig{}
+	for _, limit := range this.RateLimits {
+		if limit.Id == limitId {
+			continue
+		}
+		result = append(result, limit)
+	}
+	this.RateLimits = result
+}
+
+// 取得所有的连接数限制
+func (this *LimitList) AllConnLimits() []*ConnLimitConfig {
+	if this.ConnLimits == nil {
+		return []*ConnLimitConfig{}
+	}
+	return this.ConnLimits
+}
+
+// 根据ID查找连接数限制
+func (this *LimitList) FindConnLimit(limitId string) *ConnLimitConfig {
+	for _, limit := range this.ConnLimits {
+		if limit.Id == limitId {
+			limit.Validate()
+			return limit
+		}
+	}
+	return nil
+}
+
+// 添加连接数限制
+func (this *LimitList) AddConnLimit(limit *ConnLimitConfig) {
+	this.ConnLimits = append(this.ConnLimits, limit)
+}
+
+// 删除连接数限制
+func (this *LimitList) RemoveConnLimit(limitId string) {
+	result := []*ConnLimitConfig{}
+	for _, limit := range this.ConnLimits {
+		if limit.Id == limitId {
+			continue
+		}
+		result = append(result, limit)
+	}
+	this.ConnLimits = result
+}
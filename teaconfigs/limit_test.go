@@ -0,0 +1,96 @@
+package teaconfigs
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimitConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		limit := NewRateLimitConfig()
+		a.IsNotNil(limit.Validate())
+	}
+
+	{
+		limit := NewRateLimitConfig()
+		limit.Rate = 10
+		a.IsNil(limit.Validate())
+		a.IsTrue(limit.Interval() == 100*time.Millisecond)
+		a.IsTrue(limit.MaxDelayDuration() == 0)
+	}
+
+	{
+		limit := NewRateLimitConfig()
+		limit.Rate = 2
+		limit.Period = "1m"
+		limit.Burst = 3
+		limit.Mode = RateLimitModeDelay
+		a.IsNil(limit.Validate())
+		a.IsTrue(limit.Interval() == 30*time.Second)
+		a.IsTrue(limit.MaxDelayDuration() == 90*time.Second)
+
+		limit.MaxDelay = "5s"
+		a.IsNil(limit.Validate())
+		a.IsTrue(limit.MaxDelayDuration() == 5*time.Second)
+	}
+
+	{
+		limit := NewRateLimitConfig()
+		limit.Rate = 1
+		limit.Period = "abc"
+		a.IsNotNil(limit.Validate())
+	}
+
+	{
+		limit := NewRateLimitConfig()
+		limit.Rate = 1
+		limit.Mode = "abc"
+		a.IsNotNil(limit.Validate())
+	}
+
+	{
+		limit := NewRateLimitConfig()
+		limit.Rate = 1
+		limit.Store = &LimitStoreConfig{Type: "abc"}
+		a.IsNotNil(limit.Validate())
+	}
+}
+
+func TestLimitStoreConfig_Key(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	var store *LimitStoreConfig
+	a.IsTrue(store.Key() == LimitStoreTypeMemory)
+
+	store = &LimitStoreConfig{
+		Type: LimitStoreTypeRedis,
+		Options: map[string]interface{}{
+			"port": 6379,
+			"host": "127.0.0.1",
+		},
+	}
+	a.IsTrue(store.Key() == "redis@host=127.0.0.1@port=6379")
+}
+
+func TestLimitList(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	list := &LimitList{}
+	a.IsFalse(list.HasLimits())
+
+	limit := NewConnLimitConfig()
+	list.AddConnLimit(limit)
+	a.IsNotNil(list.ValidateLimits())
+
+	limit.MaxConns = 10
+	a.IsNil(list.ValidateLimits())
+	a.IsTrue(list.HasLimits())
+	a.IsTrue(list.FindConnLimit(limit.Id) == limit)
+
+	list.RemoveConnLimit(limit.Id)
+	a.IsTrue(len(list.AllConnLimits()) == 0)
+	a.IsFalse(list.HasLimits())
+}
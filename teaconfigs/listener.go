@@ -1,6 +1,7 @@
 package teaconfigs
 
 import (
+	"errors"
 	"github.com/go-yaml/yaml"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/lists"
@@ -13,11 +14,14 @@ import (
 
 // 本地监听服务配置
 type ListenerConfig struct {
-	Key     string // 区分用的Key
-	Address string
-	Http    bool
-	SSL     *SSLConfig
-	Servers []*ServerConfig
+	Key           string // 区分用的Key
+	Address       string
+	Http          bool
+	H2C           bool          // 是否支持不加密的HTTP/2，只要有一个服务开启即支持
+	ProxyProtocol bool          // 是否接收PROXY协议，只要有一个服务开启即接收
+	SSL           *SSLConfig    // 第一个开启SSL的服务的配置，证书会根据SNI从各个服务中选择
+	Stream        *StreamConfig // TCP/UDP代理时第一个服务的设置，为nil表示HTTP监听服务
+	Servers       []*ServerConfig
 }
 
 // 从配置文件中分析配置
@@ -54,6 +58,12 @@ func ParseConfigs() ([]*ListenerConfig, error) {
 			continue
 		}
 
+		// TCP/UDP
+		if serverConfig.IsStream() {
+			addStreamListeners(listenerConfigMap, serverConfig)
+			continue
+		}
+
 		// HTTP
 		if serverConfig.Http {
 			for _, address := range serverConfig.Listen {
@@ -75,6 +85,12 @@ func ParseConfigs() ([]*ListenerConfig, error) {
 					listenerConfig.Servers = append(listenerConfig.Servers, serverConfig)
 				}
 				listenerConfig.Http = true
+				if serverConfig.H2C {
+					listenerConfig.H2C = true
+				}
+				if serverConfig.ProxyProtocol {
+					listenerConfig.ProxyProtocol = true
+				}
 			}
 		}
 
@@ -100,7 +116,12 @@ func ParseConfigs() ([]*ListenerConfig, error) {
 					listenerConfig.Servers = append(listenerConfig.Servers, serverConfig)
 				}
 				listenerConfig.Http = false
-				listenerConfig.SSL = serverConfig.SSL
+				if serverConfig.ProxyProtocol {
+					listenerConfig.ProxyProtocol = true
+				}
+				if listenerConfig.SSL == nil {
+					listenerConfig.SSL = serverConfig.SSL
+				}
 			}
 		}
 	}
@@ -113,6 +134,37 @@ func ParseConfigs() ([]*ListenerConfig, error) {
 	return listenerConfigArray, nil
 }
 
+// 添加TCP/UDP代理服务的监听
+// 同一个地址上只有TLS处理方式为解密或者SNI路由时，才可以有多个服务，并根据SNI选择服务
+func addStreamListeners(listenerConfigMap map[string]*ListenerConfig, serverConfig *ServerConfig) {
+	stream := serverConfig.Stream
+	for _, address := range stream.Listen {
+		key := stream.ListenerKey(address)
+		listenerConfig, found := listenerConfigMap[key]
+		if !found {
+			listenerConfig = &ListenerConfig{
+				Key:     key,
+				Address: address,
+				Stream:  stream,
+				Servers: []*ServerConfig{serverConfig},
+			}
+			listenerConfigMap[key] = listenerConfig
+		} else {
+			if stream.IsUDP() || stream.TLS == StreamTLSModeNone {
+				logs.Error(errors.New("server '" + serverConfig.Id + "': stream address '" + address + "' is already used by other servers"))
+				continue
+			}
+			listenerConfig.Servers = append(listenerConfig.Servers, serverConfig)
+		}
+		if serverConfig.ProxyProtocol && !stream.IsUDP() {
+			listenerConfig.ProxyProtocol = true
+		}
+		if stream.TLS == StreamTLSModeTerminate && listenerConfig.SSL == nil {
+			listenerConfig.SSL = serverConfig.SSL
+		}
+	}
+}
+
 // 获取当前监听服务的端口
 func (this *ListenerConfig) Port() int {
 	index := strings.LastIndex(this.Address, ":")
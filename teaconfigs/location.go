@@ -3,6 +3,7 @@ package teaconfigs
 import (
 	"fmt"
 	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/TeaWeb/code/teautils"
 	"github.com/iwind/TeaGo/utils/string"
 	"regexp"
 	"strings"
@@ -14,24 +15,33 @@ type LocationConfig struct {
 	FastcgiList       `yaml:",inline"`
 	RewriteList       `yaml:",inline"`
 	BackendList       `yaml:",inline"`
+	ErrorPageList     `yaml:",inline"`
+	LimitList         `yaml:",inline"`
+	NotifyList        `yaml:",inline"`
 
 	On      bool   `yaml:"on" json:"on"`           // 是否开启
 	Id      string `yaml:"id" json:"id"`           // ID
 	Pattern string `yaml:"pattern" json:"pattern"` // 匹配规则
 
-	Async   bool          `yaml:"async" json:"async"`     // 是否异步请求 @TODO
-	Notify  []interface{} `yaml:"notify" json:"notify"`   // 转发请求，可以配置转发策略 @TODO
-	LogOnly bool          `yaml:"logOnly" json:"logOnly"` // 是否只记录日志 @TODO
-	Root    string        `yaml:"root" json:"root"`       // 资源根目录
-	Index   []string      `yaml:"index" json:"index"`     // 默认文件
-	Charset string        `yaml:"charset" json:"charset"` // 字符集设置
+	Async           bool                  `yaml:"async" json:"async"`                     // 请求是否异步处理，立即返回响应后在后台转发到后端服务
+	AsyncConfig     *AsyncConfig          `yaml:"asyncConfig" json:"asyncConfig"`         // 异步处理设置
+	LogOnly         bool                  `yaml:"logOnly" json:"logOnly"`                 // 是否只记录日志，不请求后端服务
+	LogOnlyResponse *DirectResponseConfig `yaml:"logOnlyResponse" json:"logOnlyResponse"` // 只记录日志时返回的响应，默认返回200
+
+	Root    string   `yaml:"root" json:"root"`       // 资源根目录
+	Index   []string `yaml:"index" json:"index"`     // 默认文件
+	Charset string   `yaml:"charset" json:"charset"` // 字符集设置
 
 	// 日志
 	AccessLog []*AccessLogConfig `yaml:"accessLog" json:"accessLog"` // @TODO
 
 	// 参考：http://nginx.org/en/docs/http/ngx_http_access_module.html
-	Allow []string `yaml:"allow" json:"allow"` // 允许的终端地址 @TODO
-	Deny  []string `yaml:"deny" json:"deny"`   // 禁止的终端地址 @TODO
+	// 依次匹配access、deny和allow中的规则，第一个匹配的规则生效；都不匹配时，如果设置了allow则禁止访问，否则允许访问
+	Access []string `yaml:"access" json:"access"` // 按顺序匹配的访问规则，比如：allow 192.168.1.0/24、deny all
+	Allow  []string `yaml:"allow" json:"allow"`   // 允许的终端地址，支持IP、CIDR和all，在deny之后匹配，设置后禁止其他地址
+	Deny   []string `yaml:"deny" json:"deny"`     // 禁止的终端地址，支持IP、CIDR和all，在access之后匹配，优先于allow
+
+	accessList *teautils.IPAccessList
 
 	Proxy string `yaml:proxy" json:"proxy"` //  代理配置 @TODO
 
@@ -42,6 +52,9 @@ type LocationConfig struct {
 	// websocket设置
 	Websocket *WebsocketConfig `yaml:"websocket" json:"websocket"`
 
+	// 压缩设置
+	Compression *CompressionConfig `yaml:"compression" json:"compression"`
+
 	patternType LocationPatternType // 规则类型：LocationPattern*
 	prefix      string              // 前缀
 	path        string              // 精确的路径
@@ -165,6 +178,38 @@ func (this *LocationConfig) Validate() error {
 		return err
 	}
 
+	// 错误页面
+	err = this.ValidateErrorPages()
+	if err != nil {
+		return err
+	}
+
+	// 限速和连接数限制
+	err = this.ValidateLimits()
+	if err != nil {
+		return err
+	}
+
+	// 请求镜像
+	err = this.ValidateNotify()
+	if err != nil {
+		return err
+	}
+
+	// 异步处理和只记录日志
+	if this.AsyncConfig != nil {
+		err = this.AsyncConfig.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if this.LogOnlyResponse != nil {
+		err = this.LogOnlyResponse.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
 	// 校验Fastcgi配置
 	err = this.ValidateFastcgi()
 	if err != nil {
@@ -184,9 +229,28 @@ func (this *LocationConfig) Validate() error {
 		}
 	}
 
+	// compression
+	if this.Compression != nil {
+		err = this.Compression.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// access, allow & deny
+	this.accessList, err = parseIPAccessList(this.Access, this.Allow, this.Deny)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// 判断某个终端地址是否可以访问
+func (this *LocationConfig) AllowIP(ip string) bool {
+	return this.accessList.Check(ip)
+}
+
 // 模式类型
 func (this *LocationConfig) PatternType() int {
 	return this.patternType
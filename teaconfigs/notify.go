@@ -0,0 +1,132 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/utils/string"
+	"math/rand"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 请求镜像（影子流量）配置
+// 匹配的请求会复制一份异步发送到镜像地址，镜像的响应会被丢弃，不影响原始请求
+type NotifyConfig struct {
+	On          bool    `yaml:"on" json:"on"`                   // 是否开启
+	Id          string  `yaml:"id" json:"id"`                   // ID
+	Address     string  `yaml:"address" json:"address"`         // 镜像地址，比如http://192.168.1.100:8080，请求的URI会附加在后面
+	Host        string  `yaml:"host" json:"host"`               // 发送镜像请求时使用的Host，为空表示使用原始请求的Host
+	Percent     float64 `yaml:"percent" json:"percent"`         // 采样百分比，0-100
+	Timeout     string  `yaml:"timeout" json:"timeout"`         // 超时时间，比如10s
+	MaxBodySize string  `yaml:"maxBodySize" json:"maxBodySize"` // 请求体最大尺寸，比如1m，请求体超出此尺寸的请求不会被镜像
+
+	url         *url.URL
+	timeout     time.Duration
+	maxBodySize int64
+}
+
+// 默认超时时间
+const defaultNotifyTimeout = 10 * time.Second
+
+// 默认请求体最大尺寸
+const defaultNotifyMaxBodySize = 1024 * 1024
+
+// 获取新对象
+func NewNotifyConfig() *NotifyConfig {
+	return &NotifyConfig{
+		On:          true,
+		Id:          stringutil.Rand(16),
+		Percent:     100,
+		Timeout:     "10s",
+		MaxBodySize: "1m",
+	}
+}
+
+// 从YAML中读取，兼容以前只有地址的写法
+func (this *NotifyConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	address := ""
+	if unmarshal(&address) == nil {
+		*this = *NewNotifyConfig()
+		this.Address = address
+		return nil
+	}
+
+	type notifyConfig NotifyConfig
+	config := notifyConfig(*NewNotifyConfig())
+	err := unmarshal(&config)
+	if err != nil {
+		return err
+	}
+	*this = NotifyConfig(config)
+	return nil
+}
+
+// 校验
+func (this *NotifyConfig) Validate() error {
+	address := strings.TrimSpace(this.Address)
+	if len(address) == 0 {
+		return errors.New("notify address should not be empty")
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || len(u.Host) == 0 || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("invalid notify address '" + this.Address + "'")
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	this.url = u
+
+	if this.Percent < 0 || this.Percent > 100 {
+		return errors.New("notify percent should be between 0 and 100")
+	}
+
+	this.timeout = defaultNotifyTimeout
+	if len(this.Timeout) > 0 {
+		timeout, err := time.ParseDuration(this.Timeout)
+		if err != nil || timeout <= 0 {
+			return errors.New("invalid notify timeout '" + this.Timeout + "'")
+		}
+		this.timeout = timeout
+	}
+
+	this.maxBodySize = defaultNotifyMaxBodySize
+	if len(this.MaxBodySize) > 0 {
+		size, err := stringutil.ParseFileSize(this.MaxBodySize)
+		if err != nil || size < 0 {
+			return errors.New("invalid notify max body size '" + this.MaxBodySize + "'")
+		}
+		this.maxBodySize = int64(size)
+	}
+
+	return nil
+}
+
+// 根据采样百分比判断是否镜像当前请求
+func (this *NotifyConfig) Sample() bool {
+	if !this.On || this.Percent <= 0 {
+		return false
+	}
+	if this.Percent >= 100 {
+		return true
+	}
+	return rand.Float64()*100 < this.Percent
+}
+
+// 镜像请求的完整URL
+func (this *NotifyConfig) URL(uri string) string {
+	if this.url == nil {
+		return ""
+	}
+	return this.url.Scheme + "://" + this.url.Host + this.url.Path + uri
+}
+
+// 超时时间
+func (this *NotifyConfig) TimeoutDuration() time.Duration {
+	return this.timeout
+}
+
+// 请求体最大尺寸
+func (this *NotifyConfig) MaxBodyBytes() int64 {
+	return this.maxBodySize
+}
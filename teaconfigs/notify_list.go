@@ -0,0 +1,81 @@
+package teaconfigs
+
+// NotifyList接口
+type NotifyListInterface interface {
+	// 校验
+	ValidateNotify() error
+
+	// 取得所有的镜像
+	AllNotify() []*NotifyConfig
+
+	// 根据ID查找镜像
+	FindNotify(notifyId string) *NotifyConfig
+
+	// 添加镜像
+	AddNotify(notify *NotifyConfig)
+
+	// 删除镜像
+	RemoveNotify(notifyId string)
+}
+
+// NotifyList定义
+type NotifyList struct {
+	Notify []*NotifyConfig `yaml:"notify" json:"notify"` // 请求镜像
+}
+
+// 校验
+func (this *NotifyList) ValidateNotify() error {
+	for _, notify := range this.Notify {
+		err := notify.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 是否有开启的镜像
+func (this *NotifyList) HasNotify() bool {
+	for _, notify := range this.Notify {
+		if notify.On {
+			return true
+		}
+	}
+	return false
+}
+
+// 取得所有的镜像
+func (this *NotifyList) AllNotify() []*NotifyConfig {
+	if this.Notify == nil {
+		return []*NotifyConfig{}
+	}
+	return this.Notify
+}
+
+// 根据ID查找镜像
+func (this *NotifyList) FindNotify(notifyId string) *NotifyConfig {
+	for _, notify := range this.Notify {
+		if notify.Id == notifyId {
+			notify.Validate()
+			return notify
+		}
+	}
+	return nil
+}
+
+// 添加镜像
+func (this *NotifyList) AddNotify(notify *NotifyConfig) {
+	this.Notify = append(this.Notify, notify)
+}
+
+// 删除镜像
+func (this *NotifyList) RemoveNotify(notifyId string) {
+	result := []*NotifyConfig{}
+	for _, notify := range this.Notify {
+		if notify.Id == notifyId {
+			continue
+		}
+		result = append(result, notify)
+	}
+	this.Notify = result
+}
@@ -0,0 +1,78 @@
+package teaconfigs
+
+import (
+	"github.com/go-yaml/yaml"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+func TestNotifyConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		notify := NewNotifyConfig()
+		a.IsNotNil(notify.Validate())
+	}
+
+	{
+		notify := NewNotifyConfig()
+		notify.Address = "127.0.0.1:8080"
+		a.IsNil(notify.Validate())
+		a.IsTrue(notify.URL("/hello?name=Tea") == "http://127.0.0.1:8080/hello?name=Tea")
+		a.IsTrue(notify.TimeoutDuration() == 10*time.Second)
+		a.IsTrue(notify.MaxBodyBytes() == 1024*1024)
+		a.IsTrue(notify.Sample())
+	}
+
+	{
+		notify := NewNotifyConfig()
+		notify.Address = "https://example.com/mirror/"
+		notify.Timeout = "3s"
+		notify.MaxBodySize = "2k"
+		a.IsNil(notify.Validate())
+		a.IsTrue(notify.URL("/hello") == "https://example.com/mirror/hello")
+		a.IsTrue(notify.TimeoutDuration() == 3*time.Second)
+		a.IsTrue(notify.MaxBodyBytes() == 2048)
+	}
+
+	{
+		notify := NewNotifyConfig()
+		notify.Address = "ftp://example.com"
+		a.IsNotNil(notify.Validate())
+	}
+
+	{
+		notify := NewNotifyConfig()
+		notify.Address = "127.0.0.1:8080"
+		notify.Percent = 101
+		a.IsNotNil(notify.Validate())
+
+		notify.Percent = 0
+		a.IsNil(notify.Validate())
+		a.IsFalse(notify.Sample())
+	}
+}
+
+func TestNotifyConfig_UnmarshalYAML(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	list := &NotifyList{}
+	err := yaml.Unmarshal([]byte(`notify:
+  - 127.0.0.1:8080
+  - address: 127.0.0.1:8081
+    percent: 10
+`), list)
+	a.IsNil(err)
+	a.IsTrue(len(list.Notify) == 2)
+	a.IsNil(list.ValidateNotify())
+
+	a.IsTrue(list.Notify[0].On)
+	a.IsTrue(list.Notify[0].Address == "127.0.0.1:8080")
+	a.IsTrue(list.Notify[0].Percent == 100)
+
+	a.IsTrue(list.Notify[1].On)
+	a.IsTrue(list.Notify[1].Address == "127.0.0.1:8081")
+	a.IsTrue(list.Notify[1].Percent == 10)
+	a.IsTrue(list.Notify[1].Timeout == "10s")
+}
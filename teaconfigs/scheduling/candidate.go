@@ -8,3 +8,44 @@ type CandidateInterface interface {
 	// 代号
 	CandidateCodes() []string
 }
+
+// 可以统计当前连接数的候选对象
+type ConnCandidateInterface interface {
+	// 当前连接数
+	CandidateConns() uint
+}
+
+// 可以统计响应时间的候选对象
+type ResponseTimeCandidateInterface interface {
+	// 平均响应时间，单位为秒，0表示还没有统计数据
+	CandidateResponseTime() float64
+}
+
+// 候选对象的权重，限制在1-10000之间
+func candidateWeight(c CandidateInterface) uint {
+	weight := c.CandidateWeight()
+	if weight == 0 {
+		weight = 1
+	} else if weight > 10000 {
+		weight = 10000
+	}
+	return weight
+}
+
+// 候选对象的当前连接数
+func candidateConns(c CandidateInterface) uint {
+	connCandidate, ok := c.(ConnCandidateInterface)
+	if !ok {
+		return 0
+	}
+	return connCandidate.CandidateConns()
+}
+
+// 候选对象的平均响应时间
+func candidateResponseTime(c CandidateInterface) float64 {
+	responseTimeCandidate, ok := c.(ResponseTimeCandidateInterface)
+	if !ok {
+		return 0
+	}
+	return responseTimeCandidate.CandidateResponseTime()
+}
@@ -0,0 +1,116 @@
+package scheduling
+
+import (
+	"crypto/md5"
+	"github.com/iwind/TeaGo/maps"
+	"sort"
+	"strconv"
+)
+
+// 权重最小的候选对象的虚拟节点数
+const consistentHashReplicas = 160
+
+// 每个候选对象最多的虚拟节点数
+const consistentHashMaxPoints = consistentHashReplicas * 100
+
+// 一致性Hash（Ketama）调度算法
+type ConsistentHashScheduling struct {
+	Scheduling
+
+	points []consistentHashPoint // 按Hash值排序的虚拟节点
+}
+
+// 虚拟节点
+type consistentHashPoint struct {
+	hash      uint32
+	candidate CandidateInterface
+}
+
+// 启动
+func (this *ConsistentHashScheduling) Start() {
+	this.points = []consistentHashPoint{}
+
+	// 以最小的权重为基准分配虚拟节点，这样增加或删除权重不小于它的候选对象时，其他候选对象的虚拟节点不会变化
+	minWeight := uint(0)
+	for _, c := range this.Candidates {
+		weight := candidateWeight(c)
+		if minWeight == 0 || weight < minWeight {
+			minWeight = weight
+		}
+	}
+	if minWeight == 0 {
+		return
+	}
+
+	for _, c := range this.Candidates {
+		// 每个md5值可以生成4个节点
+		countPoints := consistentHashReplicas * candidateWeight(c) / minWeight
+		if countPoints > consistentHashMaxPoints {
+			countPoints = consistentHashMaxPoints
+		}
+
+		name := consistentHashName(c)
+		for i := uint(0); i < countPoints/4; i++ {
+			sum := md5.Sum([]byte(name + "-" + strconv.FormatUint(uint64(i), 10)))
+			for j := 0; j < 4; j++ {
+				this.points = append(this.points, consistentHashPoint{
+					hash:      consistentHashValue(sum, j),
+					candidate: c,
+				})
+			}
+		}
+	}
+
+	sort.Slice(this.points, func(i, j int) bool {
+		return this.points[i].hash < this.points[j].hash
+	})
+}
+
+// 获取下一个候选对象
+func (this *ConsistentHashScheduling) Next(options maps.Map) CandidateInterface {
+	if len(this.points) == 0 {
+		return nil
+	}
+
+	key := options.GetString("key")
+
+	formatter := options.Get("formatter")
+	if formatter != nil {
+		f, ok := formatter.(func(string) string)
+		if ok {
+			key = f(key)
+		}
+	}
+
+	hash := consistentHashValue(md5.Sum([]byte(key)), 0)
+	index := sort.Search(len(this.points), func(i int) bool {
+		return this.points[i].hash >= hash
+	})
+	if index == len(this.points) {
+		index = 0
+	}
+	return this.points[index].candidate
+}
+
+// 获取简要信息
+func (this *ConsistentHashScheduling) Summary() maps.Map {
+	return maps.Map{
+		"code":        "consistentHash",
+		"name":        "ConsistentHash一致性Hash算法",
+		"description": "根据自定义的键值的Hash值分配后端服务器，增加或删除后端服务器时只会影响少部分键值",
+	}
+}
+
+// 候选对象在Hash环上的名称
+func consistentHashName(c CandidateInterface) string {
+	codes := c.CandidateCodes()
+	if len(codes) == 0 {
+		return ""
+	}
+	return codes[0]
+}
+
+// 从md5值中取出第index个32位的Hash值
+func consistentHashValue(sum [md5.Size]byte, index int) uint32 {
+	return uint32(sum[3+index*4])<<24 | uint32(sum[2+index*4])<<16 | uint32(sum[1+index*4])<<8 | uint32(sum[index*4])
+}
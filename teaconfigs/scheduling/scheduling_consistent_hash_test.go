@@ -0,0 +1,104 @@
+package scheduling
+
+import (
+	"fmt"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestConsistentHashScheduling_Next(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	s := &ConsistentHashScheduling{}
+	s.Add(&TestCandidate{
+		Name:   "a",
+		Weight: 10,
+	})
+	s.Add(&TestCandidate{
+		Name:   "b",
+		Weight: 10,
+	})
+	s.Start()
+
+	options := map[string]interface{}{
+		"key": "192.168.1.100",
+	}
+	c := s.Next(options)
+	for i := 0; i < 10; i++ {
+		a.IsTrue(s.Next(options) == c)
+	}
+
+	// formatter
+	c = s.Next(map[string]interface{}{
+		"key": "${remoteAddr}",
+		"formatter": func(s string) string {
+			return "192.168.1.100"
+		},
+	})
+	a.IsTrue(s.Next(options) == c)
+}
+
+func TestConsistentHashScheduling_Distribution(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	newScheduling := func(names ...string) *ConsistentHashScheduling {
+		s := &ConsistentHashScheduling{}
+		for _, name := range names {
+			weight := uint(10)
+			if name == "d" {
+				weight = 20
+			}
+			s.Add(&TestCandidate{
+				Name:   name,
+				Weight: weight,
+			})
+		}
+		s.Start()
+		return s
+	}
+
+	countKeys := 100000
+	lookup := func(s *ConsistentHashScheduling) []string {
+		result := []string{}
+		for i := 0; i < countKeys; i++ {
+			c := s.Next(map[string]interface{}{
+				"key": fmt.Sprintf("192.168.%d.%d", i/256, i%256),
+			})
+			result = append(result, c.(*TestCandidate).Name)
+		}
+		return result
+	}
+
+	// 根据权重分布
+	s := newScheduling("a", "b", "c", "d")
+	before := lookup(s)
+	hits := map[string]int{}
+	for _, name := range before {
+		hits[name]++
+	}
+	t.Log(hits)
+	for _, name := range []string{"a", "b", "c"} {
+		a.IsTrue(hits[name] > countKeys*15/100 && hits[name] < countKeys*25/100)
+	}
+	a.IsTrue(hits["d"] > countKeys*30/100 && hits["d"] < countKeys*50/100)
+
+	// 删除一个节点，只影响原来分配到这个节点的键值
+	after := lookup(newScheduling("a", "b", "d"))
+	for i, name := range before {
+		if name != "c" {
+			a.IsTrue(after[i] == name)
+		}
+	}
+
+	// 增加一个节点，只有一小部分键值会重新分配，而且都分配到新的节点上
+	after = lookup(newScheduling("a", "b", "c", "d", "e"))
+	moved := 0
+	for i, name := range before {
+		if after[i] != name {
+			moved++
+			a.IsTrue(after[i] == "e")
+		}
+	}
+	t.Log("moved:", moved)
+	a.IsTrue(moved < countKeys*30/100)
+}
@@ -0,0 +1,48 @@
+package scheduling
+
+import (
+	"github.com/iwind/TeaGo/maps"
+	"sync/atomic"
+)
+
+// 最少连接数调度算法
+type LeastConnScheduling struct {
+	Scheduling
+
+	count uint32
+	index uint32 // 连接数相同时轮流选择
+}
+
+// 启动
+func (this *LeastConnScheduling) Start() {
+	this.count = uint32(len(this.Candidates))
+}
+
+// 获取下一个候选对象
+func (this *LeastConnScheduling) Next(options maps.Map) CandidateInterface {
+	if this.count == 0 {
+		return nil
+	}
+
+	offset := atomic.AddUint32(&this.index, 1)
+	var result CandidateInterface = nil
+	minScore := float64(0)
+	for i := uint32(0); i < this.count; i++ {
+		c := this.Candidates[(offset+i)%this.count]
+		score := float64(candidateConns(c)) / float64(candidateWeight(c))
+		if result == nil || score < minScore {
+			result = c
+			minScore = score
+		}
+	}
+	return result
+}
+
+// 获取简要信息
+func (this *LeastConnScheduling) Summary() maps.Map {
+	return maps.Map{
+		"code":        "leastConn",
+		"name":        "LeastConn最少连接算法",
+		"description": "根据权重，优先分配当前连接数最少的后端服务器",
+	}
+}
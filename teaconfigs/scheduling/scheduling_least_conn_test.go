@@ -0,0 +1,82 @@
+package scheduling
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+type testConnCandidate struct {
+	TestCandidate
+
+	conns        uint
+	responseTime float64
+}
+
+func (this *testConnCandidate) CandidateConns() uint {
+	return this.conns
+}
+
+func (this *testConnCandidate) CandidateResponseTime() float64 {
+	return this.responseTime
+}
+
+func TestLeastConnScheduling_Next(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	c1 := &testConnCandidate{TestCandidate: TestCandidate{Name: "a", Weight: 1}, conns: 3}
+	c2 := &testConnCandidate{TestCandidate: TestCandidate{Name: "b", Weight: 1}, conns: 1}
+	c3 := &testConnCandidate{TestCandidate: TestCandidate{Name: "c", Weight: 1}, conns: 2}
+
+	s := &LeastConnScheduling{}
+	s.Add(c1, c2, c3)
+	s.Start()
+
+	for i := 0; i < 10; i++ {
+		a.IsTrue(s.Next(nil) == c2)
+	}
+
+	// 权重
+	c1.Weight = 4
+	a.IsTrue(s.Next(nil) == c1)
+}
+
+func TestLeastConnScheduling_Distribution(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	s := &LeastConnScheduling{}
+	candidates := []*testConnCandidate{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		c := &testConnCandidate{TestCandidate: TestCandidate{Name: name, Weight: 1}}
+		candidates = append(candidates, c)
+		s.Add(c)
+	}
+	candidates[3].Weight = 2
+	s.Start()
+
+	// 模拟请求一直不结束，连接数会按照权重分配
+	hits := map[string]uint{}
+	for i := 0; i < 5000; i++ {
+		c := s.Next(nil).(*testConnCandidate)
+		c.conns++
+		hits[c.Name]++
+	}
+	t.Log(hits)
+	a.IsTrue(hits["a"] == 1000)
+	a.IsTrue(hits["b"] == 1000)
+	a.IsTrue(hits["c"] == 1000)
+	a.IsTrue(hits["d"] == 2000)
+
+	// 连接数相同时轮流分配
+	for _, c := range candidates {
+		c.conns = 0
+		c.Weight = 1
+	}
+	hits = map[string]uint{}
+	for i := 0; i < 4000; i++ {
+		hits[s.Next(nil).(*testConnCandidate).Name]++
+	}
+	t.Log(hits)
+	for _, count := range hits {
+		a.IsTrue(count == 1000)
+	}
+}
@@ -0,0 +1,49 @@
+package scheduling
+
+import (
+	"github.com/iwind/TeaGo/maps"
+	"sync/atomic"
+)
+
+// 最短响应时间调度算法
+type LeastTimeScheduling struct {
+	Scheduling
+
+	count uint32
+	index uint32 // 分数相同时轮流选择
+}
+
+// 启动
+func (this *LeastTimeScheduling) Start() {
+	this.count = uint32(len(this.Candidates))
+}
+
+// 获取下一个候选对象
+// 分数为平均响应时间乘以连接数（包括当前请求），再除以权重，还没有统计数据的候选对象优先
+func (this *LeastTimeScheduling) Next(options maps.Map) CandidateInterface {
+	if this.count == 0 {
+		return nil
+	}
+
+	offset := atomic.AddUint32(&this.index, 1)
+	var result CandidateInterface = nil
+	minScore := float64(0)
+	for i := uint32(0); i < this.count; i++ {
+		c := this.Candidates[(offset+i)%this.count]
+		score := candidateResponseTime(c) * float64(candidateConns(c)+1) / float64(candidateWeight(c))
+		if result == nil || score < minScore {
+			result = c
+			minScore = score
+		}
+	}
+	return result
+}
+
+// 获取简要信息
+func (this *LeastTimeScheduling) Summary() maps.Map {
+	return maps.Map{
+		"code":        "leastTime",
+		"name":        "LeastTime最短响应时间算法",
+		"description": "根据后端服务器的平均响应时间、当前连接数和权重分配后端服务器",
+	}
+}
@@ -0,0 +1,49 @@
+package scheduling
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestLeastTimeScheduling_Next(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	c1 := &testConnCandidate{TestCandidate: TestCandidate{Name: "a", Weight: 1}, responseTime: 0.5}
+	c2 := &testConnCandidate{TestCandidate: TestCandidate{Name: "b", Weight: 1}, responseTime: 0.1}
+	c3 := &testConnCandidate{TestCandidate: TestCandidate{Name: "c", Weight: 1}, responseTime: 0.2}
+
+	s := &LeastTimeScheduling{}
+	s.Add(c1, c2, c3)
+	s.Start()
+
+	a.IsTrue(s.Next(nil) == c2)
+
+	// 连接数
+	c2.conns = 2
+	a.IsTrue(s.Next(nil) == c3)
+
+	// 还没有统计数据的优先
+	c1.responseTime = 0
+	a.IsTrue(s.Next(nil) == c1)
+}
+
+func TestLeastTimeScheduling_Distribution(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	s := &LeastTimeScheduling{}
+	fast := &testConnCandidate{TestCandidate: TestCandidate{Name: "fast", Weight: 1}, responseTime: 0.1}
+	slow := &testConnCandidate{TestCandidate: TestCandidate{Name: "slow", Weight: 1}, responseTime: 0.3}
+	s.Add(fast, slow)
+	s.Start()
+
+	// 模拟请求一直不结束，响应快的服务器分配到更多请求
+	hits := map[string]uint{}
+	for i := 0; i < 4000; i++ {
+		c := s.Next(nil).(*testConnCandidate)
+		c.conns++
+		hits[c.Name]++
+	}
+	t.Log(hits)
+	a.IsTrue(hits["fast"] >= 2990 && hits["fast"] <= 3010)
+	a.IsTrue(hits["slow"] >= 990 && hits["slow"] <= 1010)
+}
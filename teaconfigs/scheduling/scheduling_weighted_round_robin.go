@@ -0,0 +1,59 @@
+package scheduling
+
+import (
+	"github.com/iwind/TeaGo/maps"
+	"sync"
+)
+
+// 平滑加权轮询调度算法，和nginx的weighted round-robin相同
+type WeightedRoundRobinScheduling struct {
+	Scheduling
+
+	weights        []int
+	currentWeights []int
+	totalWeight    int
+
+	locker sync.Mutex
+}
+
+// 启动
+func (this *WeightedRoundRobinScheduling) Start() {
+	this.weights = []int{}
+	this.totalWeight = 0
+	for _, c := range this.Candidates {
+		weight := int(candidateWeight(c))
+		this.weights = append(this.weights, weight)
+		this.totalWeight += weight
+	}
+	this.currentWeights = make([]int, len(this.Candidates))
+}
+
+// 获取下一个候选对象
+// 每次给所有候选对象的当前权重加上各自的权重，选出当前权重最大的，再将它的当前权重减去总权重
+func (this *WeightedRoundRobinScheduling) Next(options maps.Map) CandidateInterface {
+	if len(this.weights) == 0 {
+		return nil
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	best := -1
+	for index, weight := range this.weights {
+		this.currentWeights[index] += weight
+		if best < 0 || this.currentWeights[index] > this.currentWeights[best] {
+			best = index
+		}
+	}
+	this.currentWeights[best] -= this.totalWeight
+	return this.Candidates[best]
+}
+
+// 获取简要信息
+func (this *WeightedRoundRobinScheduling) Summary() maps.Map {
+	return maps.Map{
+		"code":        "weightedRoundRobin",
+		"name":        "WeightedRoundRobin平滑加权轮询算法",
+		"description": "根据权重平滑地依次分配后端服务器，权重高的服务器不会被连续集中分配",
+	}
+}
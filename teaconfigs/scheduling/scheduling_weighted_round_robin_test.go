@@ -0,0 +1,63 @@
+package scheduling
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"strings"
+	"testing"
+)
+
+func TestWeightedRoundRobinScheduling_Next(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	s := &WeightedRoundRobinScheduling{}
+	s.Add(&TestCandidate{
+		Name:   "a",
+		Weight: 5,
+	})
+	s.Add(&TestCandidate{
+		Name:   "b",
+		Weight: 1,
+	})
+	s.Add(&TestCandidate{
+		Name:   "c",
+		Weight: 1,
+	})
+	s.Start()
+
+	names := []string{}
+	for i := 0; i < 14; i++ {
+		names = append(names, s.Next(nil).(*TestCandidate).Name)
+	}
+	t.Log(names)
+
+	// 和nginx的分配顺序相同
+	a.IsTrue(strings.Join(names, "") == "aabacaaaabacaa")
+}
+
+func TestWeightedRoundRobinScheduling_Distribution(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	s := &WeightedRoundRobinScheduling{}
+	weights := map[string]uint{
+		"a": 1,
+		"b": 2,
+		"c": 3,
+		"d": 6,
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		s.Add(&TestCandidate{
+			Name:   name,
+			Weight: weights[name],
+		})
+	}
+	s.Start()
+
+	hits := map[string]uint{}
+	for i := 0; i < 12*10000; i++ {
+		hits[s.Next(nil).(*TestCandidate).Name]++
+	}
+	t.Log(hits)
+	for name, weight := range weights {
+		a.IsTrue(hits[name] == weight*10000)
+	}
+}
@@ -5,7 +5,7 @@ import "github.com/iwind/TeaGo/maps"
 // 所有请求类型
 func AllSchedulingTypes() []maps.Map {
 	types := []maps.Map{}
-	for _, s := range []SchedulingInterface{new(RandomScheduling), new(RoundRobinScheduling), new(HashScheduling), new(StickyScheduling)} {
+	for _, s := range []SchedulingInterface{new(RandomScheduling), new(RoundRobinScheduling), new(WeightedRoundRobinScheduling), new(HashScheduling), new(ConsistentHashScheduling), new(StickyScheduling), new(LeastConnScheduling), new(LeastTimeScheduling)} {
 		summary := s.Summary()
 		summary["instance"] = s
 		types = append(types, summary)
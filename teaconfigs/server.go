@@ -11,6 +11,7 @@ import (
 	"github.com/iwind/TeaGo/logs"
 	"github.com/iwind/TeaGo/utils/string"
 	"github.com/mozillazg/go-pinyin"
+	"net/http"
 	"strings"
 )
 
@@ -20,6 +21,9 @@ type ServerConfig struct {
 	FastcgiList       `yaml:",inline"`
 	RewriteList       `yaml:",inline"`
 	BackendList       `yaml:",inline"`
+	ErrorPageList     `yaml:",inline"`
+	LimitList         `yaml:",inline"`
+	NotifyList        `yaml:",inline"`
 
 	On bool `yaml:"on" json:"on"` // 是否开启 @TODO
 
@@ -27,6 +31,7 @@ type ServerConfig struct {
 	Description string   `yaml:"description" json:"description"` // 描述
 	Name        []string `yaml:"name" json:"name"`               // 域名
 	Http        bool     `yaml:"http" json:"http"`               // 是否支持HTTP
+	H2C         bool     `yaml:"h2c" json:"h2c"`                 // 是否支持不加密的HTTP/2（h2c）
 
 	// 监听地址
 	Listen []string `yaml:"listen" json:"listen"`
@@ -36,9 +41,10 @@ type ServerConfig struct {
 	Charset   string            `yaml:"charset" json:"charset"`     // 字符集 @TODO
 	Locations []*LocationConfig `yaml:"locations" json:"locations"` // 地址配置
 
-	Async   bool     `yaml:"async" json:"async"`     // 请求是否异步处理 @TODO
-	Notify  []string `yaml:"notify" json:"notify"`   // 请求转发地址 @TODO
-	LogOnly bool     `yaml:"logOnly" json:"logOnly"` // 是否只记录日志 @TODO
+	Async           bool                  `yaml:"async" json:"async"`                     // 请求是否异步处理，立即返回响应后在后台转发到后端服务
+	AsyncConfig     *AsyncConfig          `yaml:"asyncConfig" json:"asyncConfig"`         // 异步处理设置
+	LogOnly         bool                  `yaml:"logOnly" json:"logOnly"`                 // 是否只记录日志，不请求后端服务
+	LogOnlyResponse *DirectResponseConfig `yaml:"logOnlyResponse" json:"logOnlyResponse"` // 只记录日志时返回的响应，默认返回200
 
 	// 访问日志
 	AccessLog []*AccessLogConfig `yaml:"accessLog" json:"accessLog"` // 访问日志
@@ -48,9 +54,20 @@ type ServerConfig struct {
 	// SSL
 	SSL *SSLConfig `yaml:"ssl" json:"ssl"`
 
+	// TCP/UDP代理，开启后不再处理HTTP请求
+	Stream *StreamConfig `yaml:"stream" json:"stream"`
+
 	// 参考：http://nginx.org/en/docs/http/ngx_http_access_module.html
-	Allow []string `yaml:"allow" json:"allow"` //TODO
-	Deny  []string `yaml:"deny" json:"deny"`   //TODO
+	// 依次匹配access、deny和allow中的规则，第一个匹配的规则生效；都不匹配时，如果设置了allow则禁止访问，否则允许访问
+	Access []string `yaml:"access" json:"access"` // 按顺序匹配的访问规则，比如：allow 192.168.1.0/24、deny all
+	Allow  []string `yaml:"allow" json:"allow"`   // 允许的终端地址，支持IP、CIDR和all，在deny之后匹配，设置后禁止其他地址
+	Deny   []string `yaml:"deny" json:"deny"`     // 禁止的终端地址，支持IP、CIDR和all，在access之后匹配，优先于allow
+
+	TrustedProxies []string `yaml:"trustedProxies" json:"trustedProxies"` // 受信任的代理地址，支持IP和CIDR，用来从Forwarded、X-Forwarded-For和X-Real-IP中获取终端地址
+	ProxyProtocol  bool     `yaml:"proxyProtocol" json:"proxyProtocol"`   // 是否在监听端口上接收HAProxy PROXY协议（v1/v2），同一个端口上的其他服务也会生效
+
+	accessList         *teautils.IPAccessList
+	trustedProxiesList *teautils.IPRangeList
 
 	Filename string `yaml:"filename" json:"filename"` // 配置文件名
 
@@ -62,6 +79,9 @@ type ServerConfig struct {
 
 	// API相关
 	API *api.APIConfig `yaml:"api" json:"api"` // API配置
+
+	// 压缩设置
+	Compression *CompressionConfig `yaml:"compression" json:"compression"`
 }
 
 // 从目录中加载配置
@@ -150,12 +170,33 @@ func NewServerConfigFromFile(filename string) (*ServerConfig, error) {
 func (this *ServerConfig) Validate() error {
 	// ssl
 	if this.SSL != nil {
+		// 自动申请的证书保存在配置目录下
+		if this.SSL.IsACME() {
+			if len(this.SSL.Certificate) == 0 {
+				this.SSL.Certificate = "ssl.acme." + this.Id + ".pem"
+			}
+			if len(this.SSL.CertificateKey) == 0 {
+				this.SSL.CertificateKey = "ssl.acme." + this.Id + ".key"
+			}
+		}
+
 		err := this.SSL.Validate()
 		if err != nil {
 			return err
 		}
 	}
 
+	// TCP/UDP代理
+	if this.Stream != nil {
+		err := this.Stream.Validate()
+		if err != nil {
+			return err
+		}
+		if this.Stream.On && this.Stream.TLS == StreamTLSModeTerminate && (this.SSL == nil || !this.SSL.On) {
+			return errors.New("ssl should be on when stream tls mode is 'terminate'")
+		}
+	}
+
 	// backends
 	err := this.ValidateBackends()
 	if err != nil {
@@ -182,6 +223,38 @@ func (this *ServerConfig) Validate() error {
 		return err
 	}
 
+	// 错误页面
+	err = this.ValidateErrorPages()
+	if err != nil {
+		return err
+	}
+
+	// 限速和连接数限制
+	err = this.ValidateLimits()
+	if err != nil {
+		return err
+	}
+
+	// 请求镜像
+	err = this.ValidateNotify()
+	if err != nil {
+		return err
+	}
+
+	// 异步处理和只记录日志
+	if this.AsyncConfig != nil {
+		err = this.AsyncConfig.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if this.LogOnlyResponse != nil {
+		err = this.LogOnlyResponse.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
 	// headers
 	err = this.ValidateHeaders()
 	if err != nil {
@@ -200,6 +273,24 @@ func (this *ServerConfig) Validate() error {
 		}
 	}
 
+	// compression
+	if this.Compression != nil {
+		err = this.Compression.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// access, allow & deny
+	this.accessList, err = parseIPAccessList(this.Access, this.Allow, this.Deny)
+	if err != nil {
+		return err
+	}
+	this.trustedProxiesList, err = teautils.ParseIPRangeList(this.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
 	// api
 	if this.API == nil {
 		this.API = api.NewAPIConfig()
@@ -213,6 +304,41 @@ func (this *ServerConfig) Validate() error {
 	return nil
 }
 
+// 是否为TCP/UDP代理
+func (this *ServerConfig) IsStream() bool {
+	return this.Stream != nil && this.Stream.On
+}
+
+// 判断某个终端地址是否可以访问
+func (this *ServerConfig) AllowIP(ip string) bool {
+	return this.accessList.Check(ip)
+}
+
+// 按顺序组合访问规则：access、deny、allow，设置了allow时最后禁止其他地址
+func parseIPAccessList(access []string, allow []string, deny []string) (*teautils.IPAccessList, error) {
+	list, err := teautils.ParseIPAccessList(access)
+	if err != nil {
+		return nil, err
+	}
+	err = list.Add(false, deny...)
+	if err != nil {
+		return nil, err
+	}
+	err = list.Add(true, allow...)
+	if err != nil {
+		return nil, err
+	}
+	if len(allow) > 0 {
+		list.Add(false, "all")
+	}
+	return list, nil
+}
+
+// 根据受信任的代理计算终端地址
+func (this *ServerConfig) ClientIP(remoteAddr string, header http.Header) string {
+	return teautils.ClientIPFromHeader(remoteAddr, header, this.trustedProxiesList)
+}
+
 // 添加域名
 func (this *ServerConfig) AddName(name ... string) {
 	this.Name = append(this.Name, name ...)
@@ -287,6 +413,22 @@ func (this *ServerConfig) FirstName() string {
 	return ""
 }
 
+// 可以用来申请证书的域名，不包括泛域名和正则表达式
+func (this *ServerConfig) CertificateNames() []string {
+	result := []string{}
+	for _, name := range this.Name {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 || strings.ContainsAny(name, "*~") || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+			continue
+		}
+		if lists.Contains(result, name) {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
 // 添加路径规则
 func (this *ServerConfig) AddLocation(location *LocationConfig) {
 	this.Locations = append(this.Locations, location)
@@ -452,6 +594,72 @@ func (this *ServerConfig) FindRewriteList(locationId string) (rewriteList Rewrit
 	return
 }
 
+// 查找错误页面列表
+func (this *ServerConfig) FindErrorPageList(locationId string) (errorPageList ErrorPageListInterface, err error) {
+	if len(locationId) > 0 {
+		location := this.FindLocation(locationId)
+		if location == nil {
+			err = errors.New("找不到要修改的location")
+			return
+		}
+		errorPageList = location
+		return
+	}
+	errorPageList = this
+	return
+}
+
+// 查找限制列表
+func (this *ServerConfig) FindLimitList(locationId string) (limitList LimitListInterface, err error) {
+	if len(locationId) > 0 {
+		location := this.FindLocation(locationId)
+		if location == nil {
+			err = errors.New("找不到要修改的location")
+			return
+		}
+		limitList = location
+		return
+	}
+	limitList = this
+	return
+}
+
+// 查找请求镜像列表
+func (this *ServerConfig) FindNotifyList(locationId string) (notifyList NotifyListInterface, err error) {
+	if len(locationId) > 0 {
+		location := this.FindLocation(locationId)
+		if location == nil {
+			err = errors.New("找不到要修改的location")
+			return
+		}
+		notifyList = location
+		return
+	}
+	notifyList = this
+	return
+}
+
+// 从正在运行的服务中复制所有后端服务的运行时状态，用于重新加载配置
+func (this *ServerConfig) CopyBackendStates(server *ServerConfig) {
+	this.BackendList.CopyBackendStates(&server.BackendList)
+
+	// 这里不使用FindLocation()，避免重新校验正在运行的Location
+	oldLocations := map[string]*LocationConfig{}
+	for _, location := range server.Locations {
+		oldLocations[location.Id] = location
+	}
+	for _, location := range this.Locations {
+		oldLocation, found := oldLocations[location.Id]
+		if !found {
+			continue
+		}
+		location.BackendList.CopyBackendStates(&oldLocation.BackendList)
+		if location.Websocket != nil && oldLocation.Websocket != nil {
+			location.Websocket.BackendList.CopyBackendStates(&oldLocation.Websocket.BackendList)
+		}
+	}
+}
+
 // 查找后端服务器列表
 func (this *ServerConfig) FindBackendList(locationId string, websocket bool) (backendList BackendListInterface, err error) {
 	if len(locationId) > 0 {
@@ -63,3 +63,116 @@ func TestServerConfig_Encode(t *testing.T) {
 
 	t.Log(string(data))
 }
+
+func TestServerConfig_CertificateNames(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	s := NewServerConfig()
+	s.Name = []string{"example.com", "WWW.example.com", "*.example.com", ".teaos.cn", "~^\\d+\\.com$", "example.com", ""}
+	names := s.CertificateNames()
+	a.IsTrue(len(names) == 2)
+	a.IsTrue(names[0] == "example.com")
+	a.IsTrue(names[1] == "www.example.com")
+}
+
+func TestServerConfig_CopyBackendStates(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	oldServer := NewServerConfig()
+	oldBackend := NewBackendConfig()
+	oldBackend.Address = "127.0.0.1:8001"
+	oldBackend.IsDown = true
+	oldBackend.CurrentFails = 3
+	oldServer.AddBackend(oldBackend)
+
+	oldChangedBackend := NewBackendConfig()
+	oldChangedBackend.Address = "127.0.0.1:8002"
+	oldChangedBackend.IsDown = true
+	oldServer.AddBackend(oldChangedBackend)
+
+	oldLocation := NewLocation()
+	oldLocationBackend := NewBackendConfig()
+	oldLocationBackend.Address = "127.0.0.1:8003"
+	oldLocationBackend.IsDown = true
+	oldLocation.AddBackend(oldLocationBackend)
+	oldServer.AddLocation(oldLocation)
+
+	// 新的配置
+	server := NewServerConfig()
+	backend := NewBackendConfig()
+	backend.Id = oldBackend.Id
+	backend.Address = oldBackend.Address
+	server.AddBackend(backend)
+
+	changedBackend := NewBackendConfig()
+	changedBackend.Id = oldChangedBackend.Id
+	changedBackend.Address = "127.0.0.1:8004"
+	server.AddBackend(changedBackend)
+
+	location := NewLocation()
+	location.Id = oldLocation.Id
+	locationBackend := NewBackendConfig()
+	locationBackend.Id = oldLocationBackend.Id
+	locationBackend.Address = oldLocationBackend.Address
+	location.AddBackend(locationBackend)
+	server.AddLocation(location)
+
+	a.IsNil(server.Validate())
+	server.CopyBackendStates(oldServer)
+
+	a.IsTrue(backend.IsDown)
+	a.IsTrue(backend.CurrentFails == 3)
+	a.IsFalse(changedBackend.IsDown)
+	a.IsTrue(locationBackend.IsDown)
+
+	// 下线的后端服务不参与调度
+	for i := 0; i < 10; i++ {
+		a.IsTrue(server.NextBackend(maps.Map{}) == changedBackend)
+	}
+}
+
+func TestServerConfig_AllowIP(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		server := NewServerConfig()
+		a.IsNil(server.Validate())
+		a.IsTrue(server.AllowIP("10.1.1.1"))
+	}
+
+	// allow all; deny 10.0.0.0/8;
+	{
+		server := NewServerConfig()
+		server.Allow = []string{"all"}
+		server.Deny = []string{"10.0.0.0/8"}
+		a.IsNil(server.Validate())
+		a.IsFalse(server.AllowIP("10.1.1.1"))
+		a.IsTrue(server.AllowIP("192.168.1.1"))
+	}
+
+	// 设置了allow时禁止其他地址
+	{
+		server := NewServerConfig()
+		server.Allow = []string{"192.168.1.0/24"}
+		a.IsNil(server.Validate())
+		a.IsTrue(server.AllowIP("192.168.1.100"))
+		a.IsFalse(server.AllowIP("192.168.2.100"))
+	}
+
+	// access中的规则优先
+	{
+		server := NewServerConfig()
+		server.Access = []string{"deny 192.168.1.1"}
+		server.Allow = []string{"192.168.1.0/24"}
+		a.IsNil(server.Validate())
+		a.IsFalse(server.AllowIP("192.168.1.1"))
+		a.IsTrue(server.AllowIP("192.168.1.2"))
+		a.IsFalse(server.AllowIP("192.168.2.1"))
+	}
+
+	{
+		server := NewServerConfig()
+		server.Access = []string{"allow"}
+		a.IsNotNil(server.Validate())
+	}
+}
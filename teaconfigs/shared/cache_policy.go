@@ -19,10 +19,20 @@ type CachePolicy struct {
 	Life     string `yaml:"life" json:"life"`         // 时间
 	Status   []int  `yaml:"status" json:"status"`     // 缓存的状态码列表
 	MaxSize  string `yaml:"maxSize" json:"maxSize"`   // 能够请求的最大尺寸
+	Eviction string `yaml:"eviction" json:"eviction"` // 容量不足时的淘汰策略：lru, lfu，只对内存和文件缓存有效
 
-	life     time.Duration
-	maxSize  float64
-	capacity float64
+	StaleWhileRevalidate string `yaml:"staleWhileRevalidate" json:"staleWhileRevalidate"` // 过期后可以一边使用旧内容一边在后台更新的时间，源站没有设置stale-while-revalidate时使用
+	StaleIfError         string `yaml:"staleIfError" json:"staleIfError"`                 // 过期后源站出错时仍然可以使用旧内容的时间，源站没有设置stale-if-error时使用
+	StatusHeader         bool   `yaml:"statusHeader" json:"statusHeader"`                 // 是否在响应中加入X-Cache Header，值为缓存状态
+	Coalescing           bool   `yaml:"coalescing" json:"coalescing"`                     // 是否合并回源，同一个Key同时只有一个请求回源，其他请求等待后读取缓存
+	CoalescingTimeout    string `yaml:"coalescingTimeout" json:"coalescingTimeout"`       // 合并回源时的最长等待时间，默认为5s
+
+	life                 time.Duration
+	maxSize              float64
+	capacity             float64
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	coalescingTimeout    time.Duration
 
 	Type    string                 `yaml:"type" json:"type"`       // 类型
 	Options map[string]interface{} `yaml:"options" json:"options"` // 选项
@@ -61,6 +71,9 @@ func (this *CachePolicy) Validate() error {
 	this.maxSize, _ = stringutil.ParseFileSize(this.MaxSize)
 	this.life, _ = time.ParseDuration(this.Life)
 	this.capacity, _ = stringutil.ParseFileSize(this.Capacity)
+	this.staleWhileRevalidate, _ = time.ParseDuration(this.StaleWhileRevalidate)
+	this.staleIfError, _ = time.ParseDuration(this.StaleIfError)
+	this.coalescingTimeout, _ = time.ParseDuration(this.CoalescingTimeout)
 	return err
 }
 
@@ -79,6 +92,24 @@ func (this *CachePolicy) LifeDuration() time.Duration {
 	return this.life
 }
 
+// 过期后可以在后台更新的时间
+func (this *CachePolicy) StaleWhileRevalidateDuration() time.Duration {
+	return this.staleWhileRevalidate
+}
+
+// 过期后源站出错时可以使用旧内容的时间
+func (this *CachePolicy) StaleIfErrorDuration() time.Duration {
+	return this.staleIfError
+}
+
+// 合并回源时的最长等待时间
+func (this *CachePolicy) CoalescingTimeoutDuration() time.Duration {
+	if this.coalescingTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return this.coalescingTimeout
+}
+
 // 保存
 func (this *CachePolicy) Save() error {
 	if len(this.Filename) == 0 {
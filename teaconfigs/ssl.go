@@ -1,16 +1,110 @@
 package teaconfigs
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/maps"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// TLS版本
+type TLSVersion = string
+
+const (
+	TLSVersion10 TLSVersion = "TLS 1.0"
+	TLSVersion11 TLSVersion = "TLS 1.1"
+	TLSVersion12 TLSVersion = "TLS 1.2"
+	TLSVersion13 TLSVersion = "TLS 1.3"
+)
+
+// 所有的TLS版本
+func AllTLSVersions() []TLSVersion {
+	return []TLSVersion{TLSVersion10, TLSVersion11, TLSVersion12, TLSVersion13}
+}
+
+var tlsVersionMap = map[TLSVersion]uint16{
+	TLSVersion10: tls.VersionTLS10,
+	TLSVersion11: tls.VersionTLS11,
+	TLSVersion12: tls.VersionTLS12,
+	TLSVersion13: tls.VersionTLS13,
+}
+
+// 客户端认证方式
+type SSLClientAuthType = string
+
+const (
+	SSLClientAuthTypeNone             SSLClientAuthType = ""                 // 不需要客户端证书
+	SSLClientAuthTypeRequest          SSLClientAuthType = "request"          // 请求客户端证书，但不校验
+	SSLClientAuthTypeRequire          SSLClientAuthType = "require"          // 必须提供客户端证书，但不校验
+	SSLClientAuthTypeVerifyIfGiven    SSLClientAuthType = "verifyIfGiven"    // 如果提供了客户端证书则校验
+	SSLClientAuthTypeRequireAndVerify SSLClientAuthType = "requireAndVerify" // 必须提供客户端证书并校验
+)
+
+// 所有的客户端认证方式
+func AllSSLClientAuthTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name": "不需要客户端证书",
+			"code": SSLClientAuthTypeNone,
+		},
+		{
+			"name": "请求客户端证书",
+			"code": SSLClientAuthTypeRequest,
+		},
+		{
+			"name": "需要客户端证书",
+			"code": SSLClientAuthTypeRequire,
+		},
+		{
+			"name": "校验提供的客户端证书",
+			"code": SSLClientAuthTypeVerifyIfGiven,
+		},
+		{
+			"name": "需要并校验客户端证书",
+			"code": SSLClientAuthTypeRequireAndVerify,
+		},
+	}
+}
+
+var sslClientAuthTypeMap = map[SSLClientAuthType]tls.ClientAuthType{
+	SSLClientAuthTypeNone:             tls.NoClientCert,
+	SSLClientAuthTypeRequest:          tls.RequestClientCert,
+	SSLClientAuthTypeRequire:          tls.RequireAnyClientCert,
+	SSLClientAuthTypeVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	SSLClientAuthTypeRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
 // SSL配置
 type SSLConfig struct {
 	On             bool     `yaml:"on" json:"on"`                         // 是否开启
 	Certificate    string   `yaml:"certificate" json:"certificate"`       // 证书文件
 	CertificateKey string   `yaml:"certificateKey" json:"certificateKey"` // 密钥
 	Listen         []string `yaml:"listen" json:"listen"`                 // 网络地址
+	Default        bool     `yaml:"default" json:"default"`               // 是否为默认证书，客户端没有发送SNI或者没有匹配的证书时使用
+
+	ACME *ACMEConfig `yaml:"acme" json:"acme"` // 自动申请证书
+
+	MinVersion   TLSVersion `yaml:"minVersion" json:"minVersion"`     // 最低TLS版本
+	MaxVersion   TLSVersion `yaml:"maxVersion" json:"maxVersion"`     // 最高TLS版本
+	CipherSuites []string   `yaml:"cipherSuites" json:"cipherSuites"` // 加密算法套件，比如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256，为空表示使用默认的
+
+	RedirectHTTP bool        `yaml:"redirectHTTP" json:"redirectHTTP"` // 是否将HTTP请求跳转到HTTPS
+	HSTS         *HSTSConfig `yaml:"hsts" json:"hsts"`                 // HSTS设置
+	OCSPStapling bool        `yaml:"ocspStapling" json:"ocspStapling"` // 是否开启OCSP Stapling
+
+	ClientAuthType       SSLClientAuthType `yaml:"clientAuthType" json:"clientAuthType"`             // 客户端认证方式
+	ClientCACertificates string            `yaml:"clientCACertificates" json:"clientCACertificates"` // 用来校验客户端证书的CA证书文件
+
+	minVersion   uint16
+	maxVersion   uint16
+	cipherSuites []uint16
+	clientAuth   tls.ClientAuthType
+	clientCAs    *x509.CertPool
 }
 
 // 获取新对象
@@ -23,6 +117,12 @@ func (this *SSLConfig) Validate() error {
 	if !this.On {
 		return nil
 	}
+	if this.ACME != nil {
+		err := this.ACME.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	if len(this.Certificate) == 0 {
 		return errors.New("'certificate' should not be empty")
 	}
@@ -39,5 +139,137 @@ func (this *SSLConfig) Validate() error {
 			}
 		}
 	}
+
+	// TLS版本
+	this.minVersion = 0
+	if len(this.MinVersion) > 0 {
+		version, found := tlsVersionMap[this.MinVersion]
+		if !found {
+			return errors.New("invalid tls version '" + this.MinVersion + "'")
+		}
+		this.minVersion = version
+	}
+	this.maxVersion = 0
+	if len(this.MaxVersion) > 0 {
+		version, found := tlsVersionMap[this.MaxVersion]
+		if !found {
+			return errors.New("invalid tls version '" + this.MaxVersion + "'")
+		}
+		this.maxVersion = version
+	}
+	if this.minVersion > 0 && this.maxVersion > 0 && this.minVersion > this.maxVersion {
+		return errors.New("'minVersion' should not be greater than 'maxVersion'")
+	}
+
+	// 加密算法套件
+	this.cipherSuites = nil
+	if len(this.CipherSuites) > 0 {
+		suiteMap := map[string]uint16{}
+		for _, suite := range tls.CipherSuites() {
+			suiteMap[suite.Name] = suite.ID
+		}
+		for _, suite := range tls.InsecureCipherSuites() {
+			suiteMap[suite.Name] = suite.ID
+		}
+		for _, name := range this.CipherSuites {
+			id, found := suiteMap[name]
+			if !found {
+				return errors.New("invalid cipher suite '" + name + "'")
+			}
+			this.cipherSuites = append(this.cipherSuites, id)
+		}
+	}
+
+	// HSTS
+	if this.HSTS != nil {
+		err := this.HSTS.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// 客户端认证
+	clientAuth, found := sslClientAuthTypeMap[this.ClientAuthType]
+	if !found {
+		return errors.New("invalid client auth type '" + this.ClientAuthType + "'")
+	}
+	this.clientAuth = clientAuth
+	this.clientCAs = nil
+	if len(this.ClientCACertificates) > 0 {
+		file := this.ClientCACertificates
+		if !filepath.IsAbs(file) {
+			file = Tea.ConfigFile(file)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("invalid client ca certificates '" + this.ClientCACertificates + "'")
+		}
+		this.clientCAs = pool
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return errors.New("'clientCACertificates' should not be empty")
+	}
+
 	return nil
 }
+
+// 是否自动申请证书
+func (this *SSLConfig) IsACME() bool {
+	return this.ACME != nil && this.ACME.On
+}
+
+// 生成TLS配置，不包含证书
+func (this *SSLConfig) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:   this.minVersion,
+		MaxVersion:   this.maxVersion,
+		CipherSuites: this.cipherSuites,
+		ClientAuth:   this.clientAuth,
+		ClientCAs:    this.clientCAs,
+	}
+}
+
+// HSTS配置
+type HSTSConfig struct {
+	On                bool `yaml:"on" json:"on"`                               // 是否开启
+	MaxAge            int  `yaml:"maxAge" json:"maxAge"`                       // 有效期，单位为秒，默认为一年
+	IncludeSubDomains bool `yaml:"includeSubDomains" json:"includeSubDomains"` // 是否包含子域名
+	Preload           bool `yaml:"preload" json:"preload"`                     // 是否加入预加载列表
+
+	headerValue string
+}
+
+// 获取新对象
+func NewHSTSConfig() *HSTSConfig {
+	return &HSTSConfig{
+		On:     true,
+		MaxAge: 31536000,
+	}
+}
+
+// 校验
+func (this *HSTSConfig) Validate() error {
+	if this.MaxAge < 0 {
+		return errors.New("'maxAge' should not be negative")
+	}
+	if this.MaxAge == 0 {
+		this.MaxAge = 31536000
+	}
+
+	this.headerValue = "max-age=" + strconv.Itoa(this.MaxAge)
+	if this.IncludeSubDomains {
+		this.headerValue += "; includeSubDomains"
+	}
+	if this.Preload {
+		this.headerValue += "; preload"
+	}
+	return nil
+}
+
+// Strict-Transport-Security的值
+func (this *HSTSConfig) HeaderValue() string {
+	return this.headerValue
+}
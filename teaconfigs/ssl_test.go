@@ -0,0 +1,98 @@
+package teaconfigs
+
+import (
+	"crypto/tls"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestSSLConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		config := &SSLConfig{
+			On:             true,
+			Certificate:    "a.pem",
+			CertificateKey: "a.key",
+			Listen:         []string{"127.0.0.1"},
+		}
+		a.IsNil(config.Validate())
+		a.IsTrue(config.Listen[0] == "127.0.0.1:443")
+
+		tlsConfig := config.TLSConfig()
+		a.IsTrue(tlsConfig.MinVersion == 0)
+		a.IsTrue(tlsConfig.ClientAuth == tls.NoClientCert)
+	}
+
+	{
+		config := &SSLConfig{
+			On:             true,
+			Certificate:    "a.pem",
+			CertificateKey: "a.key",
+			MinVersion:     TLSVersion12,
+			MaxVersion:     TLSVersion13,
+			CipherSuites:   []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			ClientAuthType: SSLClientAuthTypeRequire,
+		}
+		a.IsNil(config.Validate())
+
+		tlsConfig := config.TLSConfig()
+		a.IsTrue(tlsConfig.MinVersion == tls.VersionTLS12)
+		a.IsTrue(tlsConfig.MaxVersion == tls.VersionTLS13)
+		a.IsTrue(len(tlsConfig.CipherSuites) == 1)
+		a.IsTrue(tlsConfig.CipherSuites[0] == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+		a.IsTrue(tlsConfig.ClientAuth == tls.RequireAnyClientCert)
+	}
+
+	{
+		config := &SSLConfig{
+			On:             true,
+			Certificate:    "a.pem",
+			CertificateKey: "a.key",
+			MinVersion:     TLSVersion13,
+			MaxVersion:     TLSVersion12,
+		}
+		a.IsNotNil(config.Validate())
+	}
+
+	{
+		config := &SSLConfig{
+			On:             true,
+			Certificate:    "a.pem",
+			CertificateKey: "a.key",
+			CipherSuites:   []string{"TLS_UNKNOWN"},
+		}
+		a.IsNotNil(config.Validate())
+	}
+
+	{
+		config := &SSLConfig{
+			On:             true,
+			Certificate:    "a.pem",
+			CertificateKey: "a.key",
+			ClientAuthType: SSLClientAuthTypeRequireAndVerify,
+		}
+		a.IsNotNil(config.Validate())
+	}
+}
+
+func TestHSTSConfig_HeaderValue(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		config := NewHSTSConfig()
+		a.IsNil(config.Validate())
+		a.IsTrue(config.HeaderValue() == "max-age=31536000")
+	}
+
+	{
+		config := &HSTSConfig{
+			On:                true,
+			MaxAge:            600,
+			IncludeSubDomains: true,
+			Preload:           true,
+		}
+		a.IsNil(config.Validate())
+		a.IsTrue(config.HeaderValue() == "max-age=600; includeSubDomains; preload")
+	}
+}
@@ -0,0 +1,155 @@
+package teaconfigs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/maps"
+	"net"
+	"time"
+)
+
+// TCP/UDP代理协议
+type StreamProtocol = string
+
+const (
+	StreamProtocolTCP StreamProtocol = "tcp"
+	StreamProtocolUDP StreamProtocol = "udp"
+)
+
+// 所有的TCP/UDP代理协议
+func AllStreamProtocols() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "TCP",
+			"code":        StreamProtocolTCP,
+			"description": "转发TCP连接，适用于数据库、MQTT等服务",
+		},
+		{
+			"name":        "UDP",
+			"code":        StreamProtocolUDP,
+			"description": "转发UDP数据包，同一个终端地址的数据包使用同一个后端服务，适用于DNS等服务",
+		},
+	}
+}
+
+// TCP代理的TLS处理方式
+type StreamTLSMode = string
+
+const (
+	StreamTLSModeNone      StreamTLSMode = "none"      // 不处理TLS，直接转发
+	StreamTLSModeTerminate StreamTLSMode = "terminate" // 使用服务的SSL证书解密后再转发到后端服务
+	StreamTLSModeSNI       StreamTLSMode = "sni"       // 不解密，根据客户端发送的SNI选择服务后直接转发
+)
+
+// 所有的TLS处理方式
+func AllStreamTLSModes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "不处理",
+			"code":        StreamTLSModeNone,
+			"description": "不处理TLS，直接转发到后端服务",
+		},
+		{
+			"name":        "解密",
+			"code":        StreamTLSModeTerminate,
+			"description": "使用服务的SSL证书解密后再转发到后端服务",
+		},
+		{
+			"name":        "SNI路由",
+			"code":        StreamTLSModeSNI,
+			"description": "不解密，根据客户端发送的SNI和服务的域名选择服务后直接转发",
+		},
+	}
+}
+
+// TCP/UDP代理设置
+// 开启后服务不再处理HTTP请求，而是把监听地址上收到的连接或数据包转发到后端服务
+type StreamConfig struct {
+	On          bool     `yaml:"on" json:"on"`                   // 是否开启
+	Protocol    string   `yaml:"protocol" json:"protocol"`       // 协议：tcp, udp，默认为tcp
+	Listen      []string `yaml:"listen" json:"listen"`           // 监听地址，必须包含端口
+	TLS         string   `yaml:"tls" json:"tls"`                 // TLS处理方式：none, terminate, sni，只对TCP有效
+	IdleTimeout string   `yaml:"idleTimeout" json:"idleTimeout"` // 空闲超时时间，超出后关闭连接或会话，默认TCP为10m，UDP为30s
+
+	idleTimeout time.Duration
+}
+
+// 获取新对象
+func NewStreamConfig() *StreamConfig {
+	return &StreamConfig{
+		On:       true,
+		Protocol: StreamProtocolTCP,
+		TLS:      StreamTLSModeNone,
+	}
+}
+
+// 校验
+func (this *StreamConfig) Validate() error {
+	if len(this.Protocol) == 0 {
+		this.Protocol = StreamProtocolTCP
+	}
+	if this.Protocol != StreamProtocolTCP && this.Protocol != StreamProtocolUDP {
+		return errors.New("invalid stream protocol '" + this.Protocol + "'")
+	}
+
+	if len(this.TLS) == 0 {
+		this.TLS = StreamTLSModeNone
+	}
+	if this.TLS != StreamTLSModeNone && this.TLS != StreamTLSModeTerminate && this.TLS != StreamTLSModeSNI {
+		return errors.New("invalid stream tls mode '" + this.TLS + "'")
+	}
+	if this.Protocol == StreamProtocolUDP && this.TLS != StreamTLSModeNone {
+		return errors.New("tls is not supported for udp stream")
+	}
+
+	for _, address := range this.Listen {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil || len(port) == 0 {
+			return errors.New("invalid stream listen address '" + address + "', port is required")
+		}
+	}
+
+	if this.Protocol == StreamProtocolUDP {
+		this.idleTimeout = 30 * time.Second
+	} else {
+		this.idleTimeout = 10 * time.Minute
+	}
+	if len(this.IdleTimeout) > 0 {
+		duration, err := time.ParseDuration(this.IdleTimeout)
+		if err != nil || duration < 0 {
+			return errors.New("invalid stream idle timeout '" + this.IdleTimeout + "'")
+		}
+
+		// UDP没有连接关闭的信号，只能通过空闲超时结束会话
+		if duration == 0 && this.IsUDP() {
+			return errors.New("idle timeout of udp stream should be greater than 0")
+		}
+		this.idleTimeout = duration
+	}
+
+	return nil
+}
+
+// 是否为UDP
+func (this *StreamConfig) IsUDP() bool {
+	return this.Protocol == StreamProtocolUDP
+}
+
+// 空闲超时时间，0表示不限，UDP总是大于0
+func (this *StreamConfig) IdleTimeoutDuration() time.Duration {
+	return this.idleTimeout
+}
+
+// 监听服务的Key，比如 tcp://:3306
+// TLS处理方式不同时使用不同的Key，以便重新加载配置时重新启动监听服务
+func (this *StreamConfig) ListenerKey(address string) string {
+	if this.IsUDP() {
+		return "udp://" + address
+	}
+	switch this.TLS {
+	case StreamTLSModeTerminate:
+		return "tls://" + address
+	case StreamTLSModeSNI:
+		return "sni://" + address
+	}
+	return "tcp://" + address
+}
@@ -0,0 +1,100 @@
+package teaconfigs
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+func TestStreamConfig_Validate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		stream := &StreamConfig{}
+		a.IsNil(stream.Validate())
+		a.IsTrue(stream.Protocol == StreamProtocolTCP)
+		a.IsTrue(stream.TLS == StreamTLSModeNone)
+		a.IsTrue(stream.IdleTimeoutDuration() == 10*time.Minute)
+	}
+
+	{
+		stream := NewStreamConfig()
+		stream.Protocol = StreamProtocolUDP
+		a.IsNil(stream.Validate())
+		a.IsTrue(stream.IdleTimeoutDuration() == 30*time.Second)
+	}
+
+	{
+		stream := NewStreamConfig()
+		stream.IdleTimeout = "0s"
+		a.IsNil(stream.Validate())
+		a.IsTrue(stream.IdleTimeoutDuration() == 0)
+
+		stream.Protocol = StreamProtocolUDP
+		a.IsNotNil(stream.Validate())
+	}
+
+	{
+		stream := NewStreamConfig()
+		stream.Protocol = "sctp"
+		a.IsNotNil(stream.Validate())
+	}
+
+	{
+		stream := NewStreamConfig()
+		stream.Protocol = StreamProtocolUDP
+		stream.TLS = StreamTLSModeSNI
+		a.IsNotNil(stream.Validate())
+	}
+
+	{
+		stream := NewStreamConfig()
+		stream.Listen = []string{"127.0.0.1"}
+		a.IsNotNil(stream.Validate())
+
+		stream.Listen = []string{":3306", "[::1]:3306"}
+		a.IsNil(stream.Validate())
+	}
+}
+
+func TestStreamConfig_ListenerKey(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	stream := NewStreamConfig()
+	a.IsTrue(stream.ListenerKey(":3306") == "tcp://:3306")
+
+	stream.TLS = StreamTLSModeTerminate
+	a.IsTrue(stream.ListenerKey(":3306") == "tls://:3306")
+
+	stream.TLS = StreamTLSModeSNI
+	a.IsTrue(stream.ListenerKey(":3306") == "sni://:3306")
+
+	stream.Protocol = StreamProtocolUDP
+	stream.TLS = StreamTLSModeNone
+	a.IsTrue(stream.ListenerKey(":53") == "udp://:53")
+}
+
+func TestAddStreamListeners(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	listenerConfigMap := map[string]*ListenerConfig{}
+	newServer := func(id string, tls string) *ServerConfig {
+		server := NewServerConfig()
+		server.Id = id
+		server.Stream = NewStreamConfig()
+		server.Stream.TLS = tls
+		server.Stream.Listen = []string{":8443"}
+		return server
+	}
+
+	// 使用SNI路由时多个服务可以共用一个地址
+	addStreamListeners(listenerConfigMap, newServer("a", StreamTLSModeSNI))
+	addStreamListeners(listenerConfigMap, newServer("b", StreamTLSModeSNI))
+	a.IsTrue(len(listenerConfigMap["sni://:8443"].Servers) == 2)
+
+	// 不处理TLS时一个地址只能有一个服务
+	addStreamListeners(listenerConfigMap, newServer("c", StreamTLSModeNone))
+	addStreamListeners(listenerConfigMap, newServer("d", StreamTLSModeNone))
+	a.IsTrue(len(listenerConfigMap["tcp://:8443"].Servers) == 1)
+	a.IsTrue(listenerConfigMap["tcp://:8443"].Servers[0].Id == "c")
+}
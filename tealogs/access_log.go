@@ -82,6 +82,27 @@ type AccessLog struct {
 	BackendAddress string `var:"backendAddress" bson:"backendAddress" json:"backendAddress"` // 代理的后端的地址
 	FastcgiAddress string `var:"fastcgiAddress" bson:"fastcgiAddress" json:"fastcgiAddress"` // Fastcgi后端地址
 
+	BackendRetries  int                        `var:"backendRetries" bson:"backendRetries" json:"backendRetries"` // 重试的次数
+	BackendAttempts []*AccessLogBackendAttempt `bson:"backendAttempts" json:"backendAttempts"`                    // 每次请求后端的结果，只在有重试时记录
+
+	// TCP/UDP代理相关
+	BytesReceived int64 `var:"bytesReceived" bson:"bytesReceived" json:"bytesReceived"` // 从终端接收的字节数，发送到终端的字节数记录在bytesSent中
+
+	// gRPC相关
+	GRPCStatus string `var:"grpcStatus" bson:"grpcStatus" json:"grpcStatus"` // gRPC状态码，只在gRPC模式下记录，比如0、14
+
+	// 缓存相关
+	CacheStatus string `var:"cacheStatus" bson:"cacheStatus" json:"cacheStatus"` // 缓存状态：HIT, MISS, BYPASS, EXPIRED, STALE
+	CachePolicy string `var:"cachePolicy" bson:"cachePolicy" json:"cachePolicy"` // 缓存策略文件名
+
+	// SSL相关
+	SSLProtocol          string `var:"sslProtocol" bson:"sslProtocol" json:"sslProtocol"`                            // TLS协议版本，比如 TLS 1.2
+	SSLCipher            string `var:"sslCipher" bson:"sslCipher" json:"sslCipher"`                                  // TLS加密算法套件
+	SSLClientSubject     string `var:"sslClientSubject" bson:"sslClientSubject" json:"sslClientSubject"`             // 客户端证书的主体
+	SSLClientIssuer      string `var:"sslClientIssuer" bson:"sslClientIssuer" json:"sslClientIssuer"`                // 客户端证书的颁发者
+	SSLClientFingerprint string `var:"sslClientFingerprint" bson:"sslClientFingerprint" json:"sslClientFingerprint"` // 客户端证书的SHA256指纹
+	SSLClientVerify      string `var:"sslClientVerify" bson:"sslClientVerify" json:"sslClientVerify"`                // 客户端证书的校验结果：SUCCESS, NONE, NOT_VERIFIED
+
 	// 调试用
 	RequestData        []byte `var:"" bson:"requestData" json:"requestData"`               // 请求数据
 	ResponseHeaderData []byte `var:"" bson:"responseHeaderData" json:"responseHeaderData"` // 响应Header数据
@@ -99,6 +120,14 @@ type AccessLog struct {
 	headerReg *regexp.Regexp
 }
 
+type AccessLogBackendAttempt struct {
+	BackendId   string  `bson:"backendId" json:"backendId"`     // 后端服务ID
+	Address     string  `bson:"address" json:"address"`         // 后端服务地址
+	Status      int     `bson:"status" json:"status"`           // 响应的状态码，请求失败时为0
+	Error       string  `bson:"error" json:"error"`             // 错误信息
+	RequestTime float64 `bson:"requestTime" json:"requestTime"` // 耗时，单位为秒
+}
+
 type AccessLogFile struct {
 	MimeType  string `bson:"mimeType" json:"mimeType"`   // 类似于 image/jpeg
 	Extension string `bson:"extension" json:"extension"` // 扩展名，不带点（.）
@@ -0,0 +1,105 @@
+package tealogs
+
+import (
+	"bufio"
+	"github.com/iwind/TeaGo/utils/string"
+	"io"
+	"sync"
+	"time"
+)
+
+// 默认的缓冲区尺寸
+const accessLogDefaultBufferSize = 64 * 1024
+
+// 带缓冲的日志输出
+// 参考：http://nginx.org/en/docs/http/ngx_http_log_module.html#access_log 中的buffer和flush
+type accessLogBuffer struct {
+	writer io.Writer
+	buf    *bufio.Writer
+	locker sync.Mutex
+
+	ticker *time.Ticker
+	quit   chan bool
+}
+
+// 获取新对象
+// 如果只设置了flush，则使用默认的缓冲区尺寸；如果都没有设置，则不使用缓冲区
+func newAccessLogBuffer(writer io.Writer, bufferSize string, flush string) *accessLogBuffer {
+	buffer := &accessLogBuffer{
+		writer: writer,
+	}
+
+	size := 0
+	if len(bufferSize) > 0 {
+		s, _ := stringutil.ParseFileSize(bufferSize)
+		size = int(s)
+	}
+
+	duration, _ := time.ParseDuration(flush)
+	if size <= 0 && duration > 0 {
+		size = accessLogDefaultBufferSize
+	}
+
+	if size > 0 {
+		buffer.buf = bufio.NewWriterSize(writer, size)
+	}
+
+	if buffer.buf != nil && duration > 0 {
+		buffer.ticker = time.NewTicker(duration)
+		buffer.quit = make(chan bool, 1)
+		go func() {
+			for {
+				select {
+				case <-buffer.ticker.C:
+					buffer.Flush()
+				case <-buffer.quit:
+					return
+				}
+			}
+		}()
+	}
+
+	return buffer
+}
+
+// 写入一行
+func (this *accessLogBuffer) WriteLine(line string) error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	data := []byte(line + "\n")
+	if this.buf == nil {
+		_, err := this.writer.Write(data)
+		return err
+	}
+	_, err := this.buf.Write(data)
+	if err != nil {
+		// bufio.Writer出错后会一直返回错误，所以需要重置
+		this.buf.Reset(this.writer)
+	}
+	return err
+}
+
+// 将缓冲区内容写入
+func (this *accessLogBuffer) Flush() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.buf == nil {
+		return nil
+	}
+	err := this.buf.Flush()
+	if err != nil {
+		this.buf.Reset(this.writer)
+	}
+	return err
+}
+
+// 关闭
+func (this *accessLogBuffer) Close() error {
+	if this.ticker != nil {
+		this.ticker.Stop()
+		this.quit <- true
+	}
+	return this.Flush()
+}
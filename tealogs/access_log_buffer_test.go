@@ -0,0 +1,38 @@
+package tealogs
+
+import (
+	"bytes"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestAccessLogBuffer(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		w := &bytes.Buffer{}
+		buffer := newAccessLogBuffer(w, "", "")
+		buffer.WriteLine("hello")
+		a.IsTrue(w.String() == "hello\n")
+		buffer.Close()
+	}
+
+	{
+		w := &bytes.Buffer{}
+		buffer := newAccessLogBuffer(w, "1k", "")
+		buffer.WriteLine("hello")
+		a.IsTrue(w.Len() == 0)
+		buffer.Flush()
+		a.IsTrue(w.String() == "hello\n")
+		buffer.Close()
+	}
+
+	{
+		w := &bytes.Buffer{}
+		buffer := newAccessLogBuffer(w, "", "1h")
+		buffer.WriteLine("hello")
+		a.IsTrue(w.Len() == 0)
+		buffer.Close()
+		a.IsTrue(w.String() == "hello\n")
+	}
+}
@@ -6,14 +6,13 @@ import (
 	"os"
 	"github.com/iwind/TeaGo/logs"
 	"github.com/TeaWeb/code/teaconfigs"
-	"sync"
 	"errors"
 )
 
 type AccessLogFileWriter struct {
 	config *teaconfigs.AccessLogFileConfig
 	file   *os.File
-	locker *sync.Mutex
+	buffer *accessLogBuffer
 }
 
 func (writer *AccessLogFileWriter) Init() {
@@ -28,7 +27,7 @@ func (writer *AccessLogFileWriter) Init() {
 	}
 
 	writer.file = file
-	writer.locker = &sync.Mutex{}
+	writer.buffer = newAccessLogBuffer(file, writer.config.Buffer, writer.config.Flush)
 }
 
 func (writer *AccessLogFileWriter) Write(log *AccessLog) error {
@@ -38,31 +37,19 @@ func (writer *AccessLogFileWriter) Write(log *AccessLog) error {
 
 	format := writer.config.Format
 	if len(format) == 0 {
-		format = "${remoteAddr} - [${timeLocal}] \"${request}\" ${status} ${bodyBytesSent} \"${http.Referer}\" \"${http.UserAgent}\""
+		format = teaconfigs.AccessLogDefaultFormat
 	}
 
-	logString := log.Format(format)
-
-	var lastErr error = nil
-	if writer.file != nil {
-		writer.locker.Lock()
-
-		_, err := writer.file.WriteString(logString)
-		if err != nil {
-			lastErr = err
-		}
-		_, err = writer.file.WriteString("\n")
-		if err != nil {
-			lastErr = err
-		}
-
-		writer.locker.Unlock()
+	if writer.buffer == nil {
+		return nil
 	}
-
-	return lastErr
+	return writer.buffer.WriteLine(log.Format(format))
 }
 
 func (writer *AccessLogFileWriter) Close() {
+	if writer.buffer != nil {
+		writer.buffer.Close()
+	}
 	if writer.file != nil {
 		writer.file.Close()
 	}
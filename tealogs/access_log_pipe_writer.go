@@ -0,0 +1,112 @@
+package tealogs
+
+import (
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// 将日志写入到某个命令的标准输入
+type AccessLogPipeWriter struct {
+	config *teaconfigs.AccessLogPipeConfig
+	buffer *accessLogBuffer
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	locker sync.Mutex
+}
+
+func (writer *AccessLogPipeWriter) Init() {
+	err := writer.start()
+	if err != nil {
+		logs.Errorf("AccessLogPipeWriter.Init(): %s", err)
+	}
+	writer.buffer = newAccessLogBuffer(writerFunc(writer.writeRaw), writer.config.Buffer, writer.config.Flush)
+}
+
+func (writer *AccessLogPipeWriter) Write(log *AccessLog) error {
+	format := writer.config.Format
+	if len(format) == 0 {
+		format = teaconfigs.AccessLogDefaultFormat
+	}
+
+	return writer.buffer.WriteLine(log.Format(format))
+}
+
+func (writer *AccessLogPipeWriter) Close() {
+	if writer.buffer != nil {
+		writer.buffer.Close()
+	}
+
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	writer.stop()
+}
+
+// 启动命令
+func (writer *AccessLogPipeWriter) start() error {
+	pieces := strings.Fields(writer.config.Path)
+	if len(pieces) == 0 {
+		return errors.New("access log pipe 'path' invalid")
+	}
+
+	cmd := exec.Command(pieces[0], pieces[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	writer.cmd = cmd
+	writer.stdin = stdin
+	return nil
+}
+
+// 停止命令
+func (writer *AccessLogPipeWriter) stop() {
+	if writer.stdin != nil {
+		writer.stdin.Close()
+		writer.stdin = nil
+	}
+	if writer.cmd != nil {
+		writer.cmd.Wait()
+		writer.cmd = nil
+	}
+}
+
+// 写入数据，如果命令已经退出，则尝试重新启动
+func (writer *AccessLogPipeWriter) writeRaw(data []byte) (n int, err error) {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+
+	if writer.stdin != nil {
+		n, err = writer.stdin.Write(data)
+		if err == nil {
+			return
+		}
+		writer.stop()
+	}
+
+	err = writer.start()
+	if err != nil {
+		return 0, err
+	}
+	return writer.stdin.Write(data)
+}
+
+// 将函数转换为io.Writer
+type writerFunc func(data []byte) (n int, err error)
+
+func (f writerFunc) Write(data []byte) (n int, err error) {
+	return f(data)
+}
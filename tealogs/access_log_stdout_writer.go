@@ -2,44 +2,27 @@ package tealogs
 
 import (
 	"os"
-	"sync"
 	"github.com/TeaWeb/code/teaconfigs"
 )
 
 type AccessLogStdoutWriter struct {
 	config *teaconfigs.AccessLogStdoutConfig
-	locker *sync.Mutex
+	buffer *accessLogBuffer
 }
 
 func (writer *AccessLogStdoutWriter) Init() {
-	writer.locker = &sync.Mutex{}
+	writer.buffer = newAccessLogBuffer(os.Stdout, writer.config.Buffer, writer.config.Flush)
 }
 
 func (writer *AccessLogStdoutWriter) Write(log *AccessLog) error {
 	format := writer.config.Format
 	if len(format) == 0 {
-		format = "${remoteAddr} - [${timeLocal}] \"${request}\" ${status} ${bodyBytesSent} \"${http.Referer}\" \"${http.UserAgent}\""
+		format = teaconfigs.AccessLogDefaultFormat
 	}
 
-	logString := log.Format(format)
-
-	writer.locker.Lock()
-
-	var lastErr error
-	_, err := os.Stdout.WriteString(logString)
-	if err != nil {
-		lastErr = err
-	}
-	_, err = os.Stdout.WriteString("\n")
-	if err != nil {
-		lastErr = err
-	}
-
-	writer.locker.Unlock()
-
-	return lastErr
+	return writer.buffer.WriteLine(log.Format(format))
 }
 
 func (writer *AccessLogStdoutWriter) Close() {
-
+	writer.buffer.Close()
 }
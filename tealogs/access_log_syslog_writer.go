@@ -0,0 +1,189 @@
+package tealogs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslog设施
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// syslog级别
+var syslogSeverities = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"warning": 4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+// 将日志以RFC 5424格式写入到syslog
+type AccessLogSyslogWriter struct {
+	config *teaconfigs.AccessLogSyslogConfig
+
+	network  string
+	addr     string
+	priority int
+	appName  string
+	hostname string
+
+	conn       net.Conn
+	unixStream bool // 是否为流式的unix socket
+	locker     sync.Mutex
+}
+
+func (writer *AccessLogSyslogWriter) Init() {
+	writer.network = writer.config.Network
+	writer.addr = writer.config.Addr
+	if len(writer.addr) == 0 {
+		writer.network = "unix"
+		writer.addr = "/dev/log"
+	} else if len(writer.network) == 0 {
+		writer.network = "udp"
+	}
+
+	facility, ok := syslogFacilities[writer.config.Facility]
+	if !ok {
+		facility = syslogFacilities["local7"]
+	}
+	severity, ok := syslogSeverities[writer.config.Severity]
+	if !ok {
+		severity = syslogSeverities["info"]
+	}
+	writer.priority = facility*8 + severity
+
+	writer.appName = writer.config.AppName
+	if len(writer.appName) == 0 {
+		writer.appName = "teaweb"
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) == 0 {
+		hostname = "-"
+	}
+	writer.hostname = hostname
+
+	err = writer.connect()
+	if err != nil {
+		logs.Errorf("AccessLogSyslogWriter.Init(): %s", err)
+	}
+}
+
+func (writer *AccessLogSyslogWriter) Write(log *AccessLog) error {
+	format := writer.config.Format
+	if len(format) == 0 {
+		format = teaconfigs.AccessLogDefaultFormat
+	}
+
+	t := time.Now()
+	if log.Msec > 0 {
+		t = time.Unix(0, int64(log.Msec*1e9))
+	}
+	message := writer.FormatMessage(t, log.Format(format))
+
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+
+	// 出错时重新连接一次
+	var err error
+	for i := 0; i < 2; i++ {
+		if writer.conn == nil {
+			err = writer.connect()
+			if err != nil {
+				return err
+			}
+		}
+		_, err = writer.conn.Write(writer.frame(message))
+		if err == nil {
+			return nil
+		}
+		writer.conn.Close()
+		writer.conn = nil
+	}
+	return err
+}
+
+func (writer *AccessLogSyslogWriter) Close() {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+
+	if writer.conn != nil {
+		writer.conn.Close()
+		writer.conn = nil
+	}
+}
+
+// 生成RFC 5424格式的消息
+// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (writer *AccessLogSyslogWriter) FormatMessage(t time.Time, msg string) string {
+	return fmt.Sprintf("<%d>1 %s %s %s %d access - %s", writer.priority, t.Format("2006-01-02T15:04:05.000000Z07:00"), writer.hostname, writer.appName, os.Getpid(), msg)
+}
+
+// 连接
+func (writer *AccessLogSyslogWriter) connect() error {
+	var conn net.Conn
+	var err error
+	writer.unixStream = false
+	switch writer.network {
+	case "udp", "tcp":
+		conn, err = net.DialTimeout(writer.network, writer.addr, 5*time.Second)
+	case "unix":
+		conn, err = net.DialTimeout("unixgram", writer.addr, 5*time.Second)
+		if err != nil {
+			conn, err = net.DialTimeout("unix", writer.addr, 5*time.Second)
+			writer.unixStream = true
+		}
+	default:
+		return errors.New("access log syslog 'network' should be one of 'udp', 'tcp' and 'unix'")
+	}
+	if err != nil {
+		return err
+	}
+	writer.conn = conn
+	return nil
+}
+
+// 根据传输协议分帧
+func (writer *AccessLogSyslogWriter) frame(message string) []byte {
+	// TCP使用Octet Counting，参考：https://tools.ietf.org/html/rfc6587#section-3.4.1
+	if writer.network == "tcp" {
+		return []byte(fmt.Sprintf("%d %s", len(message), message))
+	}
+
+	// 流式的unix socket使用换行分隔
+	if writer.unixStream && !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	return []byte(message)
+}
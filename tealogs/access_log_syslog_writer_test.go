@@ -0,0 +1,64 @@
+package tealogs
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogSyslogWriter_UDP(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	writer := &AccessLogSyslogWriter{
+		config: &teaconfigs.AccessLogSyslogConfig{
+			Network:  "udp",
+			Addr:     conn.LocalAddr().String(),
+			Facility: "local0",
+			Severity: "info",
+			Format:   "${remoteAddr} ${status}",
+		},
+	}
+	writer.Init()
+	defer writer.Close()
+
+	err = writer.Write(&AccessLog{
+		RemoteAddr: "127.0.0.1",
+		Status:     200,
+	})
+	a.IsNil(err)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := string(buf[:n])
+	t.Log(message)
+
+	// local0(16) * 8 + info(6) = 134
+	a.IsTrue(strings.HasPrefix(message, "<134>1 "))
+	a.IsTrue(strings.Contains(message, " teaweb "))
+	a.IsTrue(strings.HasSuffix(message, " access - 127.0.0.1 200"))
+}
+
+func TestAccessLogSyslogWriter_Frame(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	writer := &AccessLogSyslogWriter{
+		network: "tcp",
+	}
+	a.IsTrue(string(writer.frame("<134>1 hello")) == "12 <134>1 hello")
+
+	writer.network = "udp"
+	a.IsTrue(string(writer.frame("<134>1 hello")) == "<134>1 hello")
+}
@@ -13,7 +13,7 @@ type AccessLogWriter interface {
 }
 
 func NewAccessLogWriter(config *teaconfigs.AccessLogConfig) (AccessLogWriter, error) {
-	if config.Target == "file" {
+	if config.Target == teaconfigs.AccessLogTargetFile {
 		fileConfig := &teaconfigs.AccessLogFileConfig{}
 		teautils.MapToObjectYAML(config.Config, fileConfig)
 
@@ -23,13 +23,31 @@ func NewAccessLogWriter(config *teaconfigs.AccessLogConfig) (AccessLogWriter, er
 
 		writer.Init()
 		return writer, nil
-	} else if config.Target == "stdout" {
+	} else if config.Target == teaconfigs.AccessLogTargetStdout {
 		stdoutConfig := &teaconfigs.AccessLogStdoutConfig{}
 		teautils.MapToObjectYAML(config.Config, stdoutConfig)
 		writer := &AccessLogStdoutWriter{
 			config: stdoutConfig,
 		}
 
+		writer.Init()
+		return writer, nil
+	} else if config.Target == teaconfigs.AccessLogTargetPipe {
+		pipeConfig := &teaconfigs.AccessLogPipeConfig{}
+		teautils.MapToObjectYAML(config.Config, pipeConfig)
+		writer := &AccessLogPipeWriter{
+			config: pipeConfig,
+		}
+
+		writer.Init()
+		return writer, nil
+	} else if config.Target == teaconfigs.AccessLogTargetSyslog {
+		syslogConfig := &teaconfigs.AccessLogSyslogConfig{}
+		teautils.MapToObjectYAML(config.Config, syslogConfig)
+		writer := &AccessLogSyslogWriter{
+			config: syslogConfig,
+		}
+
 		writer.Init()
 		return writer, nil
 	}
@@ -59,7 +59,11 @@ func (this *AccessLogger) Push(log *AccessLog) {
 	}
 }
 
+// 没有MongoDB配置时返回nil
 func (this *AccessLogger) client() *mongo.Client {
+	if !teamongo.HasConfig() {
+		return nil
+	}
 	return teamongo.SharedClient()
 }
 
@@ -169,6 +173,11 @@ func (this *AccessLogger) wait() {
 
 			// 清空
 			newDocs = []interface{}{}
+		} else {
+			// 没有MongoDB时丢弃，日志只会写入到配置的日志输出中
+			docsLocker.Lock()
+			docs = []interface{}{}
+			docsLocker.Unlock()
 		}
 	})
 
@@ -53,6 +53,11 @@ func SharedClient() *mongo.Client {
 	return sharedClient
 }
 
+// 判断是否已有MongoDB配置
+func HasConfig() bool {
+	return files.NewFile(Tea.ConfigFile("mongo.conf")).Exists()
+}
+
 func Test() error {
 	configFile := files.NewFile(Tea.ConfigFile("mongo.conf"))
 	if !configFile.Exists() {
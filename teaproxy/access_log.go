@@ -0,0 +1,98 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/tealogs"
+	"github.com/iwind/TeaGo/logs"
+	"sync"
+	"time"
+)
+
+// 所有的访问日志输出，配置 => writer
+var accessLogWriters = map[*teaconfigs.AccessLogConfig]tealogs.AccessLogWriter{}
+var accessLogWritersLocker = sync.RWMutex{}
+
+// 启动某个服务的所有访问日志输出
+func startAccessLogWriters(server *teaconfigs.ServerConfig) {
+	accessLogWritersLocker.Lock()
+	defer accessLogWritersLocker.Unlock()
+
+	configs := append([]*teaconfigs.AccessLogConfig{}, server.AccessLog...)
+	for _, location := range server.Locations {
+		configs = append(configs, location.AccessLog...)
+	}
+
+	for _, config := range configs {
+		if config == nil || !config.On {
+			continue
+		}
+		if _, found := accessLogWriters[config]; found {
+			continue
+		}
+		writer, err := tealogs.NewAccessLogWriter(config)
+		if err != nil {
+			logs.Error(err)
+			continue
+		}
+		accessLogWriters[config] = writer
+	}
+}
+
+// 关闭所有的访问日志输出
+func stopAccessLogWriters() {
+	accessLogWritersLocker.Lock()
+	defer accessLogWritersLocker.Unlock()
+
+	for _, writer := range accessLogWriters {
+		writer.Close()
+	}
+	accessLogWriters = map[*teaconfigs.AccessLogConfig]tealogs.AccessLogWriter{}
+}
+
+// 重新加载访问日志输出，旧的输出会保留到正在处理的请求结束后再关闭
+func reloadAccessLogWriters(servers []*teaconfigs.ServerConfig) {
+	accessLogWritersLocker.Lock()
+	oldWriters := accessLogWriters
+	accessLogWriters = map[*teaconfigs.AccessLogConfig]tealogs.AccessLogWriter{}
+	for config, writer := range oldWriters {
+		accessLogWriters[config] = writer
+	}
+	accessLogWritersLocker.Unlock()
+
+	for _, server := range servers {
+		startAccessLogWriters(server)
+	}
+
+	time.AfterFunc(listenerDrainTimeout, func() {
+		accessLogWritersLocker.Lock()
+		defer accessLogWritersLocker.Unlock()
+
+		for config, writer := range oldWriters {
+			if accessLogWriters[config] == writer {
+				delete(accessLogWriters, config)
+				writer.Close()
+			}
+		}
+	})
+}
+
+// 将日志写入到配置的输出中
+func writeAccessLog(configs []*teaconfigs.AccessLogConfig, accessLog *tealogs.AccessLog) {
+	if len(configs) == 0 {
+		return
+	}
+
+	accessLogWritersLocker.RLock()
+	defer accessLogWritersLocker.RUnlock()
+
+	for _, config := range configs {
+		writer, found := accessLogWriters[config]
+		if !found {
+			continue
+		}
+		err := writer.Write(accessLog)
+		if err != nil {
+			logs.Error(err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package teaproxy
+
+import (
+	"bytes"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 异步请求队列状态
+type AsyncQueueStat struct {
+	ServerId      string `json:"serverId"`      // 服务ID
+	LocationId    string `json:"locationId"`    // 路径规则ID，为空表示服务级别的队列
+	Capacity      int    `json:"capacity"`      // 队列长度
+	Length        int    `json:"length"`        // 正在排队的请求数
+	Processing    int64  `json:"processing"`    // 正在转发的请求数
+	Succeeded     int64  `json:"succeeded"`     // 转发成功的请求数
+	Failed        int64  `json:"failed"`        // 重试后仍然失败的请求数
+	Retries       int64  `json:"retries"`       // 重试次数
+	Dropped       int64  `json:"dropped"`       // 队列满时被拒绝的请求数
+	LastError     string `json:"lastError"`     // 最后一次失败的原因
+	LastErrorTime int64  `json:"lastErrorTime"` // 最后一次失败的时间戳
+}
+
+// 异步转发任务
+type asyncTask struct {
+	req    *Request
+	body   []byte
+	config *teaconfigs.AsyncConfig
+}
+
+// 异步请求队列
+type asyncQueue struct {
+	serverId    string
+	locationId  string
+	tasks       chan *asyncTask
+	concurrency int
+	done        chan bool
+
+	processing int64
+	succeeded  int64
+	failed     int64
+	retries    int64
+	dropped    int64
+
+	lastError     string
+	lastErrorTime int64
+	locker        sync.Mutex
+}
+
+var asyncQueueMap = map[string]*asyncQueue{} // serverId@locationId => queue
+var asyncQueueLocker = sync.Mutex{}
+
+// 查找队列，队列长度或并发数变化时会使用新的队列，旧的队列处理完剩余的任务后停止
+func findAsyncQueue(serverId string, locationId string, config *teaconfigs.AsyncConfig) *asyncQueue {
+	key := serverId + "@" + locationId
+
+	asyncQueueLocker.Lock()
+	defer asyncQueueLocker.Unlock()
+
+	queue, found := asyncQueueMap[key]
+	if found && cap(queue.tasks) == config.QueueSize && queue.concurrency == config.Concurrency {
+		return queue
+	}
+
+	newQueue := newAsyncQueue(serverId, locationId, config.QueueSize, config.Concurrency)
+	if found {
+		newQueue.copyStats(queue)
+		queue.stop()
+	}
+	asyncQueueMap[key] = newQueue
+	newQueue.start()
+	return newQueue
+}
+
+// 取得某个服务的所有队列状态
+func AsyncQueueStats(serverId string) []*AsyncQueueStat {
+	asyncQueueLocker.Lock()
+	queues := []*asyncQueue{}
+	for _, queue := range asyncQueueMap {
+		if queue.serverId == serverId {
+			queues = append(queues, queue)
+		}
+	}
+	asyncQueueLocker.Unlock()
+
+	result := []*AsyncQueueStat{}
+	for _, queue := range queues {
+		result = append(result, queue.stat())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LocationId < result[j].LocationId
+	})
+	return result
+}
+
+// 获取新对象
+func newAsyncQueue(serverId string, locationId string, size int, concurrency int) *asyncQueue {
+	return &asyncQueue{
+		serverId:    serverId,
+		locationId:  locationId,
+		tasks:       make(chan *asyncTask, size),
+		concurrency: concurrency,
+		done:        make(chan bool),
+	}
+}
+
+// 启动
+func (this *asyncQueue) start() {
+	for i := 0; i < this.concurrency; i++ {
+		go this.loop()
+	}
+}
+
+// 停止，已经在队列中的任务仍然会被处理
+func (this *asyncQueue) stop() {
+	close(this.done)
+}
+
+// 放入队列，队列满时返回false
+func (this *asyncQueue) push(task *asyncTask) bool {
+	select {
+	case this.tasks <- task:
+		return true
+	default:
+		atomic.AddInt64(&this.dropped, 1)
+		return false
+	}
+}
+
+// 循环处理任务
+func (this *asyncQueue) loop() {
+	for {
+		select {
+		case task := <-this.tasks:
+			this.process(task)
+		case <-this.done:
+			for {
+				select {
+				case task := <-this.tasks:
+					this.process(task)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// 转发请求，失败时切换到下一个后端服务重试
+func (this *asyncQueue) process(task *asyncTask) {
+	atomic.AddInt64(&this.processing, 1)
+	defer atomic.AddInt64(&this.processing, -1)
+
+	req := task.req
+	triedBackends := []*teaconfigs.BackendConfig{}
+	for tries := 1; ; tries++ {
+		req.raw.Body = ioutil.NopCloser(bytes.NewReader(task.body))
+		triedBackends = append(triedBackends, req.backend)
+
+		writer := NewResponseWriter(newResponseRecorder())
+		err := req.callBackend(writer)
+		writer.Close()
+		if err == nil && writer.StatusCode() < 500 {
+			atomic.AddInt64(&this.succeeded, 1)
+			return
+		}
+		if err == nil {
+			err = errors.New(req.requestPath() + ": backend responded with status " + strconv.Itoa(writer.StatusCode()))
+		}
+
+		if tries >= task.config.MaxTries {
+			atomic.AddInt64(&this.failed, 1)
+			this.recordError(err)
+			return
+		}
+
+		atomic.AddInt64(&this.retries, 1)
+		time.Sleep(task.config.RetryIntervalDuration())
+		req.nextRetryBackend(triedBackends, task.body)
+	}
+}
+
+// 记录失败原因
+func (this *asyncQueue) recordError(err error) {
+	this.locker.Lock()
+	this.lastError = err.Error()
+	this.lastErrorTime = time.Now().Unix()
+	this.locker.Unlock()
+}
+
+// 从旧的队列中复制统计数据
+func (this *asyncQueue) copyStats(queue *asyncQueue) {
+	this.succeeded = atomic.LoadInt64(&queue.succeeded)
+	this.failed = atomic.LoadInt64(&queue.failed)
+	this.retries = atomic.LoadInt64(&queue.retries)
+	this.dropped = atomic.LoadInt64(&queue.dropped)
+
+	queue.locker.Lock()
+	this.lastError = queue.lastError
+	this.lastErrorTime = queue.lastErrorTime
+	queue.locker.Unlock()
+}
+
+// 当前状态
+func (this *asyncQueue) stat() *AsyncQueueStat {
+	this.locker.Lock()
+	lastError := this.lastError
+	lastErrorTime := this.lastErrorTime
+	this.locker.Unlock()
+
+	return &AsyncQueueStat{
+		ServerId:      this.serverId,
+		LocationId:    this.locationId,
+		Capacity:      cap(this.tasks),
+		Length:        len(this.tasks),
+		Processing:    atomic.LoadInt64(&this.processing),
+		Succeeded:     atomic.LoadInt64(&this.succeeded),
+		Failed:        atomic.LoadInt64(&this.failed),
+		Retries:       atomic.LoadInt64(&this.retries),
+		Dropped:       atomic.LoadInt64(&this.dropped),
+		LastError:     lastError,
+		LastErrorTime: lastErrorTime,
+	}
+}
@@ -0,0 +1,82 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncQueue(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	requests := int32(0)
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			writer.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		bodies <- string(data)
+	}))
+	defer server.Close()
+
+	backend := &teaconfigs.BackendConfig{On: true, Address: strings.TrimPrefix(server.URL, "http://")}
+	list := &teaconfigs.BackendList{}
+	list.AddBackend(backend)
+	a.IsNil(list.ValidateBackends())
+
+	config := teaconfigs.NewAsyncConfig()
+	config.QueueSize = 1
+	config.Concurrency = 1
+	config.RetryInterval = "10ms"
+	a.IsNil(config.Validate())
+
+	raw, err := http.NewRequest(http.MethodPost, "/hello", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.RemoteAddr = "127.0.0.1:1234"
+	req := NewRequest(raw)
+	req.scheme = "http"
+	req.host = "example.com"
+	req.uri = "/hello"
+	req.backend = backend
+	req.backendList = list
+
+	queue := findAsyncQueue("test", "", config)
+	a.IsTrue(queue == findAsyncQueue("test", "", config))
+	a.IsTrue(queue.push(&asyncTask{
+		req:    req,
+		body:   []byte("Hello"),
+		config: config,
+	}))
+
+	select {
+	case body := <-bodies:
+		a.IsTrue(body == "Hello")
+	case <-time.After(5 * time.Second):
+		t.Fatal("async request timeout")
+	}
+
+	// 等待统计数据更新
+	time.Sleep(100 * time.Millisecond)
+	stats := AsyncQueueStats("test")
+	a.IsTrue(len(stats) == 1)
+	a.IsTrue(stats[0].Capacity == 1)
+	a.IsTrue(stats[0].Succeeded == 1)
+	a.IsTrue(stats[0].Retries == 1)
+	a.IsTrue(stats[0].Failed == 0)
+
+	// 修改队列长度后使用新的队列
+	config.QueueSize = 2
+	newQueue := findAsyncQueue("test", "", config)
+	a.IsTrue(newQueue != queue)
+	a.IsTrue(newQueue.stat().Succeeded == 1)
+}
@@ -0,0 +1,265 @@
+package teaproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/logs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 证书集合，根据客户端发送的SNI选择证书和TLS配置
+type certificateStore struct {
+	locker sync.RWMutex
+
+	names         map[string]*serverCertificate // 域名 => 证书
+	wildcards     map[string]*serverCertificate // 泛域名后缀，比如 .example.com => 证书
+	servers       []*serverCertificate          // 按服务顺序排列，用于匹配其他形式的域名
+	defaultServer *serverCertificate            // 默认证书
+
+	modifiedAt map[string]time.Time // 证书文件 => 修改时间
+}
+
+// 服务和对应的证书
+type serverCertificate struct {
+	server *teaconfigs.ServerConfig
+	cert   *tls.Certificate
+	config *tls.Config // 服务的TLS配置
+
+	ocspRefreshAt time.Time // OCSP响应的更新时间
+	ocspCheckedAt time.Time // 最后一次获取OCSP响应的时间
+}
+
+// 获取新对象
+func newCertificateStore() *certificateStore {
+	return &certificateStore{
+		names:      map[string]*serverCertificate{},
+		wildcards:  map[string]*serverCertificate{},
+		modifiedAt: map[string]time.Time{},
+	}
+}
+
+// 从服务配置中加载证书，加载失败的证书会被忽略
+func (this *certificateStore) load(servers []*teaconfigs.ServerConfig) error {
+	certs := []*serverCertificate{}
+	modifiedAt := map[string]time.Time{}
+	hasACME := false
+	for _, server := range servers {
+		if server.SSL == nil || !server.SSL.On {
+			continue
+		}
+		if server.SSL.IsACME() {
+			hasACME = true
+		}
+
+		certFile := certificatePath(server.SSL.Certificate)
+		keyFile := certificatePath(server.SSL.CertificateKey)
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			// 自动申请的证书还没有申请成功
+			if server.SSL.IsACME() && os.IsNotExist(err) {
+				continue
+			}
+			logs.Error(errors.New("load certificate for server '" + server.Id + "' failed: " + err.Error()))
+			continue
+		}
+
+		for _, file := range []string{certFile, keyFile} {
+			stat, err := os.Stat(file)
+			if err == nil {
+				modifiedAt[file] = stat.ModTime()
+			}
+		}
+
+		certs = append(certs, &serverCertificate{
+			server: server,
+			cert:   &cert,
+		})
+	}
+
+	// 自动申请证书的服务需要等待证书申请成功
+	if len(certs) == 0 && !hasACME {
+		return errors.New("no valid certificate found")
+	}
+
+	this.build(certs)
+
+	this.locker.Lock()
+	this.modifiedAt = modifiedAt
+	this.locker.Unlock()
+
+	return nil
+}
+
+// 构建证书索引，并替换当前的证书
+func (this *certificateStore) build(certs []*serverCertificate) {
+	names := map[string]*serverCertificate{}
+	wildcards := map[string]*serverCertificate{}
+	var defaultServer *serverCertificate
+
+	for _, c := range certs {
+		if c.cert.Leaf == nil && len(c.cert.Certificate) > 0 {
+			leaf, err := x509.ParseCertificate(c.cert.Certificate[0])
+			if err == nil {
+				c.cert.Leaf = leaf
+			}
+		}
+
+		// 服务中的域名优先于证书中的域名
+		for _, name := range c.server.Name {
+			addCertificateName(names, wildcards, name, c)
+		}
+		if c.cert.Leaf != nil {
+			for _, name := range c.cert.Leaf.DNSNames {
+				addCertificateName(names, wildcards, name, c)
+			}
+			if len(c.cert.Leaf.DNSNames) == 0 {
+				addCertificateName(names, wildcards, c.cert.Leaf.Subject.CommonName, c)
+			}
+		}
+
+		if defaultServer == nil && c.server.SSL != nil && c.server.SSL.Default {
+			defaultServer = c
+		}
+
+		// 每个服务使用自己的TLS版本、加密算法套件和客户端认证方式
+		if c.server.SSL != nil {
+			c.config = c.server.SSL.TLSConfig()
+		} else {
+			c.config = &tls.Config{}
+		}
+		c.config.NextProtos = []string{"h2", "http/1.1"}
+		serverCert := c
+		c.config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return this.certificate(serverCert), nil
+		}
+	}
+
+	// 如果没有设置默认证书，则使用第一个
+	if defaultServer == nil && len(certs) > 0 {
+		defaultServer = certs[0]
+	}
+
+	this.locker.Lock()
+	this.names = names
+	this.wildcards = wildcards
+	this.servers = certs
+	this.defaultServer = defaultServer
+	this.locker.Unlock()
+}
+
+// 根据SNI查找证书
+func (this *certificateStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	c := this.find(hello.ServerName)
+	if c == nil {
+		return nil, errors.New("no certificate for '" + hello.ServerName + "'")
+	}
+	return c.cert, nil
+}
+
+// 根据SNI查找服务的TLS配置
+func (this *certificateStore) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	c := this.find(hello.ServerName)
+	if c == nil {
+		return nil, errors.New("no certificate for '" + hello.ServerName + "'")
+	}
+	return c.config, nil
+}
+
+// 查找域名对应的证书，调用前需要加锁
+func (this *certificateStore) find(serverName string) *serverCertificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if len(name) > 0 {
+		// 精确查找
+		c, found := this.names[name]
+		if found {
+			return c
+		}
+
+		// 泛域名
+		dotIndex := strings.Index(name, ".")
+		if dotIndex > 0 {
+			c, found := this.wildcards[name[dotIndex:]]
+			if found {
+				return c
+			}
+		}
+
+		// 其他形式的域名，比如正则表达式
+		for _, c := range this.servers {
+			if _, matched := c.server.MatchName(name); matched {
+				return c
+			}
+		}
+	}
+
+	return this.defaultServer
+}
+
+// 读取服务当前的证书
+func (this *certificateStore) certificate(c *serverCertificate) *tls.Certificate {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return c.cert
+}
+
+// 判断证书文件是否有变化
+func (this *certificateStore) isChanged() bool {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	for file, modifiedAt := range this.modifiedAt {
+		stat, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !stat.ModTime().Equal(modifiedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// 加入域名，先加入的优先
+func addCertificateName(names map[string]*serverCertificate, wildcards map[string]*serverCertificate, name string, c *serverCertificate) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 || name[0] == '~' || name[0] == '.' {
+		return
+	}
+	if strings.HasPrefix(name, "*.") {
+		suffix := name[1:]
+		if strings.Contains(suffix[1:], "*") {
+			return
+		}
+		if _, found := wildcards[suffix]; !found {
+			wildcards[suffix] = c
+		}
+		return
+	}
+	if strings.Contains(name, "*") {
+		return
+	}
+	if _, found := names[name]; !found {
+		names[name] = c
+	}
+}
+
+// 证书文件路径，相对路径的文件在配置目录下
+func certificatePath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return Tea.ConfigFile(file)
+}
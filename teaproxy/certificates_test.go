@@ -0,0 +1,156 @@
+package teaproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCertificateStore_GetCertificate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	cert1 := testCertificate(t, "example.com", "www.example.com")
+	cert2 := testCertificate(t, "*.teaos.cn")
+	cert3 := testCertificate(t, "api.example.com")
+	cert4 := testCertificate(t, "other.com")
+
+	store := newCertificateStore()
+	store.build([]*serverCertificate{
+		{
+			server: &teaconfigs.ServerConfig{Name: []string{"example.com"}, SSL: &teaconfigs.SSLConfig{On: true}},
+			cert:   cert1,
+		},
+		{
+			server: &teaconfigs.ServerConfig{SSL: &teaconfigs.SSLConfig{On: true}},
+			cert:   cert2,
+		},
+		{
+			server: &teaconfigs.ServerConfig{Name: []string{"api.example.com", "~^api\\d+\\.example\\.com$"}, SSL: &teaconfigs.SSLConfig{On: true, Default: true}},
+			cert:   cert3,
+		},
+		{
+			server: &teaconfigs.ServerConfig{Name: []string{"other.com"}, SSL: &teaconfigs.SSLConfig{On: true}},
+			cert:   cert4,
+		},
+	})
+
+	get := func(serverName string) *tls.Certificate {
+		cert, err := store.getCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	a.IsTrue(get("example.com") == cert1)
+	a.IsTrue(get("WWW.Example.com.") == cert1)
+	a.IsTrue(get("api.example.com") == cert3)
+	a.IsTrue(get("api2.example.com") == cert3)
+	a.IsTrue(get("a.teaos.cn") == cert2)
+	a.IsTrue(get("a.b.teaos.cn") == cert3)
+	a.IsTrue(get("other.com") == cert4)
+	a.IsTrue(get("unknown.com") == cert3)
+	a.IsTrue(get("") == cert3)
+}
+
+func TestCertificateStore_DefaultCertificate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	cert1 := testCertificate(t, "example.com")
+	cert2 := testCertificate(t, "other.com")
+
+	store := newCertificateStore()
+	_, err := store.getCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	a.IsNotNil(err)
+
+	store.build([]*serverCertificate{
+		{
+			server: &teaconfigs.ServerConfig{SSL: &teaconfigs.SSLConfig{On: true}},
+			cert:   cert1,
+		},
+		{
+			server: &teaconfigs.ServerConfig{SSL: &teaconfigs.SSLConfig{On: true}},
+			cert:   cert2,
+		},
+	})
+
+	cert, err := store.getCertificate(&tls.ClientHelloInfo{})
+	a.IsNil(err)
+	a.IsTrue(cert == cert1)
+}
+
+func TestCertificateStore_GetConfigForClient(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	cert1 := testCertificate(t, "example.com")
+	cert2 := testCertificate(t, "other.com")
+
+	ssl1 := &teaconfigs.SSLConfig{On: true, Certificate: "a.pem", CertificateKey: "a.key", MinVersion: teaconfigs.TLSVersion12}
+	a.IsNil(ssl1.Validate())
+	ssl2 := &teaconfigs.SSLConfig{On: true, Certificate: "b.pem", CertificateKey: "b.key", ClientAuthType: teaconfigs.SSLClientAuthTypeRequest}
+	a.IsNil(ssl2.Validate())
+
+	store := newCertificateStore()
+	store.build([]*serverCertificate{
+		{
+			server: &teaconfigs.ServerConfig{SSL: ssl1},
+			cert:   cert1,
+		},
+		{
+			server: &teaconfigs.ServerConfig{SSL: ssl2},
+			cert:   cert2,
+		},
+	})
+
+	{
+		config, err := store.getConfigForClient(&tls.ClientHelloInfo{ServerName: "example.com"})
+		a.IsNil(err)
+		a.IsTrue(config.MinVersion == tls.VersionTLS12)
+		a.IsTrue(config.ClientAuth == tls.NoClientCert)
+
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		a.IsNil(err)
+		a.IsTrue(cert == cert1)
+	}
+
+	{
+		config, err := store.getConfigForClient(&tls.ClientHelloInfo{ServerName: "other.com"})
+		a.IsNil(err)
+		a.IsTrue(config.MinVersion == 0)
+		a.IsTrue(config.ClientAuth == tls.RequestClientCert)
+
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		a.IsNil(err)
+		a.IsTrue(cert == cert2)
+	}
+}
+
+func testCertificate(t *testing.T, names ...string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
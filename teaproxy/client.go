@@ -2,6 +2,9 @@ package teaproxy
 
 import (
 	"context"
+	"crypto/tls"
+	"github.com/TeaWeb/code/teaconfigs"
+	"golang.org/x/net/http2"
 	"net"
 	"net/http"
 	"sync"
@@ -11,34 +14,122 @@ import (
 // 客户端池单例
 var SharedClientPool = NewClientPool()
 
+// 客户端超过这个时间没有使用就会被清除
+const clientExpireDuration = 10 * time.Minute
+
 // 客户端池
 type ClientPool struct {
-	clientsMap map[string]*http.Client // address => client
+	clientsMap map[string]*poolClient // address@key => client
 	locker     sync.Mutex
+
+	cleanedAt time.Time // 上次清理的时间
+}
+
+// 池中的客户端
+type poolClient struct {
+	client     *http.Client
+	accessedAt time.Time
+}
+
+// 客户端选项
+type clientOptions struct {
+	connectTimeout    time.Duration // 连接超时时间
+	readHeaderTimeout time.Duration // 读取响应Header超时时间，0表示不限
+	idleTimeout       time.Duration // 空闲连接超时时间，0表示不限
+	timeout           time.Duration // 整个请求的超时时间，0表示不限
+	maxConnections    uint
+	tlsConfig         *tls.Config
+	https             bool   // 是否使用https连接
+	http2             bool   // 是否使用HTTP/2连接，不使用https时为h2c
+	key               string // 用来区分同一个地址的不同选项
 }
 
 // 获取新对象
 func NewClientPool() *ClientPool {
 	return &ClientPool{
-		clientsMap: map[string]*http.Client{},
+		clientsMap: map[string]*poolClient{},
+		cleanedAt:  time.Now(),
 	}
 }
 
 // 根据地址获取客户端
 func (this *ClientPool) client(address string, connectionTimeout time.Duration, maxConnections uint) *http.Client {
+	return this.clientWithOptions(address, &clientOptions{
+		connectTimeout: connectionTimeout,
+		timeout:        15 * time.Second,
+		maxConnections: maxConnections,
+	})
+}
+
+// 获取后端服务的客户端，使用后端服务的超时时间和TLS配置，forceHTTP2表示强制使用HTTP/2（比如gRPC）
+// 整个请求的超时时间不在客户端中设置，而是在每次请求时通过context控制，以免中断gRPC等流式响应
+func (this *ClientPool) backendClient(backend *teaconfigs.BackendConfig, forceHTTP2 bool) *http.Client {
+	return this.clientWithOptions(backend.Address, &clientOptions{
+		connectTimeout:    backend.ConnectTimeoutDuration(),
+		readHeaderTimeout: backend.ReadHeaderTimeoutDuration(),
+		idleTimeout:       backend.IdleTimeoutDuration(),
+		maxConnections:    backend.MaxConns,
+		tlsConfig:         backend.TLSConfig(),
+		https:             backend.IsHTTPS(),
+		http2:             backend.HTTP2 || forceHTTP2,
+		key:               backend.ClientKey(),
+	})
+}
+
+// 根据地址和选项获取客户端
+func (this *ClientPool) clientWithOptions(address string, options *clientOptions) *http.Client {
 	this.locker.Lock()
 	defer this.locker.Unlock()
 
-	client, found := this.clientsMap[address]
+	now := time.Now()
+	this.clean(now)
+
+	key := address
+	if len(options.key) > 0 {
+		key += "@" + options.key
+	} else {
+		key += "@" + options.connectTimeout.String() + "@" + options.timeout.String()
+	}
+	if options.http2 {
+		key += "@h2"
+	}
+
+	c, found := this.clientsMap[key]
 	if found {
-		return client
+		c.accessedAt = now
+		return c.client
 	}
 
 	// 超时时间
+	connectionTimeout := options.connectTimeout
 	if connectionTimeout <= 0 {
 		connectionTimeout = 15 * time.Second
 	}
 
+	var tr http.RoundTripper
+	if options.http2 {
+		tr = this.http2Transport(address, connectionTimeout, options)
+	} else {
+		tr = this.http1Transport(address, connectionTimeout, options)
+	}
+
+	client := &http.Client{
+		Timeout:   options.timeout,
+		Transport: tr,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return &RedirectError{}
+		},
+	}
+	this.clientsMap[key] = &poolClient{
+		client:     client,
+		accessedAt: now,
+	}
+
+	return client
+}
+
+// HTTP/1.1连接
+func (this *ClientPool) http1Transport(address string, connectionTimeout time.Duration, options *clientOptions) *http.Transport {
 	tr := &http.Transport{
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			// 握手配置
@@ -48,21 +139,59 @@ func (this *ClientPool) client(address string, connectionTimeout time.Duration,
 				DualStack: true,
 			}).DialContext(ctx, network, address)
 		},
-		MaxIdleConns:          int(maxConnections), // 0表示不限
+		MaxIdleConns:          int(options.maxConnections), // 0表示不限
 		MaxIdleConnsPerHost:   1024,
-		IdleConnTimeout:       0, // 不限
-		TLSHandshakeTimeout:   0, // 不限
+		IdleConnTimeout:       options.idleTimeout,       // 0表示不限
+		ResponseHeaderTimeout: options.readHeaderTimeout, // 0表示不限
+		TLSHandshakeTimeout:   0,                         // 不限
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	if options.tlsConfig != nil {
+		tr.TLSClientConfig = options.tlsConfig.Clone()
+	}
+	return tr
+}
 
-	c := &http.Client{
-		Timeout:   15 * time.Second,
-		Transport: tr,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return &RedirectError{}
+// HTTP/2连接，不使用https时直接使用h2c连接
+func (this *ClientPool) http2Transport(address string, connectionTimeout time.Duration, options *clientOptions) *http2.Transport {
+	tr := &http2.Transport{
+		AllowHTTP: !options.https,
+		DialTLSContext: func(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+			conn, err := (&net.Dialer{
+				Timeout:   connectionTimeout,
+				KeepAlive: 120 * time.Second,
+			}).DialContext(ctx, network, address)
+			if err != nil || !options.https {
+				return conn, err
+			}
+
+			tlsConn := tls.Client(conn, config)
+			err = tlsConn.HandshakeContext(ctx)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
 		},
+		IdleConnTimeout: options.idleTimeout, // 0表示不限
+	}
+	if options.tlsConfig != nil {
+		tr.TLSClientConfig = options.tlsConfig.Clone()
 	}
-	this.clientsMap[address] = c
+	return tr
+}
+
+// 清除长时间没有使用的客户端，调用前需要加锁
+func (this *ClientPool) clean(now time.Time) {
+	if now.Sub(this.cleanedAt) < clientExpireDuration {
+		return
+	}
+	this.cleanedAt = now
 
-	return c
+	for key, c := range this.clientsMap {
+		if now.Sub(c.accessedAt) > clientExpireDuration {
+			c.client.CloseIdleConnections()
+			delete(this.clientsMap, key)
+		}
+	}
 }
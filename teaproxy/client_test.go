@@ -1,8 +1,12 @@
 package teaproxy
 
 import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -47,3 +51,45 @@ func TestNewClientPool(t *testing.T) {
 	wg.Wait()
 	t.Log("finished, fails:", fails, int(float64(threads*count)/time.Since(before).Seconds()))
 }
+
+func TestClientPool_BackendClient(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Write([]byte("hello, " + req.TLS.ServerName))
+	}))
+	defer server.Close()
+
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	backend := teaconfigs.NewBackendConfig()
+	backend.Address = address
+	backend.Scheme = teaconfigs.BackendSchemeHTTPS
+	backend.TLS = &teaconfigs.BackendTLSConfig{
+		ServerName:         "example.com",
+		InsecureSkipVerify: true,
+	}
+	a.IsNil(backend.Validate())
+
+	pool := NewClientPool()
+	client := pool.backendClient(backend, false)
+	a.IsTrue(client == pool.backendClient(backend, false))
+	a.IsTrue(client != pool.client(address, 0, 0))
+
+	resp, err := client.Get("https://teaos.cn/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(string(data) == "hello, example.com")
+
+	// 校验证书失败
+	backend.TLS.InsecureSkipVerify = false
+	a.IsNil(backend.Validate())
+	_, err = pool.backendClient(backend, false).Get("https://teaos.cn/")
+	a.IsNotNil(err)
+}
@@ -0,0 +1,27 @@
+package teaproxy
+
+import (
+	"compress/gzip"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/andybalholm/brotli"
+	"io"
+)
+
+// 获取压缩Writer
+func newCompressionWriter(writer io.Writer, encoding string, level int) (io.WriteCloser, error) {
+	switch encoding {
+	case teaconfigs.CompressionEncodingGzip:
+		return gzip.NewWriterLevel(writer, level)
+	case teaconfigs.CompressionEncodingBrotli:
+		return brotli.NewWriterLevel(writer, level), nil
+	}
+	return nil, errors.New("unsupported compression encoding '" + encoding + "'")
+}
+
+// 将函数转换为io.Writer
+type writerFunc func(data []byte) (n int, err error)
+
+func (f writerFunc) Write(data []byte) (n int, err error) {
+	return f(data)
+}
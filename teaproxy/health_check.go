@@ -0,0 +1,188 @@
+package teaproxy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teastats"
+	"github.com/iwind/TeaGo/logs"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 所有的健康检查
+var healthCheckers = []*HealthChecker{}
+var healthCheckersLocker = sync.Mutex{}
+
+// 健康检查
+type HealthChecker struct {
+	serverId    string
+	backendList *teaconfigs.BackendList
+	config      *teaconfigs.HealthCheckConfig
+
+	client *http.Client
+	quit   chan bool
+}
+
+// 获取新对象
+func NewHealthChecker(serverId string, backendList *teaconfigs.BackendList) *HealthChecker {
+	config := backendList.HealthCheck
+	return &HealthChecker{
+		serverId:    serverId,
+		backendList: backendList,
+		config:      config,
+		client: &http.Client{
+			Timeout: config.TimeoutDuration(),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		quit: make(chan bool, 1),
+	}
+}
+
+// 启动
+func (this *HealthChecker) Start() {
+	go func() {
+		ticker := time.NewTicker(this.config.IntervalDuration())
+		defer ticker.Stop()
+
+		this.checkAll()
+		for {
+			select {
+			case <-ticker.C:
+				this.checkAll()
+			case <-this.quit:
+				return
+			}
+		}
+	}()
+}
+
+// 停止
+func (this *HealthChecker) Stop() {
+	select {
+	case this.quit <- true:
+	default:
+	}
+}
+
+// 检查所有的后端服务器
+func (this *HealthChecker) checkAll() {
+	wg := sync.WaitGroup{}
+	changed := false
+	changedLocker := sync.Mutex{}
+
+	for _, backend := range this.backendList.Backends {
+		if !backend.On {
+			continue
+		}
+		wg.Add(1)
+		go func(backend *teaconfigs.BackendConfig) {
+			defer wg.Done()
+
+			err := this.Check(backend)
+			if backend.RecordCheck(err, this.config.HealthyThreshold, this.config.UnhealthyThreshold) {
+				changedLocker.Lock()
+				changed = true
+				changedLocker.Unlock()
+
+				if backend.IsOffline() {
+					logs.Println("[health check]backend '" + backend.Address + "' is down: " + err.Error())
+				} else {
+					logs.Println("[health check]backend '" + backend.Address + "' is up")
+				}
+			}
+
+			teastats.SharedBackendHealthStat().Record(this.serverId, backend.Id, err == nil)
+		}(backend)
+	}
+	wg.Wait()
+
+	// 重建调度对象
+	if changed {
+		this.backendList.SetupScheduling(false)
+	}
+}
+
+// 检查单个后端服务器
+func (this *HealthChecker) Check(backend *teaconfigs.BackendConfig) error {
+	if this.config.Type == teaconfigs.HealthCheckTypeTCP {
+		conn, err := net.DialTimeout("tcp", backend.Address, this.config.TimeoutDuration())
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, this.config.BackendURL(backend), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "TeaWeb-HealthCheck")
+
+	// https后端使用后端的TLS配置
+	client := this.client
+	if tlsConfig := backend.TLSConfig(); tlsConfig != nil {
+		client = &http.Client{
+			Timeout:       this.client.Timeout,
+			CheckRedirect: this.client.CheckRedirect,
+			Transport: &http.Transport{
+				TLSClientConfig:   tlsConfig.Clone(),
+				DisableKeepAlives: true,
+			},
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if !this.config.MatchStatus(resp.StatusCode) {
+		return errors.New(fmt.Sprintf("unexpected status code: %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// 启动某个服务的所有健康检查
+func startHealthCheckers(server *teaconfigs.ServerConfig) {
+	healthCheckersLocker.Lock()
+	defer healthCheckersLocker.Unlock()
+
+	backendLists := []*teaconfigs.BackendList{&server.BackendList}
+	for _, location := range server.Locations {
+		if !location.On {
+			continue
+		}
+		backendLists = append(backendLists, &location.BackendList)
+		if location.Websocket != nil && location.Websocket.On {
+			backendLists = append(backendLists, &location.Websocket.BackendList)
+		}
+	}
+
+	for _, backendList := range backendLists {
+		if backendList.HealthCheck == nil || !backendList.HealthCheck.On || len(backendList.Backends) == 0 {
+			continue
+		}
+		checker := NewHealthChecker(server.Id, backendList)
+		checker.Start()
+		healthCheckers = append(healthCheckers, checker)
+	}
+}
+
+// 停止所有的健康检查
+func stopHealthCheckers() {
+	healthCheckersLocker.Lock()
+	defer healthCheckersLocker.Unlock()
+
+	for _, checker := range healthCheckers {
+		checker.Stop()
+	}
+	healthCheckers = []*HealthChecker{}
+}
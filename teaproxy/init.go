@@ -3,6 +3,7 @@ package teaproxy
 import (
 	"github.com/TeaWeb/code/teaconfigs"
 	"net/http"
+	"sync"
 )
 
 // 所有监听器集合
@@ -11,5 +12,8 @@ var LISTENERS = []*Listener{}
 // 所有服务
 var SERVERS = map[string]*teaconfigs.ServerConfig{} // id => server
 
+// 用来保护LISTENERS和SERVERS
+var proxyLocker = sync.RWMutex{}
+
 // 状态码筛选
 var StatusCodeParser func(statusCode int, headers http.Header, respData []byte, parserScript string) (string, error) = nil
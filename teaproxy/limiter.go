@@ -0,0 +1,239 @@
+package teaproxy
+
+import (
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/go-redis/redis"
+	"github.com/iwind/TeaGo/maps"
+	"sync"
+	"time"
+)
+
+// 限制计数在存储中的Key前缀
+const limitKeyPrefix = "TEA_LIMIT_"
+
+// 连接计数的最长有效期，防止节点异常退出后连接数无法释放
+const limitConnLife = 1 * time.Hour
+
+// 限制计数存储
+type limitStore interface {
+	// 取一个令牌，返回需要等待的时间
+	// interval为两个请求之间的间隔，tolerance为允许提前的时间，等待时间超出maxWait时不取令牌并返回false
+	Take(key string, interval time.Duration, tolerance time.Duration, maxWait time.Duration) (wait time.Duration, ok bool, err error)
+
+	// 占用一个连接，超出最大连接数时返回false
+	Acquire(key string, maxConns int) (ok bool, err error)
+
+	// 释放一个连接
+	Release(key string) error
+}
+
+var limitStoreMap = map[string]limitStore{}
+var limitStoreLocker = sync.Mutex{}
+
+// 查找存储，相同配置的存储共用一个对象
+func findLimitStore(config *teaconfigs.LimitStoreConfig) limitStore {
+	storeKey := config.Key()
+
+	limitStoreLocker.Lock()
+	defer limitStoreLocker.Unlock()
+
+	store, found := limitStoreMap[storeKey]
+	if found {
+		return store
+	}
+	if config != nil && config.Type == teaconfigs.LimitStoreTypeRedis {
+		store = newRedisLimitStore(config.Options)
+	} else {
+		store = newMemoryLimitStore()
+	}
+	limitStoreMap[storeKey] = store
+	return store
+}
+
+// 内存存储，使用GCRA算法实现令牌桶
+// 参考：https://en.wikipedia.org/wiki/Generic_cell_rate_algorithm
+type memoryLimitStore struct {
+	tatMap  map[string]time.Time // key => 理论上下一个请求到达的时间（TAT）
+	connMap map[string]int       // key => 连接数
+	locker  sync.Mutex
+}
+
+// 获取新对象
+func newMemoryLimitStore() *memoryLimitStore {
+	store := &memoryLimitStore{
+		tatMap:  map[string]time.Time{},
+		connMap: map[string]int{},
+	}
+	go store.clean()
+	return store
+}
+
+// 取一个令牌
+func (this *memoryLimitStore) Take(key string, interval time.Duration, tolerance time.Duration, maxWait time.Duration) (wait time.Duration, ok bool, err error) {
+	now := time.Now()
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	tat, found := this.tatMap[key]
+	if !found || tat.Before(now) {
+		tat = now
+	}
+	wait = tat.Sub(now) - tolerance
+	if wait > maxWait {
+		return wait, false, nil
+	}
+	this.tatMap[key] = tat.Add(interval)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true, nil
+}
+
+// 占用一个连接
+func (this *memoryLimitStore) Acquire(key string, maxConns int) (ok bool, err error) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.connMap[key] >= maxConns {
+		return false, nil
+	}
+	this.connMap[key]++
+	return true, nil
+}
+
+// 释放一个连接
+func (this *memoryLimitStore) Release(key string) error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	count := this.connMap[key] - 1
+	if count <= 0 {
+		delete(this.connMap, key)
+	} else {
+		this.connMap[key] = count
+	}
+	return nil
+}
+
+// 定时清理已经过期的计数
+func (this *memoryLimitStore) clean() {
+	ticker := time.NewTicker(1 * time.Minute)
+	for range ticker.C {
+		now := time.Now()
+		this.locker.Lock()
+		for key, tat := range this.tatMap {
+			if tat.Before(now) {
+				delete(this.tatMap, key)
+			}
+		}
+		this.locker.Unlock()
+	}
+}
+
+// Redis中的GCRA算法，时间单位为微秒
+var redisLimitTakeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tolerance = tonumber(ARGV[3])
+local maxWait = tonumber(ARGV[4])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local wait = tat - now - tolerance
+if wait > maxWait then
+	return {0, wait}
+end
+tat = tat + interval
+redis.call("SET", KEYS[1], tat, "PX", math.ceil((tat - now) / 1000) + 1000)
+return {1, wait}
+`)
+
+// Redis中的连接计数
+var redisLimitAcquireScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+if count > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	return 0
+end
+return 1
+`)
+
+// Redis存储，可以在多个节点之间共享计数
+type redisLimitStore struct {
+	client *redis.Client
+}
+
+// 获取新对象，选项和Redis缓存策略的选项一致
+func newRedisLimitStore(options map[string]interface{}) *redisLimitStore {
+	m := maps.NewMap(options)
+	network := m.GetString("network")
+	host := m.GetString("host")
+	port := m.GetInt("port")
+
+	addr := ""
+	if network == "sock" {
+		addr = m.GetString("sock")
+	} else {
+		network = "tcp"
+		if len(host) == 0 {
+			host = "127.0.0.1"
+		}
+		if port > 0 {
+			addr = fmt.Sprintf("%s:%d", host, port)
+		} else {
+			addr = host + ":6379"
+		}
+	}
+
+	return &redisLimitStore{
+		client: redis.NewClient(&redis.Options{
+			Network:      network,
+			Addr:         addr,
+			Password:     m.GetString("password"),
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}),
+	}
+}
+
+// 取一个令牌
+func (this *redisLimitStore) Take(key string, interval time.Duration, tolerance time.Duration, maxWait time.Duration) (wait time.Duration, ok bool, err error) {
+	now := time.Now().UnixNano() / int64(time.Microsecond)
+	result, err := redisLimitTakeScript.Run(this.client, []string{key}, now, int64(interval/time.Microsecond), int64(tolerance/time.Microsecond), int64(maxWait/time.Microsecond)).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	values, isSlice := result.([]interface{})
+	if !isSlice || len(values) != 2 {
+		return 0, false, fmt.Errorf("unexpected redis result '%v'", result)
+	}
+	allowed, _ := values[0].(int64)
+	waitMicroseconds, _ := values[1].(int64)
+	wait = time.Duration(waitMicroseconds) * time.Microsecond
+	if allowed != 1 {
+		return wait, false, nil
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true, nil
+}
+
+// 占用一个连接
+func (this *redisLimitStore) Acquire(key string, maxConns int) (ok bool, err error) {
+	result, err := redisLimitAcquireScript.Run(this.client, []string{key}, maxConns, int64(limitConnLife/time.Millisecond)).Int64()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+// 释放一个连接
+func (this *redisLimitStore) Release(key string) error {
+	return this.client.Decr(key).Err()
+}
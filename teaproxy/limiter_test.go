@@ -0,0 +1,80 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimitStore_Take(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	store := newMemoryLimitStore()
+
+	// 突发3个请求
+	for i := 0; i < 3; i++ {
+		wait, ok, err := store.Take("a", 1*time.Second, 2*time.Second, 0)
+		a.IsNil(err)
+		a.IsTrue(ok)
+		a.IsTrue(wait == 0)
+	}
+	wait, ok, err := store.Take("a", 1*time.Second, 2*time.Second, 0)
+	a.IsNil(err)
+	a.IsFalse(ok)
+	a.IsTrue(wait > 900*time.Millisecond && wait <= 1*time.Second)
+
+	// 不同的Key互不影响
+	_, ok, _ = store.Take("b", 1*time.Second, 0, 0)
+	a.IsTrue(ok)
+
+	// 延迟
+	_, ok, _ = store.Take("c", 1*time.Second, 0, 2*time.Second)
+	a.IsTrue(ok)
+	wait, ok, _ = store.Take("c", 1*time.Second, 0, 2*time.Second)
+	a.IsTrue(ok)
+	a.IsTrue(wait > 900*time.Millisecond && wait <= 1*time.Second)
+	wait, ok, _ = store.Take("c", 1*time.Second, 0, 2*time.Second)
+	a.IsTrue(ok)
+	a.IsTrue(wait > 1900*time.Millisecond && wait <= 2*time.Second)
+	_, ok, _ = store.Take("c", 1*time.Second, 0, 2*time.Second)
+	a.IsFalse(ok)
+}
+
+func TestMemoryLimitStore_Acquire(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	store := newMemoryLimitStore()
+	for i := 0; i < 2; i++ {
+		ok, err := store.Acquire("a", 2)
+		a.IsNil(err)
+		a.IsTrue(ok)
+	}
+	ok, _ := store.Acquire("a", 2)
+	a.IsFalse(ok)
+
+	a.IsNil(store.Release("a"))
+	ok, _ = store.Acquire("a", 2)
+	a.IsTrue(ok)
+
+	a.IsNil(store.Release("a"))
+	a.IsNil(store.Release("a"))
+	a.IsTrue(len(store.connMap) == 0)
+}
+
+func TestFindLimitStore(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsTrue(findLimitStore(nil) == findLimitStore(&teaconfigs.LimitStoreConfig{Type: teaconfigs.LimitStoreTypeMemory}))
+
+	redisStore := &teaconfigs.LimitStoreConfig{
+		Type: teaconfigs.LimitStoreTypeRedis,
+		Options: map[string]interface{}{
+			"network": "tcp",
+			"host":    "127.0.0.1",
+		},
+	}
+	store := findLimitStore(redisStore)
+	_, isRedis := store.(*redisLimitStore)
+	a.IsTrue(isRedis)
+	a.IsTrue(store == findLimitStore(redisStore))
+}
@@ -2,26 +2,52 @@ package teaproxy
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"github.com/TeaWeb/code/teaacme"
 	"github.com/TeaWeb/code/teaconfigs"
 	"github.com/TeaWeb/code/teaplugins"
-	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/timers"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
+// 平滑关闭时等待已有连接处理完毕的最长时间
+const listenerDrainTimeout = 30 * time.Second
+
 // 监听服务定义
 type Listener struct {
-	config *teaconfigs.ListenerConfig
-	server *http.Server
-	scheme string
+	config       *teaconfigs.ListenerConfig
+	configLocker sync.RWMutex
+
+	server            *http.Server
+	netListener       net.Listener
+	inheritedListener net.Listener // 从升级前的进程继承的监听
+	scheme            string
+	closing           bool // 是否正在关闭
+	locker            sync.Mutex
+
+	stream           streamServer   // TCP/UDP代理服务
+	packetConn       net.PacketConn // UDP监听
+	inheritedUDPConn net.PacketConn // 从升级前的进程继承的UDP监听
+
+	certs       *certificateStore // SSL证书
+	certsLooper *timers.Looper    // 检查证书文件变化的定时器
 }
 
 // 新监听服务
 func NewListener(config *teaconfigs.ListenerConfig) *Listener {
 	listener := &Listener{
-		config: config,
+		config:            config,
+		inheritedListener: takeInheritedListener(config.Key),
+		inheritedUDPConn:  takeInheritedPacketConn(config.Key),
 	}
 	LISTENERS = append(LISTENERS, listener)
 	return listener
@@ -29,45 +55,275 @@ func NewListener(config *teaconfigs.ListenerConfig) *Listener {
 
 // 启动
 func (this *Listener) Start() {
+	// TCP/UDP代理
+	if this.currentConfig().Stream != nil {
+		this.startStream()
+		return
+	}
+
 	httpHandler := http.NewServeMux()
 	httpHandler.HandleFunc("/", func(writer http.ResponseWriter, req *http.Request) {
 		this.handle(writer, req)
 	})
 
-	var err error
+	// 不加密的HTTP/2，重新加载配置后可以随时开启或关闭
+	h2cHandler := h2c.NewHandler(httpHandler, &http2.Server{})
 
-	this.server = &http.Server{
-		Addr:    this.config.Address,
-		Handler: httpHandler,
+	config := this.currentConfig()
+	server := &http.Server{
+		Addr: config.Address,
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			if this.scheme == "http" && this.currentConfig().H2C {
+				h2cHandler.ServeHTTP(writer, req)
+				return
+			}
+			httpHandler.ServeHTTP(writer, req)
+		}),
 	}
 
-	if this.config.Http {
-		logs.Println("start listener on", this.config.Address)
+	if config.Http {
+		logs.Println("start listener on", config.Address)
 		this.scheme = "http"
-		err = this.server.ListenAndServe()
+	} else if config.SSL != nil && config.SSL.On {
+		logs.Println("start ssl listener on", config.Address)
+		this.scheme = "https"
+
+		// 根据SNI选择各个服务的证书和TLS配置
+		err := this.loadCertificates(config)
 		if err != nil {
 			logs.Error(err)
+			this.closeInheritedListener()
+			return
 		}
+		server.TLSConfig = &tls.Config{
+			GetCertificate:     this.certs.getCertificate,
+			GetConfigForClient: this.certs.getConfigForClient,
+		}
+	} else {
+		this.closeInheritedListener()
+		return
 	}
 
-	if this.config.SSL != nil && this.config.SSL.On {
-		logs.Println("start ssl listener on", this.config.Address)
-		this.scheme = "https"
-		err = this.server.ListenAndServeTLS(Tea.ConfigFile(this.config.SSL.Certificate), Tea.ConfigFile(this.config.SSL.CertificateKey))
+	netListener, err := this.listen(config.Address)
+	if err != nil {
+		logs.Error(err)
+		return
+	}
+
+	this.locker.Lock()
+	if this.closing {
+		this.locker.Unlock()
+		netListener.Close()
+		return
+	}
+	this.server = server
+	this.netListener = netListener
+	this.locker.Unlock()
+
+	// PROXY协议，重新加载配置后可以随时开启或关闭
+	serveListener := newProxyProtocolListener(netListener, func() bool {
+		return this.currentConfig().ProxyProtocol
+	})
+
+	if this.scheme == "https" {
+		err = server.ServeTLS(serveListener, "", "")
+	} else {
+		err = server.Serve(serveListener)
+	}
+	if err != nil && err != http.ErrServerClosed && !this.isClosing() {
+		logs.Error(err)
+	}
+}
+
+// 加载各个服务的证书，证书文件有变化时重新加载，并更新OCSP响应
+func (this *Listener) loadCertificates(config *teaconfigs.ListenerConfig) error {
+	this.certs = newCertificateStore()
+	err := this.certs.load(config.Servers)
+	if err != nil {
+		return err
+	}
+	go this.certs.refreshOCSP()
+
+	this.certsLooper = timers.Loop(30*time.Second, func(looper *timers.Looper) {
+		if this.certs.isChanged() {
+			err := this.ReloadCertificates()
+			if err != nil {
+				logs.Error(err)
+			}
+		}
+		this.certs.refreshOCSP()
+	})
+	return nil
+}
+
+// 监听地址，优先使用从升级前的进程继承的监听
+func (this *Listener) listen(address string) (net.Listener, error) {
+	this.locker.Lock()
+	netListener := this.inheritedListener
+	this.inheritedListener = nil
+	this.locker.Unlock()
+
+	if netListener != nil {
+		logs.Println("inherit listener on", address)
+		return netListener, nil
+	}
+	return net.Listen("tcp", address)
+}
+
+// 关闭没有使用的继承的监听
+func (this *Listener) closeInheritedListener() {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.inheritedListener != nil {
+		this.inheritedListener.Close()
+		this.inheritedListener = nil
+	}
+	if this.inheritedUDPConn != nil {
+		this.inheritedUDPConn.Close()
+		this.inheritedUDPConn = nil
+	}
+}
+
+// 当前监听的文件，用于传递给新的进程
+func (this *Listener) file() (*os.File, error) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.closing {
+		return nil, nil
+	}
+	if this.packetConn != nil {
+		udpConn, ok := this.packetConn.(*net.UDPConn)
+		if !ok {
+			return nil, errors.New("listener on '" + this.currentConfig().Address + "' can not be passed to new process")
+		}
+		return udpConn.File()
+	}
+	if this.netListener == nil {
+		return nil, nil
+	}
+	tcpListener, ok := this.netListener.(*net.TCPListener)
+	if !ok {
+		return nil, errors.New("listener on '" + this.currentConfig().Address + "' can not be passed to new process")
+	}
+	return tcpListener.File()
+}
+
+// 区分用的Key
+func (this *Listener) Key() string {
+	return this.currentConfig().Key
+}
+
+// 使用新的配置，不需要重启监听服务
+func (this *Listener) Reload(config *teaconfigs.ListenerConfig) error {
+	this.configLocker.Lock()
+	this.config = config
+	this.configLocker.Unlock()
+
+	return this.ReloadCertificates()
+}
+
+// 重新加载证书，不需要重启监听服务
+func (this *Listener) ReloadCertificates() error {
+	if this.certs == nil {
+		return nil
+	}
+	config := this.currentConfig()
+	logs.Println("reload certificates on", config.Address)
+	err := this.certs.load(config.Servers)
+	if err != nil {
+		return err
+	}
+	go this.certs.refreshOCSP()
+	return nil
+}
+
+// 关闭，等待已有的连接处理完毕，超时后强制关闭
+func (this *Listener) Shutdown() error {
+	if this.certsLooper != nil {
+		this.certsLooper.Stop()
+		this.certsLooper = nil
+	}
+
+	this.closeInheritedListener()
+
+	this.locker.Lock()
+	this.closing = true
+	server := this.server
+	stream := this.stream
+	this.locker.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), listenerDrainTimeout)
+	defer cancel()
+
+	if stream != nil {
+		err := this.closeListener()
 		if err != nil {
 			logs.Error(err)
 		}
+		return stream.shutdown(ctx)
+	}
+
+	if server == nil {
+		return nil
+	}
+
+	err := server.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		return server.Close()
 	}
+	if err != nil && errors.Is(err, net.ErrClosed) {
+		// 已经调用过closeListener()
+		return nil
+	}
+	return err
 }
 
-// 关闭
-func (this *Listener) Shutdown() error {
-	if this.server != nil {
-		return this.server.Shutdown(context.Background())
+// 立即停止接受新的连接，已有的连接不受影响
+func (this *Listener) closeListener() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	this.closing = true
+	if this.inheritedListener != nil {
+		this.inheritedListener.Close()
+		this.inheritedListener = nil
+	}
+	if this.inheritedUDPConn != nil {
+		this.inheritedUDPConn.Close()
+		this.inheritedUDPConn = nil
+	}
+	if this.packetConn != nil {
+		err := this.packetConn.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+	}
+	if this.netListener == nil {
+		return nil
+	}
+	err := this.netListener.Close()
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
 	}
 	return nil
 }
 
+// 是否正在关闭
+func (this *Listener) isClosing() bool {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.closing
+}
+
+// 当前的配置
+func (this *Listener) currentConfig() *teaconfigs.ListenerConfig {
+	this.configLocker.RLock()
+	defer this.configLocker.RUnlock()
+	return this.config
+}
+
 // 处理请求
 func (this *Listener) handle(writer http.ResponseWriter, rawRequest *http.Request) {
 	responseWriter := NewResponseWriter(writer)
@@ -81,6 +337,13 @@ func (this *Listener) handle(writer http.ResponseWriter, rawRequest *http.Reques
 		rawRequest = result
 	}
 
+	// ACME验证
+	if response, found := teaacme.FindChallengeResponse(rawRequest.URL.Path); found {
+		writer.Header().Set("Content-Type", "text/plain")
+		writer.Write([]byte(response))
+		return
+	}
+
 	// 域名
 	reqHost := rawRequest.Host
 	colonIndex := strings.Index(reqHost, ":")
@@ -90,12 +353,24 @@ func (this *Listener) handle(writer http.ResponseWriter, rawRequest *http.Reques
 	} else {
 		domain = reqHost[:colonIndex]
 	}
-	server, serverName := this.config.FindNamedServer(domain)
+	config := this.currentConfig()
+	server, serverName := config.FindNamedServer(domain)
 	if server == nil {
 		http.Error(writer, "404 page not found: '"+rawRequest.URL.String()+"'", http.StatusNotFound)
 		return
 	}
 
+	// HTTPS相关设置
+	if server.SSL != nil && server.SSL.On {
+		if this.scheme == "http" && server.SSL.RedirectHTTP {
+			this.redirectToHTTPS(writer, rawRequest, domain, server)
+			return
+		}
+		if this.scheme == "https" && server.SSL.HSTS != nil && server.SSL.HSTS.On {
+			writer.Header().Set("Strict-Transport-Security", server.SSL.HSTS.HeaderValue())
+		}
+	}
+
 	// 包装新的请求
 	req := NewRequest(rawRequest)
 	req.host = reqHost
@@ -104,7 +379,7 @@ func (this *Listener) handle(writer http.ResponseWriter, rawRequest *http.Reques
 	req.rawScheme = this.scheme
 	req.scheme = "http" // @TODO 支持 https
 	req.serverName = serverName
-	req.serverAddr = this.config.Address
+	req.serverAddr = config.Address
 	req.root = server.Root
 	req.index = server.Index
 	req.charset = server.Charset
@@ -112,7 +387,11 @@ func (this *Listener) handle(writer http.ResponseWriter, rawRequest *http.Reques
 	// 查找Location
 	err := req.configure(server, 0)
 	if err != nil {
-		req.serverError(responseWriter)
+		if err == errNoBackendsAvailable {
+			req.serviceUnavailableError(responseWriter)
+		} else {
+			req.serverError(responseWriter)
+		}
 		logs.Error(errors.New(reqHost + rawRequest.URL.String() + ": " + err.Error()))
 		return
 	}
@@ -120,3 +399,15 @@ func (this *Listener) handle(writer http.ResponseWriter, rawRequest *http.Reques
 	// 处理请求
 	req.call(responseWriter)
 }
+
+// 跳转到HTTPS
+func (this *Listener) redirectToHTTPS(writer http.ResponseWriter, rawRequest *http.Request, domain string, server *teaconfigs.ServerConfig) {
+	host := domain
+	if len(server.SSL.Listen) > 0 {
+		_, port, err := net.SplitHostPort(server.SSL.Listen[0])
+		if err == nil && len(port) > 0 && port != "443" {
+			host = net.JoinHostPort(domain, port)
+		}
+	}
+	http.Redirect(writer, rawRequest, "https://"+host+rawRequest.URL.RequestURI(), http.StatusMovedPermanently)
+}
@@ -1,6 +1,7 @@
 package teaproxy
 
 import (
+	"github.com/TeaWeb/code/teaacme"
 	"github.com/TeaWeb/code/teaconfigs"
 	_ "github.com/TeaWeb/code/teastats" // 引入统计处理工具
 	"github.com/iwind/TeaGo/logs"
@@ -9,6 +10,9 @@ import (
 
 // 启动服务
 func Start() {
+	proxyLocker.Lock()
+	defer proxyLocker.Unlock()
+
 	listenerConfigs, err := teaconfigs.ParseConfigs()
 	if err != nil {
 		logs.Error(err)
@@ -23,6 +27,24 @@ func Start() {
 		listener := NewListener(config)
 		go listener.Start()
 	}
+
+	// 自动申请证书
+	servers := []*teaconfigs.ServerConfig{}
+	for _, server := range SERVERS {
+		servers = append(servers, server)
+	}
+	teaacme.SharedManager.Start(servers, ReloadCertificates)
+
+	for _, server := range SERVERS {
+		// 访问日志
+		startAccessLogWriters(server)
+
+		// 健康检查
+		startHealthCheckers(server)
+	}
+
+	// 升级后的新进程通知旧进程
+	finishUpgrade()
 }
 
 // 等待服务执行完毕
@@ -32,14 +54,32 @@ func Wait() {
 	wg.Wait()
 }
 
-// 关闭服务
+// 关闭服务，等待所有已有的连接处理完毕
 func Shutdown() {
-	for _, listener := range LISTENERS {
-		listener.Shutdown()
-	}
-
+	proxyLocker.Lock()
+	listeners := LISTENERS
 	LISTENERS = []*Listener{}
 	SERVERS = map[string]*teaconfigs.ServerConfig{}
+	proxyLocker.Unlock()
+
+	stopHealthCheckers()
+	teaacme.SharedManager.Stop()
+
+	wg := sync.WaitGroup{}
+	for _, listener := range listeners {
+		wg.Add(1)
+		go func(listener *Listener) {
+			defer wg.Done()
+			err := listener.Shutdown()
+			if err != nil {
+				logs.Error(err)
+			}
+		}(listener)
+	}
+	wg.Wait()
+
+	// 连接处理完毕后再关闭访问日志
+	stopAccessLogWriters()
 }
 
 // 重启服务
@@ -48,8 +88,25 @@ func Restart() {
 	Start()
 }
 
+// 重新加载所有监听服务的证书
+func ReloadCertificates() {
+	proxyLocker.RLock()
+	listeners := append([]*Listener{}, LISTENERS...)
+	proxyLocker.RUnlock()
+
+	for _, listener := range listeners {
+		err := listener.ReloadCertificates()
+		if err != nil {
+			logs.Error(err)
+		}
+	}
+}
+
 // 查找服务
 func FindServer(id string) (server *teaconfigs.ServerConfig, found bool) {
+	proxyLocker.RLock()
+	defer proxyLocker.RUnlock()
+
 	server, found = SERVERS[id]
 	return
 }
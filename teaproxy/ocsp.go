@@ -0,0 +1,133 @@
+package teaproxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/utils/string"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// 获取OCSP响应失败后重试的间隔
+const ocspRetryInterval = 10 * time.Minute
+
+// 获取OCSP响应的客户端
+var ocspClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// 更新开启了OCSP Stapling的证书的OCSP响应
+func (this *certificateStore) refreshOCSP() {
+	this.locker.RLock()
+	certs := append([]*serverCertificate{}, this.servers...)
+	this.locker.RUnlock()
+
+	now := time.Now()
+	for _, c := range certs {
+		if c.server.SSL == nil || !c.server.SSL.OCSPStapling {
+			continue
+		}
+
+		this.locker.Lock()
+		if (!c.ocspRefreshAt.IsZero() && now.Before(c.ocspRefreshAt)) || now.Sub(c.ocspCheckedAt) < ocspRetryInterval {
+			this.locker.Unlock()
+			continue
+		}
+		c.ocspCheckedAt = now
+		cert := c.cert
+		this.locker.Unlock()
+
+		staple, refreshAt, err := fetchOCSP(cert)
+		if err != nil {
+			logs.Error(errors.New("ocsp for server '" + c.server.Id + "': " + err.Error()))
+			continue
+		}
+
+		newCert := *cert
+		newCert.OCSPStaple = staple
+
+		this.locker.Lock()
+		c.cert = &newCert
+		c.ocspRefreshAt = refreshAt
+		this.locker.Unlock()
+	}
+}
+
+// 获取证书的OCSP响应，优先使用缓存的响应，返回响应和下次需要更新的时间
+func fetchOCSP(cert *tls.Certificate) (staple []byte, refreshAt time.Time, err error) {
+	if cert.Leaf == nil {
+		return nil, time.Time{}, errors.New("invalid certificate")
+	}
+	if len(cert.Leaf.OCSPServer) == 0 {
+		return nil, time.Time{}, errors.New("no ocsp server in certificate")
+	}
+	if len(cert.Certificate) < 2 {
+		return nil, time.Time{}, errors.New("no issuer certificate in certificate chain")
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// 缓存
+	cacheFile := Tea.ConfigFile("ssl.ocsp." + stringutil.Md5(string(cert.Certificate[0])) + ".der")
+	data, err := ioutil.ReadFile(cacheFile)
+	if err == nil {
+		refreshAt, err := parseOCSP(data, cert.Leaf, issuer)
+		if err == nil && time.Now().Before(refreshAt) {
+			return data, refreshAt, nil
+		}
+	}
+
+	request, err := ocsp.CreateRequest(cert.Leaf, issuer, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	resp, err := ocspClient.Post(cert.Leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, errors.New("ocsp server returns status '" + resp.Status + "'")
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	refreshAt, err = parseOCSP(data, cert.Leaf, issuer)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	err = ioutil.WriteFile(cacheFile, data, 0644)
+	if err != nil {
+		logs.Error(err)
+	}
+	return data, refreshAt, nil
+}
+
+// 校验OCSP响应，返回下次需要更新的时间：有效期过半时更新
+func parseOCSP(data []byte, leaf *x509.Certificate, issuer *x509.Certificate) (refreshAt time.Time, err error) {
+	resp, err := ocsp.ParseResponseForCert(data, leaf, issuer)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if resp.Status != ocsp.Good {
+		return time.Time{}, errors.New("certificate status is not good")
+	}
+	if resp.NextUpdate.IsZero() {
+		return time.Now().Add(1 * time.Hour), nil
+	}
+	if time.Now().After(resp.NextUpdate) {
+		return time.Time{}, errors.New("ocsp response is expired")
+	}
+	return resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2), nil
+}
@@ -0,0 +1,209 @@
+package teaproxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 读取PROXY协议头部的超时时间
+const proxyProtocolTimeout = 10 * time.Second
+
+// PROXY协议v1最大长度
+const proxyProtocolV1MaxLength = 107
+
+// PROXY协议v2签名
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// 接收PROXY协议的监听，enabled返回false时直接使用原始连接
+// 参考：https://www.haproxy.org/download/2.0/doc/proxy-protocol.txt
+type proxyProtocolListener struct {
+	net.Listener
+	enabled func() bool
+}
+
+// 包装监听
+func newProxyProtocolListener(listener net.Listener, enabled func() bool) net.Listener {
+	return &proxyProtocolListener{
+		Listener: listener,
+		enabled:  enabled,
+	}
+}
+
+// 接受新的连接，PROXY头部在第一次读取或者获取地址时才分析，不会阻塞其他连接
+func (this *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := this.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !this.enabled() {
+		return conn, nil
+	}
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReaderSize(conn, 256),
+	}, nil
+}
+
+// 带有PROXY协议头部的连接
+type proxyProtocolConn struct {
+	net.Conn
+
+	reader     *bufio.Reader
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+// 读取数据
+func (this *proxyProtocolConn) Read(data []byte) (int, error) {
+	this.once.Do(this.readHeader)
+	if this.err != nil {
+		return 0, this.err
+	}
+	return this.reader.Read(data)
+}
+
+// 终端地址，使用PROXY协议中的源地址
+func (this *proxyProtocolConn) RemoteAddr() net.Addr {
+	this.once.Do(this.readHeader)
+	if this.remoteAddr != nil {
+		return this.remoteAddr
+	}
+	return this.Conn.RemoteAddr()
+}
+
+// 本地地址，使用PROXY协议中的目标地址
+func (this *proxyProtocolConn) LocalAddr() net.Addr {
+	this.once.Do(this.readHeader)
+	if this.localAddr != nil {
+		return this.localAddr
+	}
+	return this.Conn.LocalAddr()
+}
+
+// 分析头部，失败时关闭连接
+func (this *proxyProtocolConn) readHeader() {
+	this.Conn.SetReadDeadline(time.Now().Add(proxyProtocolTimeout))
+	defer this.Conn.SetReadDeadline(time.Time{})
+
+	signature, err := this.reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		this.fail(err)
+		return
+	}
+	if bytes.Equal(signature, proxyProtocolV2Signature) {
+		err = this.readHeaderV2()
+	} else {
+		err = this.readHeaderV1()
+	}
+	if err != nil {
+		this.fail(err)
+	}
+}
+
+// 分析v1头部，比如：PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func (this *proxyProtocolConn) readHeaderV1() error {
+	line := []byte{}
+	for {
+		b, err := this.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyProtocolV1MaxLength {
+			return errors.New("proxy protocol: header too long")
+		}
+	}
+	if !bytes.HasPrefix(line, []byte("PROXY ")) || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New("proxy protocol: invalid header")
+	}
+
+	pieces := strings.Split(string(line[:len(line)-2]), " ")
+	if len(pieces) < 2 {
+		return errors.New("proxy protocol: invalid header")
+	}
+	switch pieces[1] {
+	case "UNKNOWN":
+		return nil
+	case "TCP4", "TCP6":
+		if len(pieces) != 6 {
+			return errors.New("proxy protocol: invalid header")
+		}
+		srcIP := net.ParseIP(pieces[2])
+		dstIP := net.ParseIP(pieces[3])
+		srcPort, err1 := strconv.Atoi(pieces[4])
+		dstPort, err2 := strconv.Atoi(pieces[5])
+		if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil || srcPort < 0 || srcPort > 65535 || dstPort < 0 || dstPort > 65535 {
+			return errors.New("proxy protocol: invalid address")
+		}
+		this.remoteAddr = &net.TCPAddr{IP: srcIP, Port: srcPort}
+		this.localAddr = &net.TCPAddr{IP: dstIP, Port: dstPort}
+		return nil
+	}
+	return errors.New("proxy protocol: unsupported protocol '" + pieces[1] + "'")
+}
+
+// 分析v2头部
+func (this *proxyProtocolConn) readHeaderV2() error {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(this.reader, header)
+	if err != nil {
+		return err
+	}
+	if header[12]>>4 != 2 {
+		return errors.New("proxy protocol: unsupported version")
+	}
+	command := header[12] & 0x0F
+	family := header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(this.reader, data)
+	if err != nil {
+		return err
+	}
+
+	// LOCAL：代理自身发起的连接，比如健康检查
+	if command == 0 {
+		return nil
+	}
+	if command != 1 {
+		return errors.New("proxy protocol: unsupported command")
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if length < 12 {
+			return errors.New("proxy protocol: invalid address")
+		}
+		this.remoteAddr = &net.TCPAddr{IP: net.IP(data[0:4]), Port: int(binary.BigEndian.Uint16(data[8:10]))}
+		this.localAddr = &net.TCPAddr{IP: net.IP(data[4:8]), Port: int(binary.BigEndian.Uint16(data[10:12]))}
+	case 0x21: // TCP over IPv6
+		if length < 36 {
+			return errors.New("proxy protocol: invalid address")
+		}
+		this.remoteAddr = &net.TCPAddr{IP: net.IP(data[0:16]), Port: int(binary.BigEndian.Uint16(data[32:34]))}
+		this.localAddr = &net.TCPAddr{IP: net.IP(data[16:32]), Port: int(binary.BigEndian.Uint16(data[34:36]))}
+	}
+
+	// 其他的地址类型（UNSPEC、UDP、UNIX）使用原始的连接地址
+	return nil
+}
+
+// 分析失败
+func (this *proxyProtocolConn) fail(err error) {
+	this.err = err
+	this.Conn.Close()
+}
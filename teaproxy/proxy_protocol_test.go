@@ -0,0 +1,96 @@
+package teaproxy
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestProxyProtocolListener(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	netListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netListener.Close()
+
+	enabled := true
+	listener := newProxyProtocolListener(netListener, func() bool {
+		return enabled
+	})
+
+	accept := func(data []byte) (conn net.Conn, body string) {
+		go func() {
+			client, err := net.Dial("tcp", netListener.Addr().String())
+			if err != nil {
+				return
+			}
+			client.Write(data)
+			client.Close()
+		}()
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		return conn, string(result)
+	}
+
+	// v1
+	{
+		conn, body := accept([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nGET / HTTP/1.1\r\n\r\n"))
+		a.IsTrue(conn.RemoteAddr().String() == "192.168.0.1:56324")
+		a.IsTrue(conn.LocalAddr().String() == "192.168.0.11:443")
+		a.IsTrue(body == "GET / HTTP/1.1\r\n\r\n")
+	}
+
+	{
+		conn, body := accept([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\nhello"))
+		a.IsTrue(conn.RemoteAddr().String() == "[2001:db8::1]:56324")
+		a.IsTrue(body == "hello")
+	}
+
+	{
+		conn, body := accept([]byte("PROXY UNKNOWN\r\nhello"))
+		a.IsTrue(conn.RemoteAddr().String() != "")
+		a.IsTrue(body == "hello")
+	}
+
+	// v2
+	{
+		header := append([]byte{}, proxyProtocolV2Signature...)
+		header = append(header, 0x21, 0x11, 0, 12)
+		header = append(header, 10, 0, 0, 1, 10, 0, 0, 2)
+		header = append(header, 0x30, 0x39, 0x00, 0x50) // 12345, 80
+		conn, body := accept(append(header, []byte("hello")...))
+		a.IsTrue(conn.RemoteAddr().String() == "10.0.0.1:12345")
+		a.IsTrue(conn.LocalAddr().String() == "10.0.0.2:80")
+		a.IsTrue(body == "hello")
+	}
+
+	// v2 LOCAL
+	{
+		header := append([]byte{}, proxyProtocolV2Signature...)
+		header = append(header, 0x20, 0x00, 0, 0)
+		conn, body := accept(append(header, []byte("hello")...))
+		a.IsTrue(conn.RemoteAddr().String() != "10.0.0.1:12345")
+		a.IsTrue(body == "hello")
+	}
+
+	// 没有PROXY头部
+	{
+		_, body := accept([]byte("GET / HTTP/1.1\r\n\r\n"))
+		a.IsTrue(body == "")
+	}
+
+	// 关闭
+	{
+		enabled = false
+		conn, body := accept([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+		a.IsTrue(conn.RemoteAddr().String() != "192.168.0.1:56324")
+		a.IsTrue(body == "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n")
+	}
+}
@@ -0,0 +1,141 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaacme"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"sync"
+)
+
+// 同一时间只能有一个重新加载的操作
+var reloadLocker = sync.Mutex{}
+
+// 监听服务的变化
+type listenerChanges struct {
+	kept    map[*Listener]*teaconfigs.ListenerConfig // 地址没有变化的监听服务 => 新的配置
+	removed []*Listener                              // 需要关闭的监听服务
+	added   []*teaconfigs.ListenerConfig             // 需要启动的监听服务
+}
+
+// 重新加载配置，只启动和关闭地址有变化的监听服务，正在处理的请求不受影响
+func Reload() error {
+	reloadLocker.Lock()
+	defer reloadLocker.Unlock()
+
+	listenerConfigs, err := teaconfigs.ParseConfigs()
+	if err != nil {
+		return err
+	}
+
+	proxyLocker.RLock()
+	oldListeners := append([]*Listener{}, LISTENERS...)
+	oldServers := SERVERS
+	proxyLocker.RUnlock()
+
+	// 新的服务，保留后端服务的运行时状态
+	newServers := map[string]*teaconfigs.ServerConfig{}
+	for _, config := range listenerConfigs {
+		for _, server := range config.Servers {
+			if _, found := newServers[server.Id]; found {
+				continue
+			}
+			newServers[server.Id] = server
+			if oldServer, found := oldServers[server.Id]; found {
+				server.CopyBackendStates(oldServer)
+			}
+		}
+	}
+
+	changes := diffListeners(oldListeners, listenerConfigs)
+
+	// 已有的监听服务直接切换配置
+	for listener, config := range changes.kept {
+		err := listener.Reload(config)
+		if err != nil {
+			logs.Error(err)
+		}
+	}
+
+	// 先停止接受新连接，以便新的监听服务可以使用同样的地址，然后在后台等待已有的连接处理完毕
+	for _, listener := range changes.removed {
+		logs.Println("close listener on", listener.currentConfig().Address)
+		err := listener.closeListener()
+		if err != nil {
+			logs.Error(err)
+		}
+		go func(listener *Listener) {
+			err := listener.Shutdown()
+			if err != nil {
+				logs.Error(err)
+			}
+		}(listener)
+	}
+
+	newListeners := []*Listener{}
+	for listener := range changes.kept {
+		newListeners = append(newListeners, listener)
+	}
+	for _, config := range changes.added {
+		listener := &Listener{
+			config: config,
+		}
+		newListeners = append(newListeners, listener)
+		go listener.Start()
+	}
+
+	proxyLocker.Lock()
+	LISTENERS = newListeners
+	SERVERS = newServers
+	proxyLocker.Unlock()
+
+	servers := []*teaconfigs.ServerConfig{}
+	for _, server := range newServers {
+		servers = append(servers, server)
+	}
+
+	// 访问日志
+	reloadAccessLogWriters(servers)
+
+	// 健康检查
+	stopHealthCheckers()
+	for _, server := range servers {
+		startHealthCheckers(server)
+	}
+
+	// 自动申请证书
+	teaacme.SharedManager.Start(servers, ReloadCertificates)
+
+	return nil
+}
+
+// 对比正在运行的监听服务和新的配置
+func diffListeners(listeners []*Listener, configs []*teaconfigs.ListenerConfig) *listenerChanges {
+	changes := &listenerChanges{
+		kept: map[*Listener]*teaconfigs.ListenerConfig{},
+	}
+
+	configMap := map[string]*teaconfigs.ListenerConfig{}
+	for _, config := range configs {
+		configMap[config.Key] = config
+	}
+
+	keptKeys := map[string]bool{}
+	for _, listener := range listeners {
+		key := listener.Key()
+		config, found := configMap[key]
+		if !found || keptKeys[key] {
+			changes.removed = append(changes.removed, listener)
+			continue
+		}
+		keptKeys[key] = true
+		changes.kept[listener] = config
+	}
+
+	for _, config := range configs {
+		if !keptKeys[config.Key] {
+			changes.added = append(changes.added, config)
+		}
+	}
+
+	return changes
+}
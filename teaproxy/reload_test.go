@@ -0,0 +1,81 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDiffListeners(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	listener1 := &Listener{config: &teaconfigs.ListenerConfig{Key: "http://:8001"}}
+	listener2 := &Listener{config: &teaconfigs.ListenerConfig{Key: "http://:8002"}}
+	listener3 := &Listener{config: &teaconfigs.ListenerConfig{Key: "https://:8003"}}
+
+	config1 := &teaconfigs.ListenerConfig{Key: "http://:8001"}
+	config3 := &teaconfigs.ListenerConfig{Key: "http://:8003"}
+	config4 := &teaconfigs.ListenerConfig{Key: "http://:8004"}
+
+	changes := diffListeners([]*Listener{listener1, listener2, listener3}, []*teaconfigs.ListenerConfig{config1, config3, config4})
+	a.IsTrue(len(changes.kept) == 1)
+	a.IsTrue(changes.kept[listener1] == config1)
+	a.IsTrue(len(changes.removed) == 2)
+	a.IsTrue(changes.removed[0] == listener2)
+	a.IsTrue(changes.removed[1] == listener3)
+	a.IsTrue(len(changes.added) == 2)
+	a.IsTrue(changes.added[0] == config3)
+	a.IsTrue(changes.added[1] == config4)
+}
+
+func TestListener_Reload(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	listener := &Listener{
+		config: &teaconfigs.ListenerConfig{
+			Key:     "http://" + address,
+			Address: address,
+			Http:    true,
+		},
+	}
+	go listener.Start()
+
+	// 等待启动
+	var resp *http.Response
+	for i := 0; i < 20; i++ {
+		resp, err = http.Get("http://" + address + "/hello")
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	a.IsTrue(resp.StatusCode == http.StatusNotFound)
+
+	// 切换配置
+	a.IsNil(listener.Reload(&teaconfigs.ListenerConfig{
+		Key:     "http://" + address,
+		Address: address,
+		Http:    true,
+	}))
+	a.IsTrue(listener.Key() == "http://"+address)
+
+	// 关闭后不再接受新的连接
+	a.IsNil(listener.closeListener())
+	_, err = net.DialTimeout("tcp", address, 1*time.Second)
+	a.IsNotNil(err)
+	a.IsNil(listener.Shutdown())
+}
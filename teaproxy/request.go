@@ -26,6 +26,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -54,8 +55,9 @@ var textMimeMap = map[string]bool{
 
 // 请求定义
 type Request struct {
-	raw    *http.Request
-	server *teaconfigs.ServerConfig
+	raw        *http.Request
+	server     *teaconfigs.ServerConfig
+	rootServer *teaconfigs.ServerConfig // 最初匹配的服务
 
 	scheme        string
 	rawScheme     string // 原始的scheme
@@ -79,6 +81,10 @@ type Request struct {
 
 	cachePolicy  *shared.CachePolicy
 	cacheEnabled bool
+	cacheStatus  string // 缓存状态：HIT, MISS, BYPASS, EXPIRED, STALE
+
+	compression         *teaconfigs.CompressionConfig // 压缩设置
+	compressionEncoding string                        // 协商后的压缩编码
 
 	api    *apiconfig.API // API
 	mockOn bool           // 是否开启了API Mock
@@ -90,6 +96,25 @@ type Request struct {
 
 	websocket *teaconfigs.WebsocketConfig
 
+	backendList     *teaconfigs.BackendList            // 后端服务所在的列表，用于故障转移
+	backendAttempts []*tealogs.AccessLogBackendAttempt // 每次请求后端服务的结果
+	grpcStatus      string                             // gRPC状态码
+
+	errorPageLists []*teaconfigs.ErrorPageList // 错误页面，后面的优先
+	isErrorPage    bool                        // 是否为请求错误页面的子请求
+
+	limitLists    []*teaconfigs.LimitList // 速率和连接数限制
+	limitConns    []*limitConn            // 已占用的连接
+	limitsApplied bool                    // 是否已经检查过限制，跳转到默认文件时不再重复检查
+
+	notifyList *teaconfigs.NotifyList // 请求镜像，Location中的镜像优先
+	notified   bool                   // 是否已经镜像过，跳转到默认文件时不再重复镜像
+
+	asyncConfig     *teaconfigs.AsyncConfig          // 异步处理设置，为nil表示不异步处理
+	asyncLocationId string                           // 异步处理设置所在的Location
+	logOnly         bool                             // 是否只记录日志
+	logOnlyResponse *teaconfigs.DirectResponseConfig // 只记录日志时返回的响应
+
 	// 执行请求
 	filePath string
 
@@ -107,6 +132,12 @@ type Request struct {
 	requestData       []byte // 导出的request，在监控请求的时候有用
 	responseAPIStatus string // API状态码
 
+	accessDenied bool // 是否被禁止访问
+
+	isForked bool // 是否为复制的子请求
+
+	forwardedHeader http.Header // 原始请求中和代理相关的Header，转发到后端服务时会修改请求的Header
+
 	shouldLog bool
 	debug     bool
 }
@@ -117,6 +148,7 @@ func NewRequest(rawRequest *http.Request) *Request {
 	return &Request{
 		varMapping:         map[string]string{},
 		raw:                rawRequest,
+		method:             rawRequest.Method,
 		rawURI:             rawRequest.URL.RequestURI(),
 		requestFromTime:    now,
 		requestTimestamp:   now.Unix(),
@@ -124,12 +156,16 @@ func NewRequest(rawRequest *http.Request) *Request {
 		requestTimeLocal:   now.Format("2/Jan/2006:15:04:05 -0700"),
 		requestMsec:        float64(now.Unix()) + float64(now.Nanosecond())/1000000000,
 		shouldLog:          true,
+		forwardedHeader:    copyForwardedHeader(rawRequest.Header),
 	}
 }
 
 func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) error {
 	isChanged := this.server != server
 	this.server = server
+	if this.rootServer == nil {
+		this.rootServer = server
+	}
 
 	if redirects > 8 {
 		return errors.New("too many redirects")
@@ -142,6 +178,12 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 	}
 	path := uri.Path
 
+	// 访问控制
+	if !server.AllowIP(this.clientIP()) {
+		this.accessDenied = true
+		return nil
+	}
+
 	// root
 	if isChanged {
 		this.root = server.Root
@@ -150,6 +192,29 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 		}
 	}
 
+	// 错误页面
+	if len(server.Pages) > 0 {
+		this.errorPageLists = append(this.errorPageLists, &server.ErrorPageList)
+	}
+
+	// 限速和连接数限制
+	this.addLimitList(&server.LimitList)
+
+	// 请求镜像
+	if server.HasNotify() {
+		this.notifyList = &server.NotifyList
+	}
+
+	// 异步处理和只记录日志
+	if server.Async {
+		this.asyncConfig = asyncConfigOrDefault(server.AsyncConfig)
+		this.asyncLocationId = ""
+	}
+	if server.LogOnly {
+		this.logOnly = true
+		this.logOnlyResponse = server.LogOnlyResponse
+	}
+
 	// 字符集
 	if len(server.Charset) > 0 {
 		this.charset = this.Format(server.Charset)
@@ -166,6 +231,15 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 		this.ignoreHeaders = append(this.ignoreHeaders, server.IgnoreHeaders ...)
 	}
 
+	// 压缩
+	if server.Compression != nil {
+		if server.Compression.On {
+			this.compression = server.Compression
+		} else {
+			this.compression = nil
+		}
+	}
+
 	// cache
 	if server.CacheOn {
 		cachePolicy := server.CachePolicyObject()
@@ -287,10 +361,33 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 			if len(location.Charset) > 0 {
 				this.charset = this.Format(location.Charset)
 			}
+			if len(location.Pages) > 0 {
+				this.errorPageLists = append(this.errorPageLists, &location.ErrorPageList)
+			}
+			this.addLimitList(&location.LimitList)
+			if location.HasNotify() {
+				this.notifyList = &location.NotifyList
+			}
+			if location.Async {
+				this.asyncConfig = asyncConfigOrDefault(location.AsyncConfig)
+				this.asyncLocationId = location.Id
+			}
+			if location.LogOnly {
+				this.logOnly = true
+				this.logOnlyResponse = location.LogOnlyResponse
+			}
 			if len(location.Index) > 0 {
 				this.index = this.formatAll(location.Index)
 			}
 
+			if location.Compression != nil {
+				if location.Compression.On {
+					this.compression = location.Compression
+				} else {
+					this.compression = nil
+				}
+			}
+
 			if location.CacheOn {
 				cachePolicy := location.CachePolicyObject()
 				if cachePolicy != nil && cachePolicy.On {
@@ -310,6 +407,12 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 
 			this.location = location
 
+			// 访问控制
+			if !location.AllowIP(this.clientIP()) {
+				this.accessDenied = true
+				return nil
+			}
+
 			// rewrite相关配置
 			if len(location.Rewrite) > 0 {
 				for _, rule := range location.Rewrite {
@@ -419,9 +522,10 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 					"request":   this.raw,
 					"formatter": this.Format,
 				}
+				this.backendList = &location.BackendList
 				backend := location.NextBackend(options)
 				if backend == nil {
-					return errors.New("no backends available")
+					return errNoBackendsAvailable
 				}
 				this.backend = backend
 				locationConfigured = true
@@ -444,6 +548,7 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 					"formatter": this.Format,
 				}
 				this.backend = location.Websocket.NextBackend(options)
+				this.backendList = &location.Websocket.BackendList
 				this.websocket = location.Websocket
 				return nil
 			}
@@ -560,10 +665,11 @@ func (this *Request) configure(server *teaconfigs.ServerConfig, redirects int) e
 		"request":   this.raw,
 		"formatter": this.Format,
 	}
+	this.backendList = &server.BackendList
 	backend := server.NextBackend(options)
 	if backend == nil {
 		if len(this.root) == 0 {
-			return errors.New("no backends available")
+			return errNoBackendsAvailable
 		}
 	}
 	responseCallback := options.Get("responseCallback")
@@ -592,6 +698,9 @@ func (this *Request) call(writer *ResponseWriter) error {
 	this.responseWriter = writer
 
 	defer func() {
+		// 写入剩余的数据
+		writer.Close()
+
 		// log
 		this.log()
 
@@ -599,6 +708,27 @@ func (this *Request) call(writer *ResponseWriter) error {
 		CallRequestAfterHook(this, writer)
 	}()
 
+	// 访问控制
+	if this.accessDenied {
+		this.forbiddenError(writer)
+		return nil
+	}
+
+	// 限速和连接数限制，子请求不受限制，每个请求只检查一次
+	if len(this.limitLists) > 0 && !this.limitsApplied && !this.isForked && !this.isErrorPage {
+		this.limitsApplied = true
+		defer this.releaseLimits()
+		if !this.applyLimits(writer) {
+			return nil
+		}
+	}
+
+	// 压缩
+	if this.compression != nil && this.method != http.MethodHead {
+		this.compressionEncoding = this.compression.Negotiate(this.requestHeader("Accept-Encoding"))
+		writer.SetCompression(this.compression, this.compressionEncoding)
+	}
+
 	// hook
 	b := CallRequestBeforeHook(this, writer)
 	if !b {
@@ -639,6 +769,22 @@ func (this *Request) call(writer *ResponseWriter) error {
 		}
 	}
 
+	// 请求镜像，子请求和websocket不镜像，每个请求只镜像一次
+	if this.notifyList != nil && !this.notified && this.websocket == nil && !this.isForked && !this.isErrorPage {
+		this.notified = true
+		this.callNotify()
+	}
+
+	// 只记录日志
+	if this.logOnly {
+		return this.callLogOnly(writer)
+	}
+
+	// 异步处理，只对后端服务有效
+	if this.asyncConfig != nil && this.backend != nil && this.websocket == nil && !this.isForked {
+		return this.callAsync(writer)
+	}
+
 	if this.websocket != nil {
 		return this.callWebsocket(writer)
 	}
@@ -767,9 +913,6 @@ func (this *Request) callRoot(writer *ResponseWriter) error {
 		}
 	}
 
-	// length
-	respHeader.Set("Content-Length", fmt.Sprintf("%d", stat.Size()))
-
 	// 自定义Header
 	for _, header := range this.headers {
 		if header.Match(http.StatusOK) {
@@ -780,10 +923,18 @@ func (this *Request) callRoot(writer *ResponseWriter) error {
 		}
 	}
 
-	// 支持 Last-Modified
-	modifiedTime := stat.ModTime().Format("Mon, 02 Jan 2006 15:04:05 GMT")
-	if len(respHeader.Get("Last-Modified")) == 0 {
-		respHeader.Set("Last-Modified", modifiedTime)
+	// 忽略Content-Type时不再自动检测
+	if hasIgnoreHeaders && ignoreHeaders.Has("CONTENT-TYPE") {
+		respHeader["Content-Type"] = nil
+	}
+
+	// 支持 Last-Modified，如果自定义了Last-Modified，则以自定义的为准
+	modifiedTime := stat.ModTime()
+	if lastModified := respHeader.Get("Last-Modified"); len(lastModified) > 0 {
+		t, err := http.ParseTime(lastModified)
+		if err == nil {
+			modifiedTime = t
+		}
 	}
 
 	// 支持 ETag
@@ -797,20 +948,6 @@ func (this *Request) callRoot(writer *ResponseWriter) error {
 		this.responseCallback(writer)
 	}
 
-	// 支持 If-None-Match
-	if this.requestHeader("If-None-Match") == eTag {
-		writer.WriteHeader(http.StatusNotModified)
-
-		return nil
-	}
-
-	// 支持 If-Modified-Since
-	if this.requestHeader("If-Modified-Since") == modifiedTime {
-		writer.WriteHeader(http.StatusNotModified)
-
-		return nil
-	}
-
 	fp, err := os.OpenFile(filePath, os.O_RDONLY, 444)
 	if err != nil {
 		this.serverError(writer)
@@ -819,14 +956,9 @@ func (this *Request) callRoot(writer *ResponseWriter) error {
 	}
 	defer fp.Close()
 
-	_, err = io.Copy(writer, fp)
-
-	if err != nil {
-		if this.debug {
-			logs.Error(err)
-		}
-		return nil
-	}
+	// 支持 If-None-Match、If-Modified-Since、If-Match、If-Unmodified-Since、Range 和 If-Range
+	// 参考：https://tools.ietf.org/html/rfc7232 和 https://tools.ietf.org/html/rfc7233
+	http.ServeContent(writer, this.raw, stat.Name(), modifiedTime, fp)
 
 	return nil
 }
@@ -836,7 +968,7 @@ func (this *Request) callWebsocket(writer *ResponseWriter) error {
 	if this.backend == nil {
 		err := errors.New(this.requestPath() + ": no available backends for websocket")
 		logs.Error(err)
-		this.serverError(writer)
+		this.serviceUnavailableError(writer)
 		return err
 	}
 
@@ -875,17 +1007,16 @@ func (this *Request) callWebsocket(writer *ResponseWriter) error {
 		wsURL := url.URL{Scheme: "ws", Host: this.backend.Address, Path: this.raw.RequestURI}
 		dialer := websocket.Dialer{
 			Proxy:            http.ProxyFromEnvironment,
-			HandshakeTimeout: this.backend.FailTimeoutDuration(),
+			HandshakeTimeout: this.backend.ConnectTimeoutDuration(),
+		}
+		if this.backend.IsHTTPS() {
+			wsURL.Scheme = "wss"
+			dialer.TLSClientConfig = this.backendTLSConfig()
 		}
 		server, _, err := dialer.Dial(wsURL.String(), nil)
 		if err != nil {
 			logs.Error(err)
-			currentFails := this.backend.IncreaseFails()
-			if this.backend.MaxFails > 0 && currentFails >= this.backend.MaxFails {
-				this.backend.IsDown = true
-				this.backend.DownTime = time.Now()
-				this.websocket.SetupScheduling(false)
-			}
+			this.increaseBackendFails()
 			return err
 		}
 		defer server.Close()
@@ -984,16 +1115,17 @@ func (this *Request) callWebsocket(writer *ResponseWriter) error {
 
 // 调用后端服务器
 func (this *Request) callBackend(writer *ResponseWriter) error {
-	this.backend.IncreaseConn()
-	defer this.backend.DecreaseConn()
-
+	if this.backend == nil {
+		this.serviceUnavailableError(writer)
+		logs.Error(errors.New(this.requestPath() + ": " + errNoBackendsAvailable.Error()))
+		return nil
+	}
 	if len(this.backend.Address) == 0 {
 		this.serverError(writer)
 		logs.Error(errors.New("backend address should not be empty"))
 		return nil
 	}
 
-	this.raw.URL.Scheme = this.scheme
 	this.raw.URL.Host = this.host
 
 	// new uri
@@ -1004,65 +1136,107 @@ func (this *Request) callBackend(writer *ResponseWriter) error {
 	}
 
 	// 设置代理相关的头部
-	// 参考 https://tools.ietf.org/html/rfc7239
-	if len(this.raw.RemoteAddr) > 0 {
-		index := strings.Index(this.raw.RemoteAddr, ":")
-		ip := ""
-		if index > -1 {
-			ip = this.raw.RemoteAddr[:index]
-		} else {
-			ip = this.raw.RemoteAddr
+	this.setForwardedHeaders(this.raw.Header)
+
+	this.raw.RequestURI = ""
+
+	// 重试设置，只有幂等的请求才能重试
+	retry := this.backendRetryConfig()
+	maxTries := 1
+	var tryTimeout time.Duration
+	var body []byte
+	if retry != nil {
+		tryTimeout = retry.TryTimeoutDuration()
+		if retry.MatchMethod(this.raw.Method) {
+			data, ok := this.bufferRequestBody()
+			if ok {
+				body = data
+				maxTries = retry.MaxTries
+			}
 		}
-		this.raw.Header.Set("X-Real-IP", ip)
-		this.raw.Header.Set("X-Forwarded-For", ip)
-		this.raw.Header.Set("X-Forwarded-By", ip)
 	}
-	this.raw.Header.Set("X-Forwarded-Host", this.host)
-	this.raw.Header.Set("X-Forwarded-Proto", this.raw.Proto)
 
-	client := SharedClientPool.client(this.backend.Address, this.backend.FailTimeoutDuration(), this.backend.MaxConns)
+	triedBackends := []*teaconfigs.BackendConfig{}
+	var resp *http.Response
+	requestFrom := time.Now()
+	for {
+		backend := this.backend
+		triedBackends = append(triedBackends, backend)
+		canRetry := len(triedBackends) < maxTries
 
-	this.raw.RequestURI = ""
+		backend.IncreaseConn()
+		tryFrom := time.Now()
+		r, cancel, errorType, err := this.doBackend(requestFrom, tryTimeout)
+		attempt := &tealogs.AccessLogBackendAttempt{
+			BackendId:   backend.Id,
+			Address:     backend.Address,
+			RequestTime: time.Since(tryFrom).Seconds(),
+		}
+		this.backendAttempts = append(this.backendAttempts, attempt)
 
-	resp, err := client.Do(this.raw)
-	if err != nil {
-		urlError, ok := err.(*url.Error)
-		if ok {
-			if _, ok := urlError.Err.(*RedirectError); ok {
-				http.Redirect(writer, this.raw, resp.Header.Get("Location"), resp.StatusCode)
-				return nil
+		if err != nil {
+			backend.DecreaseConn()
+
+			urlError, ok := err.(*url.Error)
+			if ok {
+				if _, ok := urlError.Err.(*RedirectError); ok {
+					http.Redirect(writer, this.raw, r.Header.Get("Location"), r.StatusCode)
+					return nil
+				}
 			}
-		}
 
-		// 如果超过最大失败次数，则下线
-		currentFails := this.backend.IncreaseFails()
-		if this.backend.MaxFails > 0 && currentFails >= this.backend.MaxFails {
-			this.backend.IsDown = true
-			this.backend.DownTime = time.Now()
-			if this.websocket != nil {
-				this.websocket.SetupScheduling(false)
-			} else {
-				this.server.SetupScheduling(false)
+			attempt.Error = err.Error()
+			this.increaseBackendFails()
+
+			// 尝试下一个后端服务
+			if canRetry && retry.MatchError(errorType) && !this.backendTimedOut(requestFrom) && this.nextRetryBackend(triedBackends, body) {
+				logs.Error(errors.New("retry next backend: " + err.Error()))
+				continue
 			}
+
+			this.backendError(writer, errorType)
+			logs.Error(err)
+			return nil
 		}
 
-		this.serverError(writer)
-		logs.Error(err)
-		return nil
+		attempt.Status = r.StatusCode
+		backend.RecordResponseTime(time.Since(tryFrom))
+
+		// 根据状态码重试
+		if canRetry && retry.MatchStatus(r.StatusCode) && !this.backendTimedOut(requestFrom) && this.nextRetryBackend(triedBackends, body) {
+			r.Body.Close()
+			cancel()
+			backend.DecreaseConn()
+			continue
+		}
+
+		resp = r
+		defer backend.DecreaseConn()
+		defer cancel()
+		break
 	}
 	defer resp.Body.Close()
 
 	// 清除错误次数
 	if resp.StatusCode >= 200 {
-		if !this.backend.IsDown && this.backend.CurrentFails > 0 {
-			this.backend.CurrentFails = 0
-		}
+		this.backend.ResetFails()
+	}
+
+	// gRPC请求收到非200的HTTP响应时，转换为grpc-status
+	if resp.StatusCode != http.StatusOK && this.isGRPC() {
+		this.writeGRPCError(writer, grpcStatusFromHTTP(resp.StatusCode), "backend responded with status "+strconv.Itoa(resp.StatusCode))
+		return nil
 	}
 
 	// 忽略的Header
 	ignoreHeaders := this.convertIgnoreHeaders()
 	hasIgnoreHeaders := ignoreHeaders.Len() > 0
 
+	// 声明Trailer，在内容发送完之后再设置
+	for k := range resp.Trailer {
+		writer.Header().Add("Trailer", k)
+	}
+
 	// 设置Header
 	hasCharset := len(this.charset) > 0
 	for k, v := range resp.Header {
@@ -1119,12 +1293,32 @@ func (this *Request) callBackend(writer *ResponseWriter) error {
 		}
 	}
 
-	_, err = io.Copy(writer, resp.Body)
+	// 长度未知的内容（比如gRPC、Server-Sent Events）边读边发送
+	if resp.ContentLength < 0 {
+		err = copyStream(writer, resp.Body)
+	} else {
+		_, err = io.Copy(writer, resp.Body)
+	}
 	if err != nil {
 		logs.Error(err)
+
+		// 没有收到后端服务的grpc-status
+		if this.isGRPC() && len(resp.Trailer.Get("Grpc-Status")) == 0 {
+			this.grpcStatus = strconv.Itoa(grpcStatusUnavailable)
+			writer.Header().Set(http.TrailerPrefix+"Grpc-Status", this.grpcStatus)
+			writer.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeGRPCMessage(err.Error()))
+		}
 		return nil
 	}
 
+	// 转发Trailer
+	for k, v := range resp.Trailer {
+		writer.Header()[http.TrailerPrefix+k] = v
+	}
+	if this.grpcMode() {
+		this.recordGRPCStatus(resp)
+	}
+
 	return nil
 }
 
@@ -1145,6 +1339,7 @@ func (this *Request) callProxy(writer *ResponseWriter) error {
 	}
 
 	this.backend = backend
+	this.backendList = &this.proxy.BackendList
 	return this.callBackend(writer)
 }
 
@@ -1157,7 +1352,7 @@ func (this *Request) callFastcgi(writer *ResponseWriter) error {
 		}
 	}
 	if !env.Has("REMOTE_ADDR") {
-		env["REMOTE_ADDR"] = this.raw.RemoteAddr
+		env["REMOTE_ADDR"] = this.requestRemoteAddr()
 	}
 	if !env.Has("QUERY_STRING") {
 		u, err := url.ParseRequestURI(this.uri)
@@ -1197,7 +1392,7 @@ func (this *Request) callFastcgi(writer *ResponseWriter) error {
 
 	client, err := gofcgi.SharedPool(this.fastcgi.Network(), this.fastcgi.Address(), uint(poolSize)).Client()
 	if err != nil {
-		this.serverError(writer)
+		this.backendError(writer, backendErrorType(err))
 		logs.Error(err)
 		return nil
 	}
@@ -1245,7 +1440,7 @@ func (this *Request) callFastcgi(writer *ResponseWriter) error {
 
 	resp, stderr, err := client.Call(fcgiReq)
 	if err != nil {
-		this.serverError(writer)
+		this.backendError(writer, backendErrorType(err))
 		//if this.debug {
 		logs.Error(err)
 		//}
@@ -1351,19 +1546,7 @@ func (this *Request) callRewrite(writer *ResponseWriter) error {
 		}
 
 		// ip
-		remoteAddr := this.requestRemoteAddr()
-		if len(remoteAddr) > 0 {
-			index := strings.Index(this.raw.RemoteAddr, ":")
-			ip := ""
-			if index > -1 {
-				ip = this.raw.RemoteAddr[:index]
-			} else {
-				ip = this.raw.RemoteAddr
-			}
-			req.Header.Set("X-Real-IP", ip)
-			req.Header.Set("X-Forwarded-For", ip)
-			req.Header.Set("X-Forwarded-By", ip)
-		}
+		this.setForwardedHeaders(req.Header)
 
 		// headers
 		for _, h := range this.headers {
@@ -1471,67 +1654,85 @@ func (this *Request) consumeAPI(writer *ResponseWriter) bool {
 	return true
 }
 
-func (this *Request) notFoundError(writer *ResponseWriter) {
-	msg := "404 page not found: '" + this.requestURI() + "'"
-
-	writer.WriteHeader(http.StatusNotFound)
-	writer.Write([]byte(msg))
-
+// 终端IP，只从受信任的代理传递的Forwarded、X-Forwarded-For和X-Real-IP中获取
+func (this *Request) clientIP() string {
+	server := this.rootServer
+	if server == nil {
+		server = this.server
+	}
+	if server == nil {
+		return teautils.ClientIPFromHeader(this.raw.RemoteAddr, this.forwardedHeader, nil)
+	}
+	return server.ClientIP(this.raw.RemoteAddr, this.forwardedHeader)
 }
 
-func (this *Request) serverError(writer *ResponseWriter) {
-	statusCode := http.StatusInternalServerError
-
-	// 忽略的Header
-	ignoreHeaders := this.convertIgnoreHeaders()
-	hasIgnoreHeaders := ignoreHeaders.Len() > 0
-
-	// 自定义Header
-	for _, header := range this.headers {
-		if header.Match(statusCode) {
-			if hasIgnoreHeaders && ignoreHeaders.Has(strings.ToUpper(header.Name)) {
-				continue
-			}
-			writer.Header().Set(header.Name, header.Value)
-		}
+// 设置转发到后端服务的代理相关的头部，X-Forwarded-For和Forwarded会在原有的值之后追加直接连接的地址
+// 参考 https://tools.ietf.org/html/rfc7239
+func (this *Request) setForwardedHeaders(header http.Header) {
+	proto := this.rawScheme
+	if len(proto) == 0 {
+		proto = this.scheme
 	}
 
-	writer.WriteHeader(statusCode)
-	writer.Write([]byte(http.StatusText(statusCode)))
+	peerIP := ""
+	if ip := teautils.ParseIP(this.raw.RemoteAddr); ip != nil {
+		peerIP = ip.String()
 
-}
+		header.Set("X-Real-IP", this.clientIP())
+		header.Set("X-Forwarded-For", appendHeaderValue(this.forwardedHeader.Values("X-Forwarded-For"), peerIP))
+		header.Set("X-Forwarded-By", peerIP)
+	}
+	header.Set("X-Forwarded-Host", this.host)
+	if len(proto) > 0 {
+		header.Set("X-Forwarded-Proto", proto)
+	}
 
-func (this *Request) requestRemoteAddr() string {
-	// Real-IP
-	realIP := this.raw.Header.Get("X-Real-IP")
-	if len(realIP) > 0 {
-		index := strings.LastIndex(realIP, ":")
-		if index < 0 {
-			return realIP
+	element := []string{}
+	if len(peerIP) > 0 {
+		if strings.Contains(peerIP, ":") {
+			element = append(element, "for=\"["+peerIP+"]\"")
 		} else {
-			return realIP[:index]
+			element = append(element, "for="+peerIP)
 		}
 	}
+	if len(this.host) > 0 {
+		element = append(element, "host="+strconv.Quote(this.host))
+	}
+	if len(proto) > 0 {
+		element = append(element, "proto="+proto)
+	}
+	if len(element) > 0 {
+		header.Set("Forwarded", appendHeaderValue(this.forwardedHeader.Values("Forwarded"), strings.Join(element, ";")))
+	}
+}
 
-	// X-Forwarded-For
-	forwardedFor := this.raw.Header.Get("X-Forwarded-For")
-	if len(forwardedFor) > 0 {
-		index := strings.LastIndex(forwardedFor, ":")
-		if index < 0 {
-			return forwardedFor
-		} else {
-			return forwardedFor[:index]
+// 复制和代理相关的Header
+func copyForwardedHeader(header http.Header) http.Header {
+	result := http.Header{}
+	for _, name := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		values := header.Values(name)
+		if len(values) > 0 {
+			result[name] = append([]string{}, values...)
 		}
 	}
+	return result
+}
 
-	// Remote-Addr
-	remoteAddr := this.raw.RemoteAddr
-	index := strings.LastIndex(remoteAddr, ":")
-	if index < 0 {
-		return remoteAddr
-	} else {
-		return remoteAddr[:index]
+// 在Header原有的值之后追加新的值
+func appendHeaderValue(values []string, value string) string {
+	result := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			result = append(result, v)
+		}
 	}
+	return strings.Join(append(result, value), ", ")
+}
+
+// 终端地址
+func (this *Request) requestRemoteAddr() string {
+	return this.clientIP()
 }
 
 func (this *Request) requestRemotePort() int {
@@ -1659,6 +1860,16 @@ func (this *Request) requestHeader(key string) string {
 	return strings.Join(v, ";")
 }
 
+// 原始请求
+func (this *Request) Raw() *http.Request {
+	return this.raw
+}
+
+// 请求方法
+func (this *Request) Method() string {
+	return this.method
+}
+
 func (this *Request) CachePolicy() *shared.CachePolicy {
 	return this.cachePolicy
 }
@@ -1676,6 +1887,21 @@ func (this *Request) IsCacheEnabled() bool {
 	return this.cacheEnabled
 }
 
+// 设置缓存状态
+func (this *Request) SetCacheStatus(status string) {
+	this.cacheStatus = status
+}
+
+// 缓存状态
+func (this *Request) CacheStatus() string {
+	return this.cacheStatus
+}
+
+// 协商后的压缩编码，为空表示不压缩
+func (this *Request) CompressionEncoding() string {
+	return this.compressionEncoding
+}
+
 // 设置监控状态
 func (this *Request) SetIsWatching(isWatching bool) {
 	this.isWatching = isWatching
@@ -1765,6 +1991,24 @@ func (this *Request) Format(source string) string {
 			return this.serverName
 		case "serverPort":
 			return fmt.Sprintf("%d", this.requestServerPort())
+		case "backendRetries":
+			return fmt.Sprintf("%d", this.backendRetries())
+		case "cacheStatus":
+			return this.cacheStatus
+		case "sslProtocol":
+			return this.requestSSLProtocol()
+		case "sslCipher":
+			return this.requestSSLCipher()
+		case "sslClientSubject":
+			return this.requestSSLClientSubject()
+		case "sslClientIssuer":
+			return this.requestSSLClientIssuer()
+		case "sslClientSerial":
+			return this.requestSSLClientSerial()
+		case "sslClientFingerprint":
+			return this.requestSSLClientFingerprint()
+		case "sslClientVerify":
+			return this.requestSSLClientVerify()
 		}
 
 		dotIndex := strings.Index(varName, ".")
@@ -1864,6 +2108,14 @@ func (this *Request) log() {
 		accessLog.BackendAddress = this.backend.Address
 		accessLog.BackendId = this.backend.Id
 	}
+	if this.backendRetries() > 0 {
+		accessLog.BackendRetries = this.backendRetries()
+		accessLog.BackendAttempts = this.backendAttempts
+	}
+
+	if len(this.grpcStatus) > 0 {
+		accessLog.GRPCStatus = this.grpcStatus
+	}
 
 	if this.fastcgi != nil {
 		accessLog.FastcgiAddress = this.fastcgi.Pass
@@ -1872,6 +2124,24 @@ func (this *Request) log() {
 
 	accessLog.RewriteId = this.rewriteId
 
+	if len(this.cacheStatus) > 0 {
+		accessLog.CacheStatus = this.cacheStatus
+		if this.cachePolicy != nil {
+			accessLog.CachePolicy = this.cachePolicy.Filename
+		}
+	}
+
+	if this.raw.TLS != nil {
+		accessLog.SSLProtocol = this.requestSSLProtocol()
+		accessLog.SSLCipher = this.requestSSLCipher()
+		if this.requestSSLClientCert() != nil {
+			accessLog.SSLClientSubject = this.requestSSLClientSubject()
+			accessLog.SSLClientIssuer = this.requestSSLClientIssuer()
+			accessLog.SSLClientFingerprint = this.requestSSLClientFingerprint()
+			accessLog.SSLClientVerify = this.requestSSLClientVerify()
+		}
+	}
+
 	if this.location != nil {
 		accessLog.LocationId = this.location.Id
 	}
@@ -1887,6 +2157,13 @@ func (this *Request) log() {
 		accessLog.ResponseBodyData = this.responseWriter.Body()
 	}
 
+	// 写入到配置的日志输出中，路径配置优先于服务配置
+	if this.location != nil && len(this.location.AccessLog) > 0 {
+		writeAccessLog(this.location.AccessLog, accessLog)
+	} else if this.server != nil {
+		writeAccessLog(this.server.AccessLog, accessLog)
+	}
+
 	tealogs.SharedLogger().Push(accessLog)
 }
 
@@ -0,0 +1,98 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+)
+
+// 只记录日志时，访问日志中最多记录的请求数据尺寸
+const maxLogOnlyRequestDataSize = 10240
+
+// 异步处理：请求放入队列后立即返回设置的响应，然后在后台转发到后端服务
+func (this *Request) callAsync(writer *ResponseWriter) error {
+	config := this.asyncConfig
+
+	body, ok := this.bufferLimitedBody(config.MaxBodyBytes())
+	if !ok {
+		this.writeError(writer, http.StatusRequestEntityTooLarge, "")
+		return nil
+	}
+
+	// 使用子请求转发，不影响当前请求的日志
+	req := this.Fork(nil)
+	err := req.configure(this.rootServer, 0)
+	if err != nil {
+		return err
+	}
+	req.raw.ContentLength = int64(len(body))
+
+	queue := findAsyncQueue(this.server.Id, this.asyncLocationId, config)
+	if !queue.push(&asyncTask{
+		req:    req,
+		body:   body,
+		config: config,
+	}) {
+		this.serviceUnavailableError(writer)
+		return nil
+	}
+
+	this.writeDirectResponse(writer, config.Response, http.StatusAccepted)
+	return nil
+}
+
+// 只记录日志：不请求后端服务，直接返回设置的响应，请求内容记录在访问日志中
+func (this *Request) callLogOnly(writer *ResponseWriter) error {
+	if len(this.requestData) == 0 {
+		this.requestData = this.dumpRequest(maxLogOnlyRequestDataSize)
+	}
+	this.writeDirectResponse(writer, this.logOnlyResponse, http.StatusOK)
+	return nil
+}
+
+// 导出请求的Header和Body，超出maxSize的部分会被截断
+func (this *Request) dumpRequest(maxSize int) []byte {
+	data, err := httputil.DumpRequest(this.raw, false)
+	if err != nil {
+		return nil
+	}
+	if len(data) >= maxSize {
+		return data[:maxSize]
+	}
+	if this.raw.Body != nil && this.raw.Body != http.NoBody {
+		body, _ := ioutil.ReadAll(io.LimitReader(this.raw.Body, int64(maxSize-len(data))))
+		data = append(data, body...)
+	}
+	return data
+}
+
+// 输出直接返回的响应
+func (this *Request) writeDirectResponse(writer *ResponseWriter, config *teaconfigs.DirectResponseConfig, defaultStatus int) {
+	statusCode := config.StatusCode(defaultStatus)
+	this.addErrorHeaders(writer, statusCode)
+
+	if config == nil || len(config.Body) == 0 {
+		writer.WriteHeader(statusCode)
+		return
+	}
+
+	contentType := config.ContentType
+	if len(contentType) == 0 {
+		contentType = "text/plain; charset=utf-8"
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(statusCode)
+	writer.Write([]byte(this.Format(config.Body)))
+}
+
+// 没有异步处理设置时使用默认设置
+func asyncConfigOrDefault(config *teaconfigs.AsyncConfig) *teaconfigs.AsyncConfig {
+	if config != nil {
+		return config
+	}
+	config = teaconfigs.NewAsyncConfig()
+	config.Validate()
+	return config
+}
@@ -0,0 +1,42 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequest_CallLogOnly(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	raw, err := http.NewRequest(http.MethodPost, "http://example.com/hook", strings.NewReader("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := NewRequest(raw)
+	req.uri = "/hook"
+	req.logOnly = true
+	req.logOnlyResponse = &teaconfigs.DirectResponseConfig{
+		Status:      http.StatusCreated,
+		ContentType: "application/json",
+		Body:        `{"ok":true}`,
+	}
+
+	recorder := httptest.NewRecorder()
+	a.IsNil(req.callLogOnly(NewResponseWriter(recorder)))
+	a.IsTrue(recorder.Code == http.StatusCreated)
+	a.IsTrue(recorder.Header().Get("Content-Type") == "application/json")
+	a.IsTrue(recorder.Body.String() == `{"ok":true}`)
+	a.IsTrue(strings.HasPrefix(string(req.requestData), "POST /hook HTTP/1.1\r\n"))
+	a.IsTrue(strings.HasSuffix(string(req.requestData), "\r\n\r\nHello"))
+
+	// 默认响应
+	req.logOnlyResponse = nil
+	recorder = httptest.NewRecorder()
+	a.IsNil(req.callLogOnly(NewResponseWriter(recorder)))
+	a.IsTrue(recorder.Code == http.StatusOK)
+	a.IsTrue(recorder.Body.Len() == 0)
+}
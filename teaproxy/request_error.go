@@ -0,0 +1,240 @@
+package teaproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/maps"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 没有可用的后端服务
+var errNoBackendsAvailable = errors.New("no backends available")
+
+func (this *Request) forbiddenError(writer *ResponseWriter) {
+	this.writeError(writer, http.StatusForbidden, "403 Forbidden")
+}
+
+func (this *Request) notFoundError(writer *ResponseWriter) {
+	this.writeError(writer, http.StatusNotFound, "404 page not found: '"+this.requestURI()+"'")
+}
+
+func (this *Request) serverError(writer *ResponseWriter) {
+	this.writeError(writer, http.StatusInternalServerError, "")
+}
+
+// 没有可用的后端服务
+func (this *Request) serviceUnavailableError(writer *ResponseWriter) {
+	this.writeError(writer, http.StatusServiceUnavailable, "")
+}
+
+// 请求后端服务失败，超时返回504，其他错误返回502
+func (this *Request) backendError(writer *ResponseWriter, errorType teaconfigs.RetryErrorType) {
+	if errorType == teaconfigs.RetryErrorTimeout {
+		if this.isGRPC() {
+			this.writeGRPCError(writer, grpcStatusDeadlineExceeded, http.StatusText(http.StatusGatewayTimeout))
+			return
+		}
+		this.writeError(writer, http.StatusGatewayTimeout, "")
+		return
+	}
+	this.writeError(writer, http.StatusBadGateway, "")
+}
+
+// 输出错误信息，优先使用匹配的错误页面，JSON客户端会收到JSON格式的错误信息，gRPC客户端会收到grpc-status
+func (this *Request) writeError(writer *ResponseWriter, statusCode int, message string) {
+	if this.responseWriter == nil {
+		this.responseWriter = writer
+	}
+	if len(message) == 0 {
+		message = http.StatusText(statusCode)
+	}
+
+	if this.isGRPC() {
+		this.writeGRPCError(writer, grpcStatusFromHTTP(statusCode), message)
+		return
+	}
+	isJSON := acceptsJSON(this.raw.Header.Get("Accept"))
+
+	// 错误页面
+	page := this.findErrorPage(statusCode)
+	if page != nil && (!isJSON || page.IsJSON()) {
+		respStatusCode := statusCode
+		if page.NewStatus > 0 {
+			respStatusCode = page.NewStatus
+		}
+		if this.writeErrorPage(writer, page, statusCode, respStatusCode) {
+			return
+		}
+	}
+
+	this.addErrorHeaders(writer, statusCode)
+	if isJSON {
+		data, err := json.Marshal(maps.Map{
+			"status":  statusCode,
+			"error":   http.StatusText(statusCode),
+			"message": message,
+		})
+		if err == nil {
+			writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+			writer.WriteHeader(statusCode)
+			writer.Write(data)
+			return
+		}
+	}
+	writer.WriteHeader(statusCode)
+	writer.Write([]byte(message))
+}
+
+// 查找匹配的错误页面，Location中的错误页面优先
+func (this *Request) findErrorPage(statusCode int) *teaconfigs.ErrorPageConfig {
+	if this.isErrorPage {
+		return nil
+	}
+	for i := len(this.errorPageLists) - 1; i >= 0; i-- {
+		page := this.errorPageLists[i].MatchErrorPage(statusCode)
+		if page != nil {
+			return page
+		}
+	}
+	return nil
+}
+
+// 输出错误页面，失败时返回false
+func (this *Request) writeErrorPage(writer *ResponseWriter, page *teaconfigs.ErrorPageConfig, statusCode int, respStatusCode int) bool {
+	switch page.Type {
+	case teaconfigs.ErrorPageTypeFile:
+		data, err := ioutil.ReadFile(page.FilePath())
+		if err != nil {
+			logs.Error(err)
+			return false
+		}
+		this.addErrorHeaders(writer, respStatusCode)
+		writer.Header().Set("Content-Type", page.MimeType())
+		writer.WriteHeader(respStatusCode)
+		writer.Write(data)
+		return true
+	case teaconfigs.ErrorPageTypeURL:
+		pageURL, err := url.ParseRequestURI(page.URL)
+		if err != nil {
+			logs.Error(err)
+			return false
+		}
+
+		// 子请求使用错误页面的URI，不能再被当成原URI处理
+		subReq := this.Fork(nil)
+		subReq.raw.Method = http.MethodGet
+		subReq.raw.URL = pageURL
+		subReq.raw.RequestURI = page.URL
+		subReq.method = http.MethodGet
+		subReq.uri = page.URL
+		subReq.rawURI = page.URL
+		subReq.isErrorPage = true
+
+		recorder := newResponseRecorder()
+		subWriter := NewResponseWriter(recorder)
+		err = subReq.Execute(subWriter)
+		if err != nil {
+			logs.Error(err)
+			return false
+		}
+		if subWriter.StatusCode() != http.StatusOK {
+			logs.Error(errors.New("error page '" + page.URL + "' responded with status " + strconv.Itoa(subWriter.StatusCode())))
+			return false
+		}
+		for _, name := range []string{"Content-Type", "Content-Language", "Last-Modified", "ETag"} {
+			value := recorder.header.Get(name)
+			if len(value) > 0 {
+				writer.Header().Set(name, value)
+			}
+		}
+		this.addErrorHeaders(writer, respStatusCode)
+		writer.WriteHeader(respStatusCode)
+		writer.Write(recorder.body.Bytes())
+		return true
+	case teaconfigs.ErrorPageTypeTemplate:
+		body := this.formatWithStatus(page.Template, statusCode)
+		this.addErrorHeaders(writer, respStatusCode)
+		writer.Header().Set("Content-Type", page.MimeType())
+		writer.WriteHeader(respStatusCode)
+		writer.Write([]byte(body))
+		return true
+	}
+	return false
+}
+
+// 格式化错误页面模板，其中的${status}和${statusMessage}使用错误的状态码
+func (this *Request) formatWithStatus(source string, statusCode int) string {
+	oldMapping := map[string]string{}
+	for _, varName := range []string{"status", "statusMessage"} {
+		if value, found := this.varMapping[varName]; found {
+			oldMapping[varName] = value
+		}
+	}
+	this.varMapping["status"] = strconv.Itoa(statusCode)
+	this.varMapping["statusMessage"] = http.StatusText(statusCode)
+
+	result := this.Format(source)
+
+	delete(this.varMapping, "status")
+	delete(this.varMapping, "statusMessage")
+	for varName, value := range oldMapping {
+		this.varMapping[varName] = value
+	}
+	return result
+}
+
+// 添加匹配状态码的自定义Header
+func (this *Request) addErrorHeaders(writer *ResponseWriter, statusCode int) {
+	// 忽略的Header
+	ignoreHeaders := this.convertIgnoreHeaders()
+	hasIgnoreHeaders := ignoreHeaders.Len() > 0
+
+	// 自定义Header
+	for _, header := range this.headers {
+		if header.Match(statusCode) {
+			if hasIgnoreHeaders && ignoreHeaders.Has(strings.ToUpper(header.Name)) {
+				continue
+			}
+			writer.Header().Set(header.Name, header.Value)
+		}
+	}
+}
+
+// 根据Accept判断客户端是否更希望接收JSON内容
+func acceptsJSON(accept string) bool {
+	if len(accept) == 0 {
+		return false
+	}
+	jsonQ := 0.0
+	htmlQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qValue, found := params["q"]; found {
+			q, err = strconv.ParseFloat(qValue, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			if q > jsonQ {
+				jsonQ = q
+			}
+		} else if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+			if q > htmlQ {
+				htmlQ = q
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
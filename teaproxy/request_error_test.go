@@ -0,0 +1,97 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsFalse(acceptsJSON(""))
+	a.IsFalse(acceptsJSON("*/*"))
+	a.IsFalse(acceptsJSON("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"))
+	a.IsTrue(acceptsJSON("application/json"))
+	a.IsTrue(acceptsJSON("application/problem+json, text/plain"))
+	a.IsTrue(acceptsJSON("text/html;q=0.5, application/json"))
+	a.IsFalse(acceptsJSON("application/json;q=0.5, text/html"))
+}
+
+func TestRequest_WriteError(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	newRequest := func(accept string, pages ...*teaconfigs.ErrorPageConfig) *Request {
+		req := testNewRequest(http.MethodGet, "/hello", nil)
+		if len(accept) > 0 {
+			req.raw.Header.Set("Accept", accept)
+		}
+		if len(pages) > 0 {
+			list := &teaconfigs.ErrorPageList{}
+			for _, page := range pages {
+				list.AddErrorPage(page)
+			}
+			a.IsNil(list.ValidateErrorPages())
+			req.errorPageLists = append(req.errorPageLists, list)
+		}
+		return req
+	}
+
+	// 默认的错误信息
+	{
+		recorder := httptest.NewRecorder()
+		newRequest("").backendError(NewResponseWriter(recorder), teaconfigs.RetryErrorConnect)
+		a.IsTrue(recorder.Code == http.StatusBadGateway)
+		a.IsTrue(recorder.Body.String() == "Bad Gateway")
+	}
+
+	// 超时
+	{
+		recorder := httptest.NewRecorder()
+		newRequest("").backendError(NewResponseWriter(recorder), teaconfigs.RetryErrorTimeout)
+		a.IsTrue(recorder.Code == http.StatusGatewayTimeout)
+	}
+
+	// JSON
+	{
+		recorder := httptest.NewRecorder()
+		newRequest("application/json").serviceUnavailableError(NewResponseWriter(recorder))
+		a.IsTrue(recorder.Code == http.StatusServiceUnavailable)
+		a.IsTrue(strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json"))
+		a.IsTrue(strings.Contains(recorder.Body.String(), `"status":503`))
+	}
+
+	// 模板
+	page := teaconfigs.NewErrorPageConfig()
+	page.Status = []string{"5xx"}
+	page.Template = "<h1>${status} ${statusMessage}</h1>${requestPath}"
+	{
+		recorder := httptest.NewRecorder()
+		newRequest("", page).backendError(NewResponseWriter(recorder), teaconfigs.RetryErrorOther)
+		a.IsTrue(recorder.Code == http.StatusBadGateway)
+		a.IsTrue(recorder.Body.String() == "<h1>502 Bad Gateway</h1>/hello")
+		a.IsTrue(strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/html"))
+	}
+
+	// JSON客户端不使用HTML错误页面
+	{
+		recorder := httptest.NewRecorder()
+		newRequest("application/json", page).backendError(NewResponseWriter(recorder), teaconfigs.RetryErrorOther)
+		a.IsTrue(strings.Contains(recorder.Body.String(), `"status":502`))
+	}
+
+	// 新的状态码
+	{
+		page := teaconfigs.NewErrorPageConfig()
+		page.Status = []string{"404"}
+		page.Template = "not found"
+		page.NewStatus = http.StatusOK
+
+		recorder := httptest.NewRecorder()
+		newRequest("", page).notFoundError(NewResponseWriter(recorder))
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "not found")
+	}
+}
@@ -0,0 +1,66 @@
+package teaproxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// 复制一个新的子请求，用来在不影响当前请求的情况下重新执行，比如更新缓存
+// header中的Header会覆盖原请求中的同名Header，子请求不带请求体，也不会记录访问日志
+func (this *Request) Fork(header http.Header) *Request {
+	raw := this.raw.WithContext(context.Background())
+	raw.Header = http.Header{}
+	for k, v := range this.raw.Header {
+		raw.Header[k] = append([]string{}, v...)
+	}
+	for k, v := range header {
+		raw.Header[k] = append([]string{}, v...)
+	}
+	raw.Body = http.NoBody
+	raw.ContentLength = 0
+
+	req := NewRequest(raw)
+	req.host = this.host
+	req.method = this.method
+	req.uri = this.rawURI
+	req.rawScheme = this.rawScheme
+	req.scheme = this.scheme
+	req.serverName = this.serverName
+	req.serverAddr = this.serverAddr
+	req.rootServer = this.rootServer
+	req.forwardedHeader = this.forwardedHeader
+	if this.rootServer != nil {
+		req.root = this.rootServer.Root
+		req.index = this.rootServer.Index
+		req.charset = this.rootServer.Charset
+	}
+	req.isForked = true
+	req.shouldLog = false
+	return req
+}
+
+// 执行子请求
+func (this *Request) Execute(writer *ResponseWriter) error {
+	if !this.isForked {
+		return errors.New("only forked request can be executed")
+	}
+	if this.rootServer == nil {
+		return errors.New("no server for forked request")
+	}
+	err := this.configure(this.rootServer, 0)
+	if err != nil {
+		return err
+	}
+	return this.call(writer)
+}
+
+// 是否为子请求
+func (this *Request) IsForked() bool {
+	return this.isForked
+}
+
+// 是否为请求错误页面的子请求
+func (this *Request) IsErrorPage() bool {
+	return this.isErrorPage
+}
@@ -0,0 +1,144 @@
+package teaproxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC状态码
+// 参考：https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcStatusUnknown          = 2
+	grpcStatusDeadlineExceeded = 4
+	grpcStatusPermissionDenied = 7
+	grpcStatusUnimplemented    = 12
+	grpcStatusInternal         = 13
+	grpcStatusUnavailable      = 14
+	grpcStatusUnauthenticated  = 16
+)
+
+// 是否开启了gRPC模式，开启后总是使用HTTP/2连接后端服务
+func (this *Request) grpcMode() bool {
+	return this.backendList != nil && this.backendList.IsGRPC()
+}
+
+// 是否为gRPC模式下的gRPC请求
+func (this *Request) isGRPC() bool {
+	return this.grpcMode() && isGRPCContentType(this.raw.Header.Get("Content-Type"))
+}
+
+// 输出只包含Header的gRPC错误响应，HTTP状态码总是200
+func (this *Request) writeGRPCError(writer *ResponseWriter, grpcStatus int, message string) {
+	this.grpcStatus = strconv.Itoa(grpcStatus)
+
+	header := writer.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/grpc")
+	header.Set("Grpc-Status", this.grpcStatus)
+	if len(message) > 0 {
+		header.Set("Grpc-Message", encodeGRPCMessage(message))
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// 记录后端服务返回的gRPC状态码，没有数据的响应会把状态码放在Header中
+func (this *Request) recordGRPCStatus(resp *http.Response) {
+	grpcStatus := resp.Trailer.Get("Grpc-Status")
+	if len(grpcStatus) == 0 {
+		grpcStatus = resp.Header.Get("Grpc-Status")
+	}
+	if len(grpcStatus) > 0 {
+		this.grpcStatus = grpcStatus
+	}
+}
+
+// 判断是否为gRPC的Content-Type，比如application/grpc、application/grpc+proto
+func isGRPCContentType(contentType string) bool {
+	if !strings.HasPrefix(contentType, "application/grpc") {
+		return false
+	}
+	if len(contentType) == len("application/grpc") {
+		return true
+	}
+	switch contentType[len("application/grpc")] {
+	case '+', ';':
+		return true
+	}
+	return false
+}
+
+// 将HTTP状态码转换为gRPC状态码
+// 参考：https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func grpcStatusFromHTTP(statusCode int) int {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return grpcStatusInternal
+	case http.StatusUnauthorized:
+		return grpcStatusUnauthenticated
+	case http.StatusForbidden:
+		return grpcStatusPermissionDenied
+	case http.StatusNotFound:
+		return grpcStatusUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcStatusUnavailable
+	case http.StatusInternalServerError:
+		return grpcStatusInternal
+	}
+	return grpcStatusUnknown
+}
+
+// 对grpc-message进行百分号编码
+func encodeGRPCMessage(message string) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			builder.WriteByte(c)
+		} else {
+			builder.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return builder.String()
+}
+
+// 分析grpc-timeout的值，比如100m表示100毫秒，格式不正确时返回false
+// 参考：https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+func parseGRPCTimeout(value string) (timeout time.Duration, ok bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+
+	var n int64
+	for _, c := range value[:len(value)-1] {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if n > math.MaxInt64/int64(unit) {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,278 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsGRPCContentType(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsTrue(isGRPCContentType("application/grpc"))
+	a.IsTrue(isGRPCContentType("application/grpc+proto"))
+	a.IsTrue(isGRPCContentType("application/grpc;charset=utf-8"))
+	a.IsFalse(isGRPCContentType("application/grpc-web"))
+	a.IsFalse(isGRPCContentType("application/json"))
+	a.IsFalse(isGRPCContentType(""))
+}
+
+func TestGRPCStatusFromHTTP(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsTrue(grpcStatusFromHTTP(http.StatusBadGateway) == grpcStatusUnavailable)
+	a.IsTrue(grpcStatusFromHTTP(http.StatusServiceUnavailable) == grpcStatusUnavailable)
+	a.IsTrue(grpcStatusFromHTTP(http.StatusUnauthorized) == grpcStatusUnauthenticated)
+	a.IsTrue(grpcStatusFromHTTP(http.StatusNotFound) == grpcStatusUnimplemented)
+	a.IsTrue(grpcStatusFromHTTP(http.StatusTeapot) == grpcStatusUnknown)
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsTrue(encodeGRPCMessage("Bad Gateway") == "Bad Gateway")
+	a.IsTrue(encodeGRPCMessage("100%") == "100%25")
+	a.IsTrue(encodeGRPCMessage("a\nb") == "a%0Ab")
+}
+
+func TestRequest_WriteGRPCError(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	raw, err := http.NewRequest(http.MethodPost, "/hello.Greeter/SayHello", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Header.Set("Content-Type", "application/grpc")
+
+	req := NewRequest(raw)
+	req.uri = "/hello.Greeter/SayHello"
+	req.backendList = &teaconfigs.BackendList{}
+
+	// 没有开启gRPC模式
+	{
+		recorder := httptest.NewRecorder()
+		req.serviceUnavailableError(NewResponseWriter(recorder))
+		a.IsTrue(recorder.Code == http.StatusServiceUnavailable)
+		a.IsTrue(len(recorder.Header().Get("Grpc-Status")) == 0)
+	}
+
+	req.backendList.SetGRPC(true)
+
+	{
+		recorder := httptest.NewRecorder()
+		req.serviceUnavailableError(NewResponseWriter(recorder))
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Header().Get("Content-Type") == "application/grpc")
+		a.IsTrue(recorder.Header().Get("Grpc-Status") == "14")
+		a.IsTrue(recorder.Header().Get("Grpc-Message") == "Service Unavailable")
+		a.IsTrue(req.grpcStatus == "14")
+	}
+
+	{
+		recorder := httptest.NewRecorder()
+		req.backendError(NewResponseWriter(recorder), teaconfigs.RetryErrorTimeout)
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Header().Get("Grpc-Status") == "4")
+	}
+}
+
+func TestClientPool_HTTP2(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Trailer", "Grpc-Status")
+		writer.Header().Set("Content-Type", "application/grpc")
+		writer.Write([]byte(req.Proto))
+		writer.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer server.Close()
+
+	backend := teaconfigs.NewBackendConfig()
+	backend.Address = strings.TrimPrefix(server.URL, "http://")
+	backend.HTTP2 = true
+	a.IsNil(backend.Validate())
+
+	pool := NewClientPool()
+	client := pool.backendClient(backend, false)
+	a.IsTrue(client == pool.backendClient(backend, true))
+
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(resp.ProtoMajor == 2)
+	a.IsTrue(string(data) == "HTTP/2.0")
+	a.IsTrue(resp.Trailer.Get("Grpc-Status") == "0")
+}
+
+func TestRequest_CallBackendGRPC(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/grpc")
+		writer.Write([]byte("data"))
+		writer.(http.Flusher).Flush()
+		writer.Header().Set(http.TrailerPrefix+"Grpc-Status", "5")
+		writer.Header().Set(http.TrailerPrefix+"Grpc-Message", "not found")
+	}), &http2.Server{}))
+	defer server.Close()
+
+	call := func(path string) (*httptest.ResponseRecorder, *Request) {
+		backend := &teaconfigs.BackendConfig{On: true, Address: strings.TrimPrefix(server.URL, "http://")}
+		list := &teaconfigs.BackendList{}
+		list.AddBackend(backend)
+		list.SetGRPC(true)
+		a.IsNil(list.ValidateBackends())
+
+		raw, err := http.NewRequest(http.MethodPost, path, strings.NewReader("request"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw.Header.Set("Content-Type", "application/grpc")
+		raw.RemoteAddr = "127.0.0.1:1234"
+
+		req := NewRequest(raw)
+		req.scheme = "http"
+		req.host = "example.com"
+		req.uri = path
+		req.backend = backend
+		req.backendList = list
+
+		recorder := httptest.NewRecorder()
+		writer := NewResponseWriter(recorder)
+		a.IsNil(req.callBackend(writer))
+		writer.Close()
+		return recorder, req
+	}
+
+	// Trailer
+	{
+		recorder, req := call("/hello.Greeter/SayHello")
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "data")
+		a.IsTrue(recorder.Result().Trailer.Get("Grpc-Status") == "5")
+		a.IsTrue(recorder.Result().Trailer.Get("Grpc-Message") == "not found")
+		a.IsTrue(req.grpcStatus == "5")
+	}
+
+	// HTTP状态码转换为grpc-status
+	{
+		recorder, req := call("/missing")
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Header().Get("Grpc-Status") == "12")
+		a.IsTrue(req.grpcStatus == "12")
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	for value, expected := range map[string]time.Duration{
+		"1H":   time.Hour,
+		"2M":   2 * time.Minute,
+		"3S":   3 * time.Second,
+		"100m": 100 * time.Millisecond,
+		"10u":  10 * time.Microsecond,
+		"5n":   5 * time.Nanosecond,
+		"0S":   0,
+	} {
+		timeout, ok := parseGRPCTimeout(value)
+		a.IsTrue(ok)
+		a.IsTrue(timeout == expected)
+	}
+
+	for _, value := range []string{"", "S", "1", "1s", "-1S", "+1S", "123456789S"} {
+		_, ok := parseGRPCTimeout(value)
+		a.IsFalse(ok)
+	}
+}
+
+func TestRequest_CallBackendTimeout(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			time.Sleep(1 * time.Second)
+		}
+		writer.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+		writer.Write([]byte("hello"))
+		writer.(http.Flusher).Flush()
+
+		// 流式响应持续的时间超过后端服务的超时时间
+		if req.URL.Path == "/stream" {
+			time.Sleep(300 * time.Millisecond)
+			writer.Write([]byte(", world"))
+		}
+		writer.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer server.Close()
+
+	call := func(grpc bool, path string, header http.Header) *httptest.ResponseRecorder {
+		backend := &teaconfigs.BackendConfig{On: true, Address: strings.TrimPrefix(server.URL, "http://"), Timeout: "100ms"}
+		list := &teaconfigs.BackendList{}
+		list.AddBackend(backend)
+		list.SetGRPC(grpc)
+		a.IsNil(list.ValidateBackends())
+
+		raw, err := http.NewRequest(http.MethodPost, path, strings.NewReader("request"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			raw.Header[k] = v
+		}
+		raw.RemoteAddr = "127.0.0.1:1234"
+
+		req := NewRequest(raw)
+		req.scheme = "http"
+		req.host = "example.com"
+		req.uri = path
+		req.backend = backend
+		req.backendList = list
+
+		recorder := httptest.NewRecorder()
+		writer := NewResponseWriter(recorder)
+		a.IsNil(req.callBackend(writer))
+		writer.Close()
+		return recorder
+	}
+
+	grpcHeader := http.Header{"Content-Type": {"application/grpc"}}
+
+	// 普通请求受后端服务超时时间限制
+	{
+		recorder := call(false, "/slow", http.Header{})
+		a.IsTrue(recorder.Code == http.StatusGatewayTimeout)
+	}
+
+	// gRPC请求不受后端服务超时时间限制
+	{
+		recorder := call(true, "/stream", grpcHeader)
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "hello, world")
+		a.IsTrue(recorder.Result().Trailer.Get("Grpc-Status") == "0")
+	}
+
+	// 使用客户端的grpc-timeout
+	{
+		header := http.Header{"Content-Type": {"application/grpc"}, "Grpc-Timeout": {"100m"}}
+		before := time.Now()
+		recorder := call(true, "/slow", header)
+		a.IsTrue(time.Since(before) < 900*time.Millisecond)
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Header().Get("Grpc-Status") == "4")
+	}
+}
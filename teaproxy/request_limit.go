@@ -0,0 +1,121 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 已占用的连接
+type limitConn struct {
+	store limitStore
+	key   string
+}
+
+// 添加限制列表，重新匹配服务时不会重复添加
+func (this *Request) addLimitList(limitList *teaconfigs.LimitList) {
+	if !limitList.HasLimits() {
+		return
+	}
+	for _, list := range this.limitLists {
+		if list == limitList {
+			return
+		}
+	}
+	this.limitLists = append(this.limitLists, limitList)
+}
+
+// 检查速率和连接数限制，超出限制时返回429
+// Key的值为空的请求不受限制
+func (this *Request) applyLimits(writer *ResponseWriter) (goNext bool) {
+	for _, limitList := range this.limitLists {
+		for _, limit := range limitList.RateLimits {
+			if !limit.On {
+				continue
+			}
+			key := this.Format(limit.KeyFormat())
+			if len(key) == 0 {
+				continue
+			}
+
+			interval := limit.Interval()
+			tolerance := interval * time.Duration(limit.Burst)
+			maxWait := time.Duration(0)
+			if limit.Mode == teaconfigs.RateLimitModeDelay {
+				tolerance = 0
+				maxWait = limit.MaxDelayDuration()
+			}
+
+			wait, ok, err := findLimitStore(limit.Store).Take(limitKeyPrefix+limit.Id+"_"+key, interval, tolerance, maxWait)
+			if err != nil {
+				// 存储出错时不限制请求
+				logs.Error(err)
+				continue
+			}
+			if !ok {
+				this.tooManyRequestsError(writer, wait-maxWait)
+				return false
+			}
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-this.raw.Context().Done():
+					timer.Stop()
+					return false
+				}
+			}
+		}
+
+		for _, limit := range limitList.ConnLimits {
+			if !limit.On {
+				continue
+			}
+			key := this.Format(limit.KeyFormat())
+			if len(key) == 0 {
+				continue
+			}
+
+			store := findLimitStore(limit.Store)
+			key = limitKeyPrefix + limit.Id + "_" + key
+			ok, err := store.Acquire(key, limit.MaxConns)
+			if err != nil {
+				logs.Error(err)
+				continue
+			}
+			if !ok {
+				this.tooManyRequestsError(writer, 0)
+				return false
+			}
+			this.limitConns = append(this.limitConns, &limitConn{
+				store: store,
+				key:   key,
+			})
+		}
+	}
+	return true
+}
+
+// 释放占用的连接
+func (this *Request) releaseLimits() {
+	for _, conn := range this.limitConns {
+		err := conn.store.Release(conn.key)
+		if err != nil {
+			logs.Error(err)
+		}
+	}
+	this.limitConns = nil
+}
+
+// 请求过多，retryAfter为建议客户端等待的时间，最少1秒
+func (this *Request) tooManyRequestsError(writer *ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	writer.Header().Set("Retry-After", strconv.Itoa(seconds))
+	this.writeError(writer, http.StatusTooManyRequests, "")
+}
@@ -0,0 +1,131 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRequest_ApplyLimits(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	rateLimit := teaconfigs.NewRateLimitConfig()
+	rateLimit.Key = "${header.X-Api-Key}"
+	rateLimit.Rate = 1
+	rateLimit.Period = "1h"
+	rateLimit.Burst = 1
+	connLimit := teaconfigs.NewConnLimitConfig()
+	connLimit.MaxConns = 1
+
+	list := &teaconfigs.LimitList{}
+	list.AddRateLimit(rateLimit)
+	list.AddConnLimit(connLimit)
+	a.IsNil(list.ValidateLimits())
+
+	newRequest := func(apiKey string) *Request {
+		req := testNewRequest(http.MethodGet, "http://example.com/hello", nil)
+		if len(apiKey) > 0 {
+			req.raw.Header.Set("X-Api-Key", apiKey)
+		}
+		req.addLimitList(list)
+		req.addLimitList(list)
+		a.IsTrue(len(req.limitLists) == 1)
+		return req
+	}
+
+	// 速率
+	for i := 0; i < 2; i++ {
+		req := newRequest("key1")
+		a.IsTrue(req.applyLimits(NewResponseWriter(httptest.NewRecorder())))
+		req.releaseLimits()
+	}
+	{
+		req := newRequest("key1")
+		recorder := httptest.NewRecorder()
+		a.IsFalse(req.applyLimits(NewResponseWriter(recorder)))
+		a.IsTrue(recorder.Code == http.StatusTooManyRequests)
+		a.IsTrue(recorder.Header().Get("Retry-After") == "3600")
+		req.releaseLimits()
+	}
+
+	// Key为空时不限制速率
+	for i := 0; i < 3; i++ {
+		req := newRequest("")
+		a.IsTrue(req.applyLimits(NewResponseWriter(httptest.NewRecorder())))
+		req.releaseLimits()
+	}
+
+	// 连接数
+	{
+		req1 := newRequest("key2")
+		a.IsTrue(req1.applyLimits(NewResponseWriter(httptest.NewRecorder())))
+
+		req2 := newRequest("key3")
+		recorder := httptest.NewRecorder()
+		a.IsFalse(req2.applyLimits(NewResponseWriter(recorder)))
+		a.IsTrue(recorder.Code == http.StatusTooManyRequests)
+		req2.releaseLimits()
+
+		req1.releaseLimits()
+		req3 := newRequest("key4")
+		a.IsTrue(req3.applyLimits(NewResponseWriter(httptest.NewRecorder())))
+		req3.releaseLimits()
+	}
+}
+
+func TestRequest_ApplyLimitsIndex(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	dir, err := ioutil.TempDir("", "teaweb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(dir+"/index.html", []byte("index"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rateLimit := teaconfigs.NewRateLimitConfig()
+	rateLimit.Rate = 1
+	rateLimit.Period = "1h"
+	rateLimit.Burst = 0
+	connLimit := teaconfigs.NewConnLimitConfig()
+	connLimit.MaxConns = 1
+
+	server := teaconfigs.NewServerConfig()
+	server.Root = dir
+	server.Index = []string{"index.html"}
+	server.AddRateLimit(rateLimit)
+	server.AddConnLimit(connLimit)
+	a.IsNil(server.Validate())
+
+	call := func() *httptest.ResponseRecorder {
+		req := testNewRequest(http.MethodGet, "/", nil)
+		req.raw.RemoteAddr = "127.0.0.2:1234"
+		req.root = server.Root
+		req.index = server.Index
+		a.IsNil(req.configure(server, 0))
+
+		recorder := httptest.NewRecorder()
+		a.IsNil(req.call(NewResponseWriter(recorder)))
+		return recorder
+	}
+
+	// 跳转到默认文件时不会重复检查限制
+	{
+		recorder := call()
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "index")
+	}
+
+	{
+		recorder := call()
+		a.IsTrue(recorder.Code == http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,126 @@
+package teaproxy
+
+import (
+	"bytes"
+	"context"
+	"github.com/TeaWeb/code/teaconfigs"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 同时发送的镜像请求的最大数量，超出时丢弃新的镜像请求，防止镜像地址变慢时占用过多资源
+const maxNotifyConcurrency = 1024
+
+var notifySemaphore = make(chan bool, maxNotifyConcurrency)
+
+// 发送镜像请求的客户端，超时时间由每个镜像单独设置
+var notifyClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 120 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:        1024,
+		MaxIdleConnsPerHost: 256,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// 不需要发送给镜像地址的Header
+var notifyIgnoreHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// 复制当前请求并异步发送到镜像地址，镜像的响应会被丢弃
+// 请求体会先读取到内存中，原始请求仍然从内存中读取请求体
+func (this *Request) callNotify() {
+	notifies := []*teaconfigs.NotifyConfig{}
+	maxBodySize := int64(0)
+	for _, notify := range this.notifyList.Notify {
+		if !notify.Sample() {
+			continue
+		}
+		notifies = append(notifies, notify)
+		if notify.MaxBodyBytes() > maxBodySize {
+			maxBodySize = notify.MaxBodyBytes()
+		}
+	}
+	if len(notifies) == 0 {
+		return
+	}
+
+	body, ok := this.bufferLimitedBody(maxBodySize)
+	if !ok {
+		return
+	}
+
+	header := http.Header{}
+	for k, v := range this.raw.Header {
+		header[k] = append([]string{}, v...)
+	}
+	for _, k := range notifyIgnoreHeaders {
+		header.Del(k)
+	}
+	this.setForwardedHeaders(header)
+
+	for _, notify := range notifies {
+		if int64(len(body)) > notify.MaxBodyBytes() {
+			continue
+		}
+
+		req, err := http.NewRequest(this.raw.Method, notify.URL(this.rawURI), bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if len(notify.Host) > 0 {
+			req.Host = notify.Host
+		} else {
+			req.Host = this.host
+		}
+
+		select {
+		case notifySemaphore <- true:
+		default:
+			continue
+		}
+		go func(req *http.Request, timeout time.Duration) {
+			defer func() {
+				<-notifySemaphore
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			resp, err := notifyClient.Do(req.WithContext(ctx))
+			if err != nil {
+				return
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}(req, notify.TimeoutDuration())
+	}
+}
+
+// 读取请求体，请求体超出maxBodySize时返回false
+func (this *Request) bufferLimitedBody(maxBodySize int64) (body []byte, ok bool) {
+	if this.raw.Body == nil || this.raw.Body == http.NoBody || this.raw.ContentLength == 0 {
+		return nil, true
+	}
+	if this.raw.ContentLength > maxBodySize {
+		return nil, false
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(this.raw.Body, maxBodySize+1))
+	if err != nil || int64(len(data)) > maxBodySize {
+		this.raw.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), this.raw.Body))
+		return nil, false
+	}
+	this.raw.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, true
+}
@@ -0,0 +1,144 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequest_CallNotify(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	mirrored := make(chan *http.Request, 10)
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		mirrored <- req
+		bodies <- string(data)
+	}))
+	defer server.Close()
+
+	list := &teaconfigs.NotifyList{}
+	notify := teaconfigs.NewNotifyConfig()
+	notify.Address = server.URL
+	notify.MaxBodySize = "16"
+	list.AddNotify(notify)
+	a.IsNil(list.ValidateNotify())
+
+	newRequest := func(body string) *Request {
+		req := testNewRequest(http.MethodPost, "http://example.com/hello?name=Tea", strings.NewReader(body))
+		req.raw.Header.Set("Connection", "close")
+		req.raw.Header.Set("X-Test", "1")
+		req.notifyList = list
+		return req
+	}
+
+	{
+		req := newRequest("Hello")
+		req.callNotify()
+
+		// 原始请求的请求体不受影响
+		data, err := ioutil.ReadAll(req.raw.Body)
+		a.IsNil(err)
+		a.IsTrue(string(data) == "Hello")
+
+		select {
+		case mirrorReq := <-mirrored:
+			a.IsTrue(mirrorReq.Method == http.MethodPost)
+			a.IsTrue(mirrorReq.URL.RequestURI() == "/hello?name=Tea")
+			a.IsTrue(mirrorReq.Host == "example.com")
+			a.IsTrue(mirrorReq.Header.Get("X-Test") == "1")
+			a.IsTrue(mirrorReq.Header.Get("X-Forwarded-For") == "127.0.0.1")
+			a.IsTrue(<-bodies == "Hello")
+		case <-time.After(5 * time.Second):
+			t.Fatal("mirror request timeout")
+		}
+	}
+
+	// 请求体超出限制
+	{
+		req := newRequest("Hello, World, Hello, World")
+		req.raw.ContentLength = -1
+		req.callNotify()
+
+		data, err := ioutil.ReadAll(req.raw.Body)
+		a.IsNil(err)
+		a.IsTrue(string(data) == "Hello, World, Hello, World")
+
+		select {
+		case <-mirrored:
+			t.Fatal("should not mirror the request")
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	// 采样
+	{
+		notify.Percent = 0
+		req := newRequest("Hello")
+		req.callNotify()
+		select {
+		case <-mirrored:
+			t.Fatal("should not mirror the request")
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func TestRequest_CallNotifyIndex(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	dir, err := ioutil.TempDir("", "teaweb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(dir+"/index.html", []byte("index"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirrored := make(chan string, 10)
+	mirrorServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		mirrored <- req.URL.RequestURI()
+	}))
+	defer mirrorServer.Close()
+
+	notify := teaconfigs.NewNotifyConfig()
+	notify.Address = mirrorServer.URL
+
+	server := teaconfigs.NewServerConfig()
+	server.Root = dir
+	server.Index = []string{"index.html"}
+	server.AddNotify(notify)
+	a.IsNil(server.Validate())
+
+	req := testNewRequest(http.MethodGet, "/", nil)
+	req.root = server.Root
+	req.index = server.Index
+	a.IsNil(req.configure(server, 0))
+
+	recorder := httptest.NewRecorder()
+	a.IsNil(req.call(NewResponseWriter(recorder)))
+	a.IsTrue(recorder.Body.String() == "index")
+
+	// 跳转到默认文件时不会重复镜像
+	select {
+	case uri := <-mirrored:
+		a.IsTrue(uri == "/")
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirror request timeout")
+	}
+	select {
+	case <-mirrored:
+		t.Fatal("should mirror the request only once")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -0,0 +1,183 @@
+package teaproxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/maps"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// 重试时最多缓存的请求体尺寸，超出的请求不会被重试
+const maxRetryBodySize = 1 << 20
+
+// 等待后端响应超时的错误
+var errBackendTryTimeout = errors.New("backend try timeout")
+
+// 当前请求的重试设置，没有开启时返回nil
+func (this *Request) backendRetryConfig() *teaconfigs.RetryConfig {
+	if this.backendList == nil || this.websocket != nil {
+		return nil
+	}
+	retry := this.backendList.Retry
+	if retry == nil || !retry.On || retry.MaxTries <= 1 {
+		return nil
+	}
+	return retry
+}
+
+// 请求当前的后端服务，from为第一次尝试的时间，所有尝试共用一个整个请求的超时时间
+// tryTimeout大于0时限制等待响应Header的时间，成功时需要在读取完响应后调用cancel
+func (this *Request) doBackend(from time.Time, tryTimeout time.Duration) (resp *http.Response, cancel context.CancelFunc, errorType teaconfigs.RetryErrorType, err error) {
+	this.raw.URL.Scheme = this.scheme
+	if this.backend.IsHTTPS() {
+		this.raw.URL.Scheme = "https"
+	}
+
+	client := SharedClientPool.backendClient(this.backend, this.grpcMode())
+
+	// HTTP/2连接不支持ResponseHeaderTimeout，同样用等待响应Header的时间来限制
+	if this.backend.HTTP2 || this.grpcMode() {
+		readHeaderTimeout := this.backend.ReadHeaderTimeoutDuration()
+		if readHeaderTimeout > 0 && (tryTimeout <= 0 || readHeaderTimeout < tryTimeout) {
+			tryTimeout = readHeaderTimeout
+		}
+	}
+
+	var ctx context.Context
+	timeout := this.backendTimeout()
+	if timeout > 0 {
+		ctx, cancel = context.WithDeadline(this.raw.Context(), from.Add(timeout))
+	} else {
+		ctx, cancel = context.WithCancel(this.raw.Context())
+	}
+	timedOut := int32(0)
+	var timer *time.Timer
+	if tryTimeout > 0 {
+		timer = time.AfterFunc(tryTimeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			cancel()
+		})
+	}
+
+	resp, err = client.Do(this.raw.WithContext(ctx))
+	if timer != nil && !timer.Stop() && err == nil {
+		// 响应返回时刚好超时
+		resp.Body.Close()
+		resp, err = nil, errBackendTryTimeout
+	}
+	if err != nil {
+		cancel()
+		if atomic.LoadInt32(&timedOut) == 1 {
+			return nil, nil, teaconfigs.RetryErrorTimeout, errBackendTryTimeout
+		}
+		return resp, nil, backendErrorType(err), err
+	}
+	return resp, cancel, "", nil
+}
+
+// 整个请求的超时时间，包括读取响应内容的时间，0表示不限
+// gRPC的流式请求可能持续很长时间，所以不使用后端服务的超时时间，而是使用客户端在grpc-timeout中设置的时间
+func (this *Request) backendTimeout() time.Duration {
+	if this.grpcMode() {
+		timeout, _ := parseGRPCTimeout(this.raw.Header.Get("Grpc-Timeout"))
+		return timeout
+	}
+	return this.backend.TimeoutDuration()
+}
+
+// 是否已经超过整个请求的超时时间，超过后不再重试
+func (this *Request) backendTimedOut(from time.Time) bool {
+	timeout := this.backendTimeout()
+	return timeout > 0 && time.Since(from) >= timeout
+}
+
+// 缓存请求体以便重试时重新发送，请求体太大或者长度未知时返回false
+func (this *Request) bufferRequestBody() (body []byte, ok bool) {
+	if this.raw.Body == nil || this.raw.Body == http.NoBody || this.raw.ContentLength == 0 {
+		return nil, true
+	}
+	if this.raw.ContentLength < 0 || this.raw.ContentLength > maxRetryBodySize {
+		return nil, false
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(this.raw.Body, maxRetryBodySize))
+	if err != nil {
+		this.raw.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(data), this.raw.Body))
+		return nil, false
+	}
+	this.raw.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, true
+}
+
+// 切换到下一个没有尝试过的后端服务
+func (this *Request) nextRetryBackend(triedBackends []*teaconfigs.BackendConfig, body []byte) bool {
+	// 客户端已经断开连接时不再重试
+	if this.backendList == nil || this.raw.Context().Err() != nil {
+		return false
+	}
+	options := maps.Map{
+		"request":   this.raw,
+		"formatter": this.Format,
+	}
+	backend := this.backendList.NextRetryBackend(options, triedBackends)
+	if backend == nil || len(backend.Address) == 0 {
+		return false
+	}
+
+	responseCallback := options.Get("responseCallback")
+	if responseCallback != nil {
+		f, ok := responseCallback.(func(http.ResponseWriter))
+		if ok {
+			this.responseCallback = f
+		}
+	}
+
+	this.backend = backend
+	if body != nil {
+		this.raw.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return true
+}
+
+// 增加当前后端服务的失败次数，如果超过最大失败次数，则下线
+func (this *Request) increaseBackendFails() {
+	if this.backend.RecordFail() {
+		if this.backendList != nil {
+			this.backendList.SetupScheduling(false)
+		} else if this.websocket != nil {
+			this.websocket.SetupScheduling(false)
+		} else {
+			this.server.SetupScheduling(false)
+		}
+	}
+}
+
+// 重试的次数
+func (this *Request) backendRetries() int {
+	if len(this.backendAttempts) <= 1 {
+		return 0
+	}
+	return len(this.backendAttempts) - 1
+}
+
+// 分析请求后端服务的错误类型
+func backendErrorType(err error) teaconfigs.RetryErrorType {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return teaconfigs.RetryErrorConnect
+	}
+	if errors.Is(err, errBackendTryTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return teaconfigs.RetryErrorTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return teaconfigs.RetryErrorTimeout
+	}
+	return teaconfigs.RetryErrorOther
+}
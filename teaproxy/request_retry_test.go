@@ -0,0 +1,163 @@
+package teaproxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequest_CallBackendRetry(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	okServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Write([]byte("ok"))
+	}))
+	defer okServer.Close()
+
+	badServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.WriteHeader(http.StatusBadGateway)
+	}))
+	defer badServer.Close()
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		time.Sleep(1 * time.Second)
+		writer.Write([]byte("slow"))
+	}))
+	defer slowServer.Close()
+
+	// 无法连接的地址
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddress := listener.Addr().String()
+	listener.Close()
+
+	call := func(method string, firstAddress string, retry *teaconfigs.RetryConfig) (*httptest.ResponseRecorder, *Request) {
+		first := &teaconfigs.BackendConfig{On: true, Address: firstAddress}
+		second := &teaconfigs.BackendConfig{On: true, Address: strings.TrimPrefix(okServer.URL, "http://")}
+
+		list := &teaconfigs.BackendList{}
+		list.AddBackend(first)
+		list.AddBackend(second)
+		list.SetRetryConfig(retry)
+		a.IsNil(list.ValidateBackends())
+
+		req, err := http.NewRequest(method, "/hello", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "127.0.0.1:1234"
+
+		request := NewRequest(req)
+		request.scheme = "http"
+		request.host = "example.com"
+		request.uri = "/hello"
+		request.backend = first
+		request.backendList = list
+
+		recorder := httptest.NewRecorder()
+		a.IsNil(request.callBackend(NewResponseWriter(recorder)))
+		return recorder, request
+	}
+
+	// 连接失败
+	{
+		recorder, request := call(http.MethodGet, deadAddress, &teaconfigs.RetryConfig{On: true})
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "ok")
+		a.IsTrue(request.backendRetries() == 1)
+		a.IsTrue(request.backendAttempts[0].Address == deadAddress)
+		a.IsTrue(len(request.backendAttempts[0].Error) > 0)
+		a.IsTrue(request.backendAttempts[1].Status == http.StatusOK)
+	}
+
+	// 状态码
+	{
+		recorder, request := call(http.MethodGet, strings.TrimPrefix(badServer.URL, "http://"), &teaconfigs.RetryConfig{On: true, StatusCodes: []int{http.StatusBadGateway}})
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(request.backendRetries() == 1)
+		a.IsTrue(request.backendAttempts[0].Status == http.StatusBadGateway)
+	}
+
+	// 没有设置重试的状态码
+	{
+		recorder, request := call(http.MethodGet, strings.TrimPrefix(badServer.URL, "http://"), &teaconfigs.RetryConfig{On: true})
+		a.IsTrue(recorder.Code == http.StatusBadGateway)
+		a.IsTrue(request.backendRetries() == 0)
+	}
+
+	// 每次尝试的超时时间
+	{
+		recorder, request := call(http.MethodGet, strings.TrimPrefix(slowServer.URL, "http://"), &teaconfigs.RetryConfig{On: true, TryTimeout: "100ms", Errors: []string{teaconfigs.RetryErrorTimeout}})
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "ok")
+		a.IsTrue(request.backendRetries() == 1)
+	}
+
+	// 不重试非幂等的请求
+	{
+		recorder, request := call(http.MethodPost, deadAddress, &teaconfigs.RetryConfig{On: true})
+		a.IsTrue(recorder.Code == http.StatusBadGateway)
+		a.IsTrue(request.backendRetries() == 0)
+	}
+
+	// 没有开启重试
+	{
+		recorder, request := call(http.MethodGet, deadAddress, nil)
+		a.IsTrue(recorder.Code == http.StatusBadGateway)
+		a.IsTrue(request.backendRetries() == 0)
+	}
+}
+
+func TestRequest_CallBackendRetryTimeout(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		writer.WriteHeader(http.StatusBadGateway)
+	}))
+	defer slowServer.Close()
+
+	// 所有尝试共用整个请求的超时时间
+	list := &teaconfigs.BackendList{}
+	for i := 0; i < 2; i++ {
+		list.AddBackend(&teaconfigs.BackendConfig{On: true, Address: strings.TrimPrefix(slowServer.URL, "http://"), Timeout: "300ms"})
+	}
+	list.SetRetryConfig(&teaconfigs.RetryConfig{On: true, StatusCodes: []int{http.StatusBadGateway}})
+	a.IsNil(list.ValidateBackends())
+
+	request := testNewRequest(http.MethodGet, "http://example.com/hello", nil)
+	request.backend = list.Backends[0]
+	request.backendList = list
+
+	recorder := httptest.NewRecorder()
+	before := time.Now()
+	a.IsNil(request.callBackend(NewResponseWriter(recorder)))
+	a.IsTrue(time.Since(before) < 400*time.Millisecond)
+	a.IsTrue(recorder.Code == http.StatusGatewayTimeout)
+	a.IsTrue(request.backendRetries() == 1)
+}
+
+func TestBackendErrorType(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	_, err = http.Get("http://" + address)
+	a.IsNotNil(err)
+	a.IsTrue(backendErrorType(err) == teaconfigs.RetryErrorConnect)
+
+	a.IsTrue(backendErrorType(errBackendTryTimeout) == teaconfigs.RetryErrorTimeout)
+	a.IsTrue(backendErrorType(net.ErrClosed) == teaconfigs.RetryErrorOther)
+}
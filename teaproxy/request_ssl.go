@@ -0,0 +1,97 @@
+package teaproxy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+)
+
+// TLS协议版本，比如 TLS 1.2
+func (this *Request) requestSSLProtocol() string {
+	if this.raw.TLS == nil {
+		return ""
+	}
+	return tls.VersionName(this.raw.TLS.Version)
+}
+
+// TLS加密算法套件
+func (this *Request) requestSSLCipher() string {
+	if this.raw.TLS == nil {
+		return ""
+	}
+	return tls.CipherSuiteName(this.raw.TLS.CipherSuite)
+}
+
+// 客户端证书
+func (this *Request) requestSSLClientCert() *x509.Certificate {
+	if this.raw.TLS == nil || len(this.raw.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return this.raw.TLS.PeerCertificates[0]
+}
+
+// 客户端证书的主体
+func (this *Request) requestSSLClientSubject() string {
+	cert := this.requestSSLClientCert()
+	if cert == nil {
+		return ""
+	}
+	return cert.Subject.String()
+}
+
+// 客户端证书的颁发者
+func (this *Request) requestSSLClientIssuer() string {
+	cert := this.requestSSLClientCert()
+	if cert == nil {
+		return ""
+	}
+	return cert.Issuer.String()
+}
+
+// 客户端证书的序列号
+func (this *Request) requestSSLClientSerial() string {
+	cert := this.requestSSLClientCert()
+	if cert == nil {
+		return ""
+	}
+	return fmt.Sprintf("%X", cert.SerialNumber)
+}
+
+// 客户端证书的SHA256指纹
+func (this *Request) requestSSLClientFingerprint() string {
+	cert := this.requestSSLClientCert()
+	if cert == nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+// 客户端证书的校验结果：SUCCESS, NONE, NOT_VERIFIED
+func (this *Request) requestSSLClientVerify() string {
+	if this.requestSSLClientCert() == nil {
+		return "NONE"
+	}
+	if len(this.raw.TLS.VerifiedChains) > 0 {
+		return "SUCCESS"
+	}
+	return "NOT_VERIFIED"
+}
+
+// 连接后端服务的TLS配置，没有设置SNI域名时使用请求的域名
+func (this *Request) backendTLSConfig() *tls.Config {
+	config := this.backend.TLSConfig()
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if len(config.ServerName) == 0 {
+		host, _, err := net.SplitHostPort(this.host)
+		if err != nil {
+			host = this.host
+		}
+		config.ServerName = host
+	}
+	return config
+}
@@ -6,9 +6,12 @@ import (
 	"github.com/TeaWeb/code/teaconfigs/shared"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/assert"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"sync"
 	"testing"
@@ -26,6 +29,17 @@ func testNewResponseWriter(a *assert.Assertion) *ResponseWriter {
 	})
 }
 
+// 构造测试用的请求
+func testNewRequest(method string, url string, body io.Reader) *Request {
+	raw := httptest.NewRequest(method, url, body)
+	raw.RemoteAddr = "127.0.0.1:1234"
+	req := NewRequest(raw)
+	req.scheme = "http"
+	req.host = raw.Host
+	req.uri = raw.URL.RequestURI()
+	return req
+}
+
 func (this *testResponseWriter) Header() http.Header {
 	return http.Header{}
 }
@@ -69,6 +83,86 @@ func TestRequest_CallRoot(t *testing.T) {
 	a.Log("bytes send:", writer.SentBodyBytes())
 }
 
+func TestRequest_CallRootRange(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	dir, err := ioutil.TempDir("", "teaweb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(dir+"/test.txt", []byte("0123456789"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(header http.Header) *httptest.ResponseRecorder {
+		rawRequest := httptest.NewRequest(http.MethodGet, "/test.txt", nil)
+		for k, v := range header {
+			rawRequest.Header[k] = v
+		}
+		recorder := httptest.NewRecorder()
+		request := NewRequest(rawRequest)
+		request.root = dir
+		request.uri = "/test.txt"
+		err := request.call(NewResponseWriter(recorder))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+
+	// 完整内容
+	{
+		recorder := call(http.Header{})
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "0123456789")
+		a.IsTrue(recorder.Header().Get("Accept-Ranges") == "bytes")
+	}
+
+	// 单个范围
+	{
+		recorder := call(http.Header{"Range": {"bytes=2-5"}})
+		a.IsTrue(recorder.Code == http.StatusPartialContent)
+		a.IsTrue(recorder.Body.String() == "2345")
+		a.IsTrue(recorder.Header().Get("Content-Range") == "bytes 2-5/10")
+	}
+
+	// 多个范围
+	{
+		recorder := call(http.Header{"Range": {"bytes=0-1,8-"}})
+		a.IsTrue(recorder.Code == http.StatusPartialContent)
+		a.IsTrue(strings.HasPrefix(recorder.Header().Get("Content-Type"), "multipart/byteranges"))
+	}
+
+	// 超出范围
+	{
+		recorder := call(http.Header{"Range": {"bytes=20-30"}})
+		a.IsTrue(recorder.Code == http.StatusRequestedRangeNotSatisfiable)
+	}
+
+	// If-Range不匹配时返回完整内容
+	{
+		recorder := call(http.Header{"Range": {"bytes=2-5"}, "If-Range": {"\"abc\""}})
+		a.IsTrue(recorder.Code == http.StatusOK)
+		a.IsTrue(recorder.Body.String() == "0123456789")
+	}
+
+	// If-Modified-Since使用日期比较
+	{
+		recorder := call(http.Header{"If-Modified-Since": {time.Now().Add(1 * time.Hour).UTC().Format(http.TimeFormat)}})
+		a.IsTrue(recorder.Code == http.StatusNotModified)
+	}
+
+	// If-None-Match
+	{
+		eTag := call(http.Header{}).Header().Get("ETag")
+		recorder := call(http.Header{"If-None-Match": {eTag}})
+		a.IsTrue(recorder.Code == http.StatusNotModified)
+	}
+}
+
 func TestRequest_CallBackend(t *testing.T) {
 	a := assert.NewAssertion(t).Quiet()
 	writer := testNewResponseWriter(a)
@@ -219,6 +313,77 @@ func TestRequest_Format(t *testing.T) {
 	t.Log(req.Format("hello ${teaVersion} remoteAddr:${remoteAddr} name:${arg.name} header:${header.Content-Type} test:${test}"))
 }
 
+func TestRequest_ForwardedHeaders(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	server := teaconfigs.NewServerConfig()
+	server.TrustedProxies = []string{"10.0.0.0/8"}
+	a.IsNil(server.Validate())
+
+	newRequest := func(remoteAddr string, header http.Header) *Request {
+		raw, err := http.NewRequest(http.MethodGet, "http://example.com/hello", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw.RemoteAddr = remoteAddr
+		for k, v := range header {
+			raw.Header[k] = v
+		}
+		req := NewRequest(raw)
+		req.server = server
+		req.host = "example.com"
+		req.rawScheme = "https"
+		return req
+	}
+
+	// 不受信任的地址伪造的Header
+	{
+		req := newRequest("1.2.3.4:1234", http.Header{
+			"X-Real-Ip":       []string{"5.6.7.8"},
+			"X-Forwarded-For": []string{"5.6.7.8"},
+		})
+		a.IsTrue(req.requestRemoteAddr() == "1.2.3.4")
+
+		header := http.Header{}
+		req.setForwardedHeaders(header)
+		a.IsTrue(header.Get("X-Real-IP") == "1.2.3.4")
+		a.IsTrue(header.Get("X-Forwarded-For") == "5.6.7.8, 1.2.3.4")
+		a.IsTrue(header.Get("X-Forwarded-Host") == "example.com")
+		a.IsTrue(header.Get("X-Forwarded-Proto") == "https")
+		a.IsTrue(header.Get("Forwarded") == `for=1.2.3.4;host="example.com";proto=https`)
+	}
+
+	// 受信任的代理
+	{
+		req := newRequest("10.0.0.1:1234", http.Header{
+			"X-Forwarded-For": []string{"5.6.7.8, 10.0.0.2"},
+			"Forwarded":       []string{"for=5.6.7.8"},
+		})
+		a.IsTrue(req.requestRemoteAddr() == "5.6.7.8")
+
+		req.setForwardedHeaders(req.raw.Header)
+		a.IsTrue(req.raw.Header.Get("X-Real-IP") == "5.6.7.8")
+		a.IsTrue(req.raw.Header.Get("X-Forwarded-For") == "5.6.7.8, 10.0.0.2, 10.0.0.1")
+		a.IsTrue(req.raw.Header.Get("Forwarded") == `for=5.6.7.8, for=10.0.0.1;host="example.com";proto=https`)
+
+		// 修改Header之后不会重复追加
+		req.setForwardedHeaders(req.raw.Header)
+		a.IsTrue(req.raw.Header.Get("X-Forwarded-For") == "5.6.7.8, 10.0.0.2, 10.0.0.1")
+		a.IsTrue(req.requestRemoteAddr() == "5.6.7.8")
+	}
+
+	// IPv6
+	{
+		req := newRequest("[2001:db8::1]:1234", nil)
+		a.IsTrue(req.requestRemoteAddr() == "2001:db8::1")
+
+		header := http.Header{}
+		req.setForwardedHeaders(header)
+		a.IsTrue(header.Get("X-Forwarded-For") == "2001:db8::1")
+		a.IsTrue(header.Get("Forwarded") == `for="[2001:db8::1]";host="example.com";proto=https`)
+	}
+}
+
 func TestRequest_FormatPerformance(t *testing.T) {
 	rawReq, err := http.NewRequest("GET", "http://www.example.com/hello/world?name=Lu&age=20", bytes.NewBuffer([]byte("hello=world")))
 	if err != nil {
@@ -2,7 +2,12 @@ package teaproxy
 
 import (
 	"bytes"
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"io"
 	"net/http"
+	"strings"
 )
 
 // 响应Writer
@@ -14,6 +19,15 @@ type ResponseWriter struct {
 
 	bodyCopying bool
 	body        []byte
+
+	// 压缩相关
+	compression         *teaconfigs.CompressionConfig
+	compressionEncoding string
+	compressor          io.WriteCloser
+	compressionBuffer   []byte // 内容长度未知时缓冲的数据，达到最小压缩长度后才开始压缩
+	compressionBuffing  bool
+	headerWritten       bool
+	closed              bool
 }
 
 // 包装对象
@@ -48,8 +62,48 @@ func (this *ResponseWriter) AddHeaders(header http.Header) {
 	}
 }
 
+// 设置压缩，encoding为空表示客户端不支持压缩
+func (this *ResponseWriter) SetCompression(config *teaconfigs.CompressionConfig, encoding string) {
+	this.compression = config
+	this.compressionEncoding = encoding
+}
+
 // 写入数据
 func (this *ResponseWriter) Write(data []byte) (n int, err error) {
+	if !this.headerWritten {
+		this.WriteHeader(http.StatusOK)
+	}
+
+	// 缓冲
+	if this.compressionBuffing {
+		this.compressionBuffer = append(this.compressionBuffer, data ...)
+		if int64(len(this.compressionBuffer)) >= this.compression.MinBytes() {
+			this.compressionBuffing = false
+			this.startCompression()
+			buf := this.compressionBuffer
+			this.compressionBuffer = nil
+			_, err = this.writeCompressed(buf)
+		}
+		return len(data), err
+	}
+
+	if this.compressor != nil {
+		return this.writeCompressed(data)
+	}
+
+	return this.writeRaw(data)
+}
+
+// 写入压缩的数据
+func (this *ResponseWriter) writeCompressed(data []byte) (n int, err error) {
+	if this.compressor == nil {
+		return this.writeRaw(data)
+	}
+	return this.compressor.Write(data)
+}
+
+// 直接写入数据
+func (this *ResponseWriter) writeRaw(data []byte) (n int, err error) {
 	if this.writer != nil {
 		n, err = this.writer.Write(data)
 		if n > 0 {
@@ -73,10 +127,166 @@ func (this *ResponseWriter) SentBodyBytes() int64 {
 
 // 写入状态码
 func (this *ResponseWriter) WriteHeader(statusCode int) {
+	if this.headerWritten {
+		return
+	}
+	this.headerWritten = true
+	this.statusCode = statusCode
+
+	if this.compression != nil && this.prepareCompression(statusCode) {
+		return
+	}
+
 	if this.writer != nil {
 		this.writer.WriteHeader(statusCode)
 	}
-	this.statusCode = statusCode
+}
+
+// 判断是否可以压缩，如果需要缓冲数据则返回true
+func (this *ResponseWriter) prepareCompression(statusCode int) (buffering bool) {
+	header := this.Header()
+
+	// 不需要压缩的状态码
+	if statusCode < 200 || statusCode >= 300 || statusCode == http.StatusNoContent || statusCode == http.StatusPartialContent {
+		return false
+	}
+
+	// 已经编码
+	if len(header.Get("Content-Encoding")) > 0 || len(header.Get("Content-Range")) > 0 {
+		return false
+	}
+	if strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	if !this.compression.MatchMimeType(header.Get("Content-Type")) {
+		return false
+	}
+
+	// 内容会根据Accept-Encoding变化
+	this.addVary("Accept-Encoding")
+
+	if len(this.compressionEncoding) == 0 {
+		return false
+	}
+
+	// 检查长度
+	minBytes := this.compression.MinBytes()
+	contentLength := header.Get("Content-Length")
+	if len(contentLength) > 0 {
+		length := int64(-1)
+		fmt.Sscanf(contentLength, "%d", &length)
+		if length >= 0 && length < minBytes {
+			return false
+		}
+	} else if minBytes > 0 {
+		this.compressionBuffing = true
+		return true
+	}
+
+	this.startCompression()
+	return false
+}
+
+// 开始压缩
+func (this *ResponseWriter) startCompression() {
+	header := this.Header()
+
+	var err error
+	if this.writer != nil {
+		this.compressor, err = newCompressionWriter(writerFunc(this.writeRaw), this.compressionEncoding, this.compression.LevelForEncoding(this.compressionEncoding))
+		if err != nil {
+			logs.Error(err)
+			this.compressor = nil
+		}
+	}
+
+	if this.compressor != nil {
+		header.Set("Content-Encoding", this.compressionEncoding)
+		header.Del("Content-Length")
+
+		// 压缩后的内容和原内容不是逐字节相同的，所以使用弱ETag
+		eTag := header.Get("ETag")
+		if len(eTag) > 0 && !strings.HasPrefix(eTag, "W/") {
+			header.Set("ETag", "W/"+eTag)
+		}
+	}
+
+	if this.writer != nil {
+		this.writer.WriteHeader(this.statusCode)
+	}
+}
+
+// 添加Vary
+func (this *ResponseWriter) addVary(name string) {
+	header := this.Header()
+	for _, vary := range header["Vary"] {
+		for _, piece := range strings.Split(vary, ",") {
+			piece = strings.TrimSpace(piece)
+			if piece == "*" || strings.EqualFold(piece, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// 结束写入，如果有压缩的话会写入剩余的压缩数据
+func (this *ResponseWriter) Close() {
+	if this.closed {
+		return
+	}
+	this.closed = true
+
+	// 没有达到最小压缩长度
+	if this.compressionBuffing {
+		this.compressionBuffing = false
+		buf := this.compressionBuffer
+		this.compressionBuffer = nil
+		if this.writer != nil {
+			this.Header().Set("Content-Length", fmt.Sprintf("%d", len(buf)))
+			this.writer.WriteHeader(this.statusCode)
+		}
+		this.writeRaw(buf)
+		return
+	}
+
+	if this.compressor != nil {
+		err := this.compressor.Close()
+		if err != nil {
+			logs.Error(err)
+		}
+	}
+}
+
+// 将缓冲的数据立即发送给客户端，用于流式响应
+func (this *ResponseWriter) Flush() {
+	if !this.headerWritten {
+		this.WriteHeader(http.StatusOK)
+	}
+
+	// 长度未知的流式内容不再等待最小压缩长度
+	if this.compressionBuffing {
+		this.compressionBuffing = false
+		this.startCompression()
+		buf := this.compressionBuffer
+		this.compressionBuffer = nil
+		this.writeCompressed(buf)
+	}
+
+	if this.compressor != nil {
+		flusher, ok := this.compressor.(interface{ Flush() error })
+		if ok {
+			err := flusher.Flush()
+			if err != nil {
+				logs.Error(err)
+			}
+		}
+	}
+
+	if flusher, ok := this.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 // 读取状态码
@@ -123,3 +333,24 @@ func (this *ResponseWriter) HeaderData() []byte {
 	resp.Write(writer)
 	return writer.Bytes()
 }
+
+// 拷贝流式内容，每次读取到数据后立即发送给客户端
+func copyStream(writer *ResponseWriter, reader io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			_, writeErr := writer.Write(buf[:n])
+			if writeErr != nil {
+				return writeErr
+			}
+			writer.Flush()
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package teaproxy
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// 记录子请求的响应
+type responseRecorder struct {
+	header     http.Header
+	body       *bytes.Buffer
+	statusCode int
+}
+
+// 获取新对象
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{
+		header: http.Header{},
+		body:   bytes.NewBuffer([]byte{}),
+	}
+}
+
+func (this *responseRecorder) Header() http.Header {
+	return this.header
+}
+
+func (this *responseRecorder) Write(data []byte) (int, error) {
+	if this.statusCode == 0 {
+		this.statusCode = http.StatusOK
+	}
+	return this.body.Write(data)
+}
+
+func (this *responseRecorder) WriteHeader(statusCode int) {
+	if this.statusCode == 0 {
+		this.statusCode = statusCode
+	}
+}
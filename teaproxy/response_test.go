@@ -2,7 +2,13 @@ package teaproxy
 
 import (
 	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -24,3 +30,121 @@ func TestResponseWriterHeader(t *testing.T) {
 	resp.Write(writer)
 	t.Log(string(writer.Bytes()))
 }
+
+func TestResponseWriterCompression(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := teaconfigs.NewCompressionConfig()
+	err := config.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := bytes.Repeat([]byte("hello, world "), 1024)
+
+	// gzip
+	{
+		recorder := httptest.NewRecorder()
+		writer := NewResponseWriter(recorder)
+		writer.SetCompression(config, teaconfigs.CompressionEncodingGzip)
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+		writer.WriteHeader(http.StatusOK)
+		writer.Write(body)
+		writer.Close()
+
+		a.IsTrue(recorder.Header().Get("Content-Encoding") == "gzip")
+		a.IsTrue(len(recorder.Header().Get("Content-Length")) == 0)
+		a.IsTrue(recorder.Header().Get("Vary") == "Accept-Encoding")
+
+		reader, err := gzip.NewReader(recorder.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.IsTrue(bytes.Equal(data, body))
+		a.IsTrue(writer.SentBodyBytes() < int64(len(body)))
+	}
+
+	// 未知长度时缓冲，不足最小长度不压缩
+	{
+		recorder := httptest.NewRecorder()
+		writer := NewResponseWriter(recorder)
+		writer.SetCompression(config, teaconfigs.CompressionEncodingGzip)
+		writer.Header().Set("Content-Type", "text/html")
+		writer.Write([]byte("hello"))
+		writer.Close()
+
+		a.IsTrue(len(recorder.Header().Get("Content-Encoding")) == 0)
+		a.IsTrue(recorder.Body.String() == "hello")
+	}
+
+	// 不支持的类型
+	{
+		recorder := httptest.NewRecorder()
+		writer := NewResponseWriter(recorder)
+		writer.SetCompression(config, teaconfigs.CompressionEncodingGzip)
+		writer.Header().Set("Content-Type", "image/png")
+		writer.Write(body)
+		writer.Close()
+
+		a.IsTrue(len(recorder.Header().Get("Content-Encoding")) == 0)
+		a.IsTrue(len(recorder.Header().Get("Vary")) == 0)
+		a.IsTrue(recorder.Body.Len() == len(body))
+	}
+
+	// 已经编码
+	{
+		recorder := httptest.NewRecorder()
+		writer := NewResponseWriter(recorder)
+		writer.SetCompression(config, teaconfigs.CompressionEncodingBrotli)
+		writer.Header().Set("Content-Type", "text/html")
+		writer.Header().Set("Content-Encoding", "gzip")
+		writer.Write(body)
+		writer.Close()
+
+		a.IsTrue(recorder.Header().Get("Content-Encoding") == "gzip")
+		a.IsTrue(recorder.Body.Len() == len(body))
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	config := teaconfigs.NewCompressionConfig()
+	config.MinLength = "1k"
+	err := config.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	writer := NewResponseWriter(recorder)
+	writer.SetCompression(config, teaconfigs.CompressionEncodingGzip)
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	writer.Write([]byte("hello"))
+	a.IsTrue(recorder.Body.Len() == 0)
+
+	// 流式内容不再等待达到最小压缩长度
+	writer.Flush()
+	a.IsTrue(recorder.Flushed)
+	a.IsTrue(recorder.Header().Get("Content-Encoding") == "gzip")
+	a.IsTrue(recorder.Body.Len() > 0)
+
+	writer.Write([]byte(", world"))
+	writer.Close()
+
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(string(data) == "hello, world")
+}
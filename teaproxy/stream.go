@@ -0,0 +1,306 @@
+package teaproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaconst"
+	"github.com/TeaWeb/code/tealogs"
+	"github.com/TeaWeb/code/teautils"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TCP/UDP代理服务
+type streamServer interface {
+	// 等待已有的连接处理完毕，超时后强制关闭
+	shutdown(ctx context.Context) error
+}
+
+// 启动TCP/UDP代理
+func (this *Listener) startStream() {
+	config := this.currentConfig()
+	if config.Stream.IsUDP() {
+		this.startUDPStream(config)
+		return
+	}
+
+	var tlsConfig *tls.Config
+	switch config.Stream.TLS {
+	case teaconfigs.StreamTLSModeTerminate:
+		logs.Println("start tls stream listener on", config.Address)
+		err := this.loadCertificates(config)
+		if err != nil {
+			logs.Error(err)
+			this.closeInheritedListener()
+			return
+		}
+		tlsConfig = &tls.Config{
+			GetConfigForClient: this.streamTLSConfig,
+		}
+	case teaconfigs.StreamTLSModeSNI:
+		logs.Println("start sni stream listener on", config.Address)
+	default:
+		logs.Println("start tcp stream listener on", config.Address)
+	}
+
+	netListener, err := this.listen(config.Address)
+	if err != nil {
+		logs.Error(err)
+		return
+	}
+
+	server := newTCPStreamServer(this, tlsConfig)
+
+	this.locker.Lock()
+	if this.closing {
+		this.locker.Unlock()
+		netListener.Close()
+		return
+	}
+	this.stream = server
+	this.netListener = netListener
+	this.locker.Unlock()
+
+	// PROXY协议，重新加载配置后可以随时开启或关闭
+	serveListener := newProxyProtocolListener(netListener, func() bool {
+		return this.currentConfig().ProxyProtocol
+	})
+	err = server.serve(serveListener)
+	if err != nil && !this.isClosing() {
+		logs.Error(err)
+	}
+}
+
+// 启动UDP代理
+func (this *Listener) startUDPStream(config *teaconfigs.ListenerConfig) {
+	this.locker.Lock()
+	packetConn := this.inheritedUDPConn
+	this.inheritedUDPConn = nil
+	this.locker.Unlock()
+
+	if packetConn != nil {
+		logs.Println("inherit udp stream listener on", config.Address)
+	} else {
+		logs.Println("start udp stream listener on", config.Address)
+		var err error
+		packetConn, err = net.ListenPacket("udp", config.Address)
+		if err != nil {
+			logs.Error(err)
+			return
+		}
+	}
+
+	server := newUDPStreamServer(this, packetConn)
+
+	this.locker.Lock()
+	if this.closing {
+		this.locker.Unlock()
+		packetConn.Close()
+		return
+	}
+	this.stream = server
+	this.packetConn = packetConn
+	this.locker.Unlock()
+
+	err := server.serve()
+	if err != nil && !this.isClosing() {
+		logs.Error(err)
+	}
+}
+
+// 根据SNI选择服务的TLS配置，解密后的数据不是HTTP，所以不协商应用层协议
+func (this *Listener) streamTLSConfig(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	config, err := this.certs.getConfigForClient(hello)
+	if err != nil {
+		return nil, err
+	}
+	config = config.Clone()
+	config.NextProtos = nil
+	return config, nil
+}
+
+// TCP/UDP代理的一个连接或者会话
+type streamSession struct {
+	protocol   string // tcp, udp
+	server     *teaconfigs.ServerConfig
+	backend    *teaconfigs.BackendConfig
+	remoteAddr string // 终端地址，ip:port
+	serverAddr string // 监听地址
+	serverName string // 客户端发送的SNI
+	tlsState   *tls.ConnectionState
+
+	startTime     time.Time
+	bytesSent     int64 // 发送到终端的字节数
+	bytesReceived int64 // 从终端接收的字节数
+	status        int   // 状态码，参考nginx stream模块：200, 400, 403, 502, 503
+}
+
+// 获取新对象
+func newStreamSession(protocol string, remoteAddr string, serverAddr string) *streamSession {
+	return &streamSession{
+		protocol:   protocol,
+		remoteAddr: remoteAddr,
+		serverAddr: serverAddr,
+		startTime:  time.Now(),
+		status:     http.StatusOK,
+	}
+}
+
+// 终端IP
+func (this *streamSession) remoteIP() string {
+	host, _, err := net.SplitHostPort(this.remoteAddr)
+	if err != nil {
+		return this.remoteAddr
+	}
+	return host
+}
+
+// 终端端口
+func (this *streamSession) remotePort() int {
+	_, port, err := net.SplitHostPort(this.remoteAddr)
+	if err != nil {
+		return 0
+	}
+	return types.Int(port)
+}
+
+// 监听端口
+func (this *streamSession) serverPort() int {
+	_, port, err := net.SplitHostPort(this.serverAddr)
+	if err != nil {
+		return 0
+	}
+	return types.Int(port)
+}
+
+// 格式化变量，用于调度算法中的Key，比如 ${remoteAddr}
+func (this *streamSession) Format(source string) string {
+	if len(source) == 0 {
+		return ""
+	}
+	return teautils.ParseVariables(source, func(varName string) string {
+		switch varName {
+		case "teaVersion":
+			return teaconst.TeaVersion
+		case "remoteAddr":
+			return this.remoteIP()
+		case "remotePort":
+			return fmt.Sprintf("%d", this.remotePort())
+		case "serverName", "host":
+			return this.serverName
+		case "serverPort":
+			return fmt.Sprintf("%d", this.serverPort())
+		case "serverId":
+			if this.server != nil {
+				return this.server.Id
+			}
+			return ""
+		}
+		return "${" + varName + "}"
+	})
+}
+
+// 连接后端服务，连接失败时增加失败次数，开启重试时换其他的后端服务再次连接
+// 连接成功后会增加后端服务的连接数，调用者需要在结束时调用DecreaseConn()
+func (this *streamSession) dialBackend() (net.Conn, error) {
+	backendList := &this.server.BackendList
+	backend := backendList.NextBackend(maps.Map{
+		"formatter": this.Format,
+	})
+	if backend == nil {
+		return nil, errNoBackendsAvailable
+	}
+
+	maxTries := 1
+	if backendList.Retry != nil && backendList.Retry.On && backendList.Retry.MaxTries > 1 {
+		maxTries = backendList.Retry.MaxTries
+	}
+
+	triedBackends := []*teaconfigs.BackendConfig{}
+	for {
+		this.backend = backend
+		triedBackends = append(triedBackends, backend)
+
+		dialFrom := time.Now()
+		conn, err := net.DialTimeout(this.protocol, backend.Address, backend.ConnectTimeoutDuration())
+		if err == nil {
+			backend.RecordResponseTime(time.Since(dialFrom))
+			backend.IncreaseConn()
+			return conn, nil
+		}
+		if backend.RecordFail() {
+			backendList.SetupScheduling(false)
+		}
+
+		if len(triedBackends) >= maxTries {
+			return nil, err
+		}
+		backend = backendList.NextRetryBackend(maps.Map{
+			"formatter": this.Format,
+		}, triedBackends)
+		if backend == nil {
+			return nil, err
+		}
+	}
+}
+
+// 记录从终端接收的字节数
+func (this *streamSession) addBytesReceived(n int) {
+	atomic.AddInt64(&this.bytesReceived, int64(n))
+}
+
+// 记录发送到终端的字节数
+func (this *streamSession) addBytesSent(n int) {
+	atomic.AddInt64(&this.bytesSent, int64(n))
+}
+
+// 写入访问日志
+func (this *streamSession) log() {
+	bytesSent := atomic.LoadInt64(&this.bytesSent)
+	accessLog := &tealogs.AccessLog{
+		TeaVersion:     teaconst.TeaVersion,
+		RemoteAddr:     this.remoteIP(),
+		RemotePort:     this.remotePort(),
+		RequestTime:    time.Since(this.startTime).Seconds(),
+		Scheme:         this.protocol,
+		Proto:          strings.ToUpper(this.protocol),
+		BytesSent:      bytesSent,
+		BodyBytesSent:  bytesSent,
+		BytesReceived:  atomic.LoadInt64(&this.bytesReceived),
+		Status:         this.status,
+		TimeISO8601:    this.startTime.Format("2006-01-02T15:04:05.000Z07:00"),
+		TimeLocal:      this.startTime.Format("2/Jan/2006:15:04:05 -0700"),
+		Msec:           float64(this.startTime.Unix()) + float64(this.startTime.Nanosecond())/1000000000,
+		Timestamp:      this.startTime.Unix(),
+		Host:           this.serverName,
+		ServerName:     this.serverName,
+		ServerPort:     this.serverPort(),
+		ServerProtocol: strings.ToUpper(this.protocol),
+	}
+
+	if this.server != nil {
+		accessLog.ServerId = this.server.Id
+	}
+	if this.backend != nil {
+		accessLog.BackendAddress = this.backend.Address
+		accessLog.BackendId = this.backend.Id
+	}
+	if this.tlsState != nil {
+		accessLog.SSLProtocol = tls.VersionName(this.tlsState.Version)
+		accessLog.SSLCipher = tls.CipherSuiteName(this.tlsState.CipherSuite)
+	}
+
+	if this.server != nil {
+		writeAccessLog(this.server.AccessLog, accessLog)
+	}
+
+	tealogs.SharedLogger().Push(accessLog)
+}
@@ -0,0 +1,260 @@
+package teaproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 转发数据时使用的缓冲区尺寸
+const streamBufferSize = 32 * 1024
+
+// 等待TLS握手或者读取ClientHello的最长时间
+const streamHandshakeTimeout = 10 * time.Second
+
+// 读取到ClientHello后终止握手
+var errStreamHelloRead = errors.New("client hello has been read")
+
+// TCP代理服务
+type tcpStreamServer struct {
+	listener  *Listener
+	tlsConfig *tls.Config // 解密时使用的TLS配置，为nil表示不解密
+
+	conns  map[net.Conn]bool // 正在处理的连接
+	wg     sync.WaitGroup
+	locker sync.Mutex
+}
+
+// 获取新对象
+func newTCPStreamServer(listener *Listener, tlsConfig *tls.Config) *tcpStreamServer {
+	return &tcpStreamServer{
+		listener:  listener,
+		tlsConfig: tlsConfig,
+		conns:     map[net.Conn]bool{},
+	}
+}
+
+// 接受连接，直到监听被关闭
+func (this *tcpStreamServer) serve(netListener net.Listener) error {
+	for {
+		conn, err := netListener.Accept()
+		if err != nil {
+			// 文件描述符不足等临时错误，参考http.Server
+			if tempErr, ok := err.(interface{ Temporary() bool }); ok && tempErr.Temporary() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		this.locker.Lock()
+		this.conns[conn] = true
+		this.wg.Add(1)
+		this.locker.Unlock()
+
+		go this.handle(conn)
+	}
+}
+
+// 等待已有的连接处理完毕，超时后强制关闭
+func (this *tcpStreamServer) shutdown(ctx context.Context) error {
+	done := make(chan bool)
+	go func() {
+		this.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		this.locker.Lock()
+		for conn := range this.conns {
+			conn.Close()
+		}
+		this.locker.Unlock()
+	}
+	return nil
+}
+
+// 处理连接
+func (this *tcpStreamServer) handle(rawConn net.Conn) {
+	defer func() {
+		rawConn.Close()
+
+		this.locker.Lock()
+		delete(this.conns, rawConn)
+		this.locker.Unlock()
+		this.wg.Done()
+	}()
+
+	config := this.listener.currentConfig()
+	session := newStreamSession(teaconfigs.StreamProtocolTCP, rawConn.RemoteAddr().String(), config.Address)
+
+	clientConn := rawConn
+	var helloData []byte // SNI路由时已经读取的ClientHello
+	if this.tlsConfig != nil {
+		tlsConn := tls.Server(rawConn, this.tlsConfig)
+		tlsConn.SetDeadline(time.Now().Add(streamHandshakeTimeout))
+		err := tlsConn.Handshake()
+		if err != nil {
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+
+		state := tlsConn.ConnectionState()
+		session.tlsState = &state
+		session.serverName = state.ServerName
+		clientConn = tlsConn
+	} else if config.Stream.TLS == teaconfigs.StreamTLSModeSNI {
+		rawConn.SetReadDeadline(time.Now().Add(streamHandshakeTimeout))
+		serverName, data, err := readStreamServerName(rawConn)
+		if err != nil {
+			return
+		}
+		rawConn.SetReadDeadline(time.Time{})
+
+		session.serverName = serverName
+		helloData = data
+	}
+
+	server, _ := config.FindNamedServer(session.serverName)
+	if server == nil {
+		return
+	}
+	session.server = server
+	defer session.log()
+
+	if !server.AllowIP(session.remoteIP()) {
+		session.status = http.StatusForbidden
+		return
+	}
+
+	backendConn, err := session.dialBackend()
+	if err != nil {
+		if err == errNoBackendsAvailable {
+			session.status = http.StatusServiceUnavailable
+		} else {
+			session.status = http.StatusBadGateway
+			logs.Error(errors.New(config.Key + ": " + err.Error()))
+		}
+		return
+	}
+	defer session.backend.DecreaseConn()
+	defer backendConn.Close()
+
+	if len(helloData) > 0 {
+		_, err = backendConn.Write(helloData)
+		if err != nil {
+			session.status = http.StatusBadGateway
+			return
+		}
+		session.addBytesReceived(len(helloData))
+	}
+
+	pipeStream(session, clientConn, backendConn, config.Stream.IdleTimeoutDuration())
+}
+
+// 在终端和后端服务之间双向转发数据，两个方向都超过空闲时间没有数据时关闭连接
+func pipeStream(session *streamSession, clientConn net.Conn, backendConn net.Conn, idleTimeout time.Duration) {
+	lastActive := time.Now().UnixNano()
+
+	done := make(chan bool, 1)
+	go func() {
+		err := copyStreamConn(backendConn, clientConn, idleTimeout, &lastActive, session.addBytesReceived)
+		finishCopyStreamConn(backendConn, clientConn, err)
+		done <- true
+	}()
+
+	err := copyStreamConn(clientConn, backendConn, idleTimeout, &lastActive, session.addBytesSent)
+	finishCopyStreamConn(clientConn, backendConn, err)
+	<-done
+}
+
+// 从src复制数据到dst，直到src结束或者出错
+// 超过空闲时间没有读取到数据，并且另外一个方向也没有数据时，返回超时错误
+func copyStreamConn(dst net.Conn, src net.Conn, idleTimeout time.Duration, lastActive *int64, count func(n int)) error {
+	buf := make([]byte, streamBufferSize)
+	for {
+		if idleTimeout > 0 {
+			src.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			atomic.StoreInt64(lastActive, time.Now().UnixNano())
+			if idleTimeout > 0 {
+				dst.SetWriteDeadline(time.Now().Add(idleTimeout))
+			}
+			_, writeErr := dst.Write(buf[:n])
+			if writeErr != nil {
+				return writeErr
+			}
+			count(n)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			var netErr net.Error
+			if idleTimeout > 0 && errors.As(err, &netErr) && netErr.Timeout() &&
+				time.Since(time.Unix(0, atomic.LoadInt64(lastActive))) < idleTimeout {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// 一个方向的数据复制结束，正常结束时只关闭写入，以便另外一个方向可以继续传输，出错时关闭两个连接
+func finishCopyStreamConn(dst net.Conn, src net.Conn, err error) {
+	if err == nil {
+		if closer, ok := dst.(interface{ CloseWrite() error }); ok {
+			closer.CloseWrite()
+			return
+		}
+	}
+	dst.Close()
+	src.Close()
+}
+
+// 从TLS握手数据中读取SNI，不解密，已经读取的数据会同时返回，以便转发到后端服务
+func readStreamServerName(conn net.Conn) (serverName string, data []byte, err error) {
+	buffer := &bytes.Buffer{}
+	found := false
+	err = tls.Server(&streamHelloConn{
+		Conn:   conn,
+		reader: io.TeeReader(conn, buffer),
+	}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			found = true
+			return nil, errStreamHelloRead
+		},
+	}).Handshake()
+	if !found {
+		return "", nil, err
+	}
+	return serverName, buffer.Bytes(), nil
+}
+
+// 只用来读取ClientHello的连接，握手失败时不会向终端发送任何数据
+type streamHelloConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (this *streamHelloConn) Read(data []byte) (int, error) {
+	return this.reader.Read(data)
+}
+
+func (this *streamHelloConn) Write(data []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
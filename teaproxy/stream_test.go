@@ -0,0 +1,452 @@
+package teaproxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/assert"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStream_TCP(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backendAddress := testStreamEchoServer(t)
+	downAddress := testStreamFreeAddress(t)
+
+	server := testStreamServer(t, nil, downAddress, backendAddress)
+	server.Scheduling = &teaconfigs.SchedulingConfig{Code: "roundRobin"}
+	server.Retry = teaconfigs.NewRetryConfig()
+	for _, backend := range server.Backends {
+		backend.MaxFails = 1
+	}
+	a.IsNil(server.ValidateBackends())
+
+	address := testStreamFreeAddress(t)
+	stream := teaconfigs.NewStreamConfig()
+	stream.Listen = []string{address}
+	a.IsNil(stream.Validate())
+
+	listener := &Listener{
+		config: &teaconfigs.ListenerConfig{
+			Key:     stream.ListenerKey(address),
+			Address: address,
+			Stream:  stream,
+			Servers: []*teaconfigs.ServerConfig{server},
+		},
+	}
+	go listener.Start()
+	defer listener.Shutdown()
+
+	// 无法连接的后端服务会被下线，请求转发到其他的后端服务
+	for i := 0; i < 2; i++ {
+		conn := testStreamDial(t, "tcp", address)
+		_, err := conn.Write([]byte("hello"))
+		a.IsNil(err)
+		data := make([]byte, 5)
+		_, err = io.ReadFull(conn, data)
+		a.IsNil(err)
+		a.IsTrue(string(data) == "hello")
+		conn.Close()
+	}
+	a.IsTrue(server.FindBackend("down").IsDown)
+	a.IsFalse(server.FindBackend("backend1").IsDown)
+
+	// 关闭后不再接受新的连接
+	a.IsNil(listener.Shutdown())
+	_, err := net.DialTimeout("tcp", address, 1*time.Second)
+	a.IsNotNil(err)
+}
+
+func TestStream_PipeBytes(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	client, proxyClient := testStreamConnPair(t)
+	proxyBackend, backend := testStreamConnPair(t)
+
+	session := newStreamSession(teaconfigs.StreamProtocolTCP, "127.0.0.1:1234", "127.0.0.1:3306")
+	done := make(chan bool)
+	go func() {
+		pipeStream(session, proxyClient, proxyBackend, 0)
+		close(done)
+	}()
+
+	_, err := client.Write([]byte("hello"))
+	a.IsNil(err)
+	data := make([]byte, 5)
+	_, err = io.ReadFull(backend, data)
+	a.IsNil(err)
+
+	_, err = backend.Write([]byte("world!"))
+	a.IsNil(err)
+	data = make([]byte, 6)
+	_, err = io.ReadFull(client, data)
+	a.IsNil(err)
+
+	// 终端关闭写入后，后端服务仍然可以继续发送数据
+	client.(*net.TCPConn).CloseWrite()
+	_, err = ioutil.ReadAll(backend)
+	a.IsNil(err)
+	_, err = backend.Write([]byte("bye"))
+	a.IsNil(err)
+	backend.Close()
+	data, err = ioutil.ReadAll(client)
+	a.IsNil(err)
+	a.IsTrue(string(data) == "bye")
+
+	<-done
+	a.IsTrue(atomic.LoadInt64(&session.bytesReceived) == 5)
+	a.IsTrue(atomic.LoadInt64(&session.bytesSent) == 9)
+}
+
+func TestStream_IdleTimeout(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	client, proxyClient := testStreamConnPair(t)
+	proxyBackend, backend := testStreamConnPair(t)
+	defer backend.Close()
+
+	session := newStreamSession(teaconfigs.StreamProtocolTCP, "127.0.0.1:1234", "127.0.0.1:3306")
+	before := time.Now()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		client.Write([]byte("hello"))
+	}()
+	pipeStream(session, proxyClient, proxyBackend, 200*time.Millisecond)
+
+	// 有数据时重新计算空闲时间
+	a.IsTrue(time.Since(before) >= 300*time.Millisecond)
+	a.IsTrue(atomic.LoadInt64(&session.bytesReceived) == 5)
+
+	_, err := ioutil.ReadAll(client)
+	a.IsNil(err)
+}
+
+func TestStream_SNI(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	hello := testStreamClientHello(t, "b.example.com")
+
+	// 后端服务返回自己的名字，以及收到的数据是否和ClientHello一致
+	nameServer := func(name string) string {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				data := make([]byte, len(hello))
+				_, err = io.ReadFull(conn, data)
+				if err == nil && bytes.Equal(data, hello) {
+					conn.Write([]byte(name + ":ok"))
+				} else {
+					conn.Write([]byte(name + ":error"))
+				}
+				conn.Close()
+			}
+		}()
+		return ln.Addr().String()
+	}
+
+	serverA := testStreamServer(t, []string{"a.example.com"}, nameServer("a"))
+	serverB := testStreamServer(t, []string{"b.example.com"}, nameServer("b"))
+
+	address := testStreamFreeAddress(t)
+	stream := teaconfigs.NewStreamConfig()
+	stream.TLS = teaconfigs.StreamTLSModeSNI
+	a.IsNil(stream.Validate())
+
+	listener := &Listener{
+		config: &teaconfigs.ListenerConfig{
+			Key:     stream.ListenerKey(address),
+			Address: address,
+			Stream:  stream,
+			Servers: []*teaconfigs.ServerConfig{serverA, serverB},
+		},
+	}
+	go listener.Start()
+	defer listener.Shutdown()
+
+	conn := testStreamDial(t, "tcp", address)
+	defer conn.Close()
+	_, err := conn.Write(hello)
+	a.IsNil(err)
+	data, err := ioutil.ReadAll(conn)
+	a.IsNil(err)
+	a.IsTrue(string(data) == "b:ok")
+}
+
+func TestStream_TLSTerminate(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	server := testStreamServer(t, []string{"example.com"}, testStreamEchoServer(t))
+	server.SSL = &teaconfigs.SSLConfig{On: true}
+
+	stream := teaconfigs.NewStreamConfig()
+	stream.TLS = teaconfigs.StreamTLSModeTerminate
+	a.IsNil(stream.Validate())
+
+	listener := &Listener{
+		config: &teaconfigs.ListenerConfig{
+			Stream:  stream,
+			Servers: []*teaconfigs.ServerConfig{server},
+		},
+		certs: newCertificateStore(),
+	}
+	listener.certs.build([]*serverCertificate{
+		{
+			server: server,
+			cert:   testCertificate(t, "example.com"),
+		},
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go newTCPStreamServer(listener, &tls.Config{
+		GetConfigForClient: listener.streamTLSConfig,
+	}).serve(ln)
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		ServerName:         "example.com",
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 解密后的数据不是HTTP，不协商应用层协议
+	a.IsTrue(conn.ConnectionState().NegotiatedProtocol == "")
+
+	_, err = conn.Write([]byte("hello"))
+	a.IsNil(err)
+	data := make([]byte, 5)
+	_, err = io.ReadFull(conn, data)
+	a.IsNil(err)
+	a.IsTrue(string(data) == "hello")
+}
+
+func TestStream_UDP(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	backendConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backendConn.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := backendConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			backendConn.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := packetConn.LocalAddr().String()
+	packetConn.Close()
+
+	server := testStreamServer(t, nil, backendConn.LocalAddr().String())
+	stream := teaconfigs.NewStreamConfig()
+	stream.Protocol = teaconfigs.StreamProtocolUDP
+	stream.IdleTimeout = "200ms"
+	a.IsNil(stream.Validate())
+
+	listener := &Listener{
+		config: &teaconfigs.ListenerConfig{
+			Key:     stream.ListenerKey(address),
+			Address: address,
+			Stream:  stream,
+			Servers: []*teaconfigs.ServerConfig{server},
+		},
+	}
+	go listener.Start()
+	defer listener.Shutdown()
+
+	// 每个终端使用单独的会话
+	for _, message := range []string{"ping1", "ping2"} {
+		var data []byte
+		for i := 0; i < 20; i++ {
+			conn, err := net.Dial("udp", address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Write([]byte(message))
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			buf := make([]byte, 1024)
+			n, err := conn.Read(buf)
+			conn.Close()
+			if err == nil {
+				data = buf[:n]
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		a.IsTrue(string(data) == message)
+	}
+
+	// 空闲超时后结束会话
+	time.Sleep(500 * time.Millisecond)
+	udpServer := listener.stream.(*udpStreamServer)
+	udpServer.locker.Lock()
+	a.IsTrue(len(udpServer.sessions) == 0)
+	udpServer.locker.Unlock()
+	a.IsTrue(server.FindBackend("backend1").CandidateConns() == 0)
+}
+
+func TestReadStreamServerName(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	hello := testStreamClientHello(t, "example.com")
+	client, server := testStreamConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	_, err := client.Write(append(hello, []byte("more")...))
+	a.IsNil(err)
+
+	serverName, data, err := readStreamServerName(server)
+	a.IsNil(err)
+	a.IsTrue(serverName == "example.com")
+	a.IsTrue(bytes.HasPrefix(data, hello))
+
+	// 不是TLS
+	client.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	_, _, err = readStreamServerName(server)
+	a.IsNotNil(err)
+}
+
+// 启动一个TCP回显服务
+func testStreamEchoServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// 获取一个没有使用的地址
+func testStreamFreeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	return address
+}
+
+// 创建服务，后端服务的ID依次为backend1、backend2……，第一个无法连接的地址ID为down
+func testStreamServer(t *testing.T, names []string, backendAddresses ...string) *teaconfigs.ServerConfig {
+	server := &teaconfigs.ServerConfig{
+		Id:   "stream",
+		Name: names,
+	}
+	for index, address := range backendAddresses {
+		backend := teaconfigs.NewBackendConfig()
+		backend.Address = address
+		backend.ConnectTimeout = "1s"
+		if index == 0 && len(backendAddresses) > 1 {
+			backend.Id = "down"
+		} else {
+			backend.Id = "backend1"
+		}
+		server.AddBackend(backend)
+	}
+	err := server.ValidateBackends()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// 连接，等待监听服务启动
+func testStreamDial(t *testing.T, network string, address string) net.Conn {
+	var conn net.Conn
+	var err error
+	for i := 0; i < 20; i++ {
+		conn, err = net.DialTimeout(network, address, 1*time.Second)
+		if err == nil {
+			return conn
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal(err)
+	return nil
+}
+
+// 一对互相连接的TCP连接
+func testStreamConnPair(t *testing.T) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+// 生成客户端发送的ClientHello
+func testStreamClientHello(t *testing.T, serverName string) []byte {
+	conn := &testStreamHelloConn{}
+	tls.Client(conn, &tls.Config{
+		ServerName: serverName,
+	}).Handshake()
+	if conn.buffer.Len() == 0 {
+		t.Fatal("no client hello")
+	}
+	return conn.buffer.Bytes()
+}
+
+// 记录写入的数据，读取时返回错误
+type testStreamHelloConn struct {
+	net.Conn
+	buffer bytes.Buffer
+}
+
+func (this *testStreamHelloConn) Read(data []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (this *testStreamHelloConn) Write(data []byte) (int, error) {
+	return this.buffer.Write(data)
+}
@@ -0,0 +1,209 @@
+package teaproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/logs"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDP数据包的最大尺寸
+const udpMaxPacketSize = 65535
+
+// UDP代理服务，同一个终端地址的数据包属于同一个会话，使用同一个后端服务
+type udpStreamServer struct {
+	listener   *Listener
+	packetConn net.PacketConn
+
+	sessions map[string]*udpStreamSession // 终端地址 => 会话
+	wg       sync.WaitGroup
+	locker   sync.Mutex
+}
+
+// UDP会话
+type udpStreamSession struct {
+	*streamSession
+
+	clientAddr  net.Addr
+	backendConn net.Conn
+	lastActive  int64 // 最后一次收到或者发送数据的时间，单位为纳秒
+}
+
+// 获取新对象
+func newUDPStreamServer(listener *Listener, packetConn net.PacketConn) *udpStreamServer {
+	return &udpStreamServer{
+		listener:   listener,
+		packetConn: packetConn,
+		sessions:   map[string]*udpStreamSession{},
+	}
+}
+
+// 接收数据包，直到监听被关闭
+func (this *udpStreamServer) serve() error {
+	buf := make([]byte, udpMaxPacketSize)
+	for {
+		n, clientAddr, err := this.packetConn.ReadFrom(buf)
+		if n > 0 {
+			this.receive(clientAddr, buf[:n])
+		}
+		if err != nil {
+			if tempErr, ok := err.(interface{ Temporary() bool }); ok && tempErr.Temporary() {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// 关闭所有的会话，UDP监听关闭后已有的会话无法再向终端发送数据，所以不需要等待空闲超时
+func (this *udpStreamServer) shutdown(ctx context.Context) error {
+	this.locker.Lock()
+	for _, session := range this.sessions {
+		session.backendConn.Close()
+	}
+	this.locker.Unlock()
+
+	done := make(chan bool)
+	go func() {
+		this.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// 转发终端发送的数据包
+func (this *udpStreamServer) receive(clientAddr net.Addr, data []byte) {
+	key := clientAddr.String()
+
+	this.locker.Lock()
+	session, found := this.sessions[key]
+	if found {
+		atomic.StoreInt64(&session.lastActive, time.Now().UnixNano())
+	}
+	this.locker.Unlock()
+
+	if !found {
+		session = this.startSession(clientAddr)
+		if session == nil {
+			return
+		}
+	}
+
+	_, err := session.backendConn.Write(data)
+	if err != nil {
+		return
+	}
+	session.addBytesReceived(len(data))
+}
+
+// 开始新的会话，无法转发时返回nil，数据包会被丢弃
+func (this *udpStreamServer) startSession(clientAddr net.Addr) *udpStreamSession {
+	config := this.listener.currentConfig()
+	session := newStreamSession(teaconfigs.StreamProtocolUDP, clientAddr.String(), config.Address)
+
+	server, _ := config.FindNamedServer("")
+	if server == nil {
+		return nil
+	}
+	session.server = server
+
+	if !server.AllowIP(session.remoteIP()) {
+		return nil
+	}
+
+	backendConn, err := session.dialBackend()
+	if err != nil {
+		if err == errNoBackendsAvailable {
+			session.status = http.StatusServiceUnavailable
+		} else {
+			session.status = http.StatusBadGateway
+			logs.Error(errors.New(config.Key + ": " + err.Error()))
+		}
+		session.log()
+		return nil
+	}
+
+	udpSession := &udpStreamSession{
+		streamSession: session,
+		clientAddr:    clientAddr,
+		backendConn:   backendConn,
+		lastActive:    time.Now().UnixNano(),
+	}
+
+	this.locker.Lock()
+	this.sessions[clientAddr.String()] = udpSession
+	this.wg.Add(1)
+	this.locker.Unlock()
+
+	go this.loop(udpSession, config.Stream.IdleTimeoutDuration())
+
+	return udpSession
+}
+
+// 把后端服务返回的数据包发送给终端，直到超过空闲时间或者出错
+func (this *udpStreamServer) loop(session *udpStreamSession, idleTimeout time.Duration) {
+	key := session.clientAddr.String()
+	defer func() {
+		this.locker.Lock()
+		if this.sessions[key] == session {
+			delete(this.sessions, key)
+		}
+		this.locker.Unlock()
+
+		session.backendConn.Close()
+		session.backend.DecreaseConn()
+		session.log()
+		this.wg.Done()
+	}()
+
+	buf := make([]byte, udpMaxPacketSize)
+	for {
+		session.backendConn.SetReadDeadline(time.Now().Add(idleTimeout))
+		n, err := session.backendConn.Read(buf)
+		if n > 0 {
+			atomic.StoreInt64(&session.lastActive, time.Now().UnixNano())
+			_, writeErr := this.packetConn.WriteTo(buf[:n], session.clientAddr)
+			if writeErr != nil {
+				return
+			}
+			session.addBytesSent(n)
+		}
+		if err == nil {
+			continue
+		}
+
+		// 空闲超时，在锁中再次检查，防止刚收到的数据包被转发到已经结束的会话
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			this.locker.Lock()
+			if time.Since(time.Unix(0, atomic.LoadInt64(&session.lastActive))) < idleTimeout {
+				this.locker.Unlock()
+				continue
+			}
+			delete(this.sessions, key)
+			this.locker.Unlock()
+			return
+		}
+
+		// 后端服务拒绝连接等错误
+		if !errors.Is(err, net.ErrClosed) {
+			if session.backend.RecordFail() {
+				session.server.SetupScheduling(false)
+			}
+			if atomic.LoadInt64(&session.bytesSent) == 0 {
+				session.status = http.StatusBadGateway
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,236 @@
+package teaproxy
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 升级时传递给新进程的环境变量
+const (
+	upgradeEnvListeners = "TEAWEB_UPGRADE_LISTENERS" // 监听服务的Key，和传递的文件描述符顺序一致，用逗号分隔
+	upgradeEnvReadyFd   = "TEAWEB_UPGRADE_READY_FD"  // 新进程启动成功后通知旧进程的文件描述符
+	upgradeEnvPid       = "TEAWEB_UPGRADE_PID"       // 旧进程的PID
+)
+
+// 等待新进程启动的最长时间
+const upgradeReadyTimeout = 30 * time.Second
+
+// 新进程启动成功后写入的内容
+const upgradeReadyMessage = "ok"
+
+// 从升级前的进程继承的监听，Key => listener
+var inheritedListeners = map[string]net.Listener{}
+var inheritedPacketConns = map[string]net.PacketConn{} // UDP监听，Key => conn
+var inheritedListenersLocker = sync.Mutex{}
+
+// 通知旧进程的文件
+var upgradeReadyFile *os.File
+
+// 旧进程的PID
+var upgradeParentPid = 0
+
+func init() {
+	loadInheritedListeners()
+}
+
+// 读取从升级前的进程继承的监听
+func loadInheritedListeners() {
+	keys := os.Getenv(upgradeEnvListeners)
+	readyFd := types.Int(os.Getenv(upgradeEnvReadyFd))
+	upgradeParentPid = types.Int(os.Getenv(upgradeEnvPid))
+
+	// 不再传递给子进程
+	os.Unsetenv(upgradeEnvListeners)
+	os.Unsetenv(upgradeEnvReadyFd)
+	os.Unsetenv(upgradeEnvPid)
+
+	if len(keys) > 0 {
+		for index, key := range strings.Split(keys, ",") {
+			file := os.NewFile(uintptr(3+index), key)
+			if file == nil {
+				continue
+			}
+
+			// UDP监听
+			if strings.HasPrefix(key, "udp://") {
+				packetConn, err := net.FilePacketConn(file)
+				file.Close()
+				if err != nil {
+					logs.Error(err)
+					continue
+				}
+				inheritedPacketConns[key] = packetConn
+				continue
+			}
+
+			listener, err := net.FileListener(file)
+			file.Close()
+			if err != nil {
+				logs.Error(err)
+				continue
+			}
+			inheritedListeners[key] = listener
+		}
+	}
+
+	if readyFd > 0 {
+		upgradeReadyFile = os.NewFile(uintptr(readyFd), "ready")
+	}
+}
+
+// 取出某个继承的监听
+func takeInheritedListener(key string) net.Listener {
+	inheritedListenersLocker.Lock()
+	defer inheritedListenersLocker.Unlock()
+
+	listener, found := inheritedListeners[key]
+	if !found {
+		return nil
+	}
+	delete(inheritedListeners, key)
+	return listener
+}
+
+// 取出某个继承的UDP监听
+func takeInheritedPacketConn(key string) net.PacketConn {
+	inheritedListenersLocker.Lock()
+	defer inheritedListenersLocker.Unlock()
+
+	packetConn, found := inheritedPacketConns[key]
+	if !found {
+		return nil
+	}
+	delete(inheritedPacketConns, key)
+	return packetConn
+}
+
+// 关闭没有用到的继承的监听，比如配置已经删除的监听服务，并通知旧进程已经启动成功
+func finishUpgrade() {
+	inheritedListenersLocker.Lock()
+	for key, listener := range inheritedListeners {
+		listener.Close()
+		delete(inheritedListeners, key)
+	}
+	for key, packetConn := range inheritedPacketConns {
+		packetConn.Close()
+		delete(inheritedPacketConns, key)
+	}
+	inheritedListenersLocker.Unlock()
+
+	if upgradeReadyFile != nil {
+		_, err := upgradeReadyFile.Write([]byte(upgradeReadyMessage))
+		if err != nil {
+			logs.Error(err)
+		}
+		upgradeReadyFile.Close()
+		upgradeReadyFile = nil
+	}
+}
+
+// 是否为升级后的新进程
+func IsUpgraded() bool {
+	return upgradeParentPid > 0
+}
+
+// 等待升级前的进程退出，以便新进程可以使用管理界面的端口
+func WaitUpgradeParent() {
+	if upgradeParentPid <= 0 {
+		return
+	}
+	deadline := time.Now().Add(listenerDrainTimeout + 10*time.Second)
+	for time.Now().Before(deadline) {
+		proc, err := os.FindProcess(upgradeParentPid)
+		if err != nil || proc.Signal(syscall.Signal(0)) != nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	logs.Println("wait for old process", upgradeParentPid, "timeout")
+}
+
+// 启动新的可执行文件并把所有的监听传递给新进程，新进程启动成功后返回
+// 之后调用者需要调用Shutdown()等待已有的连接处理完毕，然后退出当前进程
+func Upgrade() error {
+	reloadLocker.Lock()
+	defer reloadLocker.Unlock()
+
+	proxyLocker.RLock()
+	listeners := append([]*Listener{}, LISTENERS...)
+	proxyLocker.RUnlock()
+
+	keys := []string{}
+	files := []*os.File{}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, listener := range listeners {
+		file, err := listener.file()
+		if err != nil {
+			return err
+		}
+		if file == nil {
+			continue
+		}
+		keys = append(keys, listener.Key())
+		files = append(files, file)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		upgradeEnvListeners+"="+strings.Join(keys, ","),
+		upgradeEnvReadyFd+"="+types.String(3+len(files)),
+		upgradeEnvPid+"="+types.String(os.Getpid()),
+	)
+	cmd.ExtraFiles = append(append([]*os.File{}, files...), readyWriter)
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		return err
+	}
+	logs.Println("start new process, pid:", cmd.Process.Pid)
+
+	// 等待新进程启动
+	result := make(chan error, 1)
+	go func() {
+		data := make([]byte, len(upgradeReadyMessage))
+		_, err := io.ReadFull(readyReader, data)
+		if err != nil {
+			result <- errors.New("new process exited before it was ready")
+			return
+		}
+		result <- nil
+	}()
+
+	select {
+	case err = <-result:
+	case <-time.After(upgradeReadyTimeout):
+		err = errors.New("wait for new process timeout")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,75 @@
+package teaproxy
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"net"
+	"testing"
+)
+
+func TestListener_File(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	netListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netListener.Close()
+
+	listener := &Listener{
+		netListener: netListener,
+	}
+	file, err := listener.file()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsNotNil(file)
+
+	// 新进程使用文件重新创建监听
+	inherited, err := net.FileListener(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inherited.Close()
+
+	// 旧的监听关闭后，新的监听仍然可以接受连接
+	netListener.Close()
+	go func() {
+		conn, err := net.Dial("tcp", inherited.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := inherited.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// 正在关闭的监听不再传递
+	listener.closing = true
+	file, err = listener.file()
+	a.IsNil(err)
+	a.IsTrue(file == nil)
+}
+
+func TestTakeInheritedListener(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	netListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "http://" + netListener.Addr().String()
+	inheritedListeners[key] = netListener
+
+	a.IsTrue(takeInheritedListener(key) == netListener)
+	a.IsTrue(takeInheritedListener(key) == nil)
+
+	// 没有用到的监听会被关闭
+	inheritedListeners[key] = netListener
+	finishUpgrade()
+	a.IsTrue(len(inheritedListeners) == 0)
+	_, err = netListener.Accept()
+	a.IsNotNil(err)
+}
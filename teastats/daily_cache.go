@@ -0,0 +1,81 @@
+package teastats
+
+import (
+	"github.com/TeaWeb/code/tealogs"
+	"github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+// 缓存命中统计
+type DailyCacheStat struct {
+	Stat
+
+	ServerId   string `bson:"serverId" json:"serverId"`     // 服务ID
+	Policy     string `bson:"policy" json:"policy"`         // 缓存策略文件名
+	Day        string `bson:"day" json:"day"`               // 日期，格式为：Ymd
+	Hits       int64  `bson:"hits" json:"hits"`             // 命中次数
+	Misses     int64  `bson:"misses" json:"misses"`         // 未命中次数
+	BytesSaved int64  `bson:"bytesSaved" json:"bytesSaved"` // 节省的字节数
+}
+
+func (this *DailyCacheStat) Init() {
+	coll := findCollection("stats.cache.daily", nil)
+	coll.CreateIndex(map[string]bool{
+		"day": true,
+	})
+	coll.CreateIndex(map[string]bool{
+		"day":      true,
+		"serverId": true,
+	})
+	coll.CreateIndex(map[string]bool{
+		"day":    true,
+		"policy": true,
+	})
+}
+
+func (this *DailyCacheStat) Process(accessLog *tealogs.AccessLog) {
+	hits, ok := cacheStatusHits(accessLog.CacheStatus)
+	if !ok {
+		return
+	}
+
+	day := timeutil.Format("Ymd")
+	coll := findCollection("stats.cache.daily", this.Init)
+
+	filter := map[string]interface{}{
+		"serverId": accessLog.ServerId,
+		"policy":   accessLog.CachePolicy,
+		"day":      day,
+	}
+	if hits {
+		this.Avg(coll, filter, filter, "hits", 1, "bytesSaved", float64(accessLog.BodyBytesSent))
+	} else {
+		this.Increase(coll, filter, filter, "misses")
+	}
+}
+
+// 列出最近几天的命中率，serverId和policy为空时表示不限制
+func (this *DailyCacheStat) ListLatestDays(serverId string, policy string, days int) []map[string]interface{} {
+	if days <= 0 {
+		days = 7
+	}
+
+	result := []map[string]interface{}{}
+	for i := days - 1; i >= 0; i-- {
+		day := timeutil.Format("Ymd", time.Now().AddDate(0, 0, -i))
+		filter := map[string]interface{}{
+			"day": day,
+		}
+		if len(serverId) > 0 {
+			filter["serverId"] = serverId
+		}
+		if len(policy) > 0 {
+			filter["policy"] = policy
+		}
+
+		m := sumCacheStats("stats.cache.daily", filter)
+		m["day"] = day
+		result = append(result, m)
+	}
+	return result
+}
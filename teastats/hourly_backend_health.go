@@ -0,0 +1,109 @@
+package teastats
+
+import (
+	"context"
+	"github.com/TeaWeb/code/teamongo"
+	"github.com/iwind/TeaGo/types"
+	"github.com/iwind/TeaGo/utils/time"
+	"sync"
+	"time"
+)
+
+var backendHealthStat = &HourlyBackendHealthStat{}
+
+// 后端服务器健康检查统计
+type HourlyBackendHealthStat struct {
+	Stat
+
+	ServerId  string `bson:"serverId" json:"serverId"`   // 服务ID
+	BackendId string `bson:"backendId" json:"backendId"` // 后端服务器ID
+	Hour      string `bson:"hour" json:"hour"`           // 小时，格式为：YmdH
+	Success   int64  `bson:"success" json:"success"`     // 成功次数
+	Fail      int64  `bson:"fail" json:"fail"`           // 失败次数
+
+	mongoOnce sync.Once
+	mongoOn   bool
+}
+
+// 获取共享的对象
+func SharedBackendHealthStat() *HourlyBackendHealthStat {
+	return backendHealthStat
+}
+
+func (this *HourlyBackendHealthStat) Init() {
+	coll := findCollection("stats.backends.health.hourly", nil)
+	coll.CreateIndex(map[string]bool{
+		"hour": true,
+	})
+	coll.CreateIndex(map[string]bool{
+		"serverId":  true,
+		"backendId": true,
+		"hour":      true,
+	})
+}
+
+// 记录一次检查结果
+func (this *HourlyBackendHealthStat) Record(serverId string, backendId string, success bool) {
+	this.mongoOnce.Do(func() {
+		this.mongoOn = teamongo.Test() == nil
+	})
+	if !this.mongoOn {
+		return
+	}
+
+	hour := timeutil.Format("YmdH")
+	coll := findCollection("stats.backends.health.hourly", this.Init)
+
+	field := "success"
+	if !success {
+		field = "fail"
+	}
+
+	this.Increase(coll, map[string]interface{}{
+		"serverId":  serverId,
+		"backendId": backendId,
+		"hour":      hour,
+	}, map[string]interface{}{
+		"serverId":  serverId,
+		"backendId": backendId,
+		"hour":      hour,
+	}, field)
+}
+
+// 列出最近几个小时的检查结果
+func (this *HourlyBackendHealthStat) ListLatestHours(serverId string, backendId string, hours int) []map[string]interface{} {
+	if hours <= 0 {
+		hours = 24
+	}
+
+	result := []map[string]interface{}{}
+	coll := findCollection("stats.backends.health.hourly", nil)
+	for i := hours - 1; i >= 0; i-- {
+		hour := timeutil.Format("YmdH", time.Now().Add(time.Duration(-i)*time.Hour))
+
+		m := map[string]interface{}{}
+		err := coll.FindOne(context.Background(), map[string]interface{}{
+			"serverId":  serverId,
+			"backendId": backendId,
+			"hour":      hour,
+		}).Decode(&m)
+		if err != nil {
+			m = map[string]interface{}{}
+		}
+
+		success := types.Int64(m["success"])
+		fail := types.Int64(m["fail"])
+		rate := float64(0)
+		if success+fail > 0 {
+			rate = float64(success) * 100 / float64(success+fail)
+		}
+
+		result = append(result, map[string]interface{}{
+			"hour":    hour,
+			"success": success,
+			"fail":    fail,
+			"rate":    rate,
+		})
+	}
+	return result
+}
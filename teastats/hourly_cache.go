@@ -0,0 +1,132 @@
+package teastats
+
+import (
+	"context"
+	"github.com/TeaWeb/code/tealogs"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+// 缓存命中统计
+type HourlyCacheStat struct {
+	Stat
+
+	ServerId   string `bson:"serverId" json:"serverId"`     // 服务ID
+	Policy     string `bson:"policy" json:"policy"`         // 缓存策略文件名
+	Hour       string `bson:"hour" json:"hour"`             // 小时，格式为：YmdH
+	Hits       int64  `bson:"hits" json:"hits"`             // 命中次数
+	Misses     int64  `bson:"misses" json:"misses"`         // 未命中次数
+	BytesSaved int64  `bson:"bytesSaved" json:"bytesSaved"` // 节省的字节数
+}
+
+func (this *HourlyCacheStat) Init() {
+	coll := findCollection("stats.cache.hourly", nil)
+	coll.CreateIndex(map[string]bool{
+		"hour": true,
+	})
+	coll.CreateIndex(map[string]bool{
+		"hour":     true,
+		"serverId": true,
+	})
+	coll.CreateIndex(map[string]bool{
+		"hour":   true,
+		"policy": true,
+	})
+}
+
+func (this *HourlyCacheStat) Process(accessLog *tealogs.AccessLog) {
+	hits, ok := cacheStatusHits(accessLog.CacheStatus)
+	if !ok {
+		return
+	}
+
+	hour := timeutil.Format("YmdH")
+	coll := findCollection("stats.cache.hourly", this.Init)
+
+	filter := map[string]interface{}{
+		"serverId": accessLog.ServerId,
+		"policy":   accessLog.CachePolicy,
+		"hour":     hour,
+	}
+	if hits {
+		this.Avg(coll, filter, filter, "hits", 1, "bytesSaved", float64(accessLog.BodyBytesSent))
+	} else {
+		this.Increase(coll, filter, filter, "misses")
+	}
+}
+
+// 列出最近几个小时的命中率，serverId和policy为空时表示不限制
+func (this *HourlyCacheStat) ListLatestHours(serverId string, policy string, hours int) []map[string]interface{} {
+	if hours <= 0 {
+		hours = 24
+	}
+
+	result := []map[string]interface{}{}
+	for i := hours - 1; i >= 0; i-- {
+		hour := timeutil.Format("YmdH", time.Now().Add(time.Duration(-i)*time.Hour))
+		filter := map[string]interface{}{
+			"hour": hour,
+		}
+		if len(serverId) > 0 {
+			filter["serverId"] = serverId
+		}
+		if len(policy) > 0 {
+			filter["policy"] = policy
+		}
+
+		m := sumCacheStats("stats.cache.hourly", filter)
+		m["hour"] = hour
+		result = append(result, m)
+	}
+	return result
+}
+
+// 判断缓存状态是否为命中，ok为false表示没有使用缓存
+func cacheStatusHits(status string) (hits bool, ok bool) {
+	switch status {
+	case "HIT", "STALE":
+		return true, true
+	case "MISS", "EXPIRED":
+		return false, true
+	}
+	return false, false
+}
+
+// 计算缓存命中数据
+func sumCacheStats(collName string, filter map[string]interface{}) map[string]interface{} {
+	hits := int64(0)
+	misses := int64(0)
+	bytesSaved := int64(0)
+
+	coll := findCollection(collName, nil)
+	cursor, err := coll.Find(context.Background(), filter)
+	if err != nil {
+		logs.Error(err)
+	} else {
+		defer cursor.Close(context.Background())
+		for cursor.Next(context.Background()) {
+			m := map[string]interface{}{}
+			err = cursor.Decode(&m)
+			if err != nil {
+				logs.Error(err)
+				continue
+			}
+			hits += types.Int64(m["hits"])
+			misses += types.Int64(m["misses"])
+			bytesSaved += types.Int64(m["bytesSaved"])
+		}
+	}
+
+	rate := float64(0)
+	if hits+misses > 0 {
+		rate = float64(hits) * 100 / float64(hits+misses)
+	}
+	return map[string]interface{}{
+		"hits":       hits,
+		"misses":     misses,
+		"bytesSaved": bytesSaved,
+		"rate":       rate,
+	}
+}
@@ -0,0 +1,84 @@
+package teastats
+
+import (
+	"context"
+	"github.com/TeaWeb/code/tealogs"
+	"github.com/iwind/TeaGo/types"
+	"github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+// 流量统计，包括HTTP请求和TCP/UDP代理的连接
+type HourlyTrafficStat struct {
+	Stat
+
+	ServerId      string `bson:"serverId" json:"serverId"`           // 服务ID
+	Hour          string `bson:"hour" json:"hour"`                   // 小时，格式为：YmdH
+	Count         int64  `bson:"count" json:"count"`                 // 请求数或连接数
+	BytesSent     int64  `bson:"bytesSent" json:"bytesSent"`         // 发送到终端的字节数
+	BytesReceived int64  `bson:"bytesReceived" json:"bytesReceived"` // 从终端接收的字节数
+}
+
+func (this *HourlyTrafficStat) Init() {
+	coll := findCollection("stats.traffic.hourly", nil)
+	coll.CreateIndex(map[string]bool{
+		"hour": true,
+	})
+	coll.CreateIndex(map[string]bool{
+		"hour":     true,
+		"serverId": true,
+	})
+}
+
+func (this *HourlyTrafficStat) Process(accessLog *tealogs.AccessLog) {
+	hour := timeutil.Format("YmdH")
+	coll := findCollection("stats.traffic.hourly", this.Init)
+
+	filter := map[string]interface{}{
+		"serverId": accessLog.ServerId,
+		"hour":     hour,
+	}
+	this.Sum(coll, filter, filter, map[string]int64{
+		"count":         1,
+		"bytesSent":     accessLog.BytesSent,
+		"bytesReceived": trafficBytesReceived(accessLog),
+	})
+}
+
+// 列出最近几个小时的流量
+func (this *HourlyTrafficStat) ListLatestHours(serverId string, hours int) []map[string]interface{} {
+	if hours <= 0 {
+		hours = 24
+	}
+
+	result := []map[string]interface{}{}
+	coll := findCollection("stats.traffic.hourly", nil)
+	for i := hours - 1; i >= 0; i-- {
+		hour := timeutil.Format("YmdH", time.Now().Add(time.Duration(-i)*time.Hour))
+
+		m := map[string]interface{}{}
+		err := coll.FindOne(context.Background(), map[string]interface{}{
+			"serverId": serverId,
+			"hour":     hour,
+		}).Decode(&m)
+		if err != nil {
+			m = map[string]interface{}{}
+		}
+
+		result = append(result, map[string]interface{}{
+			"hour":          hour,
+			"count":         types.Int64(m["count"]),
+			"bytesSent":     types.Int64(m["bytesSent"]),
+			"bytesReceived": types.Int64(m["bytesReceived"]),
+		})
+	}
+	return result
+}
+
+// 从终端接收的字节数，HTTP请求使用请求内容长度
+func trafficBytesReceived(accessLog *tealogs.AccessLog) int64 {
+	if accessLog.BytesReceived > 0 {
+		return accessLog.BytesReceived
+	}
+	return accessLog.RequestLength
+}
@@ -0,0 +1,43 @@
+package teastats
+
+import (
+	"fmt"
+	"github.com/TeaWeb/code/teamongo"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/types"
+)
+
+// 为多个字段同时增加数值的操作
+type SumOperation struct {
+	coll   *teamongo.Collection
+	filter map[string]interface{}
+	init   map[string]interface{}
+	values map[string]int64 // 字段 => 增加的数值
+}
+
+func (this *SumOperation) uniqueId() string {
+	keys := []string{}
+	for key := range this.filter {
+		keys = append(keys, key)
+	}
+	lists.Sort(keys, func(i int, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	uniqueId := fmt.Sprintf("%p", this.coll)
+	for _, key := range keys {
+		uniqueId += "@" + types.String(this.filter[key])
+	}
+
+	fields := []string{}
+	for field := range this.values {
+		fields = append(fields, field)
+	}
+	lists.Sort(fields, func(i int, j int) bool {
+		return fields[i] < fields[j]
+	})
+	for _, field := range fields {
+		uniqueId += "@" + field
+	}
+	return uniqueId
+}
@@ -21,6 +21,11 @@ var processors = []tealogs.Processor{
 	new(HourlyUVStat),
 	new(MonthlyUVStat),
 
+	new(HourlyCacheStat),
+	new(DailyCacheStat),
+
+	new(HourlyTrafficStat),
+
 	new(TopRegionStat),
 	new(TopStateStat),
 	new(TopOSStat),
@@ -15,6 +15,7 @@ import (
 type Stat struct {
 	incOperations []*IncrementOperation
 	avgOperations []*AvgOperation
+	sumOperations []*SumOperation
 
 	once   sync.Once
 	locker sync.Mutex
@@ -102,6 +103,41 @@ func (this *Stat) initOnce() {
 				}
 				this.avgOperations = []*AvgOperation{}
 			}
+
+			// 求和操作
+			{
+				dataMap := map[string]*SumOperation{} // uniqueId => 合并后的操作
+				for _, op := range this.sumOperations {
+					uniqueId := op.uniqueId()
+					merged, found := dataMap[uniqueId]
+					if !found {
+						merged = &SumOperation{
+							coll:   op.coll,
+							filter: op.filter,
+							init:   op.init,
+							values: map[string]int64{},
+						}
+						dataMap[uniqueId] = merged
+					}
+					for field, value := range op.values {
+						merged.values[field] += value
+					}
+				}
+				for _, op := range dataMap {
+					inc := map[string]interface{}{}
+					for field, value := range op.values {
+						inc[field] = value
+					}
+					_, err := op.coll.UpdateOne(context.Background(), op.filter, map[string]interface{}{
+						"$set": op.init,
+						"$inc": inc,
+					}, updateopt.OptUpsert(true))
+					if err != nil {
+						logs.Error(err)
+					}
+				}
+				this.sumOperations = []*SumOperation{}
+			}
 		})
 	})
 }
@@ -144,3 +180,21 @@ func (this *Stat) Avg(collection *teamongo.Collection, filter map[string]interfa
 		sum:        sum,
 	})
 }
+
+// 为多个字段同时做增加数值操作
+func (this *Stat) Sum(collection *teamongo.Collection, filter map[string]interface{}, init map[string]interface{}, values map[string]int64) {
+	this.initOnce()
+
+	if collection == nil {
+		return
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	this.sumOperations = append(this.sumOperations, &SumOperation{
+		coll:   collection,
+		filter: filter,
+		init:   init,
+		values: values,
+	})
+}
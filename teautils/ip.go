@@ -0,0 +1,315 @@
+package teautils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IP范围
+// 支持的格式：192.168.1.100, 192.168.1.0/24, ::1, fe80::/10, all
+type IPRange struct {
+	pattern string
+	ipNet   *net.IPNet
+	all     bool
+}
+
+// 分析IP范围
+func ParseIPRange(pattern string) (*IPRange, error) {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) == 0 {
+		return nil, errors.New("empty ip pattern")
+	}
+
+	r := &IPRange{
+		pattern: pattern,
+	}
+
+	if pattern == "all" {
+		r.all = true
+		return r, nil
+	}
+
+	if strings.Contains(pattern, "/") {
+		_, ipNet, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return nil, errors.New("invalid ip pattern '" + pattern + "'")
+		}
+		r.ipNet = ipNet
+		return r, nil
+	}
+
+	ip := net.ParseIP(pattern)
+	if ip == nil {
+		return nil, errors.New("invalid ip pattern '" + pattern + "'")
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+	r.ipNet = &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(bits, bits),
+	}
+	return r, nil
+}
+
+// 规则字符串
+func (this *IPRange) Pattern() string {
+	return this.pattern
+}
+
+// 判断是否包含某个IP
+func (this *IPRange) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if this.all {
+		return true
+	}
+	return this.ipNet.Contains(ip)
+}
+
+// IP范围列表
+type IPRangeList struct {
+	ranges []*IPRange
+}
+
+// 分析一组IP范围
+func ParseIPRangeList(patterns []string) (*IPRangeList, error) {
+	list := &IPRangeList{}
+	for _, pattern := range patterns {
+		r, err := ParseIPRange(pattern)
+		if err != nil {
+			return nil, err
+		}
+		list.ranges = append(list.ranges, r)
+	}
+	return list, nil
+}
+
+// 是否为空
+func (this *IPRangeList) IsEmpty() bool {
+	return this == nil || len(this.ranges) == 0
+}
+
+// 判断是否包含某个IP
+func (this *IPRangeList) Contains(ip net.IP) bool {
+	if this == nil || ip == nil {
+		return false
+	}
+	for _, r := range this.ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 从一组规则中匹配IP，无法分析的规则按字符串精确匹配
+func MatchIP(patterns []string, ip string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	netIP := ParseIP(ip)
+	for _, pattern := range patterns {
+		r, err := ParseIPRange(pattern)
+		if err != nil {
+			if pattern == ip {
+				return true
+			}
+			continue
+		}
+		if r.Contains(netIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// IP访问规则
+type ipAccessRule struct {
+	allow bool
+	r     *IPRange
+}
+
+// IP访问规则列表，参考nginx的allow和deny指令：按顺序匹配，第一个匹配的规则生效，都不匹配时允许访问
+type IPAccessList struct {
+	rules []*ipAccessRule
+}
+
+// 分析一组访问规则，每个规则的格式为：allow IP范围 或 deny IP范围，比如 allow 192.168.1.0/24、deny all
+func ParseIPAccessList(rules []string) (*IPAccessList, error) {
+	list := &IPAccessList{}
+	for _, rule := range rules {
+		fields := strings.Fields(rule)
+		if len(fields) != 2 || (fields[0] != "allow" && fields[0] != "deny") {
+			return nil, errors.New("invalid access rule '" + rule + "'")
+		}
+		err := list.Add(fields[0] == "allow", fields[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// 在最后添加一组允许或者禁止的规则
+func (this *IPAccessList) Add(allow bool, patterns ...string) error {
+	for _, pattern := range patterns {
+		r, err := ParseIPRange(pattern)
+		if err != nil {
+			return err
+		}
+		this.rules = append(this.rules, &ipAccessRule{
+			allow: allow,
+			r:     r,
+		})
+	}
+	return nil
+}
+
+// 是否为空
+func (this *IPAccessList) IsEmpty() bool {
+	return this == nil || len(this.rules) == 0
+}
+
+// 判断某个IP是否可以访问
+func (this *IPAccessList) Check(ip string) bool {
+	if this.IsEmpty() {
+		return true
+	}
+
+	netIP := ParseIP(ip)
+	if netIP == nil {
+		return false
+	}
+
+	for _, rule := range this.rules {
+		if rule.r.Contains(netIP) {
+			return rule.allow
+		}
+	}
+	return true
+}
+
+// 分析IP，支持带端口的地址
+func ParseIP(ip string) net.IP {
+	ip = strings.TrimSpace(ip)
+	if len(ip) == 0 {
+		return nil
+	}
+	netIP := net.ParseIP(ip)
+	if netIP != nil {
+		return netIP
+	}
+	host, _, err := net.SplitHostPort(ip)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// 从X-Forwarded-For中按从右到左的顺序跳过受信任的代理，得到真实的终端IP
+func ClientIP(remoteAddr string, forwardedFor string, trustedList *IPRangeList) string {
+	if len(forwardedFor) == 0 {
+		return ClientIPFromChain(remoteAddr, nil, trustedList)
+	}
+	return ClientIPFromChain(remoteAddr, strings.Split(forwardedFor, ","), trustedList)
+}
+
+// 从请求Header中获取真实的终端IP，只有直接连接的地址是受信任的代理时才读取Header
+// 优先使用RFC 7239中的Forwarded，其次是X-Forwarded-For和X-Real-IP
+func ClientIPFromHeader(remoteAddr string, header http.Header, trustedList *IPRangeList) string {
+	if forwarded := header.Values("Forwarded"); len(forwarded) > 0 {
+		return ClientIPFromChain(remoteAddr, ParseForwardedFor(strings.Join(forwarded, ",")), trustedList)
+	}
+	if forwardedFor := header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		return ClientIP(remoteAddr, strings.Join(forwardedFor, ","), trustedList)
+	}
+	if realIP := header.Get("X-Real-IP"); len(realIP) > 0 {
+		return ClientIPFromChain(remoteAddr, []string{realIP}, trustedList)
+	}
+	return ClientIPFromChain(remoteAddr, nil, trustedList)
+}
+
+// 从一组经过的地址中按从右到左的顺序跳过受信任的代理，得到真实的终端IP
+func ClientIPFromChain(remoteAddr string, chain []string, trustedList *IPRangeList) string {
+	remoteIP := ParseIP(remoteAddr)
+	if remoteIP == nil {
+		return remoteAddr
+	}
+	if trustedList.IsEmpty() || len(chain) == 0 || !trustedList.Contains(remoteIP) {
+		return remoteIP.String()
+	}
+
+	clientIP := remoteIP
+	for i := len(chain) - 1; i >= 0; i-- {
+		hopIP := ParseIP(chain[i])
+		if hopIP == nil {
+			break
+		}
+		clientIP = hopIP
+		if !trustedList.Contains(hopIP) {
+			break
+		}
+	}
+	return clientIP.String()
+}
+
+// 从RFC 7239中的Forwarded中读取每一级的for参数，按从左到右的顺序返回
+// 比如：for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+func ParseForwardedFor(forwarded string) []string {
+	result := []string{}
+	for _, element := range splitQuoted(forwarded, ',') {
+		value := ""
+		for _, pair := range splitQuoted(element, ';') {
+			index := strings.Index(pair, "=")
+			if index < 0 {
+				continue
+			}
+			if !strings.EqualFold(strings.TrimSpace(pair[:index]), "for") {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(pair[index+1:]), "\"")
+
+			// [2001:db8:cafe::17]:4711
+			if strings.HasPrefix(value, "[") {
+				if end := strings.Index(value, "]"); end > 0 {
+					value = value[1:end]
+				}
+			}
+			break
+		}
+
+		// 没有for参数的一级仍然需要保留，用来中断受信任的代理链
+		result = append(result, value)
+	}
+	return result
+}
+
+// 按分隔符分割字符串，忽略引号中的分隔符
+func splitQuoted(s string, sep byte) []string {
+	result := []string{}
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuote = !inQuote
+		case '\\':
+			i++
+		case sep:
+			if !inQuote {
+				result = append(result, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(s) {
+		result = append(result, s[start:])
+	}
+	return result
+}
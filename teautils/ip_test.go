@@ -0,0 +1,189 @@
+package teautils
+
+import (
+	"github.com/iwind/TeaGo/assert"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseIPRange(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	{
+		r, err := ParseIPRange("192.168.1.100")
+		a.IsNil(err)
+		a.IsTrue(r.Contains(net.ParseIP("192.168.1.100")))
+		a.IsFalse(r.Contains(net.ParseIP("192.168.1.101")))
+	}
+
+	{
+		r, err := ParseIPRange("192.168.1.0/24")
+		a.IsNil(err)
+		a.IsTrue(r.Contains(net.ParseIP("192.168.1.200")))
+		a.IsFalse(r.Contains(net.ParseIP("192.168.2.1")))
+	}
+
+	{
+		r, err := ParseIPRange("fe80::/10")
+		a.IsNil(err)
+		a.IsTrue(r.Contains(net.ParseIP("fe80::1")))
+		a.IsFalse(r.Contains(net.ParseIP("::1")))
+	}
+
+	{
+		r, err := ParseIPRange("all")
+		a.IsNil(err)
+		a.IsTrue(r.Contains(net.ParseIP("8.8.8.8")))
+	}
+
+	{
+		_, err := ParseIPRange("192.168.1")
+		a.IsNotNil(err)
+	}
+
+	{
+		_, err := ParseIPRange("192.168.1.0/33")
+		a.IsNotNil(err)
+	}
+}
+
+func TestMatchIP(t *testing.T) {
+	a := assert.NewAssertion(t)
+	a.IsFalse(MatchIP([]string{}, "127.0.0.1"))
+	a.IsTrue(MatchIP([]string{"127.0.0.1"}, "127.0.0.1"))
+	a.IsTrue(MatchIP([]string{"192.168.0.0/16"}, "192.168.100.1"))
+	a.IsFalse(MatchIP([]string{"192.168.0.0/16"}, "192.169.100.1"))
+	a.IsTrue(MatchIP([]string{"all"}, "192.169.100.1"))
+	a.IsTrue(MatchIP([]string{"localhost"}, "localhost"))
+}
+
+func TestIPAccessList(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	parse := func(rules ...string) *IPAccessList {
+		list, err := ParseIPAccessList(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+
+	a.IsTrue(parse().Check("1.2.3.4"))
+	var nilList *IPAccessList
+	a.IsTrue(nilList.Check("1.2.3.4"))
+
+	// 黑名单
+	a.IsFalse(parse("deny 1.2.3.4").Check("1.2.3.4"))
+	a.IsTrue(parse("deny 1.2.3.4").Check("1.2.3.5"))
+
+	// allow 192.168.1.0/24; deny all;
+	a.IsTrue(parse("allow 192.168.1.0/24", "deny all").Check("192.168.1.1"))
+	a.IsFalse(parse("allow 192.168.1.0/24", "deny all").Check("192.168.2.1"))
+
+	// deny 192.168.1.1; allow 192.168.1.0/24; deny all;
+	{
+		list := parse("deny 192.168.1.1", "allow 192.168.1.0/24", "deny all")
+		a.IsFalse(list.Check("192.168.1.1"))
+		a.IsTrue(list.Check("192.168.1.2"))
+		a.IsFalse(list.Check("192.168.2.1"))
+	}
+
+	// 第一个匹配的规则生效，和规则的精确程度无关
+	a.IsTrue(parse("allow all", "deny 10.0.0.0/8").Check("10.1.1.1"))
+	a.IsFalse(parse("deny 10.0.0.0/8", "allow all").Check("10.1.1.1"))
+	a.IsTrue(parse("allow 192.168.1.0/24", "deny 192.168.1.1").Check("192.168.1.1"))
+
+	// 带端口
+	a.IsFalse(parse("deny 127.0.0.1").Check("127.0.0.1:1234"))
+	a.IsFalse(parse("deny ::1").Check("[::1]:1234"))
+
+	// 错误的规则
+	{
+		_, err := ParseIPAccessList([]string{"allow"})
+		a.IsNotNil(err)
+	}
+	{
+		_, err := ParseIPAccessList([]string{"block 1.2.3.4"})
+		a.IsNotNil(err)
+	}
+	{
+		_, err := ParseIPAccessList([]string{"allow 1.2.3"})
+		a.IsNotNil(err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	trusted, err := ParseIPRangeList([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.IsTrue(ClientIP("1.2.3.4:1234", "5.6.7.8", trusted) == "1.2.3.4")
+	a.IsTrue(ClientIP("127.0.0.1:1234", "5.6.7.8", trusted) == "5.6.7.8")
+	a.IsTrue(ClientIP("127.0.0.1:1234", "9.9.9.9, 5.6.7.8, 10.1.1.1", trusted) == "5.6.7.8")
+	a.IsTrue(ClientIP("127.0.0.1:1234", "10.1.1.2, 10.1.1.1", trusted) == "10.1.1.2")
+	a.IsTrue(ClientIP("127.0.0.1:1234", "", trusted) == "127.0.0.1")
+	a.IsTrue(ClientIP("127.0.0.1:1234", "5.6.7.8", nil) == "127.0.0.1")
+}
+
+func TestParseForwardedFor(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	a.IsTrue(len(ParseForwardedFor("")) == 0)
+	a.IsTrue(strings.Join(ParseForwardedFor("for=192.0.2.60;proto=http;by=203.0.113.43"), ",") == "192.0.2.60")
+	a.IsTrue(strings.Join(ParseForwardedFor(`For="[2001:db8:cafe::17]:4711", for=198.51.100.17`), ",") == "2001:db8:cafe::17,198.51.100.17")
+	a.IsTrue(strings.Join(ParseForwardedFor(`for=unknown, proto=https, for="192.0.2.1:8080"`), ",") == "unknown,,192.0.2.1:8080")
+	a.IsTrue(strings.Join(ParseForwardedFor(`for="a,b";host=example.com`), ",") == "a,b")
+}
+
+func TestClientIPFromHeader(t *testing.T) {
+	a := assert.NewAssertion(t)
+
+	trusted, err := ParseIPRangeList([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不受信任的地址传递的Header会被忽略
+	{
+		header := http.Header{}
+		header.Set("X-Real-IP", "5.6.7.8")
+		header.Set("X-Forwarded-For", "5.6.7.8")
+		a.IsTrue(ClientIPFromHeader("1.2.3.4:1234", header, trusted) == "1.2.3.4")
+	}
+
+	// X-Forwarded-For的多行
+	{
+		header := http.Header{}
+		header.Add("X-Forwarded-For", "9.9.9.9")
+		header.Add("X-Forwarded-For", "5.6.7.8, 10.1.1.1")
+		a.IsTrue(ClientIPFromHeader("127.0.0.1:1234", header, trusted) == "5.6.7.8")
+	}
+
+	// Forwarded优先
+	{
+		header := http.Header{}
+		header.Set("Forwarded", `for=9.9.9.9, for="[2001:db8::1]:4711", for=10.1.1.1`)
+		header.Set("X-Forwarded-For", "5.6.7.8")
+		a.IsTrue(ClientIPFromHeader("127.0.0.1:1234", header, trusted) == "2001:db8::1")
+	}
+
+	// 隐藏的地址
+	{
+		header := http.Header{}
+		header.Set("Forwarded", `for=9.9.9.9, for=_hidden, for=10.1.1.1`)
+		a.IsTrue(ClientIPFromHeader("127.0.0.1:1234", header, trusted) == "10.1.1.1")
+	}
+
+	// X-Real-IP
+	{
+		header := http.Header{}
+		header.Set("X-Real-IP", "5.6.7.8")
+		a.IsTrue(ClientIPFromHeader("127.0.0.1:1234", header, trusted) == "5.6.7.8")
+		a.IsTrue(ClientIPFromHeader("127.0.0.1:1234", http.Header{}, trusted) == "127.0.0.1")
+	}
+}
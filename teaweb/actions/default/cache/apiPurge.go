@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/iwind/TeaGo/actions"
+	"net/http"
+)
+
+type APIPurgeAction actions.Action
+
+// 通过HTTP接口删除缓存，比如：
+// curl -X POST -H "X-Tea-Token: TOKEN" -d "policy=cache.policy.xxx.conf&key=https://example.com/static/*" http://127.0.0.1:7777/cache/api/purge
+func (this *APIPurgeAction) RunPost(params struct {
+	Policy string   // 缓存策略文件名，为空表示所有的缓存策略
+	Key    []string // Key或者带有通配符的规则，可以有多个
+	Token  string
+}) {
+	config, _ := teaconfigs.SharedCacheConfig()
+
+	token := this.Request.Header.Get("X-Tea-Token")
+	if len(token) == 0 {
+		token = params.Token
+	}
+	if len(config.PurgeToken) == 0 || subtle.ConstantTimeCompare([]byte(token), []byte(config.PurgeToken)) != 1 {
+		this.apiResponse(http.StatusForbidden, map[string]interface{}{
+			"code":    http.StatusForbidden,
+			"message": "invalid token",
+		})
+		return
+	}
+
+	if len(params.Key) == 0 {
+		this.apiResponse(http.StatusBadRequest, map[string]interface{}{
+			"code":    http.StatusBadRequest,
+			"message": "'key' should not be empty",
+		})
+		return
+	}
+
+	policies := config.FindAllPolicies()
+	if len(params.Policy) > 0 {
+		policy := config.FindPolicy(params.Policy)
+		if policy == nil {
+			this.apiResponse(http.StatusNotFound, map[string]interface{}{
+				"code":    http.StatusNotFound,
+				"message": "policy '" + params.Policy + "' not found",
+			})
+			return
+		}
+		policies = []*shared.CachePolicy{policy}
+	}
+
+	count := 0
+	for _, policy := range policies {
+		for _, key := range params.Key {
+			c, err := purgePolicy(policy, key)
+			if err != nil {
+				this.apiResponse(http.StatusInternalServerError, map[string]interface{}{
+					"code":    http.StatusInternalServerError,
+					"message": err.Error(),
+				})
+				return
+			}
+			count += c
+		}
+	}
+
+	this.apiResponse(http.StatusOK, map[string]interface{}{
+		"code":    http.StatusOK,
+		"message": "success",
+		"count":   count,
+	})
+}
+
+// 输出JSON
+func (this *APIPurgeAction) apiResponse(statusCode int, data map[string]interface{}) {
+	jsonData, _ := json.Marshal(data)
+	this.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	this.ResponseWriter.WriteHeader(statusCode)
+	this.ResponseWriter.Write(jsonData)
+}
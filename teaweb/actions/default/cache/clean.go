@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"github.com/TeaWeb/code/teacache"
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type CleanAction actions.Action
+
+// 清除缓存策略中的所有缓存
+func (this *CleanAction) Run(params struct {
+	Filename string
+}) {
+	policy := shared.NewCachePolicyFromFile(params.Filename)
+	if policy == nil {
+		this.Fail("找不到要清除的缓存策略")
+	}
+	policy.Validate()
+
+	err := teacache.CleanPolicy(policy)
+	if err != nil {
+		this.Fail("清除失败：" + err.Error())
+	}
+
+	this.Success()
+}
@@ -14,6 +14,7 @@ type CreatePolicyAction actions.Action
 // 缓存缓存策略
 func (this *CreatePolicyAction) Run(params struct{}) {
 	this.Data["types"] = teacache.AllCacheTypes()
+	this.Data["evictionPolicies"] = teacache.AllEvictionPolicies()
 
 	this.Show()
 }
@@ -32,6 +33,13 @@ func (this *CreatePolicyAction) RunPost(params struct {
 	MaxSize      float64
 	MaxSizeUnit  string
 
+	StaleWhileRevalidate int
+	StaleIfError         int
+	StatusHeader         bool
+	Eviction             string
+	Coalescing           bool
+	CoalescingTimeout    int
+
 	Must *actions.Must
 }) {
 	params.Must.
@@ -46,6 +54,13 @@ func (this *CreatePolicyAction) RunPost(params struct {
 	policy.Name = params.Name
 	policy.Key = params.Key
 	policy.Type = params.Type
+	policy.StatusHeader = params.StatusHeader
+	policy.Eviction = params.Eviction
+	policy.Coalescing = params.Coalescing
+	policy.CoalescingTimeout = ""
+	if params.CoalescingTimeout > 0 {
+		policy.CoalescingTimeout = fmt.Sprintf("%ds", params.CoalescingTimeout)
+	}
 
 	if params.IsAdvanced {
 		policy.Capacity = fmt.Sprintf("%.2f%s", params.Capacity, params.CapacityUnit)
@@ -58,6 +73,15 @@ func (this *CreatePolicyAction) RunPost(params struct {
 		}
 		policy.MaxSize = fmt.Sprintf("%.2f%s", params.MaxSize, params.MaxSizeUnit)
 		policy.Status = params.StatusList
+
+		policy.StaleWhileRevalidate = ""
+		if params.StaleWhileRevalidate > 0 {
+			policy.StaleWhileRevalidate = fmt.Sprintf("%ds", params.StaleWhileRevalidate)
+		}
+		policy.StaleIfError = ""
+		if params.StaleIfError > 0 {
+			policy.StaleIfError = fmt.Sprintf("%ds", params.StaleIfError)
+		}
 	} else {
 		policy.Capacity = "0.00g"
 		policy.Life = "72h"
@@ -15,6 +15,16 @@ func init() {
 			GetPost("/createPolicy", new(CreatePolicyAction)).
 			Post("/deletePolicy", new(DeletePolicyAction)).
 			GetPost("/updatePolicy", new(UpdatePolicyAction)).
+			Get("/stat", new(StatAction)).
+			GetPost("/purge", new(PurgeAction)).
+			Post("/clean", new(CleanAction)).
+			GetPost("/purgeToken", new(PurgeTokenAction)).
+			EndAll()
+
+		// 供外部调用的接口，使用令牌认证
+		server.
+			Prefix("/cache/api").
+			Post("/purge", new(APIPurgeAction)).
 			EndAll()
 	})
 }
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"github.com/TeaWeb/code/teacache"
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/iwind/TeaGo/actions"
+	"strings"
+)
+
+type PurgeAction actions.Action
+
+// 删除缓存
+func (this *PurgeAction) Run(params struct {
+	Filename string
+}) {
+	policy := shared.NewCachePolicyFromFile(params.Filename)
+	if policy == nil {
+		this.Fail("找不到缓存策略")
+	}
+
+	this.Data["policy"] = policy
+
+	this.Show()
+}
+
+// 提交删除
+func (this *PurgeAction) RunPost(params struct {
+	Filename string
+	Key      string // Key或者带有通配符的规则
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("key", params.Key).
+		Require("请输入要删除的缓存Key")
+
+	policy := shared.NewCachePolicyFromFile(params.Filename)
+	if policy == nil {
+		this.Fail("找不到缓存策略")
+	}
+	policy.Validate()
+
+	count, err := purgePolicy(policy, params.Key)
+	if err != nil {
+		this.Fail("删除失败：" + err.Error())
+	}
+
+	this.Data["count"] = count
+
+	this.Success()
+}
+
+// 删除缓存，带有通配符时按规则删除
+func purgePolicy(policy *shared.CachePolicy, key string) (count int, err error) {
+	if strings.ContainsAny(key, "*?") {
+		return teacache.PurgePolicy(policy, key)
+	}
+	return teacache.DeletePolicyKey(policy, key)
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/utils/string"
+)
+
+type PurgeTokenAction actions.Action
+
+// 清除缓存接口的令牌
+func (this *PurgeTokenAction) Run(params struct{}) {
+	config, _ := teaconfigs.SharedCacheConfig()
+	this.Data["token"] = config.PurgeToken
+
+	this.Show()
+}
+
+// 重新生成或者关闭令牌
+func (this *PurgeTokenAction) RunPost(params struct {
+	Disable bool
+}) {
+	config, _ := teaconfigs.SharedCacheConfig()
+	if params.Disable {
+		config.PurgeToken = ""
+	} else {
+		config.PurgeToken = stringutil.Rand(32)
+	}
+	err := config.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	this.Data["token"] = config.PurgeToken
+
+	this.Success()
+}
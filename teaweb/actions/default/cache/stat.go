@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"github.com/TeaWeb/code/teacache"
+	"github.com/TeaWeb/code/teaconfigs/shared"
+	"github.com/TeaWeb/code/teamongo"
+	"github.com/TeaWeb/code/teastats"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type StatAction actions.Action
+
+// 缓存统计
+func (this *StatAction) Run(params struct {
+	Filename string
+}) {
+	policy := shared.NewCachePolicyFromFile(params.Filename)
+	if policy == nil {
+		this.Fail("找不到要统计的缓存策略")
+	}
+	policy.Validate()
+
+	count, size, err := teacache.StatPolicy(policy)
+	if err != nil {
+		this.Fail("统计失败：" + err.Error())
+	}
+
+	this.Data["count"] = count
+	this.Data["size"] = size
+
+	// 启动以来的命中统计
+	stat := teacache.FindPolicyStat(policy.Filename)
+	this.Data["hits"] = stat.Hits()
+	this.Data["misses"] = stat.Misses()
+	this.Data["bytesSaved"] = stat.BytesSaved()
+	this.Data["evictions"] = stat.Evictions()
+	this.Data["hitRatio"] = stat.HitRatio()
+
+	// 最近24小时的命中率
+	if teamongo.Test() == nil {
+		this.Data["hourlyStats"] = new(teastats.HourlyCacheStat).ListLatestHours("", policy.Filename, 24)
+	} else {
+		this.Data["hourlyStats"] = []interface{}{}
+	}
+
+	this.Success()
+}
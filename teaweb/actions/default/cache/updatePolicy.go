@@ -22,6 +22,7 @@ func (this *UpdatePolicyAction) Run(params struct {
 	}
 
 	this.Data["types"] = teacache.AllCacheTypes()
+	this.Data["evictionPolicies"] = teacache.AllEvictionPolicies()
 
 	policy.Validate()
 
@@ -31,11 +32,18 @@ func (this *UpdatePolicyAction) Run(params struct {
 		"key":         policy.Key,
 		"type":        policy.Type,
 		"options":     policy.Options,
-		"hasAdvanced": policy.CapacitySize() > 0 || (policy.Life != "72h" && policy.LifeDuration() > 0) || (len(policy.Status) != 1 || !lists.Contains(policy.Status, 200)) || policy.MaxDataSize() > 0,
+		"hasAdvanced": policy.CapacitySize() > 0 || (policy.Life != "72h" && policy.LifeDuration() > 0) || (len(policy.Status) != 1 || !lists.Contains(policy.Status, 200)) || policy.MaxDataSize() > 0 || policy.StaleWhileRevalidateDuration() > 0 || policy.StaleIfErrorDuration() > 0,
 		"life":        policy.Life,
 		"status":      policy.Status,
 		"maxSize":     policy.MaxSize,
 		"capacity":    policy.Capacity,
+
+		"staleWhileRevalidate": int(policy.StaleWhileRevalidateDuration().Seconds()),
+		"staleIfError":         int(policy.StaleIfErrorDuration().Seconds()),
+		"statusHeader":         policy.StatusHeader,
+		"eviction":             policy.Eviction,
+		"coalescing":           policy.Coalescing,
+		"coalescingTimeout":    int(policy.CoalescingTimeoutDuration().Seconds()),
 	}
 
 	this.Show()
@@ -56,6 +64,13 @@ func (this *UpdatePolicyAction) RunPost(params struct {
 	MaxSize      float64
 	MaxSizeUnit  string
 
+	StaleWhileRevalidate int
+	StaleIfError         int
+	StatusHeader         bool
+	Eviction             string
+	Coalescing           bool
+	CoalescingTimeout    int
+
 	Must *actions.Must
 }) {
 	policy := shared.NewCachePolicyFromFile(params.Filename)
@@ -73,6 +88,13 @@ func (this *UpdatePolicyAction) RunPost(params struct {
 	policy.Name = params.Name
 	policy.Key = params.Key
 	policy.Type = params.Type
+	policy.StatusHeader = params.StatusHeader
+	policy.Eviction = params.Eviction
+	policy.Coalescing = params.Coalescing
+	policy.CoalescingTimeout = ""
+	if params.CoalescingTimeout > 0 {
+		policy.CoalescingTimeout = fmt.Sprintf("%ds", params.CoalescingTimeout)
+	}
 
 	if params.IsAdvanced {
 		policy.Capacity = fmt.Sprintf("%.2f%s", params.Capacity, params.CapacityUnit)
@@ -85,6 +107,15 @@ func (this *UpdatePolicyAction) RunPost(params struct {
 		}
 		policy.MaxSize = fmt.Sprintf("%.2f%s", params.MaxSize, params.MaxSizeUnit)
 		policy.Status = params.StatusList
+
+		policy.StaleWhileRevalidate = ""
+		if params.StaleWhileRevalidate > 0 {
+			policy.StaleWhileRevalidate = fmt.Sprintf("%ds", params.StaleWhileRevalidate)
+		}
+		policy.StaleIfError = ""
+		if params.StaleIfError > 0 {
+			policy.StaleIfError = fmt.Sprintf("%ds", params.StaleIfError)
+		}
 	} else {
 		policy.Capacity = "0.00g"
 		policy.Life = "72h"
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaproxy"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type AsyncAction actions.Action
+
+// 异步处理和只记录日志设置
+func (this *AsyncAction) Run(params struct {
+	Server     string
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	this.Data["proxy"] = server
+	this.Data["filename"] = server.Filename
+	this.Data["locationId"] = params.LocationId
+
+	if len(params.LocationId) > 0 {
+		location := server.FindLocation(params.LocationId)
+		if location == nil {
+			this.Fail("找不到要修改的location")
+		}
+		this.Data["selectedTab"] = "location"
+		this.Data["async"] = location.Async
+		this.Data["asyncConfig"] = asyncConfigOrNew(location.AsyncConfig)
+		this.Data["logOnly"] = location.LogOnly
+		this.Data["logOnlyResponse"] = location.LogOnlyResponse
+	} else {
+		this.Data["selectedTab"] = "async"
+		this.Data["async"] = server.Async
+		this.Data["asyncConfig"] = asyncConfigOrNew(server.AsyncConfig)
+		this.Data["logOnly"] = server.LogOnly
+		this.Data["logOnlyResponse"] = server.LogOnlyResponse
+	}
+
+	this.Data["queues"] = queueStats(server.Id, params.LocationId)
+
+	this.Show()
+}
+
+// 保存提交
+func (this *AsyncAction) RunPost(params struct {
+	Server     string
+	LocationId string
+
+	Async              bool
+	AsyncStatus        int
+	AsyncContentType   string
+	AsyncBody          string
+	AsyncQueueSize     int
+	AsyncConcurrency   int
+	AsyncMaxTries      int
+	AsyncRetryInterval string
+	AsyncMaxBodySize   string
+	LogOnly            bool
+	LogOnlyStatus      int
+	LogOnlyContentType string
+	LogOnlyBody        string
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("asyncQueueSize", params.AsyncQueueSize).
+		Gte(0, "队列长度不能小于0").
+		Field("asyncConcurrency", params.AsyncConcurrency).
+		Gte(0, "并发数不能小于0").
+		Field("asyncMaxTries", params.AsyncMaxTries).
+		Gte(0, "最多尝试次数不能小于0")
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	asyncConfig := &teaconfigs.AsyncConfig{
+		Response: &teaconfigs.DirectResponseConfig{
+			Status:      params.AsyncStatus,
+			ContentType: params.AsyncContentType,
+			Body:        params.AsyncBody,
+		},
+		QueueSize:     params.AsyncQueueSize,
+		Concurrency:   params.AsyncConcurrency,
+		MaxTries:      params.AsyncMaxTries,
+		RetryInterval: params.AsyncRetryInterval,
+		MaxBodySize:   params.AsyncMaxBodySize,
+	}
+	logOnlyResponse := &teaconfigs.DirectResponseConfig{
+		Status:      params.LogOnlyStatus,
+		ContentType: params.LogOnlyContentType,
+		Body:        params.LogOnlyBody,
+	}
+
+	if len(params.LocationId) > 0 {
+		location := server.FindLocation(params.LocationId)
+		if location == nil {
+			this.Fail("找不到要修改的location")
+		}
+		location.Async = params.Async
+		location.AsyncConfig = asyncConfig
+		location.LogOnly = params.LogOnly
+		location.LogOnlyResponse = logOnlyResponse
+	} else {
+		server.Async = params.Async
+		server.AsyncConfig = asyncConfig
+		server.LogOnly = params.LogOnly
+		server.LogOnlyResponse = logOnlyResponse
+	}
+
+	err = server.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
+
+// 没有设置时使用默认设置
+func asyncConfigOrNew(config *teaconfigs.AsyncConfig) *teaconfigs.AsyncConfig {
+	if config == nil {
+		return teaconfigs.NewAsyncConfig()
+	}
+	return config
+}
+
+// 队列状态
+func queueStats(serverId string, locationId string) []*teaproxy.AsyncQueueStat {
+	result := []*teaproxy.AsyncQueueStat{}
+	for _, stat := range teaproxy.AsyncQueueStats(serverId) {
+		if len(locationId) > 0 && stat.LocationId != locationId {
+			continue
+		}
+		result = append(result, stat)
+	}
+	return result
+}
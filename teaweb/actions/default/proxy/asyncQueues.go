@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type AsyncQueuesAction actions.Action
+
+// 异步处理队列状态，包括排队数和失败数
+func (this *AsyncQueuesAction) Run(params struct {
+	Server     string
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["queues"] = queueStats(server.Id, params.LocationId)
+
+	this.Success()
+}
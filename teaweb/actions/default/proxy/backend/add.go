@@ -52,7 +52,22 @@ func (this *AddAction) RunPost(params struct {
 	MaxFails    uint
 	MaxConns    uint
 	IsBackup    bool
-	Must        *actions.Must
+
+	ConnectTimeout    uint
+	ReadHeaderTimeout uint
+	IdleTimeout       uint
+	Timeout           int
+
+	Scheme                string
+	TlsServerName         string
+	TlsInsecureSkipVerify bool
+	TlsCAFile             *actions.File
+	TlsCertFile           *actions.File
+	TlsKeyFile            *actions.File
+
+	HTTP2 bool
+
+	Must *actions.Must
 }) {
 	params.Must.
 		Field("address", params.Address).
@@ -73,6 +88,13 @@ func (this *AddAction) RunPost(params struct {
 	backend.MaxFails = params.MaxFails
 	backend.MaxConns = params.MaxConns
 	backend.IsBackup = params.IsBackup
+	backend.HTTP2 = params.HTTP2
+	updateBackendTimeouts(backend, params.ConnectTimeout, params.ReadHeaderTimeout, params.IdleTimeout, params.Timeout)
+
+	err = updateBackendTLS(backend, params.Scheme, params.TlsServerName, params.TlsInsecureSkipVerify, params.TlsCAFile, params.TlsCertFile, params.TlsKeyFile)
+	if err != nil {
+		this.Fail("TLS设置错误：" + err.Error())
+	}
 
 	backendList, err := server.FindBackendList(params.LocationId, params.Websocket)
 	if err != nil {
@@ -26,7 +26,7 @@ func (this *ClearFailsAction) Run(params struct {
 		if backendList != nil {
 			backend := backendList.FindBackend(params.BackendId)
 			if backend != nil {
-				backend.CurrentFails = 0
+				backend.ResetFails()
 			}
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"github.com/TeaWeb/code/teaconfigs"
 	"github.com/TeaWeb/code/teaconfigs/scheduling"
 	"github.com/TeaWeb/code/teaproxy"
+	"github.com/TeaWeb/code/teastats"
 	"github.com/iwind/TeaGo/actions"
 )
 
@@ -34,10 +35,8 @@ func (this *DataAction) Run(params struct {
 			if err == nil {
 				runningBackend := runningBackendList.FindBackend(backend.Id)
 				if runningBackend != nil {
-					backend.IsDown = runningBackend.IsDown
-					backend.DownTime = runningBackend.DownTime
-					backend.CurrentFails = runningBackend.CurrentFails
-					backend.CurrentConns = runningBackend.CurrentConns
+					backend.CopyState(runningBackend)
+					backend.CurrentConns = runningBackend.CandidateConns()
 				}
 			}
 		}
@@ -51,6 +50,18 @@ func (this *DataAction) Run(params struct {
 
 	this.Data["normalBackends"] = normalBackends
 	this.Data["backupBackends"] = backupBackends
+	this.Data["healthCheck"] = backendList.HealthCheckConfig()
+
+	// 最近24小时的健康检查结果
+	healthStats := map[string][]map[string]interface{}{}
+	if backendList.HealthCheckConfig() != nil && backendList.HealthCheckConfig().On {
+		for _, backend := range backendList.AllBackends() {
+			healthStats[backend.Id] = teastats.SharedBackendHealthStat().ListLatestHours(server.Id, backend.Id, 24)
+		}
+	}
+	this.Data["healthStats"] = healthStats
+	this.Data["retry"] = backendList.RetryConfig()
+	this.Data["grpc"] = backendList.IsGRPC()
 
 	// 算法
 	schedulingConfig := backendList.SchedulingConfig()
@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type GRPCAction actions.Action
+
+// 开启或关闭gRPC模式
+func (this *GRPCAction) Run(params struct {
+	Server     string
+	LocationId string
+	Websocket  bool
+	On         bool
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	backendList, err := server.FindBackendList(params.LocationId, params.Websocket)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	backendList.SetGRPC(params.On)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
@@ -0,0 +1,113 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/types"
+)
+
+type HealthCheckAction actions.Action
+
+// 健康检查设置
+func (this *HealthCheckAction) Run(params struct {
+	Server     string
+	LocationId string
+	Websocket  bool
+	From       string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["proxy"] = server
+	this.Data["filename"] = server.Filename
+	if len(params.LocationId) > 0 {
+		this.Data["selectedTab"] = "location"
+	} else {
+		this.Data["selectedTab"] = "backend"
+	}
+	this.Data["locationId"] = params.LocationId
+	this.Data["websocket"] = types.Int(params.Websocket)
+	this.Data["from"] = params.From
+
+	backendList, err := server.FindBackendList(params.LocationId, params.Websocket)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	healthCheck := backendList.HealthCheckConfig()
+	if healthCheck == nil {
+		healthCheck = teaconfigs.NewHealthCheckConfig()
+		healthCheck.On = false
+	}
+	healthCheck.Validate()
+
+	this.Data["healthCheck"] = healthCheck
+	this.Data["interval"] = int(healthCheck.IntervalDuration().Seconds())
+	this.Data["timeout"] = int(healthCheck.TimeoutDuration().Seconds())
+	this.Data["types"] = teaconfigs.AllHealthCheckTypes()
+
+	this.Show()
+}
+
+// 保存提交
+func (this *HealthCheckAction) RunPost(params struct {
+	Server             string
+	LocationId         string
+	Websocket          bool
+	On                 bool
+	Type               string
+	URL                string
+	Interval           uint
+	Timeout            uint
+	HealthyThreshold   uint
+	UnhealthyThreshold uint
+	StatusCodes        []int
+	Must               *actions.Must
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	backendList, err := server.FindBackendList(params.LocationId, params.Websocket)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	if params.On && params.Type == teaconfigs.HealthCheckTypeHTTP {
+		params.Must.
+			Field("url", params.URL).
+			Require("请输入检查的URL")
+	}
+
+	healthCheck := teaconfigs.NewHealthCheckConfig()
+	healthCheck.On = params.On
+	healthCheck.Type = params.Type
+	healthCheck.URL = params.URL
+	healthCheck.Interval = fmt.Sprintf("%ds", params.Interval)
+	healthCheck.Timeout = fmt.Sprintf("%ds", params.Timeout)
+	healthCheck.HealthyThreshold = params.HealthyThreshold
+	healthCheck.UnhealthyThreshold = params.UnhealthyThreshold
+	healthCheck.StatusCodes = params.StatusCodes
+
+	err = healthCheck.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	backendList.SetHealthCheckConfig(healthCheck)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	if len(backendList.AllBackends()) > 0 {
+		proxyutils.NotifyChange()
+	}
+
+	this.Success()
+}
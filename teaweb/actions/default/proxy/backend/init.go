@@ -22,6 +22,9 @@ func init() {
 			GetPost("/update", new(UpdateAction)).
 			Post("/delete", new(DeleteAction)).
 			GetPost("/scheduling", new(SchedulingAction)).
+			GetPost("/healthCheck", new(HealthCheckAction)).
+			GetPost("/retry", new(RetryAction)).
+			Post("/grpc", new(GRPCAction)).
 			Post("/online", new(OnlineAction)).
 			Post("/clearFails", new(ClearFailsAction)).
 			Prefix("").
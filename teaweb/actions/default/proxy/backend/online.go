@@ -26,8 +26,7 @@ func (this *OnlineAction) Run(params struct {
 		if backendList != nil {
 			backend := backendList.FindBackend(params.BackendId)
 			if backend != nil {
-				backend.IsDown = false
-				backend.CurrentFails = 0
+				backend.SetDown(false)
 				runningServer.SetupScheduling(false)
 			}
 		}
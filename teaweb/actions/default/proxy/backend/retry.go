@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/types"
+)
+
+type RetryAction actions.Action
+
+// 重试和故障转移设置
+func (this *RetryAction) Run(params struct {
+	Server     string
+	LocationId string
+	Websocket  bool
+	From       string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["proxy"] = server
+	this.Data["filename"] = server.Filename
+	if len(params.LocationId) > 0 {
+		this.Data["selectedTab"] = "location"
+	} else {
+		this.Data["selectedTab"] = "backend"
+	}
+	this.Data["locationId"] = params.LocationId
+	this.Data["websocket"] = types.Int(params.Websocket)
+	this.Data["from"] = params.From
+
+	backendList, err := server.FindBackendList(params.LocationId, params.Websocket)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	retry := backendList.RetryConfig()
+	if retry == nil {
+		retry = teaconfigs.NewRetryConfig()
+		retry.On = false
+	}
+	retry.Validate()
+
+	this.Data["retry"] = retry
+	this.Data["tryTimeout"] = int(retry.TryTimeoutDuration().Seconds())
+	this.Data["errorTypes"] = teaconfigs.AllRetryErrorTypes()
+
+	this.Show()
+}
+
+// 保存提交
+func (this *RetryAction) RunPost(params struct {
+	Server        string
+	LocationId    string
+	Websocket     bool
+	On            bool
+	MaxTries      int
+	TryTimeout    uint
+	Errors        []string
+	StatusCodes   []int
+	NonIdempotent bool
+	Must          *actions.Must
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	backendList, err := server.FindBackendList(params.LocationId, params.Websocket)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	if params.On {
+		params.Must.
+			Field("maxTries", params.MaxTries).
+			Gt(1, "最多尝试次数需要大于1")
+	}
+
+	retry := teaconfigs.NewRetryConfig()
+	retry.On = params.On
+	retry.MaxTries = params.MaxTries
+	if params.TryTimeout > 0 {
+		retry.TryTimeout = fmt.Sprintf("%ds", params.TryTimeout)
+	}
+	retry.Errors = params.Errors
+	retry.StatusCodes = params.StatusCodes
+	retry.NonIdempotent = params.NonIdempotent
+
+	err = retry.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	backendList.SetRetryConfig(retry)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	if len(backendList.AllBackends()) > 0 {
+		proxyutils.NotifyChange()
+	}
+
+	this.Success()
+}
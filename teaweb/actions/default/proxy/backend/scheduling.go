@@ -72,7 +72,7 @@ func (this *SchedulingAction) RunPost(params struct {
 	}
 
 	options := maps.Map{}
-	if params.Type == "hash" {
+	if params.Type == "hash" || params.Type == "consistentHash" {
 		params.Must.
 			Field("hashKey", params.HashKey).
 			Require("请输入Key")
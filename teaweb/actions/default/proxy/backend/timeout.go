@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/TeaWeb/code/teaconfigs"
+)
+
+// 设置后端服务的超时时间，单位为秒，0表示使用默认值，整个请求的超时时间小于0表示不限
+func updateBackendTimeouts(backend *teaconfigs.BackendConfig, connectTimeout uint, readHeaderTimeout uint, idleTimeout uint, timeout int) {
+	backend.ConnectTimeout = ""
+	if connectTimeout > 0 {
+		backend.ConnectTimeout = fmt.Sprintf("%ds", connectTimeout)
+	}
+
+	backend.ReadHeaderTimeout = ""
+	if readHeaderTimeout > 0 {
+		backend.ReadHeaderTimeout = fmt.Sprintf("%ds", readHeaderTimeout)
+	}
+
+	backend.IdleTimeout = ""
+	if idleTimeout > 0 {
+		backend.IdleTimeout = fmt.Sprintf("%ds", idleTimeout)
+	}
+
+	if timeout < 0 {
+		backend.Timeout = "0s"
+	} else if timeout > 0 {
+		backend.Timeout = fmt.Sprintf("%ds", timeout)
+	} else {
+		backend.Timeout = ""
+	}
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/files"
+	"github.com/iwind/TeaGo/utils/string"
+)
+
+// 设置后端服务的协议和TLS配置
+func updateBackendTLS(backend *teaconfigs.BackendConfig, scheme string, serverName string, insecureSkipVerify bool, caFile *actions.File, certFile *actions.File, keyFile *actions.File) error {
+	if scheme != teaconfigs.BackendSchemeHTTPS {
+		backend.Scheme = teaconfigs.BackendSchemeHTTP
+		return nil
+	}
+
+	backend.Scheme = teaconfigs.BackendSchemeHTTPS
+	if backend.TLS == nil {
+		backend.TLS = teaconfigs.NewBackendTLSConfig()
+	}
+	backend.TLS.ServerName = serverName
+	backend.TLS.InsecureSkipVerify = insecureSkipVerify
+
+	for _, f := range []struct {
+		file   *actions.File
+		target *string
+	}{
+		{caFile, &backend.TLS.CACertificates},
+		{certFile, &backend.TLS.Certificate},
+		{keyFile, &backend.TLS.CertificateKey},
+	} {
+		if f.file == nil {
+			continue
+		}
+		data, err := f.file.Read()
+		if err != nil {
+			return err
+		}
+		filename := "ssl." + stringutil.Rand(16) + f.file.Ext
+		err = files.NewFile(Tea.ConfigFile(filename)).Write(data)
+		if err != nil {
+			return err
+		}
+		*f.target = filename
+	}
+
+	return backend.TLS.Validate()
+}
@@ -46,6 +46,12 @@ func (this *UpdateAction) Run(params struct {
 
 	backend.Validate()
 
+	// 整个请求的超时时间，-1表示不限
+	timeout := int(backend.TimeoutDuration().Seconds())
+	if timeout == 0 {
+		timeout = -1
+	}
+
 	this.Data["backend"] = maps.Map{
 		"id":          backend.Id,
 		"address":     backend.Address,
@@ -57,6 +63,14 @@ func (this *UpdateAction) Run(params struct {
 		"maxFails":    backend.MaxFails,
 		"isDown":      backend.IsDown,
 		"isBackup":    backend.IsBackup,
+		"scheme":      backend.Scheme,
+		"tls":         backend.TLS,
+		"http2":       backend.HTTP2,
+
+		"connectTimeout":    int(backend.ConnectTimeoutDuration().Seconds()),
+		"readHeaderTimeout": int(backend.ReadHeaderTimeoutDuration().Seconds()),
+		"idleTimeout":       int(backend.IdleTimeoutDuration().Seconds()),
+		"timeout":           timeout,
 	}
 
 	this.Show()
@@ -76,7 +90,22 @@ func (this *UpdateAction) RunPost(params struct {
 	MaxFails    uint
 	MaxConns    uint
 	IsBackup    bool
-	Must        *actions.Must
+
+	ConnectTimeout    uint
+	ReadHeaderTimeout uint
+	IdleTimeout       uint
+	Timeout           int
+
+	Scheme                string
+	TlsServerName         string
+	TlsInsecureSkipVerify bool
+	TlsCAFile             *actions.File
+	TlsCertFile           *actions.File
+	TlsKeyFile            *actions.File
+
+	HTTP2 bool
+
+	Must *actions.Must
 }) {
 	params.Must.
 		Field("address", params.Address).
@@ -106,6 +135,13 @@ func (this *UpdateAction) RunPost(params struct {
 	backend.MaxFails = params.MaxFails
 	backend.MaxConns = params.MaxConns
 	backend.IsBackup = params.IsBackup
+	backend.HTTP2 = params.HTTP2
+	updateBackendTimeouts(backend, params.ConnectTimeout, params.ReadHeaderTimeout, params.IdleTimeout, params.Timeout)
+
+	err = updateBackendTLS(backend, params.Scheme, params.TlsServerName, params.TlsInsecureSkipVerify, params.TlsCAFile, params.TlsCertFile, params.TlsKeyFile)
+	if err != nil {
+		this.Fail("TLS设置错误：" + err.Error())
+	}
 
 	err = server.Save()
 	if err != nil {
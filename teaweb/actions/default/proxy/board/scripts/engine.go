@@ -57,17 +57,29 @@ func (this *Engine) SetContext(context *Context) {
 					runningBackend := runningServer.FindBackend(backend.Id)
 					if runningBackend != nil {
 						backend.IsDown = runningBackend.IsDown
+						backend.CheckOk = runningBackend.CheckOk
+						backend.CheckTime = runningBackend.CheckTime
+						backend.CheckError = runningBackend.CheckError
 					}
 				}
 
+				checkTime := ""
+				if !backend.CheckTime.IsZero() {
+					checkTime = backend.CheckTime.Format("2006-01-02 15:04:05")
+				}
+
 				return map[string]interface{}{
-					"on":       backend.On,
-					"weight":   backend.Weight,
-					"id":       backend.Id,
-					"isDown":   backend.IsDown,
-					"isBackup": backend.IsBackup,
-					"name":     backend.Name,
-					"address":  backend.Address,
+					"on":         backend.On,
+					"weight":     backend.Weight,
+					"id":         backend.Id,
+					"isDown":     backend.IsDown,
+					"isBackup":   backend.IsBackup,
+					"name":       backend.Name,
+					"address":    backend.Address,
+					"checkOn":    context.Server.HealthCheck != nil && context.Server.HealthCheck.On,
+					"checkOk":    backend.CheckOk,
+					"checkTime":  checkTime,
+					"checkError": backend.CheckError,
 				}
 			}),
 			"locations": lists.Map(context.Server.Locations, func(k int, v interface{}) interface{} {
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teautils"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+	"strings"
+)
+
+type ClientIPAction actions.Action
+
+// 终端地址设置：受信任的代理和PROXY协议
+func (this *ClientIPAction) Run(params struct {
+	Server string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	this.Data["proxy"] = server
+	this.Data["filename"] = server.Filename
+	this.Data["selectedTab"] = "clientIP"
+
+	if server.TrustedProxies == nil {
+		server.TrustedProxies = []string{}
+	}
+	this.Data["trustedProxies"] = server.TrustedProxies
+	this.Data["proxyProtocol"] = server.ProxyProtocol
+
+	this.Show()
+}
+
+// 保存提交
+func (this *ClientIPAction) RunPost(params struct {
+	Server         string
+	TrustedProxies []string
+	ProxyProtocol  bool
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	trustedProxies := []string{}
+	for _, trustedProxy := range params.TrustedProxies {
+		trustedProxy = strings.TrimSpace(trustedProxy)
+		if len(trustedProxy) == 0 {
+			continue
+		}
+		_, err := teautils.ParseIPRange(trustedProxy)
+		if err != nil {
+			this.Fail("受信任的代理地址'" + trustedProxy + "'格式错误")
+		}
+		trustedProxies = append(trustedProxies, trustedProxy)
+	}
+
+	server.TrustedProxies = trustedProxies
+	server.ProxyProtocol = params.ProxyProtocol
+	err = server.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
@@ -19,6 +19,10 @@ func init() {
 			GetPost("/add", new(AddAction)).
 			GetPost("/delete", new(DeleteAction)).
 			GetPost("/update", new(UpdateAction)).
+			GetPost("/clientIP", new(ClientIPAction)).
+			GetPost("/async", new(AsyncAction)).
+			Get("/asyncQueues", new(AsyncQueuesAction)).
+			GetPost("/stream", new(StreamAction)).
 			Get("/detail", new(DetailAction)).
 			Get("/localPath", new(LocalPathAction)).
 			Get("/frontend", new(FrontendAction)).
@@ -0,0 +1,80 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type AddConnAction actions.Action
+
+// 添加连接数限制
+func (this *AddConnAction) Run(params struct {
+	Server     string
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["storeTypes"] = teaconfigs.AllLimitStoreTypes()
+
+	this.Show()
+}
+
+// 提交保存
+func (this *AddConnAction) RunPost(params struct {
+	Server     string
+	LocationId string
+
+	On       bool
+	Key      string
+	MaxConns int
+
+	StoreType     string
+	StoreNetwork  string
+	StoreHost     string
+	StorePort     int
+	StorePassword string
+	StoreSock     string
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("maxConns", params.MaxConns).
+		Gt(0, "请输入大于0的连接数")
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limitList, err := server.FindLimitList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limit := teaconfigs.NewConnLimitConfig()
+	limit.On = params.On
+	limit.Key = params.Key
+	limit.MaxConns = params.MaxConns
+	limit.Store = newStoreConfig(params.StoreType, params.StoreNetwork, params.StoreHost, params.StorePort, params.StorePassword, params.StoreSock)
+	err = limit.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+	limitList.AddConnLimit(limit)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
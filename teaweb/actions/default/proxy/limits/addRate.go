@@ -0,0 +1,89 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type AddRateAction actions.Action
+
+// 添加速率限制
+func (this *AddRateAction) Run(params struct {
+	Server     string
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["modes"] = teaconfigs.AllRateLimitModes()
+	this.Data["storeTypes"] = teaconfigs.AllLimitStoreTypes()
+
+	this.Show()
+}
+
+// 提交保存
+func (this *AddRateAction) RunPost(params struct {
+	Server     string
+	LocationId string
+
+	On       bool
+	Key      string
+	Rate     int
+	Period   string
+	Burst    int
+	Mode     string
+	MaxDelay string
+
+	StoreType     string
+	StoreNetwork  string
+	StoreHost     string
+	StorePort     int
+	StorePassword string
+	StoreSock     string
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("rate", params.Rate).
+		Gt(0, "请输入大于0的请求数")
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limitList, err := server.FindLimitList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limit := teaconfigs.NewRateLimitConfig()
+	limit.On = params.On
+	limit.Key = params.Key
+	limit.Rate = params.Rate
+	limit.Period = params.Period
+	limit.Burst = params.Burst
+	limit.Mode = params.Mode
+	limit.MaxDelay = params.MaxDelay
+	limit.Store = newStoreConfig(params.StoreType, params.StoreNetwork, params.StoreHost, params.StorePort, params.StorePassword, params.StoreSock)
+	err = limit.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+	limitList.AddRateLimit(limit)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
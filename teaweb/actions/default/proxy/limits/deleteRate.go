@@ -0,0 +1,36 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type DeleteRateAction actions.Action
+
+// 删除速率限制
+func (this *DeleteRateAction) Run(params struct {
+	Server     string
+	LocationId string
+	LimitId    string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limitList, err := server.FindLimitList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	limitList.RemoveRateLimit(params.LimitId)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
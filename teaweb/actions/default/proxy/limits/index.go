@@ -0,0 +1,39 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type IndexAction actions.Action
+
+// 速率和连接数限制
+func (this *IndexAction) Run(params struct {
+	Server     string // 必填
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limitList, err := server.FindLimitList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	if len(params.LocationId) > 0 {
+		this.Data["selectedTab"] = "location"
+	} else {
+		this.Data["selectedTab"] = "limit"
+	}
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["rateLimits"] = limitList.AllRateLimits()
+	this.Data["connLimits"] = limitList.AllConnLimits()
+	this.Data["modes"] = teaconfigs.AllRateLimitModes()
+	this.Data["storeTypes"] = teaconfigs.AllLimitStoreTypes()
+
+	this.Show()
+}
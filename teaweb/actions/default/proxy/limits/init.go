@@ -0,0 +1,27 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy"
+	"github.com/TeaWeb/code/teaweb/configs"
+	"github.com/TeaWeb/code/teaweb/helpers"
+	"github.com/iwind/TeaGo"
+)
+
+func init() {
+	TeaGo.BeforeStart(func(server *TeaGo.Server) {
+		server.
+			Helper(&helpers.UserMustAuth{
+				Grant: configs.AdminGrantProxy,
+			}).
+			Helper(new(proxy.Helper)).
+			Prefix("/proxy/limits").
+			Get("", new(IndexAction)).
+			GetPost("/addRate", new(AddRateAction)).
+			GetPost("/updateRate", new(UpdateRateAction)).
+			Post("/deleteRate", new(DeleteRateAction)).
+			GetPost("/addConn", new(AddConnAction)).
+			GetPost("/updateConn", new(UpdateConnAction)).
+			Post("/deleteConn", new(DeleteConnAction)).
+			EndAll()
+	})
+}
@@ -0,0 +1,96 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type UpdateConnAction actions.Action
+
+// 修改连接数限制
+func (this *UpdateConnAction) Run(params struct {
+	Server     string
+	LocationId string
+	LimitId    string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limitList, err := server.FindLimitList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limit := limitList.FindConnLimit(params.LimitId)
+	if limit == nil {
+		this.Fail("找不到要修改的连接数限制")
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["limit"] = limit
+	this.Data["storeTypes"] = teaconfigs.AllLimitStoreTypes()
+
+	this.Show()
+}
+
+// 提交修改
+func (this *UpdateConnAction) RunPost(params struct {
+	Server     string
+	LocationId string
+	LimitId    string
+
+	On       bool
+	Key      string
+	MaxConns int
+
+	StoreType     string
+	StoreNetwork  string
+	StoreHost     string
+	StorePort     int
+	StorePassword string
+	StoreSock     string
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("maxConns", params.MaxConns).
+		Gt(0, "请输入大于0的连接数")
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limitList, err := server.FindLimitList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	limit := limitList.FindConnLimit(params.LimitId)
+	if limit == nil {
+		this.Fail("找不到要修改的连接数限制")
+	}
+
+	limit.On = params.On
+	limit.Key = params.Key
+	limit.MaxConns = params.MaxConns
+	limit.Store = newStoreConfig(params.StoreType, params.StoreNetwork, params.StoreHost, params.StorePort, params.StorePassword, params.StoreSock)
+	err = limit.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
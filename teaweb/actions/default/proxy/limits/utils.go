@@ -0,0 +1,25 @@
+package limits
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+)
+
+// 根据参数构造存储配置，内存存储返回nil
+func newStoreConfig(storeType string, network string, host string, port int, password string, sock string) *teaconfigs.LimitStoreConfig {
+	if storeType != teaconfigs.LimitStoreTypeRedis {
+		return nil
+	}
+	if len(network) == 0 {
+		network = "tcp"
+	}
+	return &teaconfigs.LimitStoreConfig{
+		Type: teaconfigs.LimitStoreTypeRedis,
+		Options: map[string]interface{}{
+			"network":  network,
+			"host":     host,
+			"port":     port,
+			"password": password,
+			"sock":     sock,
+		},
+	}
+}
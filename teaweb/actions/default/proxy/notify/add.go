@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type AddAction actions.Action
+
+// 添加请求镜像
+func (this *AddAction) Run(params struct {
+	Server     string
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+
+	this.Show()
+}
+
+// 提交保存
+func (this *AddAction) RunPost(params struct {
+	Server     string
+	LocationId string
+
+	On          bool
+	Address     string
+	Host        string
+	Percent     float64
+	Timeout     string
+	MaxBodySize string
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("address", params.Address).
+		Require("请输入镜像地址")
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notifyList, err := server.FindNotifyList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notify := teaconfigs.NewNotifyConfig()
+	notify.On = params.On
+	notify.Address = params.Address
+	notify.Host = params.Host
+	notify.Percent = params.Percent
+	notify.Timeout = params.Timeout
+	notify.MaxBodySize = params.MaxBodySize
+	err = notify.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+	notifyList.AddNotify(notify)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
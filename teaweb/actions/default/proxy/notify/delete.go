@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type DeleteAction actions.Action
+
+// 删除请求镜像
+func (this *DeleteAction) Run(params struct {
+	Server     string
+	LocationId string
+	NotifyId   string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notifyList, err := server.FindNotifyList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	notifyList.RemoveNotify(params.NotifyId)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type IndexAction actions.Action
+
+// 请求镜像
+func (this *IndexAction) Run(params struct {
+	Server     string // 必填
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notifyList, err := server.FindNotifyList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	if len(params.LocationId) > 0 {
+		this.Data["selectedTab"] = "location"
+	} else {
+		this.Data["selectedTab"] = "notify"
+	}
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["notifyList"] = notifyList.AllNotify()
+
+	this.Show()
+}
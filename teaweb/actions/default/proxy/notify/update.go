@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type UpdateAction actions.Action
+
+// 修改请求镜像
+func (this *UpdateAction) Run(params struct {
+	Server     string
+	LocationId string
+	NotifyId   string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notifyList, err := server.FindNotifyList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notify := notifyList.FindNotify(params.NotifyId)
+	if notify == nil {
+		this.Fail("找不到要修改的请求镜像")
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["notify"] = notify
+
+	this.Show()
+}
+
+// 提交修改
+func (this *UpdateAction) RunPost(params struct {
+	Server     string
+	LocationId string
+	NotifyId   string
+
+	On          bool
+	Address     string
+	Host        string
+	Percent     float64
+	Timeout     string
+	MaxBodySize string
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("address", params.Address).
+		Require("请输入镜像地址")
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notifyList, err := server.FindNotifyList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	notify := notifyList.FindNotify(params.NotifyId)
+	if notify == nil {
+		this.Fail("找不到要修改的请求镜像")
+	}
+
+	notify.On = params.On
+	notify.Address = params.Address
+	notify.Host = params.Host
+	notify.Percent = params.Percent
+	notify.Timeout = params.Timeout
+	notify.MaxBodySize = params.MaxBodySize
+	err = notify.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
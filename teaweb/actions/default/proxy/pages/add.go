@@ -0,0 +1,91 @@
+package pages
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type AddAction actions.Action
+
+// 添加错误页面
+func (this *AddAction) Run(params struct {
+	Server     string
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["pageTypes"] = teaconfigs.AllErrorPageTypes()
+
+	this.Show()
+}
+
+// 提交保存
+func (this *AddAction) RunPost(params struct {
+	Server     string
+	LocationId string
+
+	On          bool
+	Status      string
+	Type        string
+	File        string
+	URL         string
+	Template    string
+	ContentType string
+	NewStatus   int
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("status", params.Status).
+		Require("请输入状态码")
+	if params.Type == teaconfigs.ErrorPageTypeFile {
+		params.Must.
+			Field("file", params.File).
+			Require("请输入文件路径")
+	} else if params.Type == teaconfigs.ErrorPageTypeURL {
+		params.Must.
+			Field("url", params.URL).
+			Require("请输入内部URI")
+	}
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	pageList, err := server.FindErrorPageList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	page := teaconfigs.NewErrorPageConfig()
+	page.On = params.On
+	page.Status = parseStatusList(params.Status)
+	page.Type = params.Type
+	page.File = params.File
+	page.URL = params.URL
+	page.Template = params.Template
+	page.ContentType = params.ContentType
+	page.NewStatus = params.NewStatus
+	err = page.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+	pageList.AddErrorPage(page)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
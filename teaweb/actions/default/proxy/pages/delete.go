@@ -0,0 +1,36 @@
+package pages
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type DeleteAction actions.Action
+
+// 删除错误页面
+func (this *DeleteAction) Run(params struct {
+	Server     string
+	LocationId string
+	PageId     string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	pageList, err := server.FindErrorPageList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	pageList.RemoveErrorPage(params.PageId)
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
@@ -0,0 +1,37 @@
+package pages
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type IndexAction actions.Action
+
+// 自定义错误页面
+func (this *IndexAction) Run(params struct {
+	Server     string // 必填
+	LocationId string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	pageList, err := server.FindErrorPageList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	if len(params.LocationId) > 0 {
+		this.Data["selectedTab"] = "location"
+	} else {
+		this.Data["selectedTab"] = "page"
+	}
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["pages"] = pageList.AllErrorPages()
+	this.Data["pageTypes"] = teaconfigs.AllErrorPageTypes()
+
+	this.Show()
+}
@@ -0,0 +1,109 @@
+package pages
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+	"strings"
+)
+
+type UpdateAction actions.Action
+
+// 修改错误页面
+func (this *UpdateAction) Run(params struct {
+	Server     string
+	LocationId string
+	PageId     string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	pageList, err := server.FindErrorPageList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	page := pageList.FindErrorPage(params.PageId)
+	if page == nil {
+		this.Fail("找不到要修改的错误页面")
+	}
+
+	this.Data["filename"] = params.Server
+	this.Data["proxy"] = server
+	this.Data["locationId"] = params.LocationId
+	this.Data["page"] = page
+	this.Data["status"] = strings.Join(page.Status, ", ")
+	this.Data["pageTypes"] = teaconfigs.AllErrorPageTypes()
+
+	this.Show()
+}
+
+// 提交修改
+func (this *UpdateAction) RunPost(params struct {
+	Server     string
+	LocationId string
+	PageId     string
+
+	On          bool
+	Status      string
+	Type        string
+	File        string
+	URL         string
+	Template    string
+	ContentType string
+	NewStatus   int
+
+	Must *actions.Must
+}) {
+	params.Must.
+		Field("status", params.Status).
+		Require("请输入状态码")
+	if params.Type == teaconfigs.ErrorPageTypeFile {
+		params.Must.
+			Field("file", params.File).
+			Require("请输入文件路径")
+	} else if params.Type == teaconfigs.ErrorPageTypeURL {
+		params.Must.
+			Field("url", params.URL).
+			Require("请输入内部URI")
+	}
+
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	pageList, err := server.FindErrorPageList(params.LocationId)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	page := pageList.FindErrorPage(params.PageId)
+	if page == nil {
+		this.Fail("找不到要修改的错误页面")
+	}
+
+	page.On = params.On
+	page.Status = parseStatusList(params.Status)
+	page.Type = params.Type
+	page.File = params.File
+	page.URL = params.URL
+	page.Template = params.Template
+	page.ContentType = params.ContentType
+	page.NewStatus = params.NewStatus
+	err = page.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
@@ -0,0 +1,10 @@
+package pages
+
+import "strings"
+
+// 分析状态码列表，多个状态码用逗号、空格或换行分隔
+func parseStatusList(status string) []string {
+	return strings.FieldsFunc(status, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+}
@@ -8,8 +8,12 @@ import (
 
 type RestartAction actions.Action
 
+// 重新加载配置，已有的连接不受影响
 func (this *RestartAction) Run(params struct{}) {
-	teaproxy.Restart()
+	err := teaproxy.Reload()
+	if err != nil {
+		this.Fail("重新加载失败：" + err.Error())
+	}
 
 	proxyutils.FinishChange()
 
@@ -1,6 +1,7 @@
 package ssl
 
 import (
+	"github.com/TeaWeb/code/teaacme"
 	"github.com/TeaWeb/code/teaconfigs"
 	"github.com/iwind/TeaGo/actions"
 )
@@ -20,5 +21,13 @@ func (this *IndexAction) Run(params struct {
 	this.Data["filename"] = params.Server
 	this.Data["proxy"] = proxy
 
+	// 自动申请证书的状态
+	status := teaacme.SharedManager.FindStatus(proxy.Id)
+	if status != nil {
+		this.Data["acmeStatus"] = status.AsMap()
+	} else {
+		this.Data["acmeStatus"] = nil
+	}
+
 	this.Show()
 }
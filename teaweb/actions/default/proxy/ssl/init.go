@@ -18,6 +18,7 @@ func init() {
 			Prefix("/proxy/ssl").
 			Get("", new(IndexAction)).
 			GetPost("/update", new(UpdateAction)).
+			Post("/renew", new(RenewAction)).
 			Prefix("").
 			EndAll()
 	})
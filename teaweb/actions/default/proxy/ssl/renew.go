@@ -0,0 +1,26 @@
+package ssl
+
+import (
+	"github.com/TeaWeb/code/teaacme"
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/iwind/TeaGo/actions"
+)
+
+type RenewAction actions.Action
+
+// 立即申请证书
+func (this *RenewAction) Run(params struct {
+	Server string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	err = teaacme.SharedManager.Renew(server.Id)
+	if err != nil {
+		this.Fail("申请失败：" + err.Error())
+	}
+
+	this.Success()
+}
@@ -1,6 +1,7 @@
 package ssl
 
 import (
+	"crypto/tls"
 	"github.com/TeaWeb/code/teaconfigs"
 	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
 	"github.com/iwind/TeaGo/Tea"
@@ -24,16 +25,44 @@ func (this *UpdateAction) Run(params struct {
 	this.Data["filename"] = params.Server
 	this.Data["proxy"] = proxy
 
+	cipherSuites := []string{}
+	for _, suite := range tls.CipherSuites() {
+		cipherSuites = append(cipherSuites, suite.Name)
+	}
+	this.Data["tlsVersions"] = teaconfigs.AllTLSVersions()
+	this.Data["cipherSuites"] = cipherSuites
+	this.Data["clientAuthTypes"] = teaconfigs.AllSSLClientAuthTypes()
+
 	this.Show()
 }
 
 // 提交保存
 func (this *UpdateAction) RunPost(params struct {
-	Server   string
-	HttpsOn  bool
-	Listen   []string
-	CertFile *actions.File
-	KeyFile  *actions.File
+	Server    string
+	HttpsOn   bool
+	Listen    []string
+	IsDefault bool
+	CertFile  *actions.File
+	KeyFile   *actions.File
+
+	AcmeOn           bool
+	AcmeEmail        string
+	AcmeDirectoryURL string
+	AcmeRenewBefore  int
+
+	MinVersion   string
+	MaxVersion   string
+	CipherSuites []string
+	RedirectHTTP bool
+	OcspStapling bool
+
+	HstsOn                bool
+	HstsMaxAge            int
+	HstsIncludeSubDomains bool
+	HstsPreload           bool
+
+	ClientAuthType string
+	ClientCAFile   *actions.File
 }) {
 	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
 	if err != nil {
@@ -45,6 +74,27 @@ func (this *UpdateAction) RunPost(params struct {
 	}
 	server.SSL.On = params.HttpsOn
 	server.SSL.Listen = params.Listen
+	server.SSL.Default = params.IsDefault
+
+	// 自动申请证书
+	if params.AcmeOn {
+		if server.SSL.ACME == nil {
+			server.SSL.ACME = teaconfigs.NewACMEConfig()
+		}
+		server.SSL.ACME.On = true
+		server.SSL.ACME.Email = params.AcmeEmail
+		server.SSL.ACME.DirectoryURL = params.AcmeDirectoryURL
+		server.SSL.ACME.RenewBefore = params.AcmeRenewBefore
+		err = server.SSL.ACME.Validate()
+		if err != nil {
+			this.Fail("自动申请证书配置校验失败：" + err.Error())
+		}
+		if len(server.CertificateNames()) == 0 {
+			this.Fail("自动申请证书需要先设置域名")
+		}
+	} else if server.SSL.ACME != nil {
+		server.SSL.ACME.On = false
+	}
 
 	if params.CertFile != nil {
 		data, err := params.CertFile.Read()
@@ -78,6 +128,49 @@ func (this *UpdateAction) RunPost(params struct {
 		server.SSL.CertificateKey = keyFilename
 	}
 
+	// TLS设置
+	server.SSL.MinVersion = params.MinVersion
+	server.SSL.MaxVersion = params.MaxVersion
+	server.SSL.CipherSuites = params.CipherSuites
+	server.SSL.RedirectHTTP = params.RedirectHTTP
+	server.SSL.OCSPStapling = params.OcspStapling
+
+	if params.HstsOn {
+		server.SSL.HSTS = &teaconfigs.HSTSConfig{
+			On:                true,
+			MaxAge:            params.HstsMaxAge,
+			IncludeSubDomains: params.HstsIncludeSubDomains,
+			Preload:           params.HstsPreload,
+		}
+	} else if server.SSL.HSTS != nil {
+		server.SSL.HSTS.On = false
+	}
+
+	// 客户端证书
+	server.SSL.ClientAuthType = params.ClientAuthType
+	if params.ClientCAFile != nil {
+		data, err := params.ClientCAFile.Read()
+		if err != nil {
+			this.Fail(err.Error())
+		}
+
+		caFilename := "ssl." + stringutil.Rand(16) + params.ClientCAFile.Ext
+		configFile := files.NewFile(Tea.ConfigFile(caFilename))
+		err = configFile.Write(data)
+		if err != nil {
+			this.Fail(err.Error())
+		}
+
+		server.SSL.ClientCACertificates = caFilename
+	}
+
+	if server.SSL.On && (len(server.SSL.Certificate) > 0 || server.SSL.IsACME()) {
+		err = server.Validate()
+		if err != nil {
+			this.Fail("校验失败：" + err.Error())
+		}
+	}
+
 	err = server.Save()
 	if err != nil {
 		this.Fail("保存失败：" + err.Error())
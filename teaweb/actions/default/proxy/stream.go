@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"github.com/TeaWeb/code/teaconfigs"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
+	"github.com/iwind/TeaGo/actions"
+	"strings"
+)
+
+type StreamAction actions.Action
+
+// TCP/UDP代理设置
+func (this *StreamAction) Run(params struct {
+	Server string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+	this.Data["proxy"] = server
+	this.Data["filename"] = server.Filename
+	this.Data["selectedTab"] = "stream"
+
+	stream := server.Stream
+	if stream == nil {
+		stream = teaconfigs.NewStreamConfig()
+		stream.On = false
+	}
+	if stream.Listen == nil {
+		stream.Listen = []string{}
+	}
+	this.Data["stream"] = stream
+	this.Data["protocols"] = teaconfigs.AllStreamProtocols()
+	this.Data["tlsModes"] = teaconfigs.AllStreamTLSModes()
+
+	this.Show()
+}
+
+// 保存提交
+func (this *StreamAction) RunPost(params struct {
+	Server      string
+	On          bool
+	Protocol    string
+	Listen      []string
+	TLS         string
+	IdleTimeout string
+}) {
+	server, err := teaconfigs.NewServerConfigFromFile(params.Server)
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	listen := []string{}
+	for _, address := range params.Listen {
+		address = strings.TrimSpace(address)
+		if len(address) == 0 {
+			continue
+		}
+		listen = append(listen, address)
+	}
+	if params.On && len(listen) == 0 {
+		this.Fail("请输入监听地址")
+	}
+
+	stream := teaconfigs.NewStreamConfig()
+	stream.On = params.On
+	stream.Protocol = params.Protocol
+	stream.Listen = listen
+	stream.TLS = params.TLS
+	stream.IdleTimeout = strings.TrimSpace(params.IdleTimeout)
+	if stream.Protocol == teaconfigs.StreamProtocolUDP {
+		stream.TLS = teaconfigs.StreamTLSModeNone
+	}
+	server.Stream = stream
+
+	err = server.Validate()
+	if err != nil {
+		this.Fail("校验失败：" + err.Error())
+	}
+
+	err = server.Save()
+	if err != nil {
+		this.Fail("保存失败：" + err.Error())
+	}
+
+	proxyutils.NotifyChange()
+
+	this.Success()
+}
@@ -30,6 +30,7 @@ func (this *UpdateAction) Run(params struct {
 // 保存提交
 func (this *UpdateAction) RunPost(params struct {
 	HttpOn      bool
+	H2COn       bool
 	Server      string
 	Description string
 	Name        []string
@@ -49,6 +50,7 @@ func (this *UpdateAction) RunPost(params struct {
 		Require("代理服务名称不能为空")
 
 	server.Http = params.HttpOn
+	server.H2C = params.H2COn
 	server.Description = params.Description
 	server.Name = params.Name
 	server.Listen = params.Listen
@@ -9,9 +9,11 @@ import (
 type DataAction actions.Action
 
 func (this *DataAction) Run(params struct {
-	Type     string `default:"pv"`    // 数据类型：uv|pv\req
-	Range    string `default:"daily"` // 时间范围，hourly|daily|monthly
-	ServerId string
+	Type      string `default:"pv"`    // 数据类型：uv|pv|req|cache|traffic|health
+	Range     string `default:"daily"` // 时间范围，hourly|daily|monthly
+	ServerId  string
+	Policy    string // 缓存策略文件名，只对cache有效
+	BackendId string // 后端服务器ID，只对health有效
 }) {
 
 	title := ""
@@ -78,6 +80,36 @@ func (this *DataAction) Run(params struct {
 				data = append(data, types.Int64(stat["total"]))
 			}
 		}
+	} else if params.Type == "cache" { // 缓存命中率，单位为百分比
+		if params.Range == "hourly" {
+			title = "24小时缓存命中率"
+			for _, stat := range new(teastats.HourlyCacheStat).ListLatestHours(params.ServerId, params.Policy, 24) {
+				labels = append(labels, types.String(stat["hour"])[8:])
+				data = append(data, types.Int64(stat["rate"]))
+			}
+		} else if params.Range == "daily" {
+			title = "14日缓存命中率"
+			for _, stat := range new(teastats.DailyCacheStat).ListLatestDays(params.ServerId, params.Policy, 14) {
+				labels = append(labels, types.String(stat["day"])[4:])
+				data = append(data, types.Int64(stat["rate"]))
+			}
+		}
+	} else if params.Type == "traffic" { // 发送和接收的字节数之和
+		if params.Range == "hourly" {
+			title = "24小时流量统计"
+			for _, stat := range new(teastats.HourlyTrafficStat).ListLatestHours(params.ServerId, 24) {
+				labels = append(labels, types.String(stat["hour"])[8:])
+				data = append(data, types.Int64(stat["bytesSent"])+types.Int64(stat["bytesReceived"]))
+			}
+		}
+	} else if params.Type == "health" { // 后端服务器健康检查成功率，单位为百分比
+		if params.Range == "hourly" {
+			title = "24小时健康检查成功率"
+			for _, stat := range teastats.SharedBackendHealthStat().ListLatestHours(params.ServerId, params.BackendId, 24) {
+				labels = append(labels, types.String(stat["hour"])[8:])
+				data = append(data, types.Int64(stat["rate"]))
+			}
+		}
 	}
 
 	this.Data["title"] = title
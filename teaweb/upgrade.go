@@ -0,0 +1,35 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package teaweb
+
+import (
+	"github.com/TeaWeb/code/teaproxy"
+	"github.com/iwind/TeaGo/logs"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 升级使用的信号
+func upgradeSignal() os.Signal {
+	return syscall.SIGUSR2
+}
+
+// 收到升级信号时启动新的可执行文件，新进程接管监听后，当前进程处理完已有的连接后退出
+func listenUpgradeSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	for range signals {
+		logs.Println("upgrade to new process")
+		err := teaproxy.Upgrade()
+		if err != nil {
+			logs.Error(err)
+			continue
+		}
+
+		teaproxy.Shutdown()
+		logs.Println("old process exited, pid:", os.Getpid())
+		os.Exit(0)
+	}
+}
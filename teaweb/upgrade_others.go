@@ -0,0 +1,15 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package teaweb
+
+import "os"
+
+// 升级使用的信号，当前系统不支持
+func upgradeSignal() os.Signal {
+	return nil
+}
+
+// 当前系统不支持升级
+func listenUpgradeSignal() {
+}
@@ -19,8 +19,12 @@ import (
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/board"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/fastcgi"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/headers"
+	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/limits"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/locations"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/locations/websocket"
+	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/notify"
+	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/pages"
+	"github.com/TeaWeb/code/teaweb/actions/default/proxy/proxyutils"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/rewrite"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/proxy/ssl"
 	_ "github.com/TeaWeb/code/teaweb/actions/default/settings"
@@ -40,6 +44,7 @@ import (
 	"github.com/iwind/TeaGo/types"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"strings"
 	"syscall"
@@ -65,6 +70,12 @@ func Start() {
 		teaproxy.Start()
 	}()
 
+	// 收到SIGHUP信号时重新加载代理配置
+	go listenReloadSignal()
+
+	// 收到升级信号时把监听传递给新的进程
+	go listenUpgradeSignal()
+
 	// 启动测试服务器
 	if Tea.IsTesting() {
 		go func() {
@@ -74,6 +85,9 @@ func Start() {
 		}()
 	}
 
+	// 升级后的新进程需要等待旧进程退出后才能使用管理界面的端口
+	teaproxy.WaitUpgradeParent()
+
 	// 启动管理界面
 	TeaGo.NewServer().
 		AccessLog(false).
@@ -91,6 +105,21 @@ func Start() {
 		Start()
 }
 
+// 监听重新加载配置的信号
+func listenReloadSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		logs.Println("reload proxy configs")
+		err := teaproxy.Reload()
+		if err != nil {
+			logs.Error(err)
+			continue
+		}
+		proxyutils.FinishChange()
+	}
+}
+
 // 检查命令行参数
 func lookupArgs() bool {
 	if len(os.Args) == 1 {
@@ -107,6 +136,8 @@ func lookupArgs() bool {
 		fmt.Println("  start", "\n     start the server")
 		fmt.Println("  stop", "\n     stop the server")
 		fmt.Println("  restart", "\n     restart the server")
+		fmt.Println("  reload", "\n     reload proxy configs without closing connections")
+		fmt.Println("  upgrade", "\n     start the new binary and pass listeners to it without downtime")
 		return true
 	} else if lists.Contains(args, "-v") {
 		fmt.Println("TeaWeb v"+teaconst.TeaVersion, "(build: "+runtime.Version(), runtime.GOOS, runtime.GOARCH+")")
@@ -162,6 +193,41 @@ func lookupArgs() bool {
 		}
 		fmt.Println("[teaweb]restarted ok, pid:", cmd.Process.Pid)
 
+		return true
+	} else if lists.Contains(args, "reload") {
+		proc := checkPid()
+		if proc == nil {
+			fmt.Println("[teaweb]not started")
+			return true
+		}
+
+		err := proc.Signal(syscall.SIGHUP)
+		if err != nil {
+			fmt.Println("[teaweb]reload error:", err.Error())
+			return true
+		}
+		fmt.Println("[teaweb]reload signal sent, pid:", proc.Pid)
+
+		return true
+	} else if lists.Contains(args, "upgrade") {
+		proc := checkPid()
+		if proc == nil {
+			fmt.Println("[teaweb]not started")
+			return true
+		}
+
+		sig := upgradeSignal()
+		if sig == nil {
+			fmt.Println("[teaweb]upgrade is not supported on " + runtime.GOOS)
+			return true
+		}
+		err := proc.Signal(sig)
+		if err != nil {
+			fmt.Println("[teaweb]upgrade error:", err.Error())
+			return true
+		}
+		fmt.Println("[teaweb]upgrade signal sent, pid:", proc.Pid)
+
 		return true
 	}
 